@@ -0,0 +1,239 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+)
+
+const gzipExt = ".gz"
+const zstdExt = ".zst"
+
+// zstd is not in the standard library, so the zstd command is used instead.
+var zstdCommand = "zstd"
+
+// Returns the file extension of an archive format, or an error if the format
+// is unknown or does not compress.
+func Extension(format core.ArchiveFormat) (string, error) {
+	switch format {
+	case core.ArchiveFormatGzip:
+		return gzipExt, nil
+	case core.ArchiveFormatZstd:
+		return zstdExt, nil
+	}
+	return "", errors.New("unknown archive format '" + string(format) + "'")
+}
+
+// Returns whether a log file has been archived, ie. is compressed.
+func IsArchived(logFile string) bool {
+	return strings.HasSuffix(logFile, gzipExt) || strings.HasSuffix(logFile, zstdExt)
+}
+
+// Returns the name of a log file before it was archived.
+func UncompressedName(logFile string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(logFile, gzipExt), zstdExt)
+}
+
+// Compresses a log file into dir, returning the path of the archived log.
+// A blank dir archives it alongside the log. The archive is written to a
+// temporary file first, so a partially written archive is never left behind.
+// The original log is not removed.
+func Compress(logFile string, dir string, format core.ArchiveFormat) (string, error) {
+	ext, err := Extension(format)
+	if err != nil {
+		return "", err
+	}
+	if dir == "" {
+		dir = filepath.Dir(logFile)
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	src, err := os.Open(logFile)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	archiveFile := filepath.Join(dir, filepath.Base(logFile)+ext)
+	tmp, err := os.CreateTemp(dir, filepath.Base(archiveFile)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if format == core.ArchiveFormatGzip {
+		err = compressGzip(tmp, src)
+	} else {
+		err = compressZstd(tmp, src)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), archiveFile); err != nil {
+		return "", err
+	}
+	return archiveFile, nil
+}
+
+func compressGzip(dst io.Writer, src io.Reader) error {
+	w := gzip.NewWriter(dst)
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func compressZstd(dst io.Writer, src io.Reader) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(zstdCommand, "-q", "-c")
+	cmd.Stdin = src
+	cmd.Stdout = dst
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return zstdError(err, stderr)
+	}
+	return nil
+}
+
+func zstdError(err error, stderr bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return errors.New("zstd: " + msg)
+	}
+	return errors.New("zstd: " + err.Error())
+}
+
+// Opens a run log for reading. Archived logs are decompressed as they are
+// read, so callers don't need to know whether the log has been archived.
+func Open(logFile string) (io.ReadCloser, error) {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(logFile, gzipExt):
+		r, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &gzipReader{Reader: r, f: f}, nil
+	case strings.HasSuffix(logFile, zstdExt):
+		return newZstdReader(f)
+	}
+	return f, nil
+}
+
+// Reads the whole of a run log, decompressing it if it has been archived.
+func ReadFile(logFile string) ([]byte, error) {
+	r, err := Open(logFile)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+type gzipReader struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (r *gzipReader) Close() error {
+	return errors.Join(r.Reader.Close(), r.f.Close())
+}
+
+type zstdReader struct {
+	io.Reader
+	cmd    *exec.Cmd
+	f      *os.File
+	stderr *bytes.Buffer
+}
+
+func newZstdReader(f *os.File) (io.ReadCloser, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(zstdCommand, "-q", "-d", "-c")
+	cmd.Stdin = f
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		f.Close()
+		return nil, zstdError(err, stderr)
+	}
+	return &zstdReader{Reader: stdout, cmd: cmd, f: f, stderr: &stderr}, nil
+}
+
+// Returns an error if zstd failed to decompress the whole log. Closing the
+// reader before the log has been read stops zstd, which is not an error.
+func (r *zstdReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF && r.cmd != nil {
+		if waitErr := r.cmd.Wait(); waitErr != nil {
+			return n, zstdError(waitErr, *r.stderr)
+		}
+		r.cmd = nil
+	}
+	return n, err
+}
+
+func (r *zstdReader) Close() error {
+	if r.cmd != nil {
+		r.cmd.Process.Kill()
+		r.cmd.Wait()
+		r.cmd = nil
+	}
+	return r.f.Close()
+}
+
+// Compresses the log of a finished run, then points the run and its attempts
+// at the archived log. The original log is only removed once the run has been
+// updated, so the run always refers to a log that exists.
+func Run(
+	ctx context.Context,
+	db *data.Queries,
+	conf config.ArchiveConfig,
+	runId int64,
+	logFile string,
+	format core.ArchiveFormat,
+) (string, error) {
+	archiveFile, err := Compress(logFile, conf.Dir, format)
+	if err != nil {
+		return "", err
+	}
+	err = db.UpdateRunLogFile(ctx, data.UpdateRunLogFileParams{
+		ID:      runId,
+		LogFile: archiveFile,
+	})
+	if err != nil {
+		return "", errors.Join(err, os.Remove(archiveFile))
+	}
+	if err := os.Remove(logFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return archiveFile, err
+	}
+	return archiveFile, nil
+}
+
+// Returns an error if the archive format is unknown.
+func ValidateFormat(format core.ArchiveFormat) error {
+	if format == core.ArchiveFormatNone {
+		return nil
+	}
+	_, err := Extension(format)
+	return err
+}
@@ -0,0 +1,155 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func createLog(t *testing.T, content string) string {
+	logFile := path.Join(t.TempDir(), "job.1234.log")
+	if err := os.WriteFile(logFile, []byte(content), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	return logFile
+}
+
+func Test_Compress(t *testing.T) {
+	content := strings.Repeat("line of output\n", 1000)
+	data := []struct {
+		format core.ArchiveFormat
+		ext    string
+	}{
+		{core.ArchiveFormatGzip, ".gz"},
+		{core.ArchiveFormatZstd, ".zst"},
+	}
+
+	for _, d := range data {
+		t.Run(string(d.format), func(t *testing.T) {
+			if d.format == core.ArchiveFormatZstd {
+				if _, err := exec.LookPath(zstdCommand); err != nil {
+					t.Skip("zstd command not found")
+				}
+			}
+			logFile := createLog(t, content)
+			dir := path.Join(t.TempDir(), "archive")
+
+			archiveFile, err := Compress(logFile, dir, d.format)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			assert.Equal(t, path.Join(dir, "job.1234.log"+d.ext), archiveFile)
+			assert.True(t, IsArchived(archiveFile))
+			assert.Equal(t, path.Join(dir, "job.1234.log"), UncompressedName(archiveFile))
+			test.AssertFileExists(t, logFile)
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			assert.Equal(t, 1, len(entries))
+			info, err := os.Stat(archiveFile)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			assert.Less(t, info.Size(), int64(len(content)))
+			read, err := ReadFile(archiveFile)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			assert.Equal(t, content, string(read))
+		})
+	}
+}
+
+func Test_CompressUnknownFormat(t *testing.T) {
+	logFile := createLog(t, "output\n")
+
+	_, err := Compress(logFile, "", core.ArchiveFormat("lz4"))
+
+	assert.EqualError(t, err, "unknown archive format 'lz4'")
+	assert.Error(t, ValidateFormat(core.ArchiveFormat("lz4")))
+	assert.NoError(t, ValidateFormat(core.ArchiveFormatNone))
+}
+
+func Test_OpenUncompressed(t *testing.T) {
+	logFile := createLog(t, "output\n")
+
+	r, err := Open(logFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer r.Close()
+	read, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	assert.False(t, IsArchived(logFile))
+	assert.Equal(t, "output\n", string(read))
+}
+
+func Test_Run(t *testing.T) {
+	ctx := context.Background()
+	db := test.CreateDb(ctx, t)
+	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  test.UniqueIdentifer(),
+		RetryAttempts:         2,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	logFile := createLog(t, "output\n")
+	runId, err := db.StartRun(ctx, data.StartRunParams{
+		JobID:   jobId,
+		LogFile: logFile,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	attemptId, err := db.StartAttempt(ctx, data.StartAttemptParams{
+		JobID:       jobId,
+		LogFile:     logFile,
+		ParentRunID: sql.NullInt64{Int64: runId, Valid: true},
+		Attempt:     1,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	conf := config.ArchiveConfig{Dir: t.TempDir()}
+
+	archiveFile, err := Run(ctx, db, conf, runId, logFile, core.ArchiveFormatGzip)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	_, err = os.Stat(logFile)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	test.AssertFileInDirectory(t, conf.Dir, archiveFile)
+	for _, id := range []int64{runId, attemptId} {
+		run, err := db.GetRun(ctx, id)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		assert.Equal(t, archiveFile, run.Run.LogFile)
+	}
+	read, err := ReadFile(archiveFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, "output\n", string(read))
+}
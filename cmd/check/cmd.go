@@ -0,0 +1,193 @@
+/*
+Copyright © 2025 Samuel Carswell <samuelrcarswell@gmail.com>
+*/
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/robfig/cron/v3"
+	"github.com/samcarswell/trochilus/cmd"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/notify"
+	"github.com/samcarswell/trochilus/opts"
+	"github.com/spf13/cobra"
+)
+
+var notifyOpt = "notify"
+var format string
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Checks scheduled jobs for late or missed runs",
+	Long: `Checks scheduled jobs for late or missed runs.
+
+A job is late if its next scheduled run after its last run has not started,
+and missed once the job's grace period has also passed. Exits with status 1
+if any job has missed a run.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return opts.FormatTableOptValidate(cmd, format)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := slog.Default()
+		notifyOpt := opts.GetBoolOptOrExit(cmd, notifyOpt)
+		conf := config.GetConfig()
+		queries := config.GetDatabase(cmd.Context())
+		if notifyOpt {
+			if err := notify.ValidateConfig(conf, nil); err != nil {
+				core.LogErrorAndExit(logger, err)
+			}
+		}
+
+		jobRows, err := queries.GetScheduledJobs(cmd.Context())
+		if err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to get scheduled jobs"))
+		}
+
+		now := time.Now()
+		var rows = []core.JobCheck{}
+		var notifyChecks []notify.JobCheckNotifyInfo
+		missed := false
+		for _, jobRow := range jobRows {
+			var lastRun *data.Run
+			lastRunRow, err := queries.GetLastRun(cmd.Context(), jobRow.Job.ID)
+			if err == nil {
+				lastRun = &lastRunRow.Run
+			} else if err != sql.ErrNoRows {
+				core.LogErrorAndExit(logger, err, errors.New("unable to get last run for job "+jobRow.Job.Name))
+			}
+
+			status, expectedAt, err := checkJob(jobRow.Job, lastRun, conf.Check.Grace, now)
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("invalid schedule for job "+jobRow.Job.Name))
+			}
+			switch status {
+			case core.JobCheckStatusLate:
+				core.LogJobLate(logger, jobRow.Job.Name, expectedAt)
+			case core.JobCheckStatusMissed:
+				core.LogJobMissed(logger, jobRow.Job.Name, expectedAt)
+				missed = true
+			}
+			if status != core.JobCheckStatusOk {
+				notifyChecks = append(notifyChecks, notify.JobCheckNotifyInfo{
+					Name:       jobRow.Job.Name,
+					Status:     status,
+					ExpectedAt: expectedAt,
+				})
+			}
+			rows = append(rows, newJobCheck(jobRow.Job, lastRun, status, expectedAt, conf))
+		}
+
+		t := core.NewTable(rows, rowConv(conf), []string{
+			"Job Name", "Schedule", "Grace", "Last Run ID", "Last Run", "Expected At", "Status",
+		})
+		t.Print(core.OutputFormat(format))
+
+		if notifyOpt && len(notifyChecks) > 0 {
+			logger.Info("Sending notify message")
+			err := notify.NotifyJobChecks(logger, conf, notifyChecks)
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to notify"))
+			}
+		}
+		if missed {
+			os.Exit(1)
+		}
+	},
+}
+
+// Compares a job's schedule against its last run. The next expected run is
+// the first scheduled time after the last run started, or after the schedule
+// was set if the job has not run since.
+func checkJob(
+	job data.Job,
+	lastRun *data.Run,
+	defaultGrace time.Duration,
+	now time.Time,
+) (core.JobCheckStatus, time.Time, error) {
+	schedule, err := cron.ParseStandard(job.Schedule.String)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	anchor := job.ScheduleUpdatedAt.Time
+	if lastRun != nil && lastRun.StartTime.After(anchor) {
+		anchor = lastRun.StartTime
+	}
+	if anchor.IsZero() {
+		anchor = now
+	}
+	// Schedules are evaluated in the local timezone, as cron does
+	expectedAt := schedule.Next(anchor.In(time.Local))
+
+	grace := defaultGrace
+	if job.ScheduleGraceSeconds.Valid {
+		grace = time.Duration(job.ScheduleGraceSeconds.Int64) * time.Second
+	}
+
+	switch {
+	case now.Before(expectedAt):
+		return core.JobCheckStatusOk, expectedAt, nil
+	case now.Before(expectedAt.Add(grace)):
+		return core.JobCheckStatusLate, expectedAt, nil
+	}
+	return core.JobCheckStatusMissed, expectedAt, nil
+}
+
+func newJobCheck(
+	job data.Job,
+	lastRun *data.Run,
+	status core.JobCheckStatus,
+	expectedAt time.Time,
+	conf config.Config,
+) core.JobCheck {
+	grace := conf.Check.Grace.String()
+	if job.ScheduleGraceSeconds.Valid {
+		grace = core.FormatTimeout(job.ScheduleGraceSeconds)
+	}
+	check := core.JobCheck{
+		JobName:    job.Name,
+		Schedule:   job.Schedule.String,
+		Grace:      grace,
+		ExpectedAt: core.FormatTime(expectedAt, conf.LocalTime),
+		Status:     string(status),
+	}
+	if lastRun != nil {
+		check.LastRunID = core.FormatInt(sql.NullInt64{Int64: lastRun.ID, Valid: true})
+		check.LastRun = core.FormatTime(lastRun.StartTime, conf.LocalTime)
+	}
+	return check
+}
+
+func rowConv(conf config.Config) func(core.JobCheck, core.OutputFormat) table.Row {
+	return func(row core.JobCheck, format core.OutputFormat) table.Row {
+		var status string
+		if format == core.FormatPretty {
+			status = core.FormatCheckStatus(core.JobCheckStatus(row.Status), conf.Display.Emoji)
+		} else {
+			status = row.Status
+		}
+		return table.Row{
+			row.JobName,
+			row.Schedule,
+			row.Grace,
+			row.LastRunID,
+			row.LastRun,
+			row.ExpectedAt,
+			status,
+		}
+	}
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(checkCmd)
+
+	checkCmd.Flags().Bool(notifyOpt, false, "Notifies if any job is late or has missed a run")
+	opts.FormatTableOpt(checkCmd, &format)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_checkJob(t *testing.T) {
+	updatedAt := time.Date(2025, 1, 1, 9, 58, 0, 0, time.Local)
+	job := data.Job{
+		Name:              "every-five",
+		Schedule:          sql.NullString{String: "*/5 * * * *", Valid: true},
+		ScheduleUpdatedAt: sql.NullTime{Time: updatedAt, Valid: true},
+	}
+	withGrace := job
+	withGrace.ScheduleGraceSeconds = sql.NullInt64{Int64: 600, Valid: true}
+	lastRun := &data.Run{StartTime: time.Date(2025, 1, 1, 10, 5, 1, 0, time.Local)}
+
+	checks := []struct {
+		name       string
+		job        data.Job
+		lastRun    *data.Run
+		now        time.Time
+		status     core.JobCheckStatus
+		expectedAt time.Time
+	}{
+		{"not-yet-due", job, nil,
+			time.Date(2025, 1, 1, 9, 59, 0, 0, time.Local),
+			core.JobCheckStatusOk, time.Date(2025, 1, 1, 10, 0, 0, 0, time.Local)},
+		{"late", job, nil,
+			time.Date(2025, 1, 1, 10, 0, 30, 0, time.Local),
+			core.JobCheckStatusLate, time.Date(2025, 1, 1, 10, 0, 0, 0, time.Local)},
+		{"missed", job, nil,
+			time.Date(2025, 1, 1, 10, 1, 0, 0, time.Local),
+			core.JobCheckStatusMissed, time.Date(2025, 1, 1, 10, 0, 0, 0, time.Local)},
+		{"job-grace", withGrace, nil,
+			time.Date(2025, 1, 1, 10, 9, 0, 0, time.Local),
+			core.JobCheckStatusLate, time.Date(2025, 1, 1, 10, 0, 0, 0, time.Local)},
+		{"ran", job, lastRun,
+			time.Date(2025, 1, 1, 10, 7, 0, 0, time.Local),
+			core.JobCheckStatusOk, time.Date(2025, 1, 1, 10, 10, 0, 0, time.Local)},
+		{"missed-after-run", job, lastRun,
+			time.Date(2025, 1, 1, 10, 30, 0, 0, time.Local),
+			core.JobCheckStatusMissed, time.Date(2025, 1, 1, 10, 10, 0, 0, time.Local)},
+		{"utc-run", job, &data.Run{StartTime: lastRun.StartTime.UTC()},
+			time.Date(2025, 1, 1, 10, 7, 0, 0, time.Local),
+			core.JobCheckStatusOk, time.Date(2025, 1, 1, 10, 10, 0, 0, time.Local)},
+	}
+
+	for _, d := range checks {
+		t.Run(d.name, func(t *testing.T) {
+			status, expectedAt, err := checkJob(d.job, d.lastRun, time.Minute, d.now)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			assert.Equal(t, d.status, status)
+			assert.True(t, d.expectedAt.Equal(expectedAt), "expected %s, got %s", d.expectedAt, expectedAt)
+		})
+	}
+}
+
+func Test_checkJobInvalidSchedule(t *testing.T) {
+	job := data.Job{
+		Name:     "invalid",
+		Schedule: sql.NullString{String: "not a schedule", Valid: true},
+	}
+	_, _, err := checkJob(job, nil, time.Minute, time.Now())
+	assert.Error(t, err)
+}
@@ -6,7 +6,9 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -102,3 +104,301 @@ func Test_Kill(t *testing.T) {
 	run := test.CmdConv[[]core.RunShow](runCmd)[0]
 	assert.Equal(t, string(core.RunStatusTerminated), run.Status)
 }
+
+// Reads the PID a job writes to a file once it has started.
+func waitForPidFile(t *testing.T, pidFile string) int {
+	var pid int
+	assert.Eventually(t, func() bool {
+		contents, err := os.ReadFile(pidFile)
+		if err != nil || !strings.HasSuffix(string(contents), "\n") {
+			return false
+		}
+		pid, err = strconv.Atoi(strings.TrimSpace(string(contents)))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	return pid
+}
+
+// Waits for the event to be logged to a system log file.
+func waitForEvent(t *testing.T, event core.Event, logFile string) {
+	assert.Eventually(t, func() bool {
+		file, err := os.Open(logFile)
+		if err != nil {
+			return false
+		}
+		defer file.Close()
+		log, err := test.NewLogFromReader(file)
+		if err != nil {
+			return false
+		}
+		for _, row := range log.Rows {
+			if row.Event == string(event) {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_KillProcessGroup(t *testing.T) {
+	cli := test.NewTrocCli(t, trocExe)
+	pidFile := path.Join(t.TempDir(), "sleep.pid")
+	execCmd := cli.Base.Exec("first-job", "sleep 60 & echo $! > "+pidFile+"; wait")
+	execCmd.Start()
+	sleepPid := waitForPidFile(t, pidFile)
+
+	killCmd := cli.Base.Run.Kill(1)
+	killCmd.Run()
+	_ = execCmd.Cmd.Wait()
+
+	test.GetEventOrFail(t, core.EventRunSigterm, killCmd.ExecLogOrFail())
+	assert.False(t, test.IsProcessRunning(t, sleepPid))
+	listCmd := cli.Base.Run.List()
+	listCmd.Run()
+	assert.Equal(t, string(core.RunStatusTerminated), test.CmdConv[[]core.RunShow](listCmd)[0].Status)
+}
+
+func Test_KillGrace(t *testing.T) {
+	cli := test.NewTrocCli(t, trocExe)
+	pidFile := path.Join(t.TempDir(), "sleep.pid")
+	execCmd := cli.Base.Exec("first-job", "trap '' TERM; sleep 60 & echo $! > "+pidFile+"; wait")
+	execCmd.Start()
+	sleepPid := waitForPidFile(t, pidFile)
+
+	killCmd := cli.Base.Run.Kill(1, "--signal", "term", "--grace", "500ms")
+	killCmd.Run()
+	_ = execCmd.Cmd.Wait()
+
+	killLog := killCmd.ExecLogOrFail()
+	test.GetEventOrFail(t, core.EventRunSigterm, killLog)
+	test.GetEventOrFail(t, core.EventRunSigkill, killLog)
+	assert.False(t, test.IsProcessRunning(t, sleepPid))
+	listCmd := cli.Base.Run.List()
+	listCmd.Run()
+	run := test.CmdConv[[]core.RunShow](listCmd)[0]
+	assert.Equal(t, string(core.RunStatusTerminated), run.Status)
+	assert.Equal(t, "SIGKILL", run.Signal)
+}
+
+func Test_ExecReplace(t *testing.T) {
+	cli := test.NewTrocCli(t, trocExe)
+	pidFile := path.Join(t.TempDir(), "sleep.pid")
+	replacedCmd := cli.Base.Exec("first-job", "sleep 60 & echo $! > "+pidFile+"; wait")
+	replacedCmd.Start()
+	sleepPid := waitForPidFile(t, pidFile)
+
+	execCmd := cli.Base.Exec("first-job", "echo 'Hello!'", "--concurrency", "replace")
+	execCmd.Run()
+	_ = replacedCmd.Cmd.Wait()
+
+	// troc exec is sent SIGTERM, which it forwards to the run
+	runReplaced := test.GetEventOrFail(t, core.EventRunReplaced, execCmd.ExecLogOrFail())
+	assert.Equal(t, int64(1), runReplaced.RunId)
+	assert.Equal(t, replacedCmd.Cmd.Process.Pid, runReplaced.RunPid)
+	assert.False(t, test.IsProcessRunning(t, sleepPid))
+	listCmd := cli.Base.Run.List()
+	listCmd.Run()
+	runs := test.CmdConv[[]core.RunShow](listCmd)
+	assert.Equal(t, string(core.RunStatusSucceeded), runs[0].Status)
+	assert.Equal(t, string(core.RunStatusTerminated), runs[1].Status)
+	assert.Equal(t, "SIGTERM", runs[1].Signal)
+}
+
+func Test_ExecReplaceGrace(t *testing.T) {
+	cli := test.NewTrocCli(t, trocExe)
+	t.Setenv("TROC_KILLGRACE", "500ms")
+	pidFile := path.Join(t.TempDir(), "sleep.pid")
+	replacedCmd := cli.Base.Exec("first-job", "trap '' TERM; sleep 60 & echo $! > "+pidFile+"; wait")
+	replacedCmd.Start()
+	sleepPid := waitForPidFile(t, pidFile)
+
+	execCmd := cli.Base.Exec("first-job", "echo 'Hello!'", "--concurrency", "replace")
+	execCmd.Run()
+	_ = replacedCmd.Cmd.Wait()
+
+	// troc exec can't forward SIGKILL, so it is sent to the run instead
+	sigkill := test.GetEventOrFail(t, core.EventRunSigkill, execCmd.ExecLogOrFail())
+	assert.NotEqual(t, replacedCmd.Cmd.Process.Pid, sigkill.RunPid)
+	assert.False(t, test.IsProcessRunning(t, sleepPid))
+	listCmd := cli.Base.Run.List()
+	listCmd.Run()
+	runs := test.CmdConv[[]core.RunShow](listCmd)
+	assert.Equal(t, string(core.RunStatusSucceeded), runs[0].Status)
+	assert.Equal(t, string(core.RunStatusTerminated), runs[1].Status)
+	assert.Equal(t, "SIGKILL", runs[1].Signal)
+}
+
+func Test_ExecReplaceWaitingToRetry(t *testing.T) {
+	cli := test.NewTrocCli(t, trocExe)
+	cli.Base.Job.Add("first-job", "--retry-attempts", "3", "--retry-delay", "60s").Run()
+	replacedCmd := cli.Base.Exec("first-job", "exit 1")
+	replacedCmd.Start()
+	assert.Eventually(t, func() bool {
+		listCmd := cli.Base.Run.List()
+		listCmd.Run()
+		return len(test.CmdConv[[]core.RunShow](listCmd)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	listCmd := cli.Base.Run.List()
+	listCmd.Run()
+	waitForEvent(t, core.EventRunRetry, test.CmdConv[[]core.RunShow](listCmd)[0].SystemLogFile)
+
+	execCmd := cli.Base.Exec("first-job", "echo 'Hello!'", "--concurrency", "replace")
+	execCmd.Run()
+	_ = replacedCmd.Cmd.Wait()
+
+	runReplaced := test.GetEventOrFail(t, core.EventRunReplaced, execCmd.ExecLogOrFail())
+	assert.Equal(t, int64(1), runReplaced.RunId)
+	assert.Equal(t, replacedCmd.Cmd.Process.Pid, runReplaced.RunPid)
+	listCmd = cli.Base.Run.List()
+	listCmd.Run()
+	runs := test.CmdConv[[]core.RunShow](listCmd)
+	assert.Equal(t, string(core.RunStatusSucceeded), runs[0].Status)
+	assert.Equal(t, string(core.RunStatusTerminated), runs[1].Status)
+	assert.Equal(t, int64(1), runs[1].Attempt)
+}
+
+func Test_RunReap(t *testing.T) {
+	cli := test.NewTrocCli(t, trocExe)
+	pidFile := path.Join(t.TempDir(), "sh.pid")
+	execCmd := cli.Base.Exec("first-job", "echo $$ > "+pidFile+"; sleep 60; true")
+	execCmd.Start()
+	shPid := waitForPidFile(t, pidFile)
+	reap := func() []core.LostRunShow {
+		reapCmd := cli.Base.Run.Reap()
+		reapCmd.Run()
+		return test.CmdConv[[]core.LostRunShow](reapCmd)
+	}
+
+	assert.Empty(t, reap())
+	// The script is left running when exec is killed
+	if err := execCmd.Cmd.Process.Kill(); err != nil {
+		t.Fatal(err.Error())
+	}
+	_ = execCmd.Cmd.Wait()
+	assert.Empty(t, reap())
+	if err := syscall.Kill(-shPid, syscall.SIGKILL); err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Eventually(t, func() bool {
+		return !test.IsProcessRunning(t, shPid)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	reapCmd := cli.Base.Run.Reap()
+	reapCmd.Run()
+
+	lost := test.CmdConv[[]core.LostRunShow](reapCmd)
+	assert.Len(t, lost, 1)
+	assert.Equal(t, int64(1), lost[0].ID)
+	assert.Equal(t, strconv.Itoa(shPid), lost[0].Pid)
+	test.GetEventOrFail(t, core.EventRunLost, reapCmd.ExecLogOrFail())
+	listCmd := cli.Base.Run.List()
+	listCmd.Run()
+	assert.Equal(t, string(core.RunStatusLost), test.CmdConv[[]core.RunShow](listCmd)[0].Status)
+	assert.Empty(t, reap())
+}
+
+func Test_JobRun(t *testing.T) {
+	cli := test.NewTrocCli(t, trocExe)
+	assert.Error(t, cli.Base.Job.Run("first-job").Cmd.Run())
+	cli.Base.Job.Add("first-job", "--command", "echo 'Hello!'").Run()
+
+	runCmd := cli.Base.Job.Run("first-job")
+	runCmd.Run()
+
+	run := test.CmdConv[core.RunShow](runCmd)
+	assert.Equal(t, "first-job", run.JobName)
+	assert.Equal(t, string(core.RunStatusSucceeded), run.Status)
+	assert.Equal(t, "echo 'Hello!'", run.Command)
+}
+
+func Test_RunListFilters(t *testing.T) {
+	cli := test.NewTrocCli(t, trocExe)
+	for _, name := range []string{"first-job", "second-job", "first-job"} {
+		cli.Base.Exec(name, "echo 'Hello!'").Run()
+	}
+	firstCmd := cli.Base.Run.List("--sort", "id:asc", "--limit", "1")
+	firstCmd.Run()
+	firstStart, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", test.CmdConv[[]core.RunShow](firstCmd)[0].StartTime)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data := []struct {
+		name     string
+		args     []string
+		expected []int64
+	}{
+		{"newest-first", []string{}, []int64{3, 2, 1}},
+		{"name", []string{"--name", "first-job"}, []int64{3, 1}},
+		{"status", []string{"--status", "succeeded"}, []int64{3, 2, 1}},
+		{"running", []string{"--running"}, []int64{}},
+		{"since", []string{"--since", "1h"}, []int64{3, 2, 1}},
+		{"until", []string{"--until", "1h"}, []int64{}},
+		// A run started at --since is listed, and one started at --until isn't
+		{"since-start", []string{"--since", firstStart.Format(time.RFC3339)}, []int64{3, 2, 1}},
+		{"until-start", []string{"--until", firstStart.Format(time.RFC3339)}, []int64{}},
+		{"sort", []string{"--sort", "id:asc"}, []int64{1, 2, 3}},
+		{"limit-offset", []string{"--limit", "1", "--offset", "1"}, []int64{2}},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			listCmd := cli.Base.Run.List(d.args...)
+			listCmd.Run()
+			ids := []int64{}
+			for _, run := range test.CmdConv[[]core.RunShow](listCmd) {
+				ids = append(ids, run.ID)
+			}
+			assert.Equal(t, d.expected, ids)
+		})
+	}
+}
+
+func Test_RemoteRunList(t *testing.T) {
+	cli := test.NewTrocCli(t, trocExe)
+	// Stands in for ssh by running the remote command with a local shell,
+	// which runs troc against the same database
+	ssh := path.Join(t.TempDir(), "ssh")
+	err := os.WriteFile(ssh, []byte("#!/bin/sh\nwhile [ \"$1\" != \"--\" ]; do shift; done\nexec sh -c \"$3\"\n"), 0700)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	config := "remotes:\n  - name: web1\n    host: deploy@web1\n    troc: " + trocExe + "\n    ssh: [" + ssh + "]\n"
+	err = os.WriteFile(path.Join(os.Getenv("TROC_CONFIG_PATH"), "config.yaml"), []byte(config), 0600)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, name := range []string{"first-job", "second job", "first-job"} {
+		cli.Base.Exec(name, "echo 'Hello!'").Run()
+	}
+
+	listCmd := cli.Base.Run.List("--remote", "web1", "--name", "second job")
+	listCmd.Run()
+
+	runs := test.CmdConv[[]core.RunShow](listCmd)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, int64(2), runs[0].ID)
+	assert.Equal(t, "second job", runs[0].JobName)
+}
+
+func Test_RemoteNoAutoReap(t *testing.T) {
+	cli := test.NewTrocCli(t, trocExe)
+	// Stands in for ssh with a remote that has no runs
+	ssh := path.Join(t.TempDir(), "ssh")
+	err := os.WriteFile(ssh, []byte("#!/bin/sh\necho '[]'\n"), 0700)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	config := "reap:\n  auto: true\nremotes:\n  - name: web1\n    host: deploy@web1\n    ssh: [" + ssh + "]\n"
+	err = os.WriteFile(path.Join(os.Getenv("TROC_CONFIG_PATH"), "config.yaml"), []byte(config), 0600)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	listCmd := cli.Base.Run.List("--remote", "web1")
+	listCmd.Run()
+
+	assert.Empty(t, test.CmdConv[[]core.RunShow](listCmd))
+	// The local database is only opened to reap its runs
+	assert.NoFileExists(t, os.Getenv("TROC_DATABASE"))
+}
@@ -12,48 +12,78 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
-	"path/filepath"
-	"strings"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
-	"github.com/gofrs/flock"
+	"github.com/samcarswell/trochilus/archive"
 	"github.com/samcarswell/trochilus/cmd"
 	"github.com/samcarswell/trochilus/config"
 	"github.com/samcarswell/trochilus/core"
 	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/metrics"
 	"github.com/samcarswell/trochilus/notify"
 	"github.com/samcarswell/trochilus/opts"
+	"github.com/samcarswell/trochilus/output"
+	"github.com/samcarswell/trochilus/retention"
 	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
 )
 
 var nameOpt = "name"
 var notifyOpt = "notify"
+var timeoutOpt = "timeout"
+var concurrencyOpt = "concurrency"
+var queueTimeoutOpt = "queue-timeout"
 
-// While *OrExit is useful for most commands, exec actually needs to
-// try it's best to recover: it should try to get least get a message to the slack channel notifying of a failure.
-// For now I'm just going to assume there's no errors. But this should
-// be revisited before a v1
+// Notifications of a run are added to the outbox in the same transaction that
+// ends the run, so one that can't be sent is kept rather than lost. As troc
+// doesn't run a daemon, it is only retried when the outbox is next flushed: by
+// a later exec, job run or run rerun, by notify flush, or by a run reap
+// --notify or auto-reap that marks runs as Lost.
 var execCmd = &cobra.Command{
-	Use:   "exec",
+	Use:   "exec [command]",
 	Short: "Run a job",
-	Args:  cobra.ExactArgs(1),
+	Long: `
+Run a job.
+The command is run with /bin/sh -c. If no command is given, the command of the job set with
+job [add|update] --command is run.
+`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		logger := slog.Default()
 		jobName := opts.GetStringOptOrExit(cmd, nameOpt)
 		notifyOpt := opts.GetBoolOptOrExit(cmd, notifyOpt)
 		conf := config.GetConfig()
-		queries := config.GetDatabase(cmd.Context())
-		if notifyOpt && conf.Notify.Slack.Token == "" {
-			core.LogErrorAndExit(logger, errors.New("notify is set but notify.slack.token is blank."))
-		}
-		if notifyOpt && conf.Notify.Slack.Channel == "" {
-			core.LogErrorAndExit(logger, errors.New("notify is set but notify.slack.channel is blank."))
+		conn := config.GetDatabaseConn(cmd.Context())
+		if notifyOpt {
+			if err := notify.ValidateConfig(conf, nil); err != nil {
+				core.LogErrorAndExit(logger, err)
+			}
 		}
 
 		logFile := config.GetLogFileOrExit(logger, cmd.Context())
 
 		if len(args) == 0 {
-			core.LogErrorAndExit(logger, errors.New("must provide args"))
+			args = []string{getJobCommandOrExit(cmd.Context(), logger, conn, jobName)}
+		}
+		var timeout *time.Duration
+		if cmd.Flags().Changed(timeoutOpt) {
+			timeoutVal := opts.GetDurationOptOrExit(cmd, timeoutOpt)
+			timeout = &timeoutVal
+		}
+		var concurrency *concurrencyOverride
+		if cmd.Flags().Changed(concurrencyOpt) || cmd.Flags().Changed(queueTimeoutOpt) {
+			concurrency = &concurrencyOverride{}
+			if cmd.Flags().Changed(concurrencyOpt) {
+				concurrency.Mode, concurrency.Limit = opts.GetConcurrencyOptOrExit(cmd, concurrencyOpt)
+			}
+			if cmd.Flags().Changed(queueTimeoutOpt) {
+				queueTimeout := opts.GetDurationOptOrExit(cmd, queueTimeoutOpt)
+				concurrency.QueueTimeout = &queueTimeout
+			}
 		}
 		completedRun := execRun(
 			cmd.Context(),
@@ -61,21 +91,14 @@ var execCmd = &cobra.Command{
 			jobName,
 			notifyOpt,
 			conf,
-			queries,
+			conn,
 			logFile,
 			args,
+			timeout,
+			concurrency,
+			0,
 		)
-		data := core.RunShow{
-			ID:            completedRun.Run.ID,
-			JobName:       completedRun.Job.Name,
-			StartTime:     core.FormatTime(completedRun.Run.StartTime, conf.LocalTime),
-			EndTime:       core.FormatTime(completedRun.Run.EndTime.Time, conf.LocalTime),
-			LogFile:       completedRun.Run.LogFile,
-			SystemLogFile: completedRun.Run.ExecLogFile,
-			Status:        completedRun.Run.Status,
-			Pid:           core.FormatPid(completedRun.Run.Pid),
-			Duration:      core.FormatDuration(completedRun.Run.StartTime, completedRun.Run.EndTime.Time),
-		}
+		data := core.NewRunShow(completedRun.Run, completedRun.Job.Name, conf.LocalTime)
 		core.PrintJson(data)
 	},
 }
@@ -88,6 +111,9 @@ func init() {
 		core.LogErrorAndExit(slog.Default(), err)
 	}
 	execCmd.Flags().Bool(notifyOpt, false, "Notifies of the exec success")
+	execCmd.Flags().Duration(timeoutOpt, 0, "Terminates the run if it runs longer than this. eg. 30m. Overrides the job's timeout; 0 disables it")
+	execCmd.Flags().String(concurrencyOpt, "", "What to do if the job is already running (skip|queue|replace|parallel:N). Overrides the job's concurrency")
+	execCmd.Flags().Duration(queueTimeoutOpt, 0, "How long a queued run waits for the job to stop running before it is skipped. Overrides the job's queue timeout; 0 waits indefinitely")
 }
 
 func execRun(
@@ -96,10 +122,15 @@ func execRun(
 	jobName string,
 	isNotify bool,
 	conf config.Config,
-	db *data.Queries,
+	conn *sql.DB,
 	logFile string,
 	args []string,
+	timeoutOverride *time.Duration,
+	concurrencyOverride *concurrencyOverride,
+	rerunOfRunId int64,
 ) data.GetRunRow {
+	db := data.New(conn)
+	command := runCommand{Command: args[0], RerunOfRunID: rerunOfRunId}
 	jobRow, err := db.GetJob(ctx, jobName)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -109,39 +140,84 @@ func execRun(
 		}
 	}
 	if jobRow == (data.GetJobRow{}) {
-		id, err := db.CreateJob(context.Background(), data.CreateJobParams{
-			Name:             jobName,
-			NotifyLogContent: false,
+		_, err := db.CreateJob(context.Background(), data.CreateJobParams{
+			Name:                  jobName,
+			NotifyLogContent:      false,
+			RetryAttempts:         1,
+			RetryBackoff:          string(core.RetryBackoffFixed),
+			NotifyMode:            string(core.NotifyModeAlways),
+			NotifyReminderSeconds: 3600,
 		})
 		if err != nil {
 			core.LogErrorAndExit(logger, err)
 		}
-		jobRow.Job.Name = jobName
-		jobRow.Job.ID = id
+		jobRow, err = db.GetJob(ctx, jobName)
+		if err != nil {
+			core.LogErrorAndExit(logger, err)
+		}
 	}
 
-	lockFile := filepath.Join(conf.LockDir, jobName+".lock")
-	f := flock.New(lockFile)
+	// Signals are handled before the lock is taken, so that a queued run can
+	// be terminated while it waits
+	signals := handleSignals(logger)
+	defer signals.stop()
 
-	locked, err := f.TryLock()
-
-	if err != nil || !locked {
+	lockGroups, err := db.GetJobLockGroups(ctx, jobRow.Job.ID)
+	if err != nil {
+		core.LogErrorAndExit(logger, err)
+	}
+	if len(lockGroups) > 0 {
+		if err := os.MkdirAll(lockGroupDir(conf.LockDir), 0755); err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to create lock group directory"))
+		}
+	}
+	concurrency := newConcurrencyPolicy(jobRow.Job, concurrencyOverride)
+	locks := concurrency.runLocks(conf.LockDir, jobName, lockGroups)
+	held, blocked, queuedRunId := acquireLocks(
+		ctx,
+		logger,
+		conf,
+		db,
+		jobRow.Job,
+		concurrency,
+		locks,
+		logFile,
+		command,
+		signals.terminated,
+	)
+	if blocked != nil {
+		status := core.RunStatusSkipped
+		var blocker data.UpdateRunBlockedByParams
+		if queuedRunId != 0 && signals.isTerminated() {
+			core.LogRunTerminated(logger, queuedRunId, jobName, "received signal while queued")
+			status = core.RunStatusTerminated
+		} else {
+			blocker = findBlocker(ctx, logger, conf, db, jobRow.Job, *blocked)
+		}
 		return skipRun(
 			jobRow.Job,
 			logFile,
 			conf,
-			db,
+			conn,
 			context.Background(),
 			logger,
 			isNotify,
+			queuedRunId,
+			status,
+			blocked.heldReason(),
+			blocker,
+			command,
 		)
 	}
-	if !locked {
-		core.LogErrorAndExit(logger, errors.New("unable to create lock for job. Likely already running"))
-	}
 
-	defer f.Unlock()
-	logger.Info("Created job lock at " + lockFile)
+	defer unlockAll(logger, held)
+	for i, f := range held {
+		if locks[i].Group == "" {
+			logger.Info("Created job lock at " + f.Path())
+		} else {
+			logger.Info("Created lock group " + locks[i].Group + " lock at " + f.Path())
+		}
+	}
 
 	stdout, err := os.CreateTemp(conf.LogDir, jobName+".*.log")
 	if err != nil {
@@ -153,144 +229,657 @@ func execRun(
 	}
 
 	logger.Info("Run log created at: " + stdout.Name())
-	runId, err := db.StartRun(context.Background(), data.StartRunParams{
-		JobID:       jobRow.Job.ID,
-		LogFile:     stdout.Name(),
-		ExecLogFile: logFile,
-	})
+	runId := queuedRunId
+	if queuedRunId != 0 {
+		err = db.StartQueuedRun(context.Background(), data.StartQueuedRunParams{
+			ID:      queuedRunId,
+			LogFile: stdout.Name(),
+		})
+	} else {
+		identity := getExecIdentity()
+		runId, err = db.StartRun(context.Background(), data.StartRunParams{
+			JobID:            jobRow.Job.ID,
+			LogFile:          stdout.Name(),
+			ExecLogFile:      logFile,
+			Host:             conf.Notify.Hostname,
+			Command:          command.command(),
+			RerunOfRunID:     command.rerunOf(),
+			ExecPid:          identity.Pid,
+			ExecPidStartTime: identity.StartTime,
+			BootID:           identity.BootID,
+		})
+	}
 	if err != nil {
 		core.LogErrorAndExit(logger, err, errors.New("unable to start run"))
 	}
 	core.LogRunCreated(logger, runId, jobName)
+	refreshMetrics(ctx, logger, conf, db)
+
+	var timeout time.Duration
+	if jobRow.Job.TimeoutSeconds.Valid {
+		timeout = time.Duration(jobRow.Job.TimeoutSeconds.Int64) * time.Second
+	}
+	if timeoutOverride != nil {
+		timeout = *timeoutOverride
+	}
+
+	retry := newRetryPolicy(jobRow.Job)
+	var status core.RunStatus
+	var result data.UpdateRunResultParams
+	for attempt := int64(1); ; attempt++ {
+		attemptRunId := runId
+		if retry.MaxAttempts > 1 {
+			attemptRunId = startAttempt(ctx, logger, db, jobRow.Job.ID, runId, attempt, stdout.Name(), logFile, conf.Notify.Hostname, command.Command)
+		}
+		status, result = runAttempt(
+			ctx,
+			logger,
+			db,
+			conf,
+			jobName,
+			runId,
+			attemptRunId,
+			command.Command,
+			stdoutLog,
+			timeout,
+			signals,
+		)
+		if attemptRunId != runId {
+			result.ID = attemptRunId
+			endRun(ctx, logger, db, result, status)
+		}
+		if attempt >= retry.MaxAttempts || signals.isTerminated() || !retry.shouldRetry(status, result.ExitCode) {
+			break
+		}
+		delay := retry.delay(attempt)
+		core.LogRunRetrying(logger, runId, jobName, attempt+1, retry.MaxAttempts, delay)
+		select {
+		case <-time.After(delay):
+		case <-signals.terminated:
+		}
+		if signals.isTerminated() {
+			core.LogRunTerminated(logger, runId, jobName, "received signal while waiting to retry")
+			status = core.RunStatusTerminated
+			break
+		}
+	}
+
+	result.ID = runId
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		core.LogErrorAndExit(logger, err, errors.New("unable to end run"))
+	}
+	qtx := db.WithTx(tx)
+	endRun(ctx, logger, qtx, result, status)
+	if isNotify {
+		enqueueNotifications(ctx, logger, qtx, conf, jobRow.Job.ID, runId)
+	}
+	if err := tx.Commit(); err != nil {
+		core.LogErrorAndExit(logger, err, errors.New("unable to end run"))
+	}
+	core.LogRunCompleted(logger, runId, jobName, status)
+
+	if conf.Output.Store {
+		storeOutput(ctx, logger, conf, conn, runId, stdout.Name())
+	}
+	if archiveFormat := core.ArchiveFormat(jobRow.Job.Archive); archiveFormat != core.ArchiveFormatNone {
+		stdout.Close()
+		stdoutLog.Close()
+		archiveLog(ctx, logger, conf, db, runId, jobName, stdout.Name(), archiveFormat)
+	}
+
+	flushNotifications(ctx, logger, conf, db)
+
+	completedRun, err := db.GetRun(ctx, runId)
+	if err != nil {
+		core.LogErrorAndExit(logger, err, errors.New("unable to get completed run"))
+	}
+	if conf.Retention.Auto {
+		pruneRuns(ctx, logger, conf, conn, jobName)
+	}
+	refreshMetrics(ctx, logger, conf, db)
+	return completedRun
+}
+
+// Returns the command of the job, for an exec without a command.
+func getJobCommandOrExit(ctx context.Context, logger *slog.Logger, conn *sql.DB, jobName string) string {
+	jobRow, err := data.New(conn).GetJob(ctx, jobName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			core.LogErrorAndExit(logger, errors.New("job with name '"+jobName+"' not found. Provide a command to create it"))
+		}
+		core.LogErrorAndExit(logger, err)
+	}
+	if jobRow.Job.Command.String == "" {
+		core.LogErrorAndExit(logger, errors.New("job '"+jobName+"' has no command. Provide one, or set it with job update --command"))
+	}
+	return jobRow.Job.Command.String
+}
 
-	cmdArgs := []string{"-c"}
-	cmdArgs = append(cmdArgs, args[0])
-	runCmd := exec.Command("/bin/sh", cmdArgs...)
+// The command a run executes, which is recorded on the run along with the run
+// it reruns, if any.
+type runCommand struct {
+	Command      string
+	RerunOfRunID int64
+}
+
+func (c runCommand) command() sql.NullString {
+	return sql.NullString{String: c.Command, Valid: true}
+}
+
+func (c runCommand) rerunOf() sql.NullInt64 {
+	return sql.NullInt64{Int64: c.RerunOfRunID, Valid: c.RerunOfRunID != 0}
+}
+
+// Identifies this troc exec process. It is recorded against the run, as
+// unlike the command it is running while the run is queued or waiting to
+// retry, so troc run reap can tell a run whose troc exec was killed.
+type execIdentity struct {
+	Pid sql.NullInt64
+	core.ProcessIdentity
+}
+
+func getExecIdentity() execIdentity {
+	return execIdentity{
+		Pid:             sql.NullInt64{Int64: int64(os.Getpid()), Valid: true},
+		ProcessIdentity: core.GetProcessIdentity(os.Getpid()),
+	}
+}
+
+// Creates a Queued run, which waits for the lock of its job to start.
+func queueRun(
+	ctx context.Context,
+	logger *slog.Logger,
+	conf config.Config,
+	db *data.Queries,
+	job data.Job,
+	execLogFile string,
+	reason string,
+	command runCommand,
+) int64 {
+	identity := getExecIdentity()
+	runId, err := db.QueueRun(ctx, data.QueueRunParams{
+		JobID:            job.ID,
+		ExecLogFile:      execLogFile,
+		Host:             conf.Notify.Hostname,
+		Command:          command.command(),
+		RerunOfRunID:     command.rerunOf(),
+		ExecPid:          identity.Pid,
+		ExecPidStartTime: identity.StartTime,
+		BootID:           identity.BootID,
+	})
+	if err != nil {
+		core.LogErrorAndExit(logger, err, errors.New("unable to queue run"))
+	}
+	core.LogRunQueued(logger, runId, job.Name, reason)
+	refreshMetrics(ctx, logger, conf, db)
+	return runId
+}
+
+// Adds the notifications of a run to the outbox. This should be done in the
+// same transaction that ends the run, so that a notification can't be lost.
+func enqueueNotifications(
+	ctx context.Context,
+	logger *slog.Logger,
+	db *data.Queries,
+	conf config.Config,
+	jobId int64,
+	runId int64,
+) {
+	err := notify.Enqueue(ctx, logger, db, conf, runId, getNotifyTargets(ctx, logger, db, jobId))
+	if err != nil {
+		core.LogErrorAndExit(logger, err, errors.New("unable to enqueue notifications"))
+	}
+}
+
+// Delivers the notifications of the run, along with any still pending from
+// earlier runs. Failed notifications are left in the outbox to be retried.
+func flushNotifications(
+	ctx context.Context,
+	logger *slog.Logger,
+	conf config.Config,
+	db *data.Queries,
+) {
+	err := notify.Flush(ctx, logger, conf, db)
+	if err != nil {
+		logger.Error("Unable to send all notifications. They will be retried by later runs or troc notify flush", "error", err)
+	}
+}
+
+// Deletes the runs of the job that are outside its retention, as troc prune
+// does. The run has already completed, so an error is only logged.
+func pruneRuns(
+	ctx context.Context,
+	logger *slog.Logger,
+	conf config.Config,
+	conn *sql.DB,
+	jobName string,
+) {
+	pruned, err := retention.Plan(ctx, data.New(conn), conf.Retention, jobName, time.Now())
+	if err == nil {
+		err = retention.Prune(ctx, logger, conn, pruned)
+	}
+	if err != nil {
+		logger.Error("Unable to prune runs", "error", err)
+	}
+}
+
+// Rewrites the metrics file, if exec is configured to refresh it. An error is
+// only logged, as it must not affect the run.
+func refreshMetrics(
+	ctx context.Context,
+	logger *slog.Logger,
+	conf config.Config,
+	db *data.Queries,
+) {
+	if !conf.Metrics.Refresh || conf.Metrics.File == "" {
+		return
+	}
+	if err := metrics.WriteFile(ctx, db, conf.Metrics.File); err != nil {
+		logger.Error("Unable to write metrics to "+conf.Metrics.File, "error", err)
+	}
+}
+
+// Stores the log of the run in the database, so it can be searched and outlives
+// the log file. The run has already completed, so an error is only logged.
+func storeOutput(
+	ctx context.Context,
+	logger *slog.Logger,
+	conf config.Config,
+	conn *sql.DB,
+	runId int64,
+	logFile string,
+) {
+	err := output.Store(ctx, conn, runId, logFile, conf.Output.ChunkLines)
+	if err != nil {
+		logger.Error("Unable to store run output", "error", err)
+	}
+}
+
+// Compresses the log of the run into the archive directory. The run has already
+// completed, so an error is only logged and the uncompressed log is kept.
+func archiveLog(
+	ctx context.Context,
+	logger *slog.Logger,
+	conf config.Config,
+	db *data.Queries,
+	runId int64,
+	jobName string,
+	logFile string,
+	format core.ArchiveFormat,
+) {
+	archiveFile, err := archive.Run(ctx, db, conf.Archive, runId, logFile, format)
+	if err != nil {
+		logger.Error("Unable to archive run log", "error", err)
+		return
+	}
+	core.LogRunArchived(logger, runId, jobName, archiveFile)
+}
+
+// Returns the notify targets of a job. An empty list notifies every target.
+func getNotifyTargets(ctx context.Context, logger *slog.Logger, db *data.Queries, jobId int64) []string {
+	targets, err := db.GetJobNotifyTargets(ctx, jobId)
+	if err != nil {
+		core.LogErrorAndExit(logger, err, errors.New("unable to get job notify targets"))
+	}
+	return targets
+}
+
+// Creates a child run for an attempt of a run with retries.
+// Attempts share the log file of the parent run.
+func startAttempt(
+	ctx context.Context,
+	logger *slog.Logger,
+	db *data.Queries,
+	jobId int64,
+	runId int64,
+	attempt int64,
+	runLogFile string,
+	execLogFile string,
+	host string,
+	command string,
+) int64 {
+	attemptRunId, err := db.StartAttempt(ctx, data.StartAttemptParams{
+		JobID:       jobId,
+		LogFile:     runLogFile,
+		ExecLogFile: execLogFile,
+		ParentRunID: sql.NullInt64{Int64: runId, Valid: true},
+		Attempt:     attempt,
+		Host:        host,
+		Command:     sql.NullString{String: command, Valid: true},
+	})
+	if err != nil {
+		core.LogErrorAndExit(logger, err, errors.New("unable to start attempt"))
+	}
+	err = db.UpdateRunAttempt(ctx, data.UpdateRunAttemptParams{
+		ID:      runId,
+		Attempt: attempt,
+	})
+	if err != nil {
+		core.LogErrorAndExit(logger, err, errors.New("unable to update run attempt"))
+	}
+	return attemptRunId
+}
+
+// Executes the command once, recording the PID against the run and attempt.
+// Returns the status and result of the attempt.
+func runAttempt(
+	ctx context.Context,
+	logger *slog.Logger,
+	db *data.Queries,
+	conf config.Config,
+	jobName string,
+	runId int64,
+	attemptRunId int64,
+	command string,
+	stdoutLog *os.File,
+	timeout time.Duration,
+	signals *runSignals,
+) (core.RunStatus, data.UpdateRunResultParams) {
+	if signals.isTerminated() {
+		return core.RunStatusTerminated, data.UpdateRunResultParams{}
+	}
+	runCmd := exec.Command("/bin/sh", "-c", command)
 	runCmd.Stdout = stdoutLog
 	runCmd.Stderr = stdoutLog
+	// The run is started in its own process group, so that signalling the
+	// group reaches every process of the run, not only the shell
+	runCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		sig := <-c
-		if sig == syscall.SIGTERM {
-			err := runCmd.Process.Signal(syscall.SIGTERM)
-			if err != nil {
-				logger.Error("Failed to send SIGTERM to run.")
-			}
-		}
-	}()
+	err := runCmd.Start()
+	if err != nil {
+		logger.Error("Failed to start run: " + err.Error())
+		return core.RunStatusFailed, data.UpdateRunResultParams{}
+	}
+	signals.setProcess(runCmd.Process)
+	defer signals.setProcess(nil)
 
 	status := core.RunStatusSucceeded
-	err = runCmd.Start()
+	core.LogRunStarted(logger, runId, jobName, runCmd.Process.Pid)
+	pid := sql.NullInt64{
+		Int64: int64(runCmd.Process.Pid),
+		Valid: true,
+	}
+	// Recorded so troc run reap can tell if the PID is later reused
+	identity := core.GetProcessIdentity(runCmd.Process.Pid)
+	// The process is the leader of its group, so the group has its PID
+	err = db.UpdateRunPid(ctx, data.UpdateRunPidParams{
+		ID:           runId,
+		Pid:          pid,
+		Pgid:         pid,
+		PidStartTime: identity.StartTime,
+		BootID:       identity.BootID,
+	})
+	if err == nil && attemptRunId != runId {
+		err = db.UpdateRunPid(ctx, data.UpdateRunPidParams{
+			ID:           attemptRunId,
+			Pid:          pid,
+			Pgid:         pid,
+			PidStartTime: identity.StartTime,
+			BootID:       identity.BootID,
+		})
+	}
 	if err != nil {
-		logger.Error("Failed to start run: " + err.Error())
+		sigtermErr := signalGroup(runCmd.Process, syscall.SIGTERM)
+		if sigtermErr != nil {
+			logger.Error("Failed to send SIGTERM to process.")
+		}
 		status = core.RunStatusFailed
-	} else {
-		core.LogRunStarted(logger, runId, jobName, runCmd.Process.Pid)
-		err := db.UpdateRunPid(ctx, data.UpdateRunPidParams{
-			ID: runId,
-			Pid: sql.NullInt64{
-				Int64: int64(runCmd.Process.Pid),
-				Valid: true,
-			},
+	}
+	done := make(chan struct{})
+	var timedOut atomic.Bool
+	var timeoutWg sync.WaitGroup
+	if timeout > 0 {
+		timeoutWg.Go(func() {
+			enforceTimeout(logger, runId, jobName, runCmd.Process, timeout, conf.KillGrace, &timedOut, done)
 		})
-		if err != nil {
-			sigtermErr := runCmd.Process.Signal(syscall.SIGTERM)
-			if sigtermErr != nil {
-				logger.Error("Failed to send SIGTERM to process.")
-			}
-			status = core.RunStatusFailed
-		}
-		err = runCmd.Wait()
-		if err != nil {
-			if strings.HasPrefix(err.Error(), "signal: ") {
-				core.LogRunTerminated(logger, runId, jobName, err.Error())
-				status = core.RunStatusTerminated
-			} else {
-				logger.Error("Error occurred during run", "error", err)
-				status = core.RunStatusFailed
-			}
+	}
+	err = runCmd.Wait()
+	close(done)
+	timeoutWg.Wait()
+	result := runResult(runId, runCmd.ProcessState)
+	if timedOut.Load() {
+		status = core.RunStatusTimedOut
+	} else if result.Signal.Valid {
+		core.LogRunTerminated(logger, runId, jobName, result.Signal.String)
+		status = core.RunStatusTerminated
+	} else if err != nil {
+		logger.Error("Error occurred during run", "error", err)
+		status = core.RunStatusFailed
+	}
+	if status == core.RunStatusTimedOut || status == core.RunStatusTerminated {
+		// Processes of the run that outlived the shell would otherwise be
+		// left running after the run has ended
+		err := signalGroup(runCmd.Process, syscall.SIGKILL)
+		if err == nil {
+			core.LogRunSentSigkill(logger, runId, jobName, runCmd.Process.Pid)
 		}
 	}
+	return status, result
+}
+
+// Sends a signal to every process in the process group of the run.
+func signalGroup(process *os.Process, sig syscall.Signal) error {
+	return syscall.Kill(-process.Pid, sig)
+}
 
-	db.EndRun(context.Background(), data.EndRunParams{
+// Stores the result of a run and sets its final status.
+func endRun(
+	ctx context.Context,
+	logger *slog.Logger,
+	db *data.Queries,
+	result data.UpdateRunResultParams,
+	status core.RunStatus,
+) {
+	err := db.UpdateRunResult(ctx, result)
+	if err != nil {
+		logger.Error("Unable to store run result", "error", err)
+	}
+	err = db.EndRun(ctx, data.EndRunParams{
 		Status: string(status),
-		ID:     runId,
+		ID:     result.ID,
 	})
-	core.LogRunCompleted(logger, runId, jobName, status)
-
-	completedRun, err := db.GetRun(ctx, runId)
 	if err != nil {
-		core.LogErrorAndExit(logger, err, errors.New("unable to get completed run"))
+		logger.Error("Unable to end run", "error", err)
 	}
+}
 
-	if isNotify {
-		logger.Info("Sending notify message")
-		ok, err := notify.NotifyRun(
-			conf,
-			notify.RunNotifyInfo{
-				Name:             completedRun.Job.Name,
-				Id:               completedRun.Run.ID,
-				Status:           core.RunStatus(completedRun.Run.Status),
-				LogFile:          completedRun.Run.LogFile,
-				NotifyLogContent: jobRow.Job.NotifyLogContent,
-			},
-		)
-		if err != nil {
-			core.LogErrorAndExit(logger, err, errors.New("unable to notify"))
+// Forwards SIGINT and SIGTERM received by exec to the process group of the
+// current attempt, which doesn't receive the SIGINT of a terminal as it is
+// in its own group. Receiving either also prevents any further attempts.
+type runSignals struct {
+	mu         sync.Mutex
+	process    *os.Process
+	terminated chan struct{}
+	c          chan os.Signal
+}
+
+func handleSignals(logger *slog.Logger) *runSignals {
+	s := &runSignals{
+		terminated: make(chan struct{}),
+		c:          make(chan os.Signal, 1),
+	}
+	signal.Notify(s.c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		var once sync.Once
+		for sig := range s.c {
+			once.Do(func() { close(s.terminated) })
+			s.mu.Lock()
+			process := s.process
+			s.mu.Unlock()
+			if process == nil {
+				continue
+			}
+			err := signalGroup(process, sig.(syscall.Signal))
+			if err != nil {
+				logger.Error("Failed to send " + unix.SignalName(sig.(syscall.Signal)) + " to run.")
+			}
 		}
-		if !ok {
-			logger.Error("command was run, but notification was unable to be sent")
+	}()
+	return s
+}
+
+func (s *runSignals) setProcess(process *os.Process) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.process = process
+}
+
+func (s *runSignals) isTerminated() bool {
+	select {
+	case <-s.terminated:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *runSignals) stop() {
+	signal.Stop(s.c)
+	close(s.c)
+}
+
+// Returns the exit code, terminating signal and resource usage of an exited process.
+// A process that was terminated by a signal has no exit code.
+func runResult(runId int64, state *os.ProcessState) data.UpdateRunResultParams {
+	result := data.UpdateRunResultParams{ID: runId}
+	if state == nil {
+		return result
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		name := unix.SignalName(ws.Signal())
+		if name == "" {
+			name = ws.Signal().String()
 		}
+		result.Signal = sql.NullString{String: name, Valid: true}
+	} else {
+		result.ExitCode = sql.NullInt64{Int64: int64(state.ExitCode()), Valid: true}
 	}
-	return completedRun
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		result.UserCpuMs = sql.NullInt64{Int64: time.Duration(rusage.Utime.Nano()).Milliseconds(), Valid: true}
+		result.SystemCpuMs = sql.NullInt64{Int64: time.Duration(rusage.Stime.Nano()).Milliseconds(), Valid: true}
+		// Maxrss is in kilobytes on linux
+		result.MaxRssKb = sql.NullInt64{Int64: rusage.Maxrss, Valid: true}
+		result.BlockInput = sql.NullInt64{Int64: rusage.Inblock, Valid: true}
+		result.BlockOutput = sql.NullInt64{Int64: rusage.Oublock, Valid: true}
+	}
+	return result
 }
 
+// Sends SIGTERM to the process group once the timeout has elapsed, followed
+// by a SIGKILL if the process is still running after the grace period.
+// Returns early if done is closed, ie. the process has exited.
+func enforceTimeout(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	process *os.Process,
+	timeout time.Duration,
+	grace time.Duration,
+	timedOut *atomic.Bool,
+	done <-chan struct{},
+) {
+	select {
+	case <-done:
+		return
+	case <-time.After(timeout):
+	}
+	timedOut.Store(true)
+	core.LogRunTimedOut(logger, runId, jobName, timeout)
+	if err := signalGroup(process, syscall.SIGTERM); err != nil {
+		logger.Error("Failed to send SIGTERM to run.")
+	} else {
+		core.LogRunSentSigterm(logger, runId, jobName, process.Pid)
+	}
+
+	select {
+	case <-done:
+		return
+	case <-time.After(grace):
+	}
+	if err := signalGroup(process, syscall.SIGKILL); err != nil {
+		logger.Error("Failed to send SIGKILL to run.")
+		return
+	}
+	core.LogRunSentSigkill(logger, runId, jobName, process.Pid)
+}
+
+// Records a run that never took the locks of its job. A Skipped run is created,
+// unless the run was queued, in which case the queued run is ended with status.
+// A Skipped run records the run holding the lock that blocked it.
 func skipRun(
 	job data.Job,
 	execLogFile string,
 	conf config.Config,
-	queries *data.Queries,
+	conn *sql.DB,
 	ctx context.Context,
 	logger *slog.Logger,
 	isNotify bool,
+	queuedRunId int64,
+	status core.RunStatus,
+	reason string,
+	blocker data.UpdateRunBlockedByParams,
+	command runCommand,
 ) data.GetRunRow {
-	id, err := queries.SkipRun(ctx, data.SkipRunParams{
-		JobID:       job.ID,
-		ExecLogFile: execLogFile,
-	})
+	queries := data.New(conn)
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
-		// TODO: need a standard function here to deal with errors and communicate to slack
 		core.LogErrorAndExit(logger, err, errors.New("unable to skip run"))
 	}
-	run, err := queries.GetRun(ctx, id)
+	qtx := queries.WithTx(tx)
+	id := queuedRunId
+	if queuedRunId != 0 {
+		err = qtx.EndRun(ctx, data.EndRunParams{
+			ID:     queuedRunId,
+			Status: string(status),
+		})
+	} else {
+		id, err = qtx.SkipRun(ctx, data.SkipRunParams{
+			JobID:        job.ID,
+			ExecLogFile:  execLogFile,
+			Host:         conf.Notify.Hostname,
+			Command:      command.command(),
+			RerunOfRunID: command.rerunOf(),
+		})
+	}
 	if err != nil {
 		// TODO: need a standard function here to deal with errors and communicate to slack
-		core.LogErrorAndExit(logger, err, errors.New("unable to get updated run"))
+		core.LogErrorAndExit(logger, err, errors.New("unable to skip run"))
 	}
-	core.LogRunSkipped(logger, id, job.Name)
-	if isNotify {
-		ok, err := notify.NotifyRun(
-			conf,
-			notify.RunNotifyInfo{
-				Name:             run.Job.Name,
-				Id:               run.Run.ID,
-				Status:           core.RunStatus(run.Run.Status),
-				LogFile:          "",
-				NotifyLogContent: job.NotifyLogContent,
-			},
-		)
-		if err != nil {
-			core.LogErrorAndExit(logger, err, errors.New("unable to notify"))
+	if status == core.RunStatusSkipped {
+		blocker.ID = id
+		if err := qtx.UpdateRunBlockedBy(ctx, blocker); err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to skip run"))
 		}
-		if !ok {
-			logger.Error("command was run, but notification was unable to be sent")
+	}
+	if isNotify {
+		enqueueNotifications(ctx, logger, qtx, conf, job.ID, id)
+	}
+	if err := tx.Commit(); err != nil {
+		core.LogErrorAndExit(logger, err, errors.New("unable to skip run"))
+	}
+	if status == core.RunStatusSkipped {
+		core.LogRunSkipped(logger, id, job.Name, reason)
+		if blocker.BlockedByRunID.Valid {
+			logger.Info(
+				"Blocked by run "+strconv.FormatInt(blocker.BlockedByRunID.Int64, 10)+" of job "+blocker.BlockedByJob.String,
+				core.LogRunId(id),
+				core.LogJobName(job.Name),
+			)
 		}
+	} else {
+		core.LogRunCompleted(logger, id, job.Name, status)
 	}
-	row, err := queries.GetRun(ctx, run.Run.ID)
+
+	flushNotifications(ctx, logger, conf, queries)
+
+	row, err := queries.GetRun(ctx, id)
 	if err != nil {
 		core.LogErrorAndExit(logger, err, errors.New("unable to get updated run"))
 	}
+	if conf.Retention.Auto {
+		pruneRuns(ctx, logger, conf, conn, job.Name)
+	}
+	refreshMetrics(ctx, logger, conf, queries)
 	return row
 }
@@ -2,10 +2,16 @@ package cmd
 
 import (
 	"context"
+	"database/sql"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/samcarswell/trochilus/archive"
 	"github.com/samcarswell/trochilus/config"
 	"github.com/samcarswell/trochilus/core"
 	"github.com/samcarswell/trochilus/data"
@@ -16,7 +22,8 @@ import (
 // TODO: move these tests to e2e tests in /cmd/cmd_test.go
 func Test_execRunNonExistentJob(t *testing.T) {
 	ctx := context.Background()
-	db := test.CreateDb(ctx, t)
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
 	jobName := test.UniqueIdentifer()
 	logFile, logger := test.CreateSysLogFile(t)
 	conf := config.Config{
@@ -29,9 +36,12 @@ func Test_execRunNonExistentJob(t *testing.T) {
 		jobName,
 		false,
 		conf,
-		db,
+		conn,
 		logFile,
 		[]string{"./testdata/script-passes"},
+		nil,
+		nil,
+		0,
 	)
 	dbJob, err := db.GetJob(ctx, jobName)
 	if err != nil {
@@ -70,11 +80,17 @@ func Test_execRunNonExistentJob(t *testing.T) {
 	assert.Equal(t, "Succeeded", dbRun.Run.Status)
 	assert.Equal(t, run.Run.LogFile, dbRun.Run.LogFile)
 	assert.Equal(t, run.Run.ExecLogFile, dbRun.Run.ExecLogFile)
+	assert.Equal(t, "./testdata/script-passes", dbRun.Run.Command.String)
+	assert.False(t, dbRun.Run.RerunOfRunID.Valid)
+	assert.Equal(t, sql.NullInt64{Int64: 0, Valid: true}, dbRun.Run.ExitCode)
+	assert.True(t, dbRun.Run.UserCpuMs.Valid)
+	assert.True(t, dbRun.Run.SystemCpuMs.Valid)
 }
 
 func Test_execRunExistentJob(t *testing.T) {
 	ctx := context.Background()
-	db := test.CreateDb(ctx, t)
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
 	jobName := test.UniqueIdentifer()
 	logFile, logger := test.CreateSysLogFile(t)
 	conf := config.Config{
@@ -83,8 +99,12 @@ func Test_execRunExistentJob(t *testing.T) {
 	}
 
 	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
-		Name:             jobName,
-		NotifyLogContent: false,
+		Name:                  jobName,
+		NotifyLogContent:      false,
+		RetryAttempts:         1,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
 	})
 	if err != nil {
 		t.Fatal(err.Error())
@@ -96,9 +116,12 @@ func Test_execRunExistentJob(t *testing.T) {
 		jobName,
 		false,
 		conf,
-		db,
+		conn,
 		logFile,
 		[]string{"./testdata/script-passes"},
+		nil,
+		nil,
+		0,
 	)
 	dbJob, err := db.GetJob(ctx, jobName)
 	if err != nil {
@@ -138,7 +161,8 @@ func Test_execRunExistentJob(t *testing.T) {
 
 func Test_execRunScriptFails(t *testing.T) {
 	ctx := context.Background()
-	db := test.CreateDb(ctx, t)
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
 	jobName := test.UniqueIdentifer()
 	logFile, logger := test.CreateSysLogFile(t)
 	conf := config.Config{
@@ -151,9 +175,12 @@ func Test_execRunScriptFails(t *testing.T) {
 		jobName,
 		false,
 		conf,
-		db,
+		conn,
 		logFile,
 		[]string{"./testdata/script-fails"},
+		nil,
+		nil,
+		0,
 	)
 	dbRun, err := db.GetRun(ctx, 1)
 	if err != nil {
@@ -163,6 +190,9 @@ func Test_execRunScriptFails(t *testing.T) {
 	execLog := test.NewLogFromFileOrFail(run.Run.ExecLogFile)
 	assert.Equal(t, "Failed", run.Run.Status)
 	assert.Equal(t, "Failed", dbRun.Run.Status)
+	assert.Equal(t, sql.NullInt64{Int64: 1, Valid: true}, dbRun.Run.ExitCode)
+	assert.False(t, dbRun.Run.Signal.Valid)
+	assert.True(t, dbRun.Run.MaxRssKb.Valid)
 	runCompleted := test.GetEventOrFail(t, core.EventRunCompleted, execLog)
 	assert.Equal(t, string(core.RunStatusFailed), runCompleted.RunStatus)
 	test.AssertFileContents(t, "This script will fail\n", run.Run.LogFile)
@@ -171,7 +201,8 @@ func Test_execRunScriptFails(t *testing.T) {
 
 func Test_execRunStdoutStderr(t *testing.T) {
 	ctx := context.Background()
-	db := test.CreateDb(ctx, t)
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
 	jobName := test.UniqueIdentifer()
 	logFile, logger := test.CreateSysLogFile(t)
 	conf := config.Config{
@@ -184,9 +215,12 @@ func Test_execRunStdoutStderr(t *testing.T) {
 		jobName,
 		false,
 		conf,
-		db,
+		conn,
 		logFile,
 		[]string{"./testdata/script-stdout-stderr"},
+		nil,
+		nil,
+		0,
 	)
 	dbRun, err := db.GetRun(ctx, 1)
 	if err != nil {
@@ -205,7 +239,8 @@ func Test_execRunStdoutStderr(t *testing.T) {
 func Test_execRunSkippedRun(t *testing.T) {
 	blocked := make(chan data.GetRunRow)
 	ctx := context.Background()
-	db := test.CreateDb(ctx, t)
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
 	jobName := test.UniqueIdentifer()
 	logFile1, logger1 := test.CreateSysLogFile(t)
 	logFile2, logger2 := test.CreateSysLogFile(t)
@@ -220,9 +255,12 @@ func Test_execRunSkippedRun(t *testing.T) {
 			jobName,
 			false,
 			conf,
-			db,
+			conn,
 			logFile1,
 			[]string{"./testdata/script-sleeps"},
+			nil,
+			nil,
+			0,
 		)
 	}()
 	time.Sleep(100 * time.Millisecond)
@@ -232,12 +270,21 @@ func Test_execRunSkippedRun(t *testing.T) {
 		jobName,
 		false,
 		conf,
-		db,
+		conn,
 		logFile2,
 		[]string{"./testdata/script-passes"},
+		nil,
+		nil,
+		0,
 	)
 	successfulRun := <-blocked
-	runs, err := db.GetRuns(ctx, "")
+	runs, err := core.GetRuns(ctx, db, core.RunFilter{
+		JobName: "",
+		Status:  "",
+		Host:    "",
+		Sort:    "start:desc",
+		Limit:   -1,
+	})
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -247,6 +294,10 @@ func Test_execRunSkippedRun(t *testing.T) {
 	assert.Equal(t, 2, len(runs))
 	assert.Equal(t, "Skipped", skippedRun.Run.Status)
 	assert.Equal(t, "", skippedRun.Run.LogFile)
+	assert.Equal(t, successfulRun.Run.ID, skippedRun.Run.BlockedByRunID.Int64)
+	assert.Equal(t, jobName, skippedRun.Run.BlockedByJob.String)
+	assert.False(t, skippedRun.Run.BlockedByLockGroup.Valid)
+	assert.Equal(t, "./testdata/script-passes", skippedRun.Run.Command.String)
 	runSkipped := test.GetEventOrFail(t, core.EventRunSkipped, skippedLog)
 	assert.Equal(t, skippedRun.Run.ID, runSkipped.RunId)
 	assert.Equal(t, "Succeeded", successfulRun.Run.Status)
@@ -258,7 +309,8 @@ func Test_execRunSkippedRun(t *testing.T) {
 
 func Test_execRunComplexCommand(t *testing.T) {
 	ctx := context.Background()
-	db := test.CreateDb(ctx, t)
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
 	jobName := test.UniqueIdentifer()
 	logFile, logger := test.CreateSysLogFile(t)
 	conf := config.Config{
@@ -271,9 +323,12 @@ func Test_execRunComplexCommand(t *testing.T) {
 		jobName,
 		false,
 		conf,
-		db,
+		conn,
 		logFile,
 		[]string{"echo \"Testing again...\" && echo \"and again...\" | awk '{ print toupper($0) }'"},
+		nil,
+		nil,
+		0,
 	)
 	dbRun, err := db.GetRun(ctx, 1)
 	if err != nil {
@@ -287,3 +342,853 @@ func Test_execRunComplexCommand(t *testing.T) {
 	assert.Equal(t, string(core.RunStatusSucceeded), runCompleted.RunStatus)
 	test.AssertFileContents(t, "Testing again...\nAND AGAIN...\n", run.Run.LogFile)
 }
+
+func Test_execRunTimedOut(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobName := test.UniqueIdentifer()
+	logFile, logger := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir:   t.TempDir(),
+		LogDir:    t.TempDir(),
+		KillGrace: time.Second,
+	}
+	timeout := 100 * time.Millisecond
+	run := execRun(
+		ctx,
+		logger,
+		jobName,
+		false,
+		conf,
+		conn,
+		logFile,
+		[]string{"./testdata/script-sleeps"},
+		&timeout,
+		nil,
+		0,
+	)
+	dbRun, err := db.GetRun(ctx, 1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	execLog := test.NewLogFromFileOrFail(run.Run.ExecLogFile)
+	assert.Equal(t, "TimedOut", run.Run.Status)
+	assert.Equal(t, "TimedOut", dbRun.Run.Status)
+	timedOut := test.GetEventOrFail(t, core.EventRunTimedOut, execLog)
+	assert.Equal(t, run.Run.ID, timedOut.RunId)
+	test.GetEventOrFail(t, core.EventRunSigterm, execLog)
+	runCompleted := test.GetEventOrFail(t, core.EventRunCompleted, execLog)
+	assert.Equal(t, string(core.RunStatusTimedOut), runCompleted.RunStatus)
+}
+
+func Test_execRunTimedOutJobDefaultSigkill(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobName := test.UniqueIdentifer()
+	logFile, logger := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir:   t.TempDir(),
+		LogDir:    t.TempDir(),
+		KillGrace: 100 * time.Millisecond,
+	}
+
+	_, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  jobName,
+		NotifyLogContent:      false,
+		TimeoutSeconds:        sql.NullInt64{Int64: 1, Valid: true},
+		RetryAttempts:         1,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	run := execRun(
+		ctx,
+		logger,
+		jobName,
+		false,
+		conf,
+		conn,
+		logFile,
+		[]string{"trap '' TERM; sleep 5"},
+		nil,
+		nil,
+		0,
+	)
+
+	execLog := test.NewLogFromFileOrFail(run.Run.ExecLogFile)
+	assert.Equal(t, "TimedOut", run.Run.Status)
+	test.GetEventOrFail(t, core.EventRunTimedOut, execLog)
+	test.GetEventOrFail(t, core.EventRunSigkill, execLog)
+	assert.Equal(t, "SIGKILL", run.Run.Signal.String)
+	assert.False(t, run.Run.ExitCode.Valid)
+	assert.Less(t, run.Run.EndTime.Time.Sub(run.Run.StartTime), 5*time.Second)
+}
+
+func Test_execRunTimedOutKillsProcessGroup(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobName := test.UniqueIdentifer()
+	logFile, logger := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir:   t.TempDir(),
+		LogDir:    t.TempDir(),
+		KillGrace: time.Second,
+	}
+	pidFile := path.Join(t.TempDir(), "sleep.pid")
+	timeout := 500 * time.Millisecond
+
+	run := execRun(
+		ctx,
+		logger,
+		jobName,
+		false,
+		conf,
+		conn,
+		logFile,
+		[]string{"sleep 60 & echo $! > " + pidFile + "; wait"},
+		&timeout,
+		nil,
+		0,
+	)
+	dbRun, err := db.GetRun(ctx, run.Run.ID)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	assert.Equal(t, "TimedOut", run.Run.Status)
+	assert.Equal(t, dbRun.Run.Pid, dbRun.Run.Pgid)
+	// troc exec is the test process
+	assert.Equal(t, int64(os.Getpid()), dbRun.Run.ExecPid.Int64)
+	pid, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sleepPid, err := strconv.Atoi(strings.TrimSpace(string(pid)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Eventually(t, func() bool {
+		return !test.IsProcessRunning(t, sleepPid)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_execRunRetries(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobName := test.UniqueIdentifer()
+	logFile, logger := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir: t.TempDir(),
+		LogDir:  t.TempDir(),
+	}
+	_, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  jobName,
+		RetryAttempts:         3,
+		RetryBackoff:          string(core.RetryBackoffExponential),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	run := execRun(
+		ctx,
+		logger,
+		jobName,
+		false,
+		conf,
+		conn,
+		logFile,
+		[]string{"./testdata/script-fails"},
+		nil,
+		nil,
+		0,
+	)
+	runs, err := core.GetRuns(ctx, db, core.RunFilter{
+		JobName: "",
+		Status:  "",
+		Host:    "",
+		Sort:    "start:desc",
+		Limit:   -1,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	attempts, err := db.GetRunAttempts(ctx, sql.NullInt64{Int64: run.Run.ID, Valid: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	execLog := test.NewLogFromFileOrFail(run.Run.ExecLogFile)
+	assert.Equal(t, 1, len(runs))
+	assert.Equal(t, "Failed", run.Run.Status)
+	assert.Equal(t, int64(3), run.Run.Attempt)
+	assert.Equal(t, sql.NullInt64{Int64: 1, Valid: true}, run.Run.ExitCode)
+	assert.Equal(t, 3, len(attempts))
+	for i, attempt := range attempts {
+		assert.Equal(t, int64(i+1), attempt.Run.Attempt)
+		assert.Equal(t, "Failed", attempt.Run.Status)
+		assert.Equal(t, run.Run.ID, attempt.Run.ParentRunID.Int64)
+		assert.Equal(t, run.Run.LogFile, attempt.Run.LogFile)
+	}
+	test.AssertFileContents(t, "This script will fail\nThis script will fail\nThis script will fail\n", run.Run.LogFile)
+	retry := test.GetEventOrFail(t, core.EventRunRetry, execLog)
+	assert.Equal(t, run.Run.ID, retry.RunId)
+	runCompleted := test.GetEventOrFail(t, core.EventRunCompleted, execLog)
+	assert.Equal(t, string(core.RunStatusFailed), runCompleted.RunStatus)
+}
+
+func Test_execRunRetrySucceeds(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobName := test.UniqueIdentifer()
+	logFile, logger := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir: t.TempDir(),
+		LogDir:  t.TempDir(),
+	}
+	_, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  jobName,
+		RetryAttempts:         3,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	marker := path.Join(t.TempDir(), "marker")
+
+	run := execRun(
+		ctx,
+		logger,
+		jobName,
+		false,
+		conf,
+		conn,
+		logFile,
+		[]string{"if [ -f " + marker + " ]; then exit 0; fi; touch " + marker + "; exit 1"},
+		nil,
+		nil,
+		0,
+	)
+	attempts, err := db.GetRunAttempts(ctx, sql.NullInt64{Int64: run.Run.ID, Valid: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	assert.Equal(t, "Succeeded", run.Run.Status)
+	assert.Equal(t, int64(2), run.Run.Attempt)
+	assert.Equal(t, 2, len(attempts))
+	assert.Equal(t, "Failed", attempts[0].Run.Status)
+	assert.Equal(t, "Succeeded", attempts[1].Run.Status)
+}
+
+func Test_execRunRetryExitCodes(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobName := test.UniqueIdentifer()
+	logFile, logger := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir: t.TempDir(),
+		LogDir:  t.TempDir(),
+	}
+	_, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  jobName,
+		RetryAttempts:         3,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+		RetryExitCodes:        "2,75",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	run := execRun(
+		ctx,
+		logger,
+		jobName,
+		false,
+		conf,
+		conn,
+		logFile,
+		[]string{"./testdata/script-fails"},
+		nil,
+		nil,
+		0,
+	)
+	attempts, err := db.GetRunAttempts(ctx, sql.NullInt64{Int64: run.Run.ID, Valid: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	assert.Equal(t, "Failed", run.Run.Status)
+	assert.Equal(t, int64(1), run.Run.Attempt)
+	assert.Equal(t, 1, len(attempts))
+}
+
+func Test_execRunArchived(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobName := test.UniqueIdentifer()
+	logFile, logger := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir: t.TempDir(),
+		LogDir:  t.TempDir(),
+		Archive: config.ArchiveConfig{
+			Dir: path.Join(t.TempDir(), "archive"),
+		},
+	}
+	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  jobName,
+		RetryAttempts:         2,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = db.UpdateJobArchive(ctx, data.UpdateJobArchiveParams{
+		ID:      jobId,
+		Archive: string(core.ArchiveFormatGzip),
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	run := execRun(
+		ctx,
+		logger,
+		jobName,
+		false,
+		conf,
+		conn,
+		logFile,
+		[]string{"./testdata/script-fails"},
+		nil,
+		nil,
+		0,
+	)
+	attempts, err := db.GetRunAttempts(ctx, sql.NullInt64{Int64: run.Run.ID, Valid: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	execLog := test.NewLogFromFileOrFail(run.Run.ExecLogFile)
+	test.AssertFileInDirectory(t, conf.Archive.Dir, run.Run.LogFile)
+	assert.True(t, strings.HasSuffix(run.Run.LogFile, ".log.gz"))
+	assert.Equal(t, 2, len(attempts))
+	for _, attempt := range attempts {
+		assert.Equal(t, run.Run.LogFile, attempt.Run.LogFile)
+	}
+	logs, err := filepath.Glob(path.Join(conf.LogDir, "*"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Empty(t, logs)
+	content, err := archive.ReadFile(run.Run.LogFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, "This script will fail\nThis script will fail\n", string(content))
+	archived := test.GetEventOrFail(t, core.EventRunArchived, execLog)
+	assert.Equal(t, run.Run.ID, archived.RunId)
+}
+
+func Test_execRunStoresOutput(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobName := test.UniqueIdentifer()
+	logFile, logger := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir: t.TempDir(),
+		LogDir:  t.TempDir(),
+		Output: config.OutputConfig{
+			Store:      true,
+			ChunkLines: 1000,
+		},
+	}
+	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  jobName,
+		RetryAttempts:         1,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = db.UpdateJobArchive(ctx, data.UpdateJobArchiveParams{
+		ID:      jobId,
+		Archive: string(core.ArchiveFormatGzip),
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	run := execRun(
+		ctx,
+		logger,
+		jobName,
+		false,
+		conf,
+		conn,
+		logFile,
+		[]string{"./testdata/script-fails"},
+		nil,
+		nil,
+		0,
+	)
+	runOutput, err := db.GetRunOutput(ctx, run.Run.ID)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	assert.True(t, strings.HasSuffix(run.Run.LogFile, ".log.gz"))
+	assert.Equal(t, []string{"This script will fail\n"}, runOutput)
+}
+
+func Test_execRunRefreshesMetrics(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	jobName := test.UniqueIdentifer()
+	logFile, logger := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir: t.TempDir(),
+		LogDir:  t.TempDir(),
+		Metrics: config.MetricsConfig{
+			File:    path.Join(t.TempDir(), "troc.prom"),
+			Refresh: true,
+		},
+	}
+
+	execRun(
+		ctx,
+		logger,
+		jobName,
+		false,
+		conf,
+		conn,
+		logFile,
+		[]string{"./testdata/script-fails"},
+		nil,
+		nil,
+		0,
+	)
+
+	test.AssertFileExists(t, conf.Metrics.File)
+	content, err := os.ReadFile(conf.Metrics.File)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Contains(t, string(content), `troc_job_last_run_status{job="`+jobName+`",status="Failed"} 1`)
+	assert.Contains(t, string(content), `troc_job_running{job="`+jobName+`"} 0`)
+}
+
+func Test_execRunQueued(t *testing.T) {
+	blocked := make(chan data.GetRunRow)
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobName := test.UniqueIdentifer()
+	logFile1, logger1 := test.CreateSysLogFile(t)
+	logFile2, logger2 := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir: t.TempDir(),
+		LogDir:  t.TempDir(),
+	}
+	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  jobName,
+		RetryAttempts:         1,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = db.UpdateJobConcurrency(ctx, data.UpdateJobConcurrencyParams{
+		ID:               jobId,
+		Concurrency:      string(core.ConcurrencyQueue),
+		ConcurrencyLimit: 1,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	go func() {
+		blocked <- execRun(
+			ctx,
+			logger1,
+			jobName,
+			false,
+			conf,
+			conn,
+			logFile1,
+			[]string{"./testdata/script-sleeps"},
+			nil,
+			nil,
+			0,
+		)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	queued := make(chan data.GetRunRow)
+	go func() {
+		queued <- execRun(
+			ctx,
+			logger2,
+			jobName,
+			false,
+			conf,
+			conn,
+			logFile2,
+			[]string{"./testdata/script-passes"},
+			nil,
+			nil,
+			0,
+		)
+	}()
+	assert.Eventually(t, func() bool {
+		runs, err := core.GetRuns(ctx, db, core.RunFilter{
+			JobName: "",
+			Status:  string(core.RunStatusQueued),
+			Host:    "",
+			Sort:    "",
+			Limit:   -1,
+		})
+		return err == nil && len(runs) == 1
+	}, time.Second, 10*time.Millisecond)
+	firstRun := <-blocked
+	queuedRun := <-queued
+
+	queuedLog := test.NewLogFromFileOrFail(queuedRun.Run.ExecLogFile)
+	assert.Equal(t, "Succeeded", firstRun.Run.Status)
+	assert.Equal(t, "Succeeded", queuedRun.Run.Status)
+	assert.Equal(t, int64(os.Getpid()), queuedRun.Run.ExecPid.Int64)
+	test.AssertFileContents(t, "Output line 1\nOutput line 2\n", queuedRun.Run.LogFile)
+	assert.False(t, queuedRun.Run.StartTime.Before(firstRun.Run.EndTime.Time))
+	runQueued := test.GetEventOrFail(t, core.EventRunQueued, queuedLog)
+	assert.Equal(t, queuedRun.Run.ID, runQueued.RunId)
+	runCreated := test.GetEventOrFail(t, core.EventRunCreated, queuedLog)
+	assert.Equal(t, queuedRun.Run.ID, runCreated.RunId)
+}
+
+func Test_execRunQueueTimedOut(t *testing.T) {
+	blocked := make(chan data.GetRunRow)
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobName := test.UniqueIdentifer()
+	logFile1, logger1 := test.CreateSysLogFile(t)
+	logFile2, logger2 := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir: t.TempDir(),
+		LogDir:  t.TempDir(),
+	}
+	go func() {
+		blocked <- execRun(
+			ctx,
+			logger1,
+			jobName,
+			false,
+			conf,
+			conn,
+			logFile1,
+			[]string{"./testdata/script-sleeps"},
+			nil,
+			nil,
+			0,
+		)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	queueTimeout := 200 * time.Millisecond
+	skippedRun := execRun(
+		ctx,
+		logger2,
+		jobName,
+		false,
+		conf,
+		conn,
+		logFile2,
+		[]string{"./testdata/script-passes"},
+		nil,
+		&concurrencyOverride{Mode: core.ConcurrencyQueue, QueueTimeout: &queueTimeout},
+		0,
+	)
+	<-blocked
+	runs, err := core.GetRuns(ctx, db, core.RunFilter{
+		JobName: "",
+		Status:  "",
+		Host:    "",
+		Sort:    "",
+		Limit:   -1,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	skippedLog := test.NewLogFromFileOrFail(skippedRun.Run.ExecLogFile)
+	assert.Equal(t, 2, len(runs))
+	assert.Equal(t, "Skipped", skippedRun.Run.Status)
+	assert.Equal(t, "", skippedRun.Run.LogFile)
+	runQueued := test.GetEventOrFail(t, core.EventRunQueued, skippedLog)
+	assert.Equal(t, skippedRun.Run.ID, runQueued.RunId)
+	runSkipped := test.GetEventOrFail(t, core.EventRunSkipped, skippedLog)
+	assert.Equal(t, skippedRun.Run.ID, runSkipped.RunId)
+}
+
+func Test_execRunParallel(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	jobName := test.UniqueIdentifer()
+	conf := config.Config{
+		LockDir: t.TempDir(),
+		LogDir:  t.TempDir(),
+	}
+	parallel := &concurrencyOverride{Mode: core.ConcurrencyParallel, Limit: 2}
+	blocked := make(chan data.GetRunRow, 2)
+	for range 2 {
+		logFile, logger := test.CreateSysLogFile(t)
+		go func() {
+			blocked <- execRun(
+				ctx,
+				logger,
+				jobName,
+				false,
+				conf,
+				conn,
+				logFile,
+				[]string{"./testdata/script-sleeps"},
+				nil,
+				parallel,
+				0,
+			)
+		}()
+	}
+	time.Sleep(300 * time.Millisecond)
+	logFile, logger := test.CreateSysLogFile(t)
+	skippedRun := execRun(
+		ctx,
+		logger,
+		jobName,
+		false,
+		conf,
+		conn,
+		logFile,
+		[]string{"./testdata/script-passes"},
+		nil,
+		parallel,
+		0,
+	)
+	run1 := <-blocked
+	run2 := <-blocked
+
+	assert.Equal(t, "Skipped", skippedRun.Run.Status)
+	assert.Equal(t, "Succeeded", run1.Run.Status)
+	assert.Equal(t, "Succeeded", run2.Run.Status)
+	// The runs overlapped, rather than one waiting for the other
+	assert.True(t, run1.Run.StartTime.Before(run2.Run.EndTime.Time))
+	assert.True(t, run2.Run.StartTime.Before(run1.Run.EndTime.Time))
+	lockFiles := []string{}
+	for _, run := range []data.GetRunRow{run1, run2} {
+		execLog := test.NewLogFromFileOrFail(run.Run.ExecLogFile)
+		lockRow := test.GetInfoLogLineStartingWith(t, "Created job lock at ", execLog)
+		lockFiles = append(lockFiles, strings.Split(lockRow.Msg, "Created job lock at ")[1])
+	}
+	assert.ElementsMatch(t, []string{
+		filepath.Join(conf.LockDir, jobName+".lock"),
+		filepath.Join(conf.LockDir, jobName+".lock.2"),
+	}, lockFiles)
+}
+
+// Creates a job in the lock group, returning its name.
+func createLockGroupJob(ctx context.Context, t *testing.T, db *data.Queries, lockGroup string) string {
+	jobName := test.UniqueIdentifer()
+	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  jobName,
+		RetryAttempts:         1,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = db.AddJobLockGroup(ctx, data.AddJobLockGroupParams{
+		JobID:     jobId,
+		LockGroup: lockGroup,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return jobName
+}
+
+func Test_execRunLockGroupSkipped(t *testing.T) {
+	blocked := make(chan data.GetRunRow)
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	lockGroup := test.UniqueIdentifer()
+	jobName1 := createLockGroupJob(ctx, t, db, lockGroup)
+	jobName2 := createLockGroupJob(ctx, t, db, lockGroup)
+	logFile1, logger1 := test.CreateSysLogFile(t)
+	logFile2, logger2 := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir: t.TempDir(),
+		LogDir:  t.TempDir(),
+	}
+	go func() {
+		blocked <- execRun(
+			ctx,
+			logger1,
+			jobName1,
+			false,
+			conf,
+			conn,
+			logFile1,
+			[]string{"./testdata/script-sleeps"},
+			nil,
+			nil,
+			0,
+		)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	skippedRun := execRun(
+		ctx,
+		logger2,
+		jobName2,
+		false,
+		conf,
+		conn,
+		logFile2,
+		[]string{"./testdata/script-passes"},
+		nil,
+		nil,
+		0,
+	)
+	successfulRun := <-blocked
+
+	skippedLog := test.NewLogFromFileOrFail(skippedRun.Run.ExecLogFile)
+	assert.Equal(t, "Skipped", skippedRun.Run.Status)
+	assert.Equal(t, "Succeeded", successfulRun.Run.Status)
+	assert.Equal(t, successfulRun.Run.ID, skippedRun.Run.BlockedByRunID.Int64)
+	assert.Equal(t, jobName1, skippedRun.Run.BlockedByJob.String)
+	assert.Equal(t, lockGroup, skippedRun.Run.BlockedByLockGroup.String)
+	runSkipped := test.GetEventOrFail(t, core.EventRunSkipped, skippedLog)
+	assert.Equal(t, skippedRun.Run.ID, runSkipped.RunId)
+	assert.False(t, successfulRun.Run.BlockedByRunID.Valid)
+}
+
+func Test_execRunLockGroupQueued(t *testing.T) {
+	blocked := make(chan data.GetRunRow)
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	lockGroup := test.UniqueIdentifer()
+	jobName1 := createLockGroupJob(ctx, t, db, lockGroup)
+	jobName2 := createLockGroupJob(ctx, t, db, lockGroup)
+	logFile1, logger1 := test.CreateSysLogFile(t)
+	logFile2, logger2 := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir: t.TempDir(),
+		LogDir:  t.TempDir(),
+	}
+	go func() {
+		blocked <- execRun(
+			ctx,
+			logger1,
+			jobName1,
+			false,
+			conf,
+			conn,
+			logFile1,
+			[]string{"./testdata/script-sleeps"},
+			nil,
+			nil,
+			0,
+		)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	queuedRun := execRun(
+		ctx,
+		logger2,
+		jobName2,
+		false,
+		conf,
+		conn,
+		logFile2,
+		[]string{"./testdata/script-passes"},
+		nil,
+		&concurrencyOverride{Mode: core.ConcurrencyQueue},
+		0,
+	)
+	firstRun := <-blocked
+
+	assert.Equal(t, "Succeeded", firstRun.Run.Status)
+	assert.Equal(t, "Succeeded", queuedRun.Run.Status)
+	assert.False(t, queuedRun.Run.StartTime.Before(firstRun.Run.EndTime.Time))
+	assert.False(t, queuedRun.Run.BlockedByRunID.Valid)
+}
+
+func Test_execRunRerun(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	jobName := test.UniqueIdentifer()
+	logFile1, logger1 := test.CreateSysLogFile(t)
+	logFile2, logger2 := test.CreateSysLogFile(t)
+	conf := config.Config{
+		LockDir: t.TempDir(),
+		LogDir:  t.TempDir(),
+	}
+	originalRun := execRun(
+		ctx,
+		logger1,
+		jobName,
+		false,
+		conf,
+		conn,
+		logFile1,
+		[]string{"./testdata/script-fails"},
+		nil,
+		nil,
+		0,
+	)
+	rerun := execRun(
+		ctx,
+		logger2,
+		jobName,
+		false,
+		conf,
+		conn,
+		logFile2,
+		[]string{originalRun.Run.Command.String},
+		nil,
+		nil,
+		originalRun.Run.ID,
+	)
+
+	assert.Equal(t, "Failed", originalRun.Run.Status)
+	assert.Equal(t, "./testdata/script-fails", originalRun.Run.Command.String)
+	assert.False(t, originalRun.Run.RerunOfRunID.Valid)
+	assert.Equal(t, "Failed", rerun.Run.Status)
+	assert.NotEqual(t, originalRun.Run.ID, rerun.Run.ID)
+	assert.Equal(t, originalRun.Run.Command.String, rerun.Run.Command.String)
+	assert.Equal(t, originalRun.Run.ID, rerun.Run.RerunOfRunID.Int64)
+	assert.Equal(t, originalRun.Job.ID, rerun.Job.ID)
+}
@@ -0,0 +1,373 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+)
+
+// How often a run waiting for the lock of its job tries to take it.
+const lockRetryDelay = 100 * time.Millisecond
+
+type concurrencyPolicy struct {
+	Mode core.ConcurrencyMode
+	// Number of runs of the job allowed at once, which is only more than 1
+	// for parallel
+	Limit int64
+	// How long a queued run waits for the lock. 0 waits indefinitely
+	QueueTimeout time.Duration
+}
+
+// Overrides of the concurrency policy of a job given to exec. Unset fields
+// keep the job's setting.
+type concurrencyOverride struct {
+	Mode         core.ConcurrencyMode
+	Limit        int64
+	QueueTimeout *time.Duration
+}
+
+func newConcurrencyPolicy(job data.Job, override *concurrencyOverride) concurrencyPolicy {
+	policy := concurrencyPolicy{
+		Mode:  core.ConcurrencyMode(job.Concurrency),
+		Limit: max(job.ConcurrencyLimit, 1),
+	}
+	if policy.Mode == "" {
+		policy.Mode = core.ConcurrencySkip
+	}
+	if job.QueueTimeoutSeconds.Valid {
+		policy.QueueTimeout = time.Duration(job.QueueTimeoutSeconds.Int64) * time.Second
+	}
+	if override == nil {
+		return policy
+	}
+	if override.Mode != "" {
+		policy.Mode = override.Mode
+		policy.Limit = max(override.Limit, 1)
+	}
+	if override.QueueTimeout != nil {
+		policy.QueueTimeout = *override.QueueTimeout
+	}
+	return policy
+}
+
+// Returns the lock files of the job, one for each run allowed at once. The
+// first is the lock file of jobs that allow a single run, so a run of the job
+// still excludes the others while its policy is changed.
+func (p concurrencyPolicy) lockFiles(lockDir string, jobName string) []string {
+	files := []string{filepath.Join(lockDir, jobName+".lock")}
+	if p.Mode != core.ConcurrencyParallel {
+		return files
+	}
+	for slot := int64(2); slot <= p.Limit; slot++ {
+		files = append(files, filepath.Join(lockDir, jobName+".lock."+strconv.FormatInt(slot, 10)))
+	}
+	return files
+}
+
+// A lock a run must hold to start.
+type runLock struct {
+	// Name of the lock group, or "" for the lock of the job itself
+	Group string
+	// Lock files, any one of which the run may hold. Only the lock of a job
+	// with a parallel policy has more than one
+	Files []string
+}
+
+// Returns why a run can't take the lock.
+func (l runLock) heldReason() string {
+	if l.Group == "" {
+		return "Job is already running."
+	}
+	return "Lock group " + l.Group + " is held by another job."
+}
+
+// Returns the locks a run of the job must hold: the lock of the job, followed
+// by the locks of its lock groups sorted by name. Every run takes its locks
+// in this order, so runs waiting for each other's locks can't deadlock.
+func (p concurrencyPolicy) runLocks(lockDir string, jobName string, lockGroups []string) []runLock {
+	locks := []runLock{{Files: p.lockFiles(lockDir, jobName)}}
+	for _, group := range slices.Sorted(slices.Values(lockGroups)) {
+		locks = append(locks, runLock{
+			Group: group,
+			Files: []string{filepath.Join(lockGroupDir(lockDir), group+".lock")},
+		})
+	}
+	return locks
+}
+
+// Returns the directory of lock group lock files, which is separate from job
+// lock files so a group can't share a lock with a job of the same name.
+func lockGroupDir(lockDir string) string {
+	return filepath.Join(lockDir, "lock-groups")
+}
+
+// Takes the locks of a run of the job, applying its concurrency policy if any
+// are held. Lock groups are only waited for by a queue policy; replace only
+// terminates runs of the job itself. Returns the locks taken, or the lock that
+// blocked the run, along with the run created for a queue policy while it
+// waited.
+func acquireLocks(
+	ctx context.Context,
+	logger *slog.Logger,
+	conf config.Config,
+	db *data.Queries,
+	job data.Job,
+	policy concurrencyPolicy,
+	locks []runLock,
+	execLogFile string,
+	command runCommand,
+	terminated <-chan struct{},
+) ([]*flock.Flock, *runLock, int64) {
+	held, blocked := tryLocks(logger, locks)
+	if blocked == nil {
+		return held, nil, 0
+	}
+	switch policy.Mode {
+	case core.ConcurrencyQueue:
+		queuedRunId := queueRun(ctx, logger, conf, db, job, execLogFile, blocked.heldReason(), command)
+		held, blocked = waitForLocks(ctx, logger, locks, policy.QueueTimeout, terminated)
+		return held, blocked, queuedRunId
+	case core.ConcurrencyReplace:
+		if blocked.Group != "" {
+			return nil, blocked, 0
+		}
+		jobLock := flock.New(locks[0].Files[0])
+		if !replaceRuns(ctx, logger, conf, db, job, jobLock, terminated) {
+			return nil, blocked, 0
+		}
+		held, blocked = tryLocks(logger, locks[1:])
+		if blocked != nil {
+			unlockAll(logger, []*flock.Flock{jobLock})
+			return nil, blocked, 0
+		}
+		return append([]*flock.Flock{jobLock}, held...), nil, 0
+	}
+	return nil, blocked, 0
+}
+
+// Takes each lock in order without waiting. If one is held, the locks already
+// taken are released and the held lock is returned.
+func tryLocks(logger *slog.Logger, locks []runLock) ([]*flock.Flock, *runLock) {
+	var held []*flock.Flock
+	for i, lock := range locks {
+		f := tryLock(logger, lock.Files)
+		if f == nil {
+			unlockAll(logger, held)
+			return nil, &locks[i]
+		}
+		held = append(held, f)
+	}
+	return held, nil
+}
+
+// Waits for each lock in order, up to timeout in total, or indefinitely if
+// timeout is 0. If a lock isn't taken in time, the locks already taken are
+// released and the lock is returned.
+func waitForLocks(
+	ctx context.Context,
+	logger *slog.Logger,
+	locks []runLock,
+	timeout time.Duration,
+	terminated <-chan struct{},
+) ([]*flock.Flock, *runLock) {
+	deadline := time.Now().Add(timeout)
+	var held []*flock.Flock
+	for i, lock := range locks {
+		f := tryLock(logger, lock.Files)
+		if f == nil {
+			var remaining time.Duration
+			if timeout > 0 {
+				remaining = time.Until(deadline)
+			}
+			f = flock.New(lock.Files[0])
+			if (timeout > 0 && remaining <= 0) || !waitForLock(ctx, logger, f, remaining, terminated) {
+				unlockAll(logger, held)
+				return nil, &locks[i]
+			}
+		}
+		held = append(held, f)
+	}
+	return held, nil
+}
+
+func unlockAll(logger *slog.Logger, held []*flock.Flock) {
+	for _, f := range held {
+		if err := f.Unlock(); err != nil {
+			logger.Error("Unable to unlock "+f.Path(), "error", err)
+		}
+	}
+}
+
+// Returns the Running run on this host holding a lock that blocked a run of
+// the job. That is the latest run of the job, or of a job in the lock group.
+// The result is left blank if there is no such run, eg. as it has since ended.
+func findBlocker(
+	ctx context.Context,
+	logger *slog.Logger,
+	conf config.Config,
+	db *data.Queries,
+	job data.Job,
+	lock runLock,
+) data.UpdateRunBlockedByParams {
+	var blocker data.UpdateRunBlockedByParams
+	var run data.Run
+	var jobName string
+	if lock.Group == "" {
+		runs, err := db.GetJobRunsWithStatus(ctx, data.GetJobRunsWithStatusParams{
+			Status: string(core.RunStatusRunning),
+			JobID:  job.ID,
+			Host:   conf.Notify.Hostname,
+		})
+		if err != nil {
+			logger.Error("Unable to get the run holding the lock", "error", err)
+			return blocker
+		}
+		if len(runs) == 0 {
+			return blocker
+		}
+		run, jobName = runs[0].Run, runs[0].Job.Name
+	} else {
+		blocker.BlockedByLockGroup = sql.NullString{String: lock.Group, Valid: true}
+		runs, err := db.GetLockGroupRuns(ctx, data.GetLockGroupRunsParams{
+			LockGroup: lock.Group,
+			Host:      conf.Notify.Hostname,
+		})
+		if err != nil {
+			logger.Error("Unable to get the run holding the lock", "error", err)
+			return blocker
+		}
+		if len(runs) == 0 {
+			return blocker
+		}
+		run, jobName = runs[0].Run, runs[0].Job.Name
+	}
+	blocker.BlockedByRunID = sql.NullInt64{Int64: run.ID, Valid: true}
+	blocker.BlockedByJob = sql.NullString{String: jobName, Valid: true}
+	return blocker
+}
+
+// Takes the first of the lock files that isn't held. Returns nil if they are
+// all held.
+func tryLock(logger *slog.Logger, lockFiles []string) *flock.Flock {
+	for _, lockFile := range lockFiles {
+		lock := flock.New(lockFile)
+		locked, err := lock.TryLock()
+		if err != nil {
+			logger.Error("Unable to lock "+lockFile, "error", err)
+			continue
+		}
+		if locked {
+			return lock
+		}
+	}
+	return nil
+}
+
+// Waits up to timeout for the lock, or indefinitely if timeout is 0. Returns
+// false if the timeout elapses, or terminated is closed, first.
+func waitForLock(
+	ctx context.Context,
+	logger *slog.Logger,
+	lock *flock.Flock,
+	timeout time.Duration,
+	terminated <-chan struct{},
+) bool {
+	var waitCtx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		waitCtx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		waitCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	go func() {
+		select {
+		case <-terminated:
+			cancel()
+		case <-waitCtx.Done():
+		}
+	}()
+	locked, err := lock.TryLockContext(waitCtx, lockRetryDelay)
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		logger.Error("Unable to lock "+lock.Path(), "error", err)
+	}
+	return locked
+}
+
+// Terminates the running runs of the job on this host so the new run can
+// take their lock. The runs are sent SIGTERM, followed by SIGKILL if the lock
+// is still held after killgrace. Returns whether the lock was taken.
+func replaceRuns(
+	ctx context.Context,
+	logger *slog.Logger,
+	conf config.Config,
+	db *data.Queries,
+	job data.Job,
+	lock *flock.Flock,
+	terminated <-chan struct{},
+) bool {
+	runs, err := db.GetJobRunsWithStatus(ctx, data.GetJobRunsWithStatusParams{
+		Status: string(core.RunStatusRunning),
+		JobID:  job.ID,
+		Host:   conf.Notify.Hostname,
+	})
+	if err != nil {
+		logger.Error("Unable to get the runs to replace", "error", err)
+		return false
+	}
+	// The runs need a moment to release the lock once they exit, even with
+	// no grace period
+	grace := max(conf.KillGrace, time.Second)
+	for _, sig := range []syscall.Signal{syscall.SIGTERM, syscall.SIGKILL} {
+		for _, row := range runs {
+			pid, err := signalReplacedRun(row.Run, sig)
+			if err != nil {
+				continue
+			}
+			if sig == syscall.SIGTERM {
+				core.LogRunReplaced(logger, row.Run.ID, job.Name, pid)
+				core.LogRunSentSigterm(logger, row.Run.ID, job.Name, pid)
+			} else {
+				core.LogRunSentSigkill(logger, row.Run.ID, job.Name, pid)
+			}
+		}
+		if waitForLock(ctx, logger, lock, grace, terminated) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sends a signal to replace a run, returning the PID it was sent to. SIGTERM
+// is sent to the troc exec holding the lock, which forwards it to the command
+// or stops waiting to retry. As SIGKILL can't be forwarded it is sent to the
+// command instead, so troc exec still ends the run and releases its lock, or
+// to troc exec if the command has exited. Runs started before troc exec
+// recorded its PID only have their command signalled.
+func signalReplacedRun(run data.Run, sig syscall.Signal) (int, error) {
+	isCommandRunning := run.Pid.Valid && core.IsSameProcess(int(run.Pid.Int64), run.PidStartTime)
+	if run.ExecPid.Valid && (sig != syscall.SIGKILL || !isCommandRunning) {
+		pid := int(run.ExecPid.Int64)
+		if !core.IsSameProcess(pid, run.ExecPidStartTime) {
+			return 0, os.ErrProcessDone
+		}
+		return pid, syscall.Kill(pid, sig)
+	}
+	// Runs that haven't started their process yet can't be signalled
+	if !run.Pid.Valid {
+		return 0, errors.New("run does not have a PID associated with it")
+	}
+	return int(run.Pid.Int64), core.SignalRun(run, sig)
+}
@@ -0,0 +1,67 @@
+/*
+Copyright © 2025 Samuel Carswell <samuelrcarswell@gmail.com>
+*/
+package cmd
+
+import (
+	"log/slog"
+
+	jobcmd "github.com/samcarswell/trochilus/cmd/job"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/notify"
+	"github.com/samcarswell/trochilus/opts"
+	"github.com/spf13/cobra"
+)
+
+// Part of job, but defined with exec as the job's command is executed the same way.
+var jobRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run the command of a job",
+	Long: `
+Run the command of a job.
+The command set with job [add|update] --command is run as a new run of the job,
+the same as exec --name with no command.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := slog.Default()
+		jobName := opts.GetStringOptOrExit(cmd, nameOpt)
+		isNotify := opts.GetBoolOptOrExit(cmd, notifyOpt)
+		conf := config.GetConfig()
+		conn := config.GetDatabaseConn(cmd.Context())
+		if isNotify {
+			if err := notify.ValidateConfig(conf, nil); err != nil {
+				core.LogErrorAndExit(logger, err)
+			}
+		}
+
+		command := getJobCommandOrExit(cmd.Context(), logger, conn, jobName)
+		logFile := config.GetLogFileOrExit(logger, cmd.Context())
+
+		completedRun := execRun(
+			cmd.Context(),
+			logger,
+			jobName,
+			isNotify,
+			conf,
+			conn,
+			logFile,
+			[]string{command},
+			nil,
+			nil,
+			0,
+		)
+		data := core.NewRunShow(completedRun.Run, completedRun.Job.Name, conf.LocalTime)
+		core.PrintJson(data)
+	},
+}
+
+func init() {
+	jobcmd.JobCmd.AddCommand(jobRunCmd)
+
+	jobRunCmd.Flags().String(nameOpt, "", "Job Name (required)")
+	jobRunCmd.Flags().Bool(notifyOpt, false, "Notifies of the run's success")
+	if err := jobRunCmd.MarkFlagRequired(nameOpt); err != nil {
+		core.LogErrorAndExit(slog.Default(), err)
+	}
+}
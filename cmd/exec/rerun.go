@@ -0,0 +1,86 @@
+/*
+Copyright © 2025 Samuel Carswell <samuelrcarswell@gmail.com>
+*/
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	runcmd "github.com/samcarswell/trochilus/cmd/run"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/notify"
+	"github.com/samcarswell/trochilus/opts"
+	"github.com/spf13/cobra"
+)
+
+// Part of run, but defined with exec as a rerun is executed the same way.
+var rerunCmd = &cobra.Command{
+	Use:   "rerun",
+	Short: "Rerun the command of a past run",
+	Long: `
+Rerun the command of a past run.
+The command the run executed is run again as a new run of its job, which records the run it reruns.
+The job's current settings, such as its timeout, retries and concurrency, apply to the new run.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := slog.Default()
+		runId := opts.GetInt64OrExit(cmd, "run-id")
+		isNotify := opts.GetBoolOptOrExit(cmd, notifyOpt)
+		conf := config.GetConfig()
+		conn := config.GetDatabaseConn(cmd.Context())
+		if isNotify {
+			if err := notify.ValidateConfig(conf, nil); err != nil {
+				core.LogErrorAndExit(logger, err)
+			}
+		}
+
+		runRow, err := data.New(conn).GetRun(cmd.Context(), runId)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				core.LogErrorAndExit(logger, errors.New("run with id "+strconv.FormatInt(runId, 10)+" not found"))
+			} else {
+				core.LogErrorAndExit(logger, err)
+			}
+		}
+		if !runRow.Run.Command.Valid {
+			core.LogErrorAndExit(logger, errors.New("run with id "+strconv.FormatInt(runId, 10)+" has no recorded command"))
+		}
+		logFile := config.GetLogFileOrExit(logger, cmd.Context())
+		logger.Info(
+			"Rerunning run "+strconv.FormatInt(runId, 10),
+			core.LogRunId(runId),
+			core.LogJobName(runRow.Job.Name),
+		)
+
+		completedRun := execRun(
+			cmd.Context(),
+			logger,
+			runRow.Job.Name,
+			isNotify,
+			conf,
+			conn,
+			logFile,
+			[]string{runRow.Run.Command.String},
+			nil,
+			nil,
+			runId,
+		)
+		data := core.NewRunShow(completedRun.Run, completedRun.Job.Name, conf.LocalTime)
+		core.PrintJson(data)
+	},
+}
+
+func init() {
+	runcmd.RunCmd.AddCommand(rerunCmd)
+
+	rerunCmd.Flags().Int64P("run-id", "r", 0, "Id of the run to rerun")
+	rerunCmd.Flags().Bool(notifyOpt, false, "Notifies of the rerun's success")
+	if err := rerunCmd.MarkFlagRequired("run-id"); err != nil {
+		core.LogErrorAndExit(slog.Default(), err)
+	}
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+)
+
+type retryPolicy struct {
+	MaxAttempts int64
+	Backoff     core.RetryBackoff
+	Delay       time.Duration
+	ExitCodes   []int64
+}
+
+func newRetryPolicy(job data.Job) retryPolicy {
+	exitCodes, err := core.ParseExitCodes(job.RetryExitCodes)
+	if err != nil {
+		// Invalid exit codes can't be stored through the cli; retry on any
+		// exit code rather than fail the run.
+		exitCodes = nil
+	}
+	return retryPolicy{
+		MaxAttempts: max(job.RetryAttempts, 1),
+		Backoff:     core.RetryBackoff(job.RetryBackoff),
+		Delay:       time.Duration(job.RetryDelaySeconds) * time.Second,
+		ExitCodes:   exitCodes,
+	}
+}
+
+// Failed runs are retried if their exit code is one of the configured exit
+// codes, or any exit code if none are configured.
+// Timed out runs are retried only if no exit codes are configured.
+// Terminated runs are never retried.
+func (p retryPolicy) shouldRetry(status core.RunStatus, exitCode sql.NullInt64) bool {
+	switch status {
+	case core.RunStatusFailed:
+		if len(p.ExitCodes) == 0 {
+			return true
+		}
+		return exitCode.Valid && slices.Contains(p.ExitCodes, exitCode.Int64)
+	case core.RunStatusTimedOut:
+		return len(p.ExitCodes) == 0
+	}
+	return false
+}
+
+// Returns the delay before the attempt following the given attempt.
+func (p retryPolicy) delay(attempt int64) time.Duration {
+	if p.Backoff == core.RetryBackoffExponential {
+		return p.Delay * time.Duration(int64(1)<<min(attempt-1, 16))
+	}
+	return p.Delay
+}
@@ -6,10 +6,12 @@ import (
 	"log/slog"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/samcarswell/trochilus/config"
 	"github.com/samcarswell/trochilus/core"
 	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/notify"
 	"github.com/samcarswell/trochilus/opts"
 	"github.com/spf13/cobra"
 )
@@ -21,11 +23,27 @@ var addCmd = &cobra.Command{
 		logger := slog.Default()
 		jobName := opts.GetStringOptOrExit(cmd, "name")
 		notifyLog := opts.GetBoolOptOrExit(cmd, "notify-log")
+		timeout := opts.GetSecondsOptOrExit(cmd, timeoutOpt)
+		retryAttempts := opts.GetRetryAttemptsOptOrExit(cmd, retryAttemptsOpt)
+		retryBackoff := opts.GetRetryBackoffOptOrExit(cmd, retryBackoffOpt)
+		retryDelay := opts.GetSecondsOptOrExit(cmd, retryDelayOpt)
+		retryExitCodes := opts.GetExitCodesOptOrExit(cmd, retryExitCodesOpt)
+		schedule := opts.GetScheduleOptOrExit(cmd, scheduleOpt)
+		grace := opts.GetSecondsOptOrExit(cmd, graceOpt)
+		notifyMode := opts.GetNotifyModeOptOrExit(cmd, notifyModeOpt)
+		notifyReminder := opts.GetSecondsOptOrExit(cmd, notifyReminderOpt)
 		queries := config.GetDatabase(cmd.Context())
 
 		newJobId, err := queries.CreateJob(cmd.Context(), data.CreateJobParams{
-			Name:             jobName,
-			NotifyLogContent: notifyLog,
+			Name:                  jobName,
+			NotifyLogContent:      notifyLog,
+			TimeoutSeconds:        timeout,
+			RetryAttempts:         retryAttempts,
+			RetryBackoff:          retryBackoff,
+			RetryDelaySeconds:     retryDelay.Int64,
+			RetryExitCodes:        retryExitCodes,
+			NotifyMode:            notifyMode,
+			NotifyReminderSeconds: notifyReminder.Int64,
 		})
 		if err != nil {
 			if strings.Contains(err.Error(), "UNIQUE constraint failed: jobs.name") {
@@ -33,6 +51,69 @@ var addCmd = &cobra.Command{
 			}
 			core.LogErrorAndExit(logger, err, errors.New("unable to create job"))
 		}
+		if schedule.Valid {
+			err = queries.UpdateJobSchedule(cmd.Context(), data.UpdateJobScheduleParams{
+				ID:                   newJobId,
+				Schedule:             schedule,
+				ScheduleGraceSeconds: grace,
+			})
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to set job schedule"))
+			}
+		}
+
+		if cmd.Flags().Changed(notifyTargetsOpt) {
+			setNotifyTargets(cmd, queries, newJobId)
+		}
+
+		if retentionOptsChanged(cmd) {
+			err = queries.UpdateJobRetention(cmd.Context(), data.UpdateJobRetentionParams{
+				ID:             newJobId,
+				KeepLast:       opts.GetCountOptOrExit(cmd, keepLastOpt),
+				KeepDays:       opts.GetCountOptOrExit(cmd, keepDaysOpt),
+				KeepFailedDays: opts.GetCountOptOrExit(cmd, keepFailedDaysOpt),
+			})
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to set job retention"))
+			}
+		}
+
+		if cmd.Flags().Changed(archiveOpt) {
+			err = queries.UpdateJobArchive(cmd.Context(), data.UpdateJobArchiveParams{
+				ID:      newJobId,
+				Archive: opts.GetArchiveFormatOptOrExit(cmd, archiveOpt),
+			})
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to set job archive"))
+			}
+		}
+
+		if concurrencyOptsChanged(cmd) {
+			mode, limit := opts.GetConcurrencyOptOrExit(cmd, concurrencyOpt)
+			err = queries.UpdateJobConcurrency(cmd.Context(), data.UpdateJobConcurrencyParams{
+				ID:                  newJobId,
+				Concurrency:         string(mode),
+				ConcurrencyLimit:    limit,
+				QueueTimeoutSeconds: opts.GetSecondsOptOrExit(cmd, queueTimeoutOpt),
+			})
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to set job concurrency"))
+			}
+		}
+
+		if cmd.Flags().Changed(lockGroupOpt) {
+			setLockGroups(cmd, queries, newJobId)
+		}
+
+		if cmd.Flags().Changed(commandOpt) {
+			err = queries.UpdateJobCommand(cmd.Context(), data.UpdateJobCommandParams{
+				ID:      newJobId,
+				Command: opts.GetCommandOptOrExit(cmd, commandOpt),
+			})
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to set job command"))
+			}
+		}
 
 		logger.Info("Job created with ID " + strconv.FormatInt(newJobId, 10) + " and name " + jobName)
 	},
@@ -42,7 +123,110 @@ func init() {
 	JobCmd.AddCommand(addCmd)
 	addCmd.Flags().String("name", "", "Job Name (required)")
 	addCmd.Flags().Bool("notify-log", false, "Includes the raw log output rather than the log filename in notification messages (default false)")
+	addCmd.Flags().Duration(timeoutOpt, 0, "Default timeout for runs of the job. eg. 30m (default no timeout)")
+	retryOpts(addCmd)
+	scheduleOpts(addCmd)
+	notifyTargetsOpts(addCmd)
+	notifyModeOpts(addCmd)
+	retentionOpts(addCmd)
+	archiveOpts(addCmd)
+	concurrencyOpts(addCmd)
+	lockGroupOpts(addCmd)
+	commandOpts(addCmd)
 	if err := addCmd.MarkFlagRequired("name"); err != nil {
 		log.Fatalf("Unable to mark name as required %s", err)
 	}
 }
+
+func retryOpts(cmd *cobra.Command) {
+	cmd.Flags().Int64(retryAttemptsOpt, 1, "Maximum number of attempts for a failed run, including the first")
+	cmd.Flags().String(retryBackoffOpt, string(core.RetryBackoffFixed), "Delay between attempts (fixed|exponential)")
+	cmd.Flags().Duration(retryDelayOpt, 0, "Delay before retrying a failed run. Doubled for each attempt with exponential backoff")
+	cmd.Flags().Int64Slice(retryExitCodesOpt, nil, "Only retry runs that failed with these exit codes. eg. 1,75 (default any exit code)")
+}
+
+func scheduleOpts(cmd *cobra.Command) {
+	cmd.Flags().String(scheduleOpt, "", "Cron expression the job is expected to run on, checked by troc check. eg. \"*/5 * * * *\"")
+	cmd.Flags().Duration(graceOpt, 0, "How long after the scheduled time a run may start before the job is missed (default check.grace)")
+}
+
+func notifyTargetsOpts(cmd *cobra.Command) {
+	cmd.Flags().StringSlice(notifyTargetsOpt, nil, "Names of the notify targets to send notifications to. eg. slack,ops-webhook. Empty sends to every target (default every target)")
+}
+
+func notifyModeOpts(cmd *cobra.Command) {
+	cmd.Flags().String(notifyModeOpt, string(core.NotifyModeAlways), "When runs are notified (always|on-change|on-failure-with-reminder). on-change only notifies the first failure and the first success after a failure")
+	cmd.Flags().Duration(notifyReminderOpt, time.Hour, "How often a job that is still failing is notified with on-failure-with-reminder")
+}
+
+func retentionOpts(cmd *cobra.Command) {
+	cmd.Flags().Int64(keepLastOpt, 0, "Number of runs troc prune keeps. 0 uses retention.keeplast")
+	cmd.Flags().Int64(keepDaysOpt, 0, "Number of days troc prune keeps runs for. 0 uses retention.keepdays")
+	cmd.Flags().Int64(keepFailedDaysOpt, 0, "Number of days troc prune keeps failed runs for. 0 uses retention.keepfaileddays")
+}
+
+func retentionOptsChanged(cmd *cobra.Command) bool {
+	return cmd.Flags().Changed(keepLastOpt) ||
+		cmd.Flags().Changed(keepDaysOpt) ||
+		cmd.Flags().Changed(keepFailedDaysOpt)
+}
+
+func archiveOpts(cmd *cobra.Command) {
+	cmd.Flags().String(archiveOpt, string(core.ArchiveFormatNone), "Compresses the log of each finished run into archive.dir (none|gzip|zstd). zstd requires the zstd command")
+}
+
+func concurrencyOpts(cmd *cobra.Command) {
+	cmd.Flags().String(concurrencyOpt, string(core.ConcurrencySkip), "What to do when a run starts while the job is already running (skip|queue|replace|parallel:N). parallel:N allows up to N runs at once")
+	cmd.Flags().Duration(queueTimeoutOpt, 0, "How long a queued run waits for the job to stop running before it is skipped. 0 waits indefinitely")
+}
+
+func concurrencyOptsChanged(cmd *cobra.Command) bool {
+	return cmd.Flags().Changed(concurrencyOpt) ||
+		cmd.Flags().Changed(queueTimeoutOpt)
+}
+
+func lockGroupOpts(cmd *cobra.Command) {
+	cmd.Flags().StringSlice(lockGroupOpt, nil, "Names of lock groups the job shares with other jobs. A run of the job doesn't overlap runs of other jobs in any of its groups. eg. pg-main. Empty removes the job from every group")
+}
+
+func commandOpts(cmd *cobra.Command) {
+	cmd.Flags().String(commandOpt, "", "Command run by troc exec --name when no command is given. eg. \"rsync -avh /src /dest\". Empty removes the command")
+}
+
+// Replaces the lock groups of a job with the ones given by the option.
+func setLockGroups(cmd *cobra.Command, queries *data.Queries, jobId int64) {
+	logger := slog.Default()
+	groups := opts.GetLockGroupsOptOrExit(cmd, lockGroupOpt)
+	err := queries.DeleteJobLockGroups(cmd.Context(), jobId)
+	if err != nil {
+		core.LogErrorAndExit(logger, err, errors.New("unable to update job lock groups"))
+	}
+	for _, group := range groups {
+		err := queries.AddJobLockGroup(cmd.Context(), data.AddJobLockGroupParams{
+			JobID:     jobId,
+			LockGroup: group,
+		})
+		if err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to update job lock groups"))
+		}
+	}
+}
+
+// Replaces the notify targets of a job with the ones given by the option.
+func setNotifyTargets(cmd *cobra.Command, queries *data.Queries, jobId int64) {
+	logger := slog.Default()
+	targets := opts.GetNotifyTargetsOptOrExit(cmd, notifyTargetsOpt, notify.TargetNames(config.GetConfig().Notify))
+	err := queries.DeleteJobNotifyTargets(cmd.Context(), jobId)
+	if err != nil {
+		core.LogErrorAndExit(logger, err, errors.New("unable to update job notify targets"))
+	}
+	for _, target := range targets {
+		err := queries.AddJobNotifyTarget(cmd.Context(), data.AddJobNotifyTargetParams{
+			JobID:      jobId,
+			TargetName: target,
+		})
+		if err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to update job notify targets"))
+		}
+	}
+}
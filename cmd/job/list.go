@@ -4,11 +4,16 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/samcarswell/trochilus/config"
 	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
 	"github.com/samcarswell/trochilus/opts"
+	"github.com/samcarswell/trochilus/remote"
+	"github.com/samcarswell/trochilus/stats"
 	"github.com/spf13/cobra"
 )
 
@@ -21,37 +26,125 @@ var listCmd = &cobra.Command{
 		return opts.FormatTableOptValidate(cmd, format)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		queries := config.GetDatabase(cmd.Context())
+		listStats := opts.GetBoolOptOrExit(cmd, statsOpt)
+		conf := config.GetConfig()
+		var rows []core.JobShow
+		if t := remote.GetRemoteOptOrExit(cmd, conf.Remotes); t != nil {
+			rows = remote.JsonOrExit[[]core.JobShow](cmd, t)
+		} else {
+			rows = listJobs(cmd, conf, listStats)
+		}
 
-		jobRows, err := queries.GetJobs(context.Background())
-		if err != nil {
-			core.LogErrorAndExit(slog.Default(), err, errors.New("unable to get jobs"))
+		headers := []string{
+			"ID", "Name", "Notify Log Content", "Timeout",
+			"Retry Attempts", "Retry Backoff", "Retry Delay", "Retry Exit Codes",
+			"Schedule", "Grace", "Notify Targets", "Notify Mode", "Notify Reminder",
+			"Keep Last", "Keep Days", "Keep Failed Days", "Archive",
+			"Concurrency", "Queue Timeout", "Lock Groups",
 		}
-		var rows = []core.JobShow{}
-		for _, job := range jobRows {
-			rows = append(rows, core.JobShow{
-				ID:               job.Job.ID,
-				Name:             job.Job.Name,
-				NotifyLogContent: job.Job.NotifyLogContent,
-			})
+		if listStats {
+			headers = append(headers,
+				"Runs", "Success 24h", "Success 7d", "Success 30d",
+				"Last Success", "Last Failure", "Streak",
+				"Duration p50", "Duration p95", "Duration Max",
+			)
 		}
-
-		t := core.NewTable(rows, rowConv, []string{
-			"ID", "Name", "Notify Log Content",
-		})
+		t := core.NewTable(rows, rowConv, headers)
 		t.Print(core.OutputFormat(format))
 	},
 }
 
+// Returns the jobs from the local database, with the statistics of their runs
+// if listStats is set.
+func listJobs(cmd *cobra.Command, conf config.Config, listStats bool) []core.JobShow {
+	queries := config.GetDatabase(cmd.Context())
+
+	jobRows, err := queries.GetJobs(context.Background())
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err, errors.New("unable to get jobs"))
+	}
+	now := time.Now()
+	var rows = []core.JobShow{}
+	for _, job := range jobRows {
+		row := newJobShow(cmd.Context(), queries, job.Job)
+		if listStats {
+			row.Stats = getJobStatsShow(cmd.Context(), queries, job.Job.ID, now, conf.LocalTime)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
 func rowConv(row core.JobShow, _ core.OutputFormat) table.Row {
-	return table.Row{
+	tableRow := table.Row{
 		row.ID,
 		row.Name,
 		row.NotifyLogContent,
+		row.Timeout,
+		row.RetryAttempts,
+		row.RetryBackoff,
+		row.RetryDelay,
+		row.RetryExitCodes,
+		row.Schedule,
+		row.ScheduleGrace,
+		strings.Join(row.NotifyTargets, ","),
+		row.NotifyMode,
+		row.NotifyReminder,
+		row.KeepLast,
+		row.KeepDays,
+		row.KeepFailedDays,
+		row.Archive,
+		row.Concurrency,
+		row.QueueTimeout,
+		strings.Join(row.LockGroups, ","),
+	}
+	if row.Stats != nil {
+		tableRow = append(tableRow,
+			row.Stats.TotalRuns,
+			row.Stats.SuccessRate24h,
+			row.Stats.SuccessRate7d,
+			row.Stats.SuccessRate30d,
+			row.Stats.LastSuccess,
+			row.Stats.LastFailure,
+			core.FormatStreak(row.Stats.StreakStatus, row.Stats.StreakLength),
+			row.Stats.DurationP50,
+			row.Stats.DurationP95,
+			row.Stats.DurationMax,
+		)
+	}
+	return tableRow
+}
+
+func newJobShow(ctx context.Context, queries *data.Queries, job data.Job) core.JobShow {
+	notifyTargets, err := queries.GetJobNotifyTargets(ctx, job.ID)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err, errors.New("unable to get job notify targets"))
+	}
+	lockGroups, err := queries.GetJobLockGroups(ctx, job.ID)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err, errors.New("unable to get job lock groups"))
+	}
+	return core.NewJobShow(job, notifyTargets, lockGroups)
+}
+
+func getJobStatsShow(
+	ctx context.Context,
+	queries *data.Queries,
+	jobId int64,
+	now time.Time,
+	useLocalTime bool,
+) *core.JobStatsShow {
+	jobStats, err := stats.ForJob(ctx, queries, jobId, now)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err, errors.New("unable to get job stats"))
 	}
+	show := jobStats.Show(useLocalTime)
+	return &show
 }
 
 func init() {
 	JobCmd.AddCommand(listCmd)
+	listCmd.Flags().Bool(statsOpt, false, "Adds columns with statistics of each job's runs")
 	opts.FormatTableOpt(listCmd, &format)
+	remote.RemoteOpt(listCmd)
 }
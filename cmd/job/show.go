@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/opts"
+	"github.com/samcarswell/trochilus/remote"
+	"github.com/spf13/cobra"
+)
+
+var showFormat string
+
+type jobField struct {
+	Name  string
+	Value any
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show details and statistics of a job",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return opts.FormatTableOptValidate(cmd, showFormat)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		conf := config.GetConfig()
+		var show core.JobShow
+		if t := remote.GetRemoteOptOrExit(cmd, conf.Remotes); t != nil {
+			show = remote.JsonOrExit[core.JobShow](cmd, t)
+		} else {
+			show = showJob(cmd, conf)
+		}
+
+		if core.OutputFormat(showFormat) == core.FormatJson {
+			core.PrintJson(show)
+			return
+		}
+		t := core.NewTable(jobFields(show), func(field jobField, _ core.OutputFormat) table.Row {
+			return table.Row{field.Name, field.Value}
+		}, []string{"Field", "Value"})
+		t.Print(core.OutputFormat(showFormat))
+	},
+}
+
+// Returns the details and statistics of the job from the local database.
+func showJob(cmd *cobra.Command, conf config.Config) core.JobShow {
+	logger := slog.Default()
+	jobName := opts.GetStringOptOrExit(cmd, "name")
+	queries := config.GetDatabase(cmd.Context())
+
+	job, err := queries.GetJob(cmd.Context(), jobName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			core.LogErrorAndExit(logger, errors.New("job with name '"+jobName+"' not found"))
+		} else {
+			core.LogErrorAndExit(logger, err)
+		}
+	}
+	show := newJobShow(cmd.Context(), queries, job.Job)
+	show.Stats = getJobStatsShow(cmd.Context(), queries, job.Job.ID, time.Now(), conf.LocalTime)
+	return show
+}
+
+// Returns the details and statistics of a job as a list of fields, for
+// formats that display the job as a table.
+func jobFields(show core.JobShow) []jobField {
+	fields := []jobField{
+		{"ID", show.ID},
+		{"Name", show.Name},
+		{"Notify Log Content", show.NotifyLogContent},
+		{"Timeout", show.Timeout},
+		{"Retry Attempts", show.RetryAttempts},
+		{"Retry Backoff", show.RetryBackoff},
+		{"Retry Delay", show.RetryDelay},
+		{"Retry Exit Codes", show.RetryExitCodes},
+		{"Schedule", show.Schedule},
+		{"Grace", show.ScheduleGrace},
+		{"Notify Targets", strings.Join(show.NotifyTargets, ",")},
+		{"Notify Mode", show.NotifyMode},
+		{"Notify Reminder", show.NotifyReminder},
+		{"Keep Last", show.KeepLast},
+		{"Keep Days", show.KeepDays},
+		{"Keep Failed Days", show.KeepFailedDays},
+		{"Archive", show.Archive},
+		{"Concurrency", show.Concurrency},
+		{"Queue Timeout", show.QueueTimeout},
+		{"Lock Groups", strings.Join(show.LockGroups, ",")},
+		{"Command", show.Command},
+		{"Runs", show.Stats.TotalRuns},
+	}
+	for _, status := range core.RunStatuses {
+		fields = append(fields, jobField{string(status) + " Runs", show.Stats.StatusCounts[string(status)]})
+	}
+	return append(fields,
+		jobField{"Success Rate 24h", show.Stats.SuccessRate24h},
+		jobField{"Success Rate 7d", show.Stats.SuccessRate7d},
+		jobField{"Success Rate 30d", show.Stats.SuccessRate30d},
+		jobField{"Last Success", show.Stats.LastSuccess},
+		jobField{"Last Failure", show.Stats.LastFailure},
+		jobField{"Streak", core.FormatStreak(show.Stats.StreakStatus, show.Stats.StreakLength)},
+		jobField{"Duration p50", show.Stats.DurationP50},
+		jobField{"Duration p95", show.Stats.DurationP95},
+		jobField{"Duration Max", show.Stats.DurationMax},
+	)
+}
+
+func init() {
+	JobCmd.AddCommand(showCmd)
+	showCmd.Flags().String("name", "", "Job Name (required)")
+	opts.FormatTableOpt(showCmd, &showFormat)
+	remote.RemoteOpt(showCmd)
+	if err := showCmd.MarkFlagRequired("name"); err != nil {
+		log.Fatalf("Unable to mark name as required %s", err)
+	}
+}
@@ -15,6 +15,25 @@ import (
 
 var notifyLogOpt = "notify-log"
 var newNameOpt = "new-name"
+var timeoutOpt = "timeout"
+var retryAttemptsOpt = "retry-attempts"
+var retryBackoffOpt = "retry-backoff"
+var retryDelayOpt = "retry-delay"
+var retryExitCodesOpt = "retry-exit-codes"
+var scheduleOpt = "schedule"
+var graceOpt = "grace"
+var notifyTargetsOpt = "notify-targets"
+var notifyModeOpt = "notify-mode"
+var notifyReminderOpt = "notify-reminder"
+var keepLastOpt = "keep-last"
+var keepDaysOpt = "keep-days"
+var keepFailedDaysOpt = "keep-failed-days"
+var archiveOpt = "archive"
+var concurrencyOpt = "concurrency"
+var queueTimeoutOpt = "queue-timeout"
+var lockGroupOpt = "lock-group"
+var commandOpt = "command"
+var statsOpt = "stats"
 
 var updateCmd = &cobra.Command{
 	Use:   "update",
@@ -39,17 +58,133 @@ var updateCmd = &cobra.Command{
 		if cmd.Flags().Changed(newNameOpt) {
 			job.Job.Name = opts.GetStringOptOrExit(cmd, newNameOpt)
 		}
+		if cmd.Flags().Changed(timeoutOpt) {
+			job.Job.TimeoutSeconds = opts.GetSecondsOptOrExit(cmd, timeoutOpt)
+		}
+		if cmd.Flags().Changed(retryAttemptsOpt) {
+			job.Job.RetryAttempts = opts.GetRetryAttemptsOptOrExit(cmd, retryAttemptsOpt)
+		}
+		if cmd.Flags().Changed(retryBackoffOpt) {
+			job.Job.RetryBackoff = opts.GetRetryBackoffOptOrExit(cmd, retryBackoffOpt)
+		}
+		if cmd.Flags().Changed(retryDelayOpt) {
+			job.Job.RetryDelaySeconds = opts.GetSecondsOptOrExit(cmd, retryDelayOpt).Int64
+		}
+		if cmd.Flags().Changed(retryExitCodesOpt) {
+			job.Job.RetryExitCodes = opts.GetExitCodesOptOrExit(cmd, retryExitCodesOpt)
+		}
+		if cmd.Flags().Changed(notifyModeOpt) {
+			job.Job.NotifyMode = opts.GetNotifyModeOptOrExit(cmd, notifyModeOpt)
+		}
+		if cmd.Flags().Changed(notifyReminderOpt) {
+			job.Job.NotifyReminderSeconds = opts.GetSecondsOptOrExit(cmd, notifyReminderOpt).Int64
+		}
 
 		err = queries.UpdateJob(cmd.Context(), data.UpdateJobParams{
-			ID:               job.Job.ID,
-			Name:             job.Job.Name,
-			NotifyLogContent: job.Job.NotifyLogContent,
+			ID:                    job.Job.ID,
+			Name:                  job.Job.Name,
+			NotifyLogContent:      job.Job.NotifyLogContent,
+			TimeoutSeconds:        job.Job.TimeoutSeconds,
+			RetryAttempts:         job.Job.RetryAttempts,
+			RetryBackoff:          job.Job.RetryBackoff,
+			RetryDelaySeconds:     job.Job.RetryDelaySeconds,
+			RetryExitCodes:        job.Job.RetryExitCodes,
+			NotifyMode:            job.Job.NotifyMode,
+			NotifyReminderSeconds: job.Job.NotifyReminderSeconds,
 		})
 
 		if err != nil {
 			core.LogErrorAndExit(logger, err, errors.New("unable to update job"))
 		}
 
+		if cmd.Flags().Changed(scheduleOpt) || cmd.Flags().Changed(graceOpt) {
+			schedule := job.Job.Schedule
+			if cmd.Flags().Changed(scheduleOpt) {
+				schedule = opts.GetScheduleOptOrExit(cmd, scheduleOpt)
+			}
+			grace := job.Job.ScheduleGraceSeconds
+			if cmd.Flags().Changed(graceOpt) {
+				grace = opts.GetSecondsOptOrExit(cmd, graceOpt)
+			}
+			err = queries.UpdateJobSchedule(cmd.Context(), data.UpdateJobScheduleParams{
+				ID:                   job.Job.ID,
+				Schedule:             schedule,
+				ScheduleGraceSeconds: grace,
+			})
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to update job schedule"))
+			}
+		}
+
+		if cmd.Flags().Changed(notifyTargetsOpt) {
+			setNotifyTargets(cmd, queries, job.Job.ID)
+		}
+
+		if retentionOptsChanged(cmd) {
+			if cmd.Flags().Changed(keepLastOpt) {
+				job.Job.KeepLast = opts.GetCountOptOrExit(cmd, keepLastOpt)
+			}
+			if cmd.Flags().Changed(keepDaysOpt) {
+				job.Job.KeepDays = opts.GetCountOptOrExit(cmd, keepDaysOpt)
+			}
+			if cmd.Flags().Changed(keepFailedDaysOpt) {
+				job.Job.KeepFailedDays = opts.GetCountOptOrExit(cmd, keepFailedDaysOpt)
+			}
+			err = queries.UpdateJobRetention(cmd.Context(), data.UpdateJobRetentionParams{
+				ID:             job.Job.ID,
+				KeepLast:       job.Job.KeepLast,
+				KeepDays:       job.Job.KeepDays,
+				KeepFailedDays: job.Job.KeepFailedDays,
+			})
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to update job retention"))
+			}
+		}
+
+		if cmd.Flags().Changed(archiveOpt) {
+			err = queries.UpdateJobArchive(cmd.Context(), data.UpdateJobArchiveParams{
+				ID:      job.Job.ID,
+				Archive: opts.GetArchiveFormatOptOrExit(cmd, archiveOpt),
+			})
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to update job archive"))
+			}
+		}
+
+		if concurrencyOptsChanged(cmd) {
+			if cmd.Flags().Changed(concurrencyOpt) {
+				mode, limit := opts.GetConcurrencyOptOrExit(cmd, concurrencyOpt)
+				job.Job.Concurrency = string(mode)
+				job.Job.ConcurrencyLimit = limit
+			}
+			if cmd.Flags().Changed(queueTimeoutOpt) {
+				job.Job.QueueTimeoutSeconds = opts.GetSecondsOptOrExit(cmd, queueTimeoutOpt)
+			}
+			err = queries.UpdateJobConcurrency(cmd.Context(), data.UpdateJobConcurrencyParams{
+				ID:                  job.Job.ID,
+				Concurrency:         job.Job.Concurrency,
+				ConcurrencyLimit:    job.Job.ConcurrencyLimit,
+				QueueTimeoutSeconds: job.Job.QueueTimeoutSeconds,
+			})
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to update job concurrency"))
+			}
+		}
+
+		if cmd.Flags().Changed(lockGroupOpt) {
+			setLockGroups(cmd, queries, job.Job.ID)
+		}
+
+		if cmd.Flags().Changed(commandOpt) {
+			err = queries.UpdateJobCommand(cmd.Context(), data.UpdateJobCommandParams{
+				ID:      job.Job.ID,
+				Command: opts.GetCommandOptOrExit(cmd, commandOpt),
+			})
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to update job command"))
+			}
+		}
+
 		logger.Info("Job updated")
 	},
 }
@@ -59,6 +194,16 @@ func init() {
 	updateCmd.Flags().String("name", "", "Job Name (required)")
 	updateCmd.Flags().String(newNameOpt, "", "New job Name")
 	updateCmd.Flags().Bool(notifyLogOpt, false, "Includes the raw log output rather than the log filename in notification messages (default false)")
+	updateCmd.Flags().Duration(timeoutOpt, 0, "Default timeout for runs of the job. eg. 30m. 0 removes the timeout")
+	retryOpts(updateCmd)
+	scheduleOpts(updateCmd)
+	notifyTargetsOpts(updateCmd)
+	notifyModeOpts(updateCmd)
+	retentionOpts(updateCmd)
+	archiveOpts(updateCmd)
+	concurrencyOpts(updateCmd)
+	lockGroupOpts(updateCmd)
+	commandOpts(updateCmd)
 	if err := updateCmd.MarkFlagRequired("name"); err != nil {
 		log.Fatalf("Unable to mark name as required %s", err)
 	}
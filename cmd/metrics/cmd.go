@@ -0,0 +1,59 @@
+/*
+Copyright © 2025 Samuel Carswell <samuelrcarswell@gmail.com>
+*/
+package cmd
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+
+	"github.com/samcarswell/trochilus/cmd"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/metrics"
+	"github.com/samcarswell/trochilus/opts"
+	"github.com/spf13/cobra"
+)
+
+var fileOpt = "file"
+
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Writes Prometheus metrics of jobs and their runs",
+	Long: `Writes Prometheus metrics of jobs and their runs, in the text exposition
+format read by the node_exporter textfile collector.
+
+The metrics are written to --file, or metrics.file if it is not given.
+Without either, they are printed. Files are replaced atomically. Set
+metrics.refresh to have exec rewrite metrics.file when a run starts and ends.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := slog.Default()
+		conf := config.GetConfig()
+		file := conf.Metrics.File
+		if cmd.Flags().Changed(fileOpt) {
+			file = opts.GetStringOptOrExit(cmd, fileOpt)
+		}
+		queries := config.GetDatabase(cmd.Context())
+
+		if file == "" {
+			jobs, err := metrics.Collect(cmd.Context(), queries)
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to get metrics"))
+			}
+			if err := metrics.Write(os.Stdout, jobs); err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to write metrics"))
+			}
+			return
+		}
+		if err := metrics.WriteFile(cmd.Context(), queries, file); err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to write metrics to "+file))
+		}
+		logger.Info("Metrics written to " + file)
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(metricsCmd)
+	metricsCmd.Flags().String(fileOpt, "", "File to write the metrics to. eg. /var/lib/node_exporter/textfile/troc.prom (default metrics.file)")
+}
@@ -0,0 +1,18 @@
+/*
+Copyright © 2025 Samuel Carswell <samuelrcarswell@gmail.com>
+*/
+package cmd
+
+import (
+	"github.com/samcarswell/trochilus/cmd"
+	"github.com/spf13/cobra"
+)
+
+var NotifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Commands related to notifications",
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(NotifyCmd)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/notify"
+	"github.com/spf13/cobra"
+)
+
+var flushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Sends pending notifications that are due",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := slog.Default()
+		conf := config.GetConfig()
+		queries := config.GetDatabase(cmd.Context())
+
+		err := notify.Flush(cmd.Context(), logger, conf, queries)
+		if err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to send all notifications"))
+		}
+	},
+}
+
+func init() {
+	NotifyCmd.AddCommand(flushCmd)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/opts"
+	"github.com/spf13/cobra"
+)
+
+var runIdOpt = "run-id"
+var statusOpt = "status"
+var format string
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists notifications and their delivery state",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return opts.FormatTableOptValidate(cmd, format)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := slog.Default()
+		conf := config.GetConfig()
+		runId, err := cmd.Flags().GetInt64(runIdOpt)
+		if err != nil {
+			core.LogErrorAndExit(logger, err)
+		}
+		status := opts.GetStringOptOrExit(cmd, statusOpt)
+		switch core.NotificationStatus(status) {
+		case "", core.NotificationStatusPending, core.NotificationStatusSent, core.NotificationStatusFailed:
+		default:
+			core.LogErrorAndExit(logger, fmt.Errorf("invalid %s: %s", statusOpt, status))
+		}
+		queries := config.GetDatabase(cmd.Context())
+
+		notificationRows, err := queries.GetNotifications(cmd.Context(), data.GetNotificationsParams{
+			Dollar1: runId,
+			Dollar2: status,
+		})
+		if err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to get notifications"))
+		}
+		var rows = []core.NotificationShow{}
+		for _, row := range notificationRows {
+			rows = append(rows, newNotificationShow(row, conf.LocalTime))
+		}
+
+		t := core.NewTable(rows, rowConv, []string{
+			"ID", "Run ID", "Job Name", "Target", "Status", "Label", "Attempts",
+			"Last Error", "Created At", "Next Attempt At", "Sent At",
+		})
+		t.Print(core.OutputFormat(format))
+	},
+}
+
+func newNotificationShow(row data.GetNotificationsRow, useLocalTime bool) core.NotificationShow {
+	n := row.Notification
+	var nextAttemptAt string
+	if core.NotificationStatus(n.Status) == core.NotificationStatusPending {
+		nextAttemptAt = core.FormatTime(time.Unix(n.NextAttemptAt, 0).UTC(), useLocalTime)
+	}
+	return core.NotificationShow{
+		ID:            n.ID,
+		RunID:         n.RunID,
+		JobName:       row.Name,
+		Target:        n.TargetName,
+		Status:        n.Status,
+		Label:         n.Label,
+		Attempts:      n.Attempts,
+		LastError:     n.LastError.String,
+		CreatedAt:     core.FormatTime(n.CreatedAt, useLocalTime),
+		NextAttemptAt: nextAttemptAt,
+		SentAt:        core.FormatTime(n.SentAt.Time, useLocalTime),
+	}
+}
+
+func rowConv(row core.NotificationShow, _ core.OutputFormat) table.Row {
+	return table.Row{
+		row.ID,
+		row.RunID,
+		row.JobName,
+		row.Target,
+		row.Status,
+		row.Label,
+		row.Attempts,
+		row.LastError,
+		row.CreatedAt,
+		row.NextAttemptAt,
+		row.SentAt,
+	}
+}
+
+func init() {
+	NotifyCmd.AddCommand(listCmd)
+	listCmd.Flags().Int64P(runIdOpt, "r", 0, "Only list notifications of this run")
+	listCmd.Flags().String(statusOpt, "", "Only list notifications with this status (Pending|Sent|Failed)")
+	opts.FormatTableOpt(listCmd, &format)
+}
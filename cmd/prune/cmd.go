@@ -0,0 +1,108 @@
+/*
+Copyright © 2025 Samuel Carswell <samuelrcarswell@gmail.com>
+*/
+package cmd
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/samcarswell/trochilus/cmd"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/opts"
+	"github.com/samcarswell/trochilus/retention"
+	"github.com/spf13/cobra"
+)
+
+var nameOpt = "name"
+var dryRunOpt = "dry-run"
+var vacuumOpt = "vacuum"
+var format string
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Deletes runs and their logs that are outside the retention of their job",
+	Long: `Deletes runs and their logs that are outside the retention of their job.
+
+A run is kept if it is one of the last keeplast runs of its job, started
+within keepdays, or failed within keepfaileddays. Nothing is deleted unless
+keeplast or keepdays is set, in the retention config or on the job.
+Running runs are never deleted.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return opts.FormatTableOptValidate(cmd, format)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := slog.Default()
+		jobName := opts.GetStringOptOrExit(cmd, nameOpt)
+		dryRun := opts.GetBoolOptOrExit(cmd, dryRunOpt)
+		vacuum := opts.GetBoolOptOrExit(cmd, vacuumOpt)
+		conf := config.GetConfig()
+		conn := config.GetDatabaseConn(cmd.Context())
+		queries := data.New(conn)
+
+		pruned, err := retention.Plan(cmd.Context(), queries, conf.Retention, jobName, time.Now())
+		if err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to get runs to prune"))
+		}
+
+		var rows = []core.PrunedRunShow{}
+		for _, run := range pruned {
+			rows = append(rows, core.PrunedRunShow{
+				ID:        run.Run.ID,
+				JobName:   run.JobName,
+				StartTime: core.FormatTime(run.Run.StartTime, conf.LocalTime),
+				Status:    run.Run.Status,
+				Attempts:  run.Attempts,
+				Files:     append([]string{}, run.Files...),
+			})
+		}
+		t := core.NewTable(rows, rowConv, []string{
+			"ID", "Job Name", "Start Time", "Status", "Attempts", "Files",
+		})
+		t.Print(core.OutputFormat(format))
+
+		if dryRun {
+			logger.Info("Dry run. " + strconv.Itoa(len(pruned)) + " run(s) would be pruned")
+			return
+		}
+		err = retention.Prune(cmd.Context(), logger, conn, pruned)
+		if err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to prune runs"))
+		}
+		logger.Info(strconv.Itoa(len(pruned)) + " run(s) pruned")
+		if vacuum {
+			if _, err := conn.ExecContext(cmd.Context(), "vacuum"); err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to vacuum database"))
+			}
+		}
+	},
+}
+
+func rowConv(row core.PrunedRunShow, format core.OutputFormat) table.Row {
+	separator := "\n"
+	if format != core.FormatPretty {
+		separator = ","
+	}
+	return table.Row{
+		row.ID,
+		row.JobName,
+		row.StartTime,
+		row.Status,
+		row.Attempts,
+		strings.Join(row.Files, separator),
+	}
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().String(nameOpt, "", "Only prune runs of this job")
+	pruneCmd.Flags().Bool(dryRunOpt, false, "Lists the runs that would be pruned without deleting them")
+	pruneCmd.Flags().Bool(vacuumOpt, false, "Vacuums the database after pruning to reclaim the space on disk")
+	opts.FormatTableOpt(pruneCmd, &format)
+}
@@ -14,6 +14,10 @@ import (
 	slogmulti "github.com/samber/slog-multi"
 	"github.com/samcarswell/trochilus/config"
 	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/notify"
+	"github.com/samcarswell/trochilus/reap"
+	"github.com/samcarswell/trochilus/remote"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -40,12 +44,15 @@ https://github.com/samcarswell/trochilus
 // Most of this file needs to be moved to config/config.go
 func setupContext(cmd *cobra.Command) {
 	conf := config.GetConfig()
+	if err := notify.ValidateTemplates(conf.Notify); err != nil {
+		core.LogErrorAndExit(slog.Default(), err)
+	}
 	err := os.MkdirAll(conf.LogDir, os.ModePerm)
 	if err != nil {
 		core.LogErrorAndExit(slog.Default(), err, errors.New("unable to create logdir"))
 	}
 	var l *slog.Logger
-	if cmd.CommandPath() == cliName+" exec" {
+	if path := cmd.CommandPath(); path == cliName+" exec" || path == cliName+" job run" || path == cliName+" run rerun" {
 		// If we're executing a job, we need to log to file
 		logFile, err := core.CreateSyslog(conf.LogDir)
 		if err != nil {
@@ -64,6 +71,31 @@ func setupContext(cmd *cobra.Command) {
 
 	cmd.SetContext(config.ContextWithLogger(cmd.Context(), l))
 	cmd.SetContext(config.ContextWithMigrations(cmd.Context(), Migrations))
+
+	// A remote command only runs troc over ssh, so it doesn't open the local
+	// database to reap its runs
+	if conf.Reap.Auto && cmd.CommandPath() != cliName+" run reap" && !remote.IsRemote(cmd) {
+		autoReap(cmd, l, conf)
+	}
+}
+
+// Marks the runs of this host whose process no longer exists as Lost, as troc
+// run reap does. Errors are only logged, so that they don't stop the command.
+func autoReap(cmd *cobra.Command, logger *slog.Logger, conf config.Config) {
+	conn := config.GetDatabaseConn(cmd.Context())
+	db := data.New(conn)
+	lost, err := reap.Plan(cmd.Context(), db, conf.Notify.Hostname)
+	if err == nil {
+		lost, err = reap.Reap(cmd.Context(), logger, conn, conf, lost, conf.Reap.Notify)
+	}
+	if err != nil {
+		logger.Error("Unable to reap lost runs", "error", err)
+	}
+	if conf.Reap.Notify && len(lost) > 0 {
+		if err := notify.Flush(cmd.Context(), logger, conf, db); err != nil {
+			logger.Error("Unable to send all notifications. They will be retried by later runs or troc notify flush", "error", err)
+		}
+	}
 }
 
 var Migrations embed.FS
@@ -98,7 +130,13 @@ func init() {
 	viper.SetDefault("database", path.Join(homedir, ".config", "troc", "troc.db"))
 	viper.SetDefault("logdir", os.TempDir())
 	viper.SetDefault("lockdir", os.TempDir())
+	viper.SetDefault("killgrace", "10s")
 	viper.SetDefault("notify.hostname", hostname)
+	viper.SetDefault("notify.webhook.method", "POST")
+	viper.SetDefault("notify.webhook.logtail", 0)
+	viper.SetDefault("notify.webhook.timeout", "30s")
+	viper.SetDefault("notify.outbox.maxattempts", 10)
+	viper.SetDefault("notify.outbox.retrydelay", "30s")
 	viper.SetDefault("localtime", true)
 	viper.SetDefault("display.emoji", true)
 	viper.SetDefault("display.color.status.succeeded", false)
@@ -106,11 +144,32 @@ func init() {
 	viper.SetDefault("display.color.status.running", false)
 	viper.SetDefault("display.color.status.skipped", false)
 	viper.SetDefault("display.color.status.terminated", false)
+	viper.SetDefault("display.color.status.timedout", false)
+	viper.SetDefault("display.color.status.lost", false)
+	viper.SetDefault("display.color.status.queued", false)
 	viper.SetDefault("notify.status.succeeded", false)
 	viper.SetDefault("notify.status.failed", true)
 	viper.SetDefault("notify.status.running", false)
 	viper.SetDefault("notify.status.skipped", false)
 	viper.SetDefault("notify.status.terminated", true)
+	viper.SetDefault("notify.status.timedout", true)
+	viper.SetDefault("notify.status.lost", true)
+	viper.SetDefault("notify.status.queued", false)
+	viper.SetDefault("check.grace", "1m")
+	viper.SetDefault("retention.keeplast", 0)
+	viper.SetDefault("retention.keepdays", 0)
+	viper.SetDefault("retention.keepfaileddays", 0)
+	viper.SetDefault("retention.auto", false)
+	viper.SetDefault("archive.dir", "")
+	viper.SetDefault("output.store", false)
+	viper.SetDefault("output.chunklines", 1000)
+	viper.SetDefault("metrics.file", "")
+	viper.SetDefault("metrics.refresh", false)
+	viper.SetDefault("serve.listen", "127.0.0.1:8080")
+	viper.SetDefault("serve.token", "")
+	viper.SetDefault("serve.allowkill", false)
+	viper.SetDefault("reap.auto", false)
+	viper.SetDefault("reap.notify", false)
 
 	confPath, ok := os.LookupEnv("TROC_CONFIG_PATH")
 	if !ok {
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/opts"
+	"github.com/samcarswell/trochilus/output"
+	"github.com/spf13/cobra"
+)
+
+var sinceOpt = "since"
+var grepFormat string
+
+var grepCmd = &cobra.Command{
+	Use:   "grep <pattern>",
+	Short: "Searches the stored output of runs",
+	Long: "Searches the output of runs stored in the database with output.store, " +
+		"printing each line that contains the pattern. Matching ignores case.",
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return opts.FormatTableOptValidate(cmd, grepFormat)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := slog.Default()
+		conf := config.GetConfig()
+		jobName := opts.GetStringOptOrExit(cmd, nameOpt)
+		queries := config.GetDatabase(cmd.Context())
+
+		if jobName != "" {
+			_, err := queries.GetJob(cmd.Context(), jobName)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					core.LogErrorAndExit(logger, errors.New("job with name '"+jobName+"' not found"))
+				} else {
+					core.LogErrorAndExit(logger, err)
+				}
+			}
+		}
+		var since time.Time
+		if cmd.Flags().Changed(sinceOpt) {
+			since = time.Now().Add(-opts.GetDurationOptOrExit(cmd, sinceOpt))
+		}
+
+		matches, err := output.Grep(cmd.Context(), queries, args[0], jobName, since)
+		if err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to search run output"))
+		}
+		var rows = []core.OutputMatchShow{}
+		for _, match := range matches {
+			rows = append(rows, core.OutputMatchShow{
+				RunID:     match.RunID,
+				JobName:   match.JobName,
+				StartTime: core.FormatTime(match.StartTime, conf.LocalTime),
+				Line:      match.Line,
+				Text:      match.Text,
+			})
+		}
+
+		t := core.NewTable(rows, grepRowConv, []string{
+			"Run ID", "Job Name", "Start Time", "Line", "Text",
+		})
+		t.Print(core.OutputFormat(grepFormat))
+	},
+}
+
+func grepRowConv(row core.OutputMatchShow, _ core.OutputFormat) table.Row {
+	return table.Row{
+		row.RunID,
+		row.JobName,
+		row.StartTime,
+		row.Line,
+		row.Text,
+	}
+}
+
+func init() {
+	RunCmd.AddCommand(grepCmd)
+
+	grepCmd.Flags().String(nameOpt, "", "Name of job to filter on")
+	grepCmd.Flags().Duration(sinceOpt, 0, "Only search runs started within this long ago. eg. 24h (default all runs)")
+	opts.FormatTableOpt(grepCmd, &grepFormat)
+}
@@ -11,11 +11,14 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/samcarswell/trochilus/config"
 	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
 	"github.com/samcarswell/trochilus/opts"
 	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
 )
 
 var killCmd = &cobra.Command{
@@ -23,12 +26,19 @@ var killCmd = &cobra.Command{
 	Short: "Kill a run",
 	Long: `
 Kill a run.
-This command will lookup the PID of a run and send a SIGTERM signal to it.
+This command will lookup the process group of a run and send a SIGTERM signal, or the signal set with --signal, to
+every process in it. If any are still running after --grace, they are sent SIGKILL.
+Runs started before process groups were recorded only have their own process signalled.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		logger := slog.Default()
 		runId := opts.GetInt64OrExit(cmd, "run-id")
 		force := opts.GetBoolOptOrExit(cmd, "force")
+		sig := opts.GetSignalOptOrExit(cmd, "signal")
+		grace := config.GetConfig().KillGrace
+		if cmd.Flags().Changed("grace") {
+			grace = opts.GetDurationOptOrExit(cmd, "grace")
+		}
 		queries := config.GetDatabase(cmd.Context())
 
 		runRow, err := queries.GetRun(context.Background(), runId)
@@ -62,19 +72,47 @@ This command will lookup the PID of a run and send a SIGTERM signal to it.
 			logger.Info("--force flag provided. Skipping confirmation")
 		}
 
-		err = syscall.Kill(int(runRow.Run.Pid.Int64), syscall.SIGTERM)
+		pid := int(runRow.Run.Pid.Int64)
+		err = core.SignalRun(runRow.Run, sig)
 		if err != nil {
 			core.LogErrorAndExit(logger, err, errors.New("unable to kill run"))
 		}
-		core.LogRunSentSigterm(logger, runId, runRow.Job.Name, int(runRow.Run.Pid.Int64))
+		if sig == syscall.SIGTERM {
+			core.LogRunSentSigterm(logger, runId, runRow.Job.Name, pid)
+		} else {
+			core.LogRunSentSignal(logger, runId, runRow.Job.Name, pid, unix.SignalName(sig))
+		}
+		if sig == syscall.SIGKILL || grace == 0 || waitForExit(runRow.Run, grace) {
+			return
+		}
+		err = core.SignalRun(runRow.Run, syscall.SIGKILL)
+		if err != nil && !errors.Is(err, syscall.ESRCH) {
+			core.LogErrorAndExit(logger, err, errors.New("unable to kill run"))
+		}
+		core.LogRunSentSigkill(logger, runId, runRow.Job.Name, pid)
 	},
 }
 
+// Waits for every process of a run to exit, returning false if any are still
+// running after the grace period.
+func waitForExit(run data.Run, grace time.Duration) bool {
+	deadline := time.Now().Add(grace)
+	for core.IsRunAlive(run) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true
+}
+
 func init() {
 	RunCmd.AddCommand(killCmd)
 
 	killCmd.Flags().Int64P("run-id", "r", 0, "Run id")
 	killCmd.Flags().Bool("force", false, "Force kill")
+	killCmd.Flags().String("signal", "TERM", "Signal to send to the run. eg. TERM, INT, HUP or 9")
+	killCmd.Flags().Duration("grace", 0, "Time to wait for the run to exit before sending SIGKILL. 0 doesn't send SIGKILL (default killgrace)")
 	if err := killCmd.MarkFlagRequired("run-id"); err != nil {
 		core.LogErrorAndExit(slog.Default(), err)
 	}
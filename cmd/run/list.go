@@ -5,19 +5,31 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/jedib0t/go-pretty/v6/text"
 	"github.com/samcarswell/trochilus/config"
 	"github.com/samcarswell/trochilus/core"
 	"github.com/samcarswell/trochilus/opts"
+	"github.com/samcarswell/trochilus/remote"
 	"github.com/spf13/cobra"
 )
 
 var nameOpt = "name"
+var statusOpt = "status"
+var runningOpt = "running"
+var hostOpt = "host"
+var untilOpt = "until"
+var sortOpt = "sort"
+var limitOpt = "limit"
+var offsetOpt = "offset"
 var statusField = "Status"
 var format string
 
+const defaultRunLimit = 50
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "Lists runs",
@@ -27,73 +39,38 @@ var listCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		logger := slog.Default()
 		conf := config.GetConfig()
-		jobName := opts.GetStringOptOrExit(cmd, nameOpt)
-		queries := config.GetDatabase(cmd.Context())
-
-		if jobName != "" {
-			_, err := queries.GetJob(cmd.Context(), jobName)
-			if err != nil {
-				if err == sql.ErrNoRows {
-					core.LogErrorAndExit(logger, errors.New("job with name '"+jobName+"' not found"))
-				} else {
-					core.LogErrorAndExit(logger, err)
-				}
-			}
-		}
-
-		runRows, err := queries.GetRuns(cmd.Context(), jobName)
-		if err != nil {
-			core.LogErrorAndExit(slog.Default(), err)
-		}
-		var rows = []core.RunShow{}
-
-		for _, runRow := range runRows {
-			data := core.RunShow{
-				ID:            runRow.Run.ID,
-				JobName:       runRow.Job.Name,
-				StartTime:     core.FormatTime(runRow.Run.StartTime, conf.LocalTime),
-				EndTime:       core.FormatTime(runRow.Run.EndTime.Time, conf.LocalTime),
-				LogFile:       runRow.Run.LogFile,
-				SystemLogFile: runRow.Run.ExecLogFile,
-				Status:        runRow.Run.Status,
-				Pid:           core.FormatPid(runRow.Run.Pid),
-				Duration:      core.FormatDuration(runRow.Run.StartTime, runRow.Run.EndTime.Time),
-			}
-			rows = append(rows, data)
+		limit := opts.GetCountOptOrExit(cmd, limitOpt)
+		var rows []core.RunShow
+		// The remote troc logs whether there are more runs
+		transport := remote.GetRemoteOptOrExit(cmd, conf.Remotes)
+		if transport != nil {
+			rows = remote.JsonOrExit[[]core.RunShow](cmd, transport)
+		} else {
+			rows = listRuns(cmd, conf, limit)
 		}
 
 		t := core.NewTable(rows, rowConv(conf), []string{
 			"ID",
 			"Job Name",
+			"Host",
 			"Start Time",
 			"End Time",
+			"Duration",
 			"Log File",
 			"Exec Log File",
+			"Exit Code",
+			"Signal",
+			"User CPU",
+			"System CPU",
+			"Max RSS",
 			statusField,
 		})
 		statusTransformer := text.Transformer(func(val any) string {
 			if status, ok := val.(string); ok {
 				color := text.FgWhite
-				switch status {
-				case core.FormatStatus(core.RunStatusSucceeded, conf.Display.Emoji):
-					if conf.Display.Color.Status.Succeeded {
-						color = text.FgGreen
-					}
-				case core.FormatStatus(core.RunStatusFailed, conf.Display.Emoji):
-					if conf.Display.Color.Status.Failed {
-						color = text.FgHiRed
-					}
-				case core.FormatStatus(core.RunStatusRunning, conf.Display.Emoji):
-					if conf.Display.Color.Status.Running {
-						color = text.FgCyan
-					}
-				case core.FormatStatus(core.RunStatusSkipped, conf.Display.Emoji):
-					if conf.Display.Color.Status.Skipped {
-						color = text.FgYellow
-					}
-				case core.FormatStatus(core.RunStatusTerminated, conf.Display.Emoji):
-					if conf.Display.Color.Status.Terminated {
-						color = text.FgHiMagenta
+				for _, runStatus := range core.RunStatuses {
+					if status == core.FormatStatus(runStatus, conf.Display.Emoji) && conf.Display.Color.Status.IsColored(runStatus) {
+						color = core.StatusColor(runStatus)
 					}
 				}
 				return color.Sprintf("%s", status)
@@ -108,9 +85,61 @@ var listCmd = &cobra.Command{
 			},
 		})
 		t.Print(core.OutputFormat(format))
+		if transport == nil && limit.Valid && int64(len(rows)) == limit.Int64 {
+			logger.Info("Showing the first " + strconv.FormatInt(limit.Int64, 10) + " runs. Use --limit and --offset to see more")
+		}
 	},
 }
 
+// Returns the runs matching the filter options from the local database.
+func listRuns(cmd *cobra.Command, conf config.Config, limit sql.NullInt64) []core.RunShow {
+	logger := slog.Default()
+	jobName := opts.GetStringOptOrExit(cmd, nameOpt)
+	status := opts.GetRunStatusOptOrExit(cmd, statusOpt)
+	if opts.GetBoolOptOrExit(cmd, runningOpt) {
+		status = string(core.RunStatusRunning)
+	}
+	now := time.Now()
+	queries := config.GetDatabase(cmd.Context())
+
+	if jobName != "" {
+		_, err := queries.GetJob(cmd.Context(), jobName)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				core.LogErrorAndExit(logger, errors.New("job with name '"+jobName+"' not found"))
+			} else {
+				core.LogErrorAndExit(logger, err)
+			}
+		}
+	}
+
+	params := core.RunFilter{
+		JobName: jobName,
+		Status:  status,
+		Host:    opts.GetStringOptOrExit(cmd, hostOpt),
+		Since:   opts.GetTimeOptOrExit(cmd, sinceOpt, now),
+		Until:   opts.GetTimeOptOrExit(cmd, untilOpt, now),
+		Sort:    opts.GetRunSortOptOrExit(cmd, sortOpt),
+		// A negative limit is no limit in sqlite
+		Limit:  -1,
+		Offset: opts.GetCountOptOrExit(cmd, offsetOpt).Int64,
+	}
+	if limit.Valid {
+		params.Limit = limit.Int64
+	}
+	runRows, err := core.GetRuns(cmd.Context(), queries, params)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err)
+	}
+	var rows = []core.RunShow{}
+
+	for _, runRow := range runRows {
+		data := core.NewRunShow(runRow.Run, runRow.Job.Name, conf.LocalTime)
+		rows = append(rows, data)
+	}
+	return rows
+}
+
 func rowConv(conf config.Config) func(core.RunShow, core.OutputFormat) table.Row {
 	return func(row core.RunShow, format core.OutputFormat) table.Row {
 		var status string
@@ -122,10 +151,17 @@ func rowConv(conf config.Config) func(core.RunShow, core.OutputFormat) table.Row
 		return table.Row{
 			row.ID,
 			row.JobName,
+			row.Host,
 			row.StartTime,
 			row.EndTime,
+			row.Duration,
 			row.LogFile,
 			row.SystemLogFile,
+			row.ExitCode,
+			row.Signal,
+			row.UserCpuTime,
+			row.SystemCpuTime,
+			row.MaxRss,
 			status,
 		}
 	}
@@ -135,5 +171,15 @@ func init() {
 	RunCmd.AddCommand(listCmd)
 
 	listCmd.Flags().String(nameOpt, "", "Name of job to filter on")
+	listCmd.Flags().String(statusOpt, "", "Status to filter on (Running|Skipped|Succeeded|Failed|Terminated|TimedOut|Lost|Queued)")
+	listCmd.Flags().Bool(runningOpt, false, "Only lists running runs. Same as --status Running")
+	listCmd.Flags().String(hostOpt, "", "Host to filter on, ie. the notify.hostname of the exec")
+	listCmd.Flags().String(sinceOpt, "", "Only lists runs started at or after this time. eg. 2025-01-02, \"2025-01-02 15:04\" or 24h for the last 24 hours")
+	listCmd.Flags().String(untilOpt, "", "Only lists runs started before this time, in the same formats as --since")
+	listCmd.Flags().String(sortOpt, "start:desc", "Sorts runs by start, duration or id, optionally followed by :asc or :desc. eg. duration:desc")
+	listCmd.Flags().Int64(limitOpt, defaultRunLimit, "Maximum number of runs to list. 0 lists every run")
+	listCmd.Flags().Int64(offsetOpt, 0, "Number of runs to skip, for paging through runs with --limit")
+	listCmd.MarkFlagsMutuallyExclusive(statusOpt, runningOpt)
 	opts.FormatTableOpt(listCmd, &format)
+	remote.RemoteOpt(listCmd)
 }
@@ -0,0 +1,110 @@
+/*
+Copyright © 2025 Samuel Carswell <samuelrcarswell@gmail.com>
+*/
+package cmd
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/notify"
+	"github.com/samcarswell/trochilus/opts"
+	"github.com/samcarswell/trochilus/reap"
+	"github.com/spf13/cobra"
+)
+
+var dryRunOpt = "dry-run"
+var notifyOpt = "notify"
+var reapFormat string
+
+var reapCmd = &cobra.Command{
+	Use:   "reap",
+	Short: "Marks runs whose process no longer exists as Lost",
+	Long: `Marks runs whose process no longer exists as Lost.
+
+A run is left Running or Queued if troc exec is killed with SIGKILL, or the
+machine restarts, while it is running. Running and Queued runs executed on
+this host, ie. with this notify.hostname, are lost if the troc exec running
+them and its script no longer exist, the PID of that troc exec has been reused
+by another process, or the machine has restarted since they started. As troc
+exec is running while a run is queued or waiting to retry, those runs are
+checked too. Runs started before troc exec recorded its PID are only checked
+by the PID of their script, and not while waiting to retry.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return opts.FormatTableOptValidate(cmd, reapFormat)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := slog.Default()
+		dryRun := opts.GetBoolOptOrExit(cmd, dryRunOpt)
+		isNotify := opts.GetBoolOptOrExit(cmd, notifyOpt)
+		conf := config.GetConfig()
+		conn := config.GetDatabaseConn(cmd.Context())
+		queries := data.New(conn)
+		if isNotify {
+			if err := notify.ValidateConfig(conf, nil); err != nil {
+				core.LogErrorAndExit(logger, err)
+			}
+		}
+
+		lost, err := reap.Plan(cmd.Context(), queries, conf.Notify.Hostname)
+		if err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to get lost runs"))
+		}
+		if !dryRun {
+			lost, err = reap.Reap(cmd.Context(), logger, conn, conf, lost, isNotify)
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to reap runs"))
+			}
+		}
+
+		var rows = []core.LostRunShow{}
+		for _, run := range lost {
+			rows = append(rows, core.LostRunShow{
+				ID:        run.Run.ID,
+				JobName:   run.JobName,
+				StartTime: core.FormatTime(run.Run.StartTime, conf.LocalTime),
+				Pid:       core.FormatPid(run.Run.Pid),
+				Reason:    run.Reason,
+			})
+		}
+		t := core.NewTable(rows, reapRowConv, []string{
+			"ID", "Job Name", "Start Time", "PID", "Reason",
+		})
+		t.Print(core.OutputFormat(reapFormat))
+
+		if dryRun {
+			logger.Info("Dry run. " + strconv.Itoa(len(lost)) + " run(s) would be marked as Lost")
+			return
+		}
+		logger.Info(strconv.Itoa(len(lost)) + " run(s) marked as Lost")
+		if isNotify && len(lost) > 0 {
+			err := notify.Flush(cmd.Context(), logger, conf, queries)
+			if err != nil {
+				core.LogErrorAndExit(logger, err, errors.New("unable to send all notifications. They will be retried by troc notify flush"))
+			}
+		}
+	},
+}
+
+func reapRowConv(row core.LostRunShow, format core.OutputFormat) table.Row {
+	return table.Row{
+		row.ID,
+		row.JobName,
+		row.StartTime,
+		row.Pid,
+		row.Reason,
+	}
+}
+
+func init() {
+	RunCmd.AddCommand(reapCmd)
+
+	reapCmd.Flags().Bool(dryRunOpt, false, "Lists the runs that would be marked as Lost without marking them")
+	reapCmd.Flags().Bool(notifyOpt, false, "Notifies of the runs marked as Lost")
+	opts.FormatTableOpt(reapCmd, &reapFormat)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/samcarswell/trochilus/config"
 	"github.com/samcarswell/trochilus/core"
 	"github.com/samcarswell/trochilus/opts"
+	"github.com/samcarswell/trochilus/remote"
 	"github.com/spf13/cobra"
 )
 
@@ -19,8 +20,12 @@ var showCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		logger := slog.Default()
 		runId := opts.GetInt64OrExit(cmd, "run-id")
-		queries := config.GetDatabase(cmd.Context())
 		conf := config.GetConfig()
+		if t := remote.GetRemoteOptOrExit(cmd, conf.Remotes); t != nil {
+			remote.RunOrExit(cmd, t)
+			return
+		}
+		queries := config.GetDatabase(cmd.Context())
 
 		runRow, err := queries.GetRun(context.Background(), runId)
 		if err != nil {
@@ -30,16 +35,17 @@ var showCmd = &cobra.Command{
 				core.LogErrorAndExit(logger, err)
 			}
 		}
-		data := core.RunShow{
-			ID:            runRow.Run.ID,
-			JobName:       runRow.Job.Name,
-			StartTime:     core.FormatTime(runRow.Run.StartTime, conf.LocalTime),
-			EndTime:       core.FormatTime(runRow.Run.EndTime.Time, conf.LocalTime),
-			LogFile:       runRow.Run.LogFile,
-			SystemLogFile: runRow.Run.ExecLogFile,
-			Status:        runRow.Run.Status,
-			Pid:           core.FormatPid(runRow.Run.Pid),
-			Duration:      core.FormatDuration(runRow.Run.StartTime, runRow.Run.EndTime.Time),
+		if opts.GetBoolOptOrExit(cmd, "log") {
+			printLog(cmd.Context(), logger, queries, runRow.Run)
+			return
+		}
+		data := core.NewRunShow(runRow.Run, runRow.Job.Name, conf.LocalTime)
+		attempts, err := queries.GetRunAttempts(cmd.Context(), sql.NullInt64{Int64: runId, Valid: true})
+		if err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to get run attempts"))
+		}
+		for _, attempt := range attempts {
+			data.Attempts = append(data.Attempts, core.NewRunShow(attempt.Run, runRow.Job.Name, conf.LocalTime))
 		}
 		core.PrintJson(data)
 	},
@@ -49,6 +55,8 @@ func init() {
 	RunCmd.AddCommand(showCmd)
 
 	showCmd.Flags().Int64P("run-id", "r", 0, "Run id")
+	showCmd.Flags().BoolP("log", "l", false, "Prints the log of the run instead of its details. Falls back to the stored output if the log no longer exists")
+	remote.RemoteOpt(showCmd)
 	if err := showCmd.MarkFlagRequired("run-id"); err != nil {
 		core.LogErrorAndExit(slog.Default(), err)
 	}
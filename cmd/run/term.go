@@ -27,6 +27,8 @@ This does NOT stop the original process if it is still running;
 if it is still running it will update the run again once it completes.
 
 This command will fail if the run is in any other state than 'running'.
+
+To mark runs whose process no longer exists, use 'troc run reap' instead.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		logger := slog.Default()
@@ -60,17 +62,7 @@ This command will fail if the run is in any other state than 'running'.
 			core.LogErrorAndExit(logger, err)
 		}
 
-		data := core.RunShow{
-			ID:            updRunRow.Run.ID,
-			JobName:       updRunRow.Job.Name,
-			StartTime:     core.FormatTime(updRunRow.Run.StartTime, conf.LocalTime),
-			EndTime:       core.FormatTime(updRunRow.Run.EndTime.Time, conf.LocalTime),
-			LogFile:       updRunRow.Run.LogFile,
-			SystemLogFile: updRunRow.Run.ExecLogFile,
-			Status:        updRunRow.Run.Status,
-			Duration:      core.FormatDuration(updRunRow.Run.StartTime, updRunRow.Run.EndTime.Time),
-			Pid:           core.FormatPid(updRunRow.Run.Pid),
-		}
+		data := core.NewRunShow(updRunRow.Run, updRunRow.Job.Name, conf.LocalTime)
 		core.PrintJson(data)
 	},
 }
@@ -1,19 +1,19 @@
 package cmd
 
 import (
-	"bufio"
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
-	"io"
 	"log/slog"
 	"os"
 	"strconv"
-	"time"
 
 	"github.com/samcarswell/trochilus/config"
 	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
 	"github.com/samcarswell/trochilus/opts"
+	"github.com/samcarswell/trochilus/remote"
+	"github.com/samcarswell/trochilus/watch"
 	"github.com/spf13/cobra"
 )
 
@@ -23,8 +23,12 @@ var watchCmd = &cobra.Command{
 	Long:  "Watches the logs of a run. If is not running, the log will be printed and the command will immediately exit",
 	Run: func(cmd *cobra.Command, args []string) {
 		logger := slog.Default()
-		queries := config.GetDatabase(cmd.Context())
 		runId := opts.GetInt64OrExit(cmd, "run-id")
+		if t := remote.GetRemoteOptOrExit(cmd, config.GetConfig().Remotes); t != nil {
+			remote.RunOrExit(cmd, t)
+			return
+		}
+		queries := config.GetDatabase(cmd.Context())
 		runRow, err := queries.GetRun(cmd.Context(), runId)
 		if err != nil {
 			if err == sql.ErrNoRows {
@@ -34,66 +38,28 @@ var watchCmd = &cobra.Command{
 			}
 		}
 
-		file, err := os.Open(runRow.Run.LogFile)
-		if err != nil {
-			return
+		if err := watch.Follow(cmd.Context(), queries, runRow.Run, os.Stdout); err != nil {
+			core.LogErrorAndExit(logger, err)
 		}
-		defer file.Close()
-		reader := bufio.NewReader(file)
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				if err == io.EOF {
-					// without this sleep you would hogg the CPU
-					time.Sleep(500 * time.Millisecond)
-					// truncated ?
-					truncated, errTruncated := isTruncated(file)
-					if errTruncated != nil {
-						break
-					}
-					if truncated {
-						// seek from start
-						_, errSeekStart := file.Seek(0, io.SeekStart)
-						if errSeekStart != nil {
-							break
-						}
-					}
-					finished, err := queries.IsRunFinished(cmd.Context(), runId)
-					if err != nil {
-						logger.Error(err.Error())
-					}
-					if finished {
-						logger.Info("Run has finished. Exiting.")
-						break
-					}
-					continue
-				}
-				break
-			}
-			fmt.Printf("%s", string(line))
+		if runRow.Run.Status == string(core.RunStatusRunning) {
+			logger.Info("Run has finished. Exiting.")
 		}
 	},
 }
 
 func init() {
 	watchCmd.Flags().Int64P("run-id", "r", 0, "Run Id")
+	remote.RemoteOpt(watchCmd)
 	if err := watchCmd.MarkFlagRequired("run-id"); err != nil {
 		core.LogErrorAndExit(slog.Default(), err)
 	}
 	RunCmd.AddCommand(watchCmd)
 }
 
-// https://medium.com/@arunprabhu.1/tailing-a-file-in-golang-72944204f22b
-func isTruncated(file *os.File) (bool, error) {
-	// current read position in a file
-	currentPos, err := file.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return false, err
-	}
-	// file stat to get the size
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return false, err
+// Prints the whole of a run log, decompressing it if it has been archived.
+// If the log no longer exists, the output stored in the database is printed.
+func printLog(ctx context.Context, logger *slog.Logger, queries *data.Queries, run data.Run) {
+	if err := watch.Copy(ctx, queries, run, os.Stdout); err != nil {
+		core.LogErrorAndExit(logger, err)
 	}
-	return currentPos > fileInfo.Size(), nil
 }
@@ -0,0 +1,98 @@
+/*
+Copyright © 2025 Samuel Carswell <samuelrcarswell@gmail.com>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/samcarswell/trochilus/cmd"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/opts"
+	"github.com/samcarswell/trochilus/server"
+	"github.com/spf13/cobra"
+)
+
+var listenOpt = "listen"
+
+const shutdownTimeout = 10 * time.Second
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serves an HTTP API and dashboard of jobs, runs and metrics",
+	Long: `Serves a read-only HTTP API and a dashboard of jobs, runs and metrics.
+The dashboard is at /ui/.
+
+  GET /healthz            Health of the server and database
+  GET /metrics            Prometheus metrics, as written by troc metrics
+  GET /jobs               Jobs
+  GET /jobs/{name}        A job and statistics of its runs
+  GET /runs               Runs, filtered with the query parameters job, status,
+                          host, since, until, sort, limit and offset
+  GET /runs/{id}          A run and its attempts
+  GET /runs/{id}/log      The log of a run. Supports range requests, and with
+                          ?follow=true streams it as server-sent events
+
+If serve.token is set, every endpoint but /healthz requires the header
+Authorization: Bearer <token>. The dashboard asks for the token instead.
+With serve.allowkill, runs can be killed from the dashboard.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := slog.Default()
+		conf := config.GetConfig()
+		listen := conf.Serve.Listen
+		if cmd.Flags().Changed(listenOpt) {
+			listen = opts.GetStringOptOrExit(cmd, listenOpt)
+		}
+		conn := config.GetDatabaseConn(cmd.Context())
+		defer conn.Close()
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		srv := &http.Server{
+			Addr:              listen,
+			Handler:           server.New(logger, conn, conf).Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+			// Requests are cancelled on shutdown, which ends followed logs
+			BaseContext: func(net.Listener) context.Context {
+				return ctx
+			},
+		}
+		listener, err := net.Listen("tcp", listen)
+		if err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to listen on "+listen))
+		}
+		if conf.Serve.Token == "" {
+			logger.Warn("serve.token is not set. Requests are not authenticated")
+		}
+		logger.Info("Listening on " + listener.Addr().String())
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- srv.Serve(listener)
+		}()
+		select {
+		case err := <-errs:
+			core.LogErrorAndExit(logger, err, errors.New("server stopped"))
+		case <-ctx.Done():
+		}
+		logger.Info("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			core.LogErrorAndExit(logger, err, errors.New("unable to shut down server"))
+		}
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().String(listenOpt, "", "Address to listen on. eg. :8080 (default serve.listen)")
+}
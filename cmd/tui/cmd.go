@@ -0,0 +1,74 @@
+/*
+Copyright © 2025 Samuel Carswell <samuelrcarswell@gmail.com>
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/samcarswell/trochilus/cmd"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/tui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Browses jobs and runs in a terminal UI",
+	Long: `Browses jobs and runs in a full-screen terminal UI.
+
+Jobs are listed with the status of their last run. Open a job to list its
+runs, and open a run to follow its log as troc run watch does. Running runs
+can be killed, as troc run kill does, or marked as terminated, as troc run
+term does, after confirming.
+
+  ↑/↓ j/k        Move, or scroll a log
+  pgup/pgdn      Move, or scroll a log, a page at a time
+  enter          Open
+  esc            Go back
+  G              Follow the end of a log
+  K              Kill the run
+  T              Mark the run as terminated
+  r              Refresh
+  q              Quit
+
+Statuses are displayed with display.emoji and display.color.status, and times
+with localtime.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		conf := config.GetConfig()
+		queries := config.GetDatabase(cmd.Context())
+
+		// Logging would be drawn over the UI, so it is printed once the UI exits
+		var logs bytes.Buffer
+		var logger *slog.Logger
+		if viper.GetBool("logjson") {
+			logger = slog.New(slog.NewJSONHandler(&logs, core.GetSlogHandlerOptions()))
+		} else {
+			logger = slog.New(slog.NewTextHandler(&logs, core.GetSlogHandlerOptions()))
+		}
+		stderrLogger := slog.Default()
+		core.SetDefaultSlogLogger(logger)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGTERM, syscall.SIGHUP)
+		defer stop()
+		err := tui.Run(ctx, logger, queries, conf)
+		core.SetDefaultSlogLogger(stderrLogger)
+		if _, writeErr := os.Stderr.Write(logs.Bytes()); writeErr != nil {
+			err = errors.Join(err, writeErr)
+		}
+		if err != nil {
+			core.LogErrorAndExit(stderrLogger, err)
+		}
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(tuiCmd)
+}
@@ -115,7 +115,9 @@ func CreateOrUpdateDatabase(
 	if err != nil {
 		core.LogErrorAndExit(logger, err, errors.New("unable to update database"))
 	}
-	db, err := sql.Open("sqlite", path.Join(dir, fileName)+"?mode=rw")
+	// Connection pragmas are also set in the DSN, as the PRAGMA statements
+	// below only apply to the connection of the pool they happen to run on
+	db, err := sql.Open("sqlite", path.Join(dir, fileName)+"?mode=rw&_pragma=busy_timeout(10000)&_pragma=foreign_keys(1)")
 	if err != nil {
 		core.LogErrorAndExit(logger, err, errors.New("unable to open database"))
 	}
@@ -148,10 +150,61 @@ func CreateOrUpdateDatabase(
 	if err != nil {
 		core.LogErrorAndExit(logger, err, errors.New(confDbErrMsg+": foreign_keys"))
 	}
+	err = createOutputIndex(ctx, db)
+	if err != nil {
+		core.LogErrorAndExit(logger, err, errors.New("unable to create run output index"))
+	}
 	return db
 }
 
+// Creates the full-text index of run_output, along with the triggers that keep
+// it up to date. It can't be created by a migration, as dbmate applies them
+// with a sqlite driver that is built without FTS5. The trigram tokenizer allows
+// substring matches with like, rather than only whole words. The index is
+// rebuilt if any of them is missing, as output stored without the triggers
+// isn't indexed.
+func createOutputIndex(ctx context.Context, db *sql.DB) error {
+	var exists bool
+	err := db.QueryRowContext(ctx, `
+select count(*) = 3 from sqlite_master
+where name in ('run_output_fts', 'run_output_insert', 'run_output_delete')`).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `
+create virtual table if not exists run_output_fts using fts5(
+    content,
+    content = 'run_output',
+    content_rowid = 'id',
+    tokenize = 'trigram'
+);
+
+create trigger if not exists run_output_insert after insert on run_output begin
+    insert into run_output_fts (rowid, content) values (new.id, new.content);
+end;
+
+create trigger if not exists run_output_delete after delete on run_output begin
+    insert into run_output_fts (run_output_fts, rowid, content) values ('delete', old.id, old.content);
+end;
+
+insert into run_output_fts (run_output_fts) values ('rebuild');`)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func GetDatabase(ctx context.Context) *data.Queries {
+	return data.New(GetDatabaseConn(ctx))
+}
+
+// Returns the database connection, for queries that need a transaction.
+func GetDatabaseConn(ctx context.Context) *sql.DB {
 	migrations, ok := MigrationsFromContext(ctx)
 	if !ok {
 		core.LogErrorAndExit(slog.Default(), errors.New("could not get migrations"))
@@ -165,12 +218,12 @@ func GetDatabase(ctx context.Context) *data.Queries {
 		core.LogErrorAndExit(slog.Default(), err, errors.New("unable to expand database path"))
 	}
 
-	return data.New(CreateOrUpdateDatabase(
+	return CreateOrUpdateDatabase(
 		migrations,
 		ctx,
 		expandedPath,
 		"./db/migrations",
-	))
+	)
 }
 
 func GetLogFileOrExit(logger *slog.Logger, ctx context.Context) string {
@@ -184,7 +237,37 @@ func GetLogFileOrExit(logger *slog.Logger, ctx context.Context) string {
 type NotifyConfig struct {
 	Hostname string
 	Slack    SlackConfig
+	Webhook  WebhookConfig
+	Email    EmailConfig
+	Targets  []NotifyTargetConfig
+	Outbox   OutboxConfig
 	Status   StatusConfig
+	// Go text/template of run notifications. Empty uses the default.
+	Template  string
+	Templates []NotifyTemplateConfig
+}
+
+// A notification template used for runs of a job, runs with a status, or
+// both.
+type NotifyTemplateConfig struct {
+	Job      string
+	Status   string
+	Template string
+}
+
+type OutboxConfig struct {
+	MaxAttempts int
+	RetryDelay  time.Duration
+}
+
+// A named notification target. Type selects the notifier, which reads its
+// settings from the matching config.
+type NotifyTargetConfig struct {
+	Name    string
+	Type    string
+	Slack   SlackConfig   `mapstructure:",squash"`
+	Webhook WebhookConfig `mapstructure:",squash"`
+	Email   EmailConfig   `mapstructure:",squash"`
 }
 
 type SlackConfig struct {
@@ -192,12 +275,59 @@ type SlackConfig struct {
 	Channel string
 }
 
+type WebhookConfig struct {
+	Url     string
+	Method  string
+	Headers map[string]string
+	Secret  string
+	LogTail int
+	Timeout time.Duration
+}
+
+type EmailConfig struct {
+	Host        string
+	Port        int
+	Tls         string
+	Username    string
+	Password    string
+	From        string
+	To          []string
+	AttachLog   bool
+	AttachLimit int
+}
+
 type StatusConfig struct {
 	Succeeded  bool
 	Failed     bool
 	Running    bool
 	Skipped    bool
 	Terminated bool
+	TimedOut   bool
+	Lost       bool
+	Queued     bool
+}
+
+// Returns whether text output is coloured for a status.
+func (c StatusConfig) IsColored(status core.RunStatus) bool {
+	switch status {
+	case core.RunStatusSucceeded:
+		return c.Succeeded
+	case core.RunStatusFailed:
+		return c.Failed
+	case core.RunStatusRunning:
+		return c.Running
+	case core.RunStatusSkipped:
+		return c.Skipped
+	case core.RunStatusTerminated:
+		return c.Terminated
+	case core.RunStatusTimedOut:
+		return c.TimedOut
+	case core.RunStatusLost:
+		return c.Lost
+	case core.RunStatusQueued:
+		return c.Queued
+	}
+	return false
 }
 
 type ColorConfig struct {
@@ -209,20 +339,105 @@ type DisplayConfig struct {
 	Color ColorConfig
 }
 
+type CheckConfig struct {
+	Grace time.Duration
+}
+
+// How long runs are kept before troc prune deletes them. Zero values are
+// unset. Jobs may override each value.
+type RetentionConfig struct {
+	KeepLast       int
+	KeepDays       int
+	KeepFailedDays int
+	Auto           bool
+}
+
+// Where finished run logs are compressed to, for jobs with an archive format.
+// A blank directory keeps them in the log directory.
+type ArchiveConfig struct {
+	Dir string
+}
+
+// Whether the output of runs is stored in the database, where it can be
+// searched with troc run grep.
+type OutputConfig struct {
+	Store      bool
+	ChunkLines int
+}
+
+// Where troc metrics writes the Prometheus metrics of jobs, and whether exec
+// refreshes them when a run starts and ends.
+type MetricsConfig struct {
+	File    string
+	Refresh bool
+}
+
+// The address troc serve listens on, and the bearer token its requests must
+// have. A blank token allows every request. AllowKill enables the kill button
+// of the dashboard.
+type ServeConfig struct {
+	Listen    string
+	Token     string
+	AllowKill bool
+}
+
+// Whether every troc command first marks the runs of this host whose process
+// no longer exists as Lost, as troc run reap does, and whether their
+// notifications are sent.
+type ReapConfig struct {
+	Auto   bool
+	Notify bool
+}
+
+// A machine troc is run on over ssh with --remote. Host is the ssh
+// destination, Troc the command that runs troc on it, and Ssh the ssh command
+// with any arguments.
+type RemoteConfig struct {
+	Name string
+	Host string
+	Troc string
+	Ssh  []string
+}
+
 type Config struct {
 	Database  string
 	LockDir   string
 	LogDir    string
+	KillGrace time.Duration
 	Notify    NotifyConfig
 	LocalTime bool
 	Display   DisplayConfig
+	Check     CheckConfig
+	Retention RetentionConfig
+	Archive   ArchiveConfig
+	Output    OutputConfig
+	Metrics   MetricsConfig
+	Serve     ServeConfig
+	Remotes   []RemoteConfig
+	Reap      ReapConfig
 }
 
 func GetConfig() Config {
+	var notifyTargets []NotifyTargetConfig
+	err := viper.UnmarshalKey("notify.targets", &notifyTargets)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err, errors.New("unable to read notify.targets config"))
+	}
+	var notifyTemplates []NotifyTemplateConfig
+	err = viper.UnmarshalKey("notify.templates", &notifyTemplates)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err, errors.New("unable to read notify.templates config"))
+	}
+	var remotes []RemoteConfig
+	err = viper.UnmarshalKey("remotes", &remotes)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err, errors.New("unable to read remotes config"))
+	}
 	return Config{
 		Database:  viper.GetString("database"),
 		LockDir:   viper.GetString("lockdir"),
 		LogDir:    viper.GetString("logdir"),
+		KillGrace: viper.GetDuration("killgrace"),
 		LocalTime: viper.GetBool("localtime"),
 		Notify: NotifyConfig{
 			Hostname: viper.GetString("notify.hostname"),
@@ -230,12 +445,41 @@ func GetConfig() Config {
 				Token:   viper.GetString("notify.slack.token"),
 				Channel: viper.GetString("notify.slack.channel"),
 			},
+			Webhook: WebhookConfig{
+				Url:     viper.GetString("notify.webhook.url"),
+				Method:  viper.GetString("notify.webhook.method"),
+				Headers: viper.GetStringMapString("notify.webhook.headers"),
+				Secret:  viper.GetString("notify.webhook.secret"),
+				LogTail: viper.GetInt("notify.webhook.logtail"),
+				Timeout: viper.GetDuration("notify.webhook.timeout"),
+			},
+			Email: EmailConfig{
+				Host:        viper.GetString("notify.email.host"),
+				Port:        viper.GetInt("notify.email.port"),
+				Tls:         viper.GetString("notify.email.tls"),
+				Username:    viper.GetString("notify.email.username"),
+				Password:    viper.GetString("notify.email.password"),
+				From:        viper.GetString("notify.email.from"),
+				To:          viper.GetStringSlice("notify.email.to"),
+				AttachLog:   viper.GetBool("notify.email.attachlog"),
+				AttachLimit: viper.GetInt("notify.email.attachlimit"),
+			},
+			Targets:   notifyTargets,
+			Template:  viper.GetString("notify.template"),
+			Templates: notifyTemplates,
+			Outbox: OutboxConfig{
+				MaxAttempts: viper.GetInt("notify.outbox.maxattempts"),
+				RetryDelay:  viper.GetDuration("notify.outbox.retrydelay"),
+			},
 			Status: StatusConfig{
 				Succeeded:  viper.GetBool("notify.status.succeeded"),
 				Failed:     viper.GetBool("notify.status.failed"),
 				Running:    viper.GetBool("notify.status.running"),
 				Skipped:    viper.GetBool("notify.status.skipped"),
 				Terminated: viper.GetBool("notify.status.terminated"),
+				TimedOut:   viper.GetBool("notify.status.timedout"),
+				Lost:       viper.GetBool("notify.status.lost"),
+				Queued:     viper.GetBool("notify.status.queued"),
 			},
 		},
 		Display: DisplayConfig{
@@ -247,9 +491,42 @@ func GetConfig() Config {
 					Running:    viper.GetBool("display.color.status.running"),
 					Skipped:    viper.GetBool("display.color.status.skipped"),
 					Terminated: viper.GetBool("display.color.status.terminated"),
+					TimedOut:   viper.GetBool("display.color.status.timedout"),
+					Lost:       viper.GetBool("display.color.status.lost"),
+					Queued:     viper.GetBool("display.color.status.queued"),
 				},
 			},
 		},
+		Check: CheckConfig{
+			Grace: viper.GetDuration("check.grace"),
+		},
+		Retention: RetentionConfig{
+			KeepLast:       viper.GetInt("retention.keeplast"),
+			KeepDays:       viper.GetInt("retention.keepdays"),
+			KeepFailedDays: viper.GetInt("retention.keepfaileddays"),
+			Auto:           viper.GetBool("retention.auto"),
+		},
+		Archive: ArchiveConfig{
+			Dir: viper.GetString("archive.dir"),
+		},
+		Output: OutputConfig{
+			Store:      viper.GetBool("output.store"),
+			ChunkLines: viper.GetInt("output.chunklines"),
+		},
+		Metrics: MetricsConfig{
+			File:    viper.GetString("metrics.file"),
+			Refresh: viper.GetBool("metrics.refresh"),
+		},
+		Serve: ServeConfig{
+			Listen:    viper.GetString("serve.listen"),
+			Token:     viper.GetString("serve.token"),
+			AllowKill: viper.GetBool("serve.allowkill"),
+		},
+		Remotes: remotes,
+		Reap: ReapConfig{
+			Auto:   viper.GetBool("reap.auto"),
+			Notify: viper.GetBool("reap.notify"),
+		},
 	}
 }
 
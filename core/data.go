@@ -1,5 +1,7 @@
 package core
 
+import "github.com/samcarswell/trochilus/data"
+
 type RunStatus string
 
 const (
@@ -8,22 +10,248 @@ const (
 	RunStatusSucceeded  RunStatus = "Succeeded"
 	RunStatusFailed     RunStatus = "Failed"
 	RunStatusTerminated RunStatus = "Terminated"
+	RunStatusTimedOut   RunStatus = "TimedOut"
+	RunStatusLost       RunStatus = "Lost"
+	RunStatusQueued     RunStatus = "Queued"
+)
+
+var RunStatuses = []RunStatus{
+	RunStatusRunning,
+	RunStatusSkipped,
+	RunStatusSucceeded,
+	RunStatusFailed,
+	RunStatusTerminated,
+	RunStatusTimedOut,
+	RunStatusLost,
+	RunStatusQueued,
+}
+
+// Returns whether a finished run did not succeed.
+func IsFailedStatus(status RunStatus) bool {
+	return status == RunStatusFailed ||
+		status == RunStatusTerminated ||
+		status == RunStatusTimedOut ||
+		status == RunStatusLost
+}
+
+type RetryBackoff string
+
+const (
+	RetryBackoffFixed       RetryBackoff = "fixed"
+	RetryBackoffExponential RetryBackoff = "exponential"
+)
+
+type NotifyMode string
+
+const (
+	NotifyModeAlways                NotifyMode = "always"
+	NotifyModeOnChange              NotifyMode = "on-change"
+	NotifyModeOnFailureWithReminder NotifyMode = "on-failure-with-reminder"
+)
+
+type ArchiveFormat string
+
+const (
+	ArchiveFormatNone ArchiveFormat = "none"
+	ArchiveFormatGzip ArchiveFormat = "gzip"
+	ArchiveFormatZstd ArchiveFormat = "zstd"
+)
+
+type ConcurrencyMode string
+
+const (
+	ConcurrencySkip     ConcurrencyMode = "skip"
+	ConcurrencyQueue    ConcurrencyMode = "queue"
+	ConcurrencyReplace  ConcurrencyMode = "replace"
+	ConcurrencyParallel ConcurrencyMode = "parallel"
+)
+
+type JobCheckStatus string
+
+const (
+	JobCheckStatusOk     JobCheckStatus = "OK"
+	JobCheckStatusLate   JobCheckStatus = "Late"
+	JobCheckStatusMissed JobCheckStatus = "Missed"
+)
+
+type NotificationStatus string
+
+const (
+	NotificationStatusPending NotificationStatus = "Pending"
+	NotificationStatusSent    NotificationStatus = "Sent"
+	NotificationStatusFailed  NotificationStatus = "Failed"
 )
 
 type RunShow struct {
+	ID            int64     `json:"id"`
+	JobName       string    `json:"job_name"`
+	StartTime     string    `json:"start_time"`
+	EndTime       string    `json:"end_time"`
+	LogFile       string    `json:"log_file"`
+	SystemLogFile string    `json:"system_log_file"`
+	Status        string    `json:"status"`
+	Duration      string    `json:"duration"`
+	Pid           string    `json:"pid"`
+	Pgid          string    `json:"pgid"`
+	ExitCode      string    `json:"exit_code"`
+	Signal        string    `json:"signal"`
+	UserCpuTime   string    `json:"user_cpu_time"`
+	SystemCpuTime string    `json:"system_cpu_time"`
+	MaxRss        string    `json:"max_rss"`
+	BlockInput    string    `json:"block_input"`
+	BlockOutput   string    `json:"block_output"`
+	ParentRunID   string    `json:"parent_run_id"`
+	Attempt       int64     `json:"attempt"`
+	Host          string    `json:"host"`
+	BlockedByRun  string    `json:"blocked_by_run"`
+	BlockedByJob  string    `json:"blocked_by_job"`
+	BlockedByLock string    `json:"blocked_by_lock"`
+	Command       string    `json:"command"`
+	RerunOfRunID  string    `json:"rerun_of_run_id"`
+	Attempts      []RunShow `json:"attempts,omitempty"`
+}
+
+func NewRunShow(run data.Run, jobName string, useLocalTime bool) RunShow {
+	return RunShow{
+		ID:            run.ID,
+		JobName:       jobName,
+		StartTime:     FormatTime(run.StartTime, useLocalTime),
+		EndTime:       FormatTime(run.EndTime.Time, useLocalTime),
+		LogFile:       run.LogFile,
+		SystemLogFile: run.ExecLogFile,
+		Status:        run.Status,
+		Duration:      FormatDuration(run.StartTime, run.EndTime.Time),
+		Pid:           FormatPid(run.Pid),
+		Pgid:          FormatPid(run.Pgid),
+		ExitCode:      FormatInt(run.ExitCode),
+		Signal:        run.Signal.String,
+		UserCpuTime:   FormatCpuTime(run.UserCpuMs),
+		SystemCpuTime: FormatCpuTime(run.SystemCpuMs),
+		MaxRss:        FormatMaxRss(run.MaxRssKb),
+		BlockInput:    FormatInt(run.BlockInput),
+		BlockOutput:   FormatInt(run.BlockOutput),
+		ParentRunID:   FormatInt(run.ParentRunID),
+		Attempt:       run.Attempt,
+		Host:          run.Host,
+		BlockedByRun:  FormatInt(run.BlockedByRunID),
+		BlockedByJob:  run.BlockedByJob.String,
+		BlockedByLock: run.BlockedByLockGroup.String,
+		Command:       run.Command.String,
+		RerunOfRunID:  FormatInt(run.RerunOfRunID),
+	}
+}
+
+type JobShow struct {
+	ID               int64         `json:"id"`
+	Name             string        `json:"name"`
+	NotifyLogContent bool          `json:"notify_log_content"`
+	Timeout          string        `json:"timeout"`
+	RetryAttempts    int64         `json:"retry_attempts"`
+	RetryBackoff     string        `json:"retry_backoff"`
+	RetryDelay       string        `json:"retry_delay"`
+	RetryExitCodes   string        `json:"retry_exit_codes"`
+	NotifyMode       string        `json:"notify_mode"`
+	NotifyReminder   string        `json:"notify_reminder"`
+	Schedule         string        `json:"schedule"`
+	ScheduleGrace    string        `json:"schedule_grace"`
+	NotifyTargets    []string      `json:"notify_targets"`
+	KeepLast         string        `json:"keep_last"`
+	KeepDays         string        `json:"keep_days"`
+	KeepFailedDays   string        `json:"keep_failed_days"`
+	Archive          string        `json:"archive"`
+	Concurrency      string        `json:"concurrency"`
+	QueueTimeout     string        `json:"queue_timeout"`
+	LockGroups       []string      `json:"lock_groups"`
+	Command          string        `json:"command"`
+	Stats            *JobStatsShow `json:"stats,omitempty"`
+}
+
+func NewJobShow(job data.Job, notifyTargets []string, lockGroups []string) JobShow {
+	return JobShow{
+		ID:               job.ID,
+		Name:             job.Name,
+		NotifyLogContent: job.NotifyLogContent,
+		Timeout:          FormatTimeout(job.TimeoutSeconds),
+		RetryAttempts:    job.RetryAttempts,
+		RetryBackoff:     job.RetryBackoff,
+		RetryDelay:       FormatSeconds(job.RetryDelaySeconds),
+		RetryExitCodes:   job.RetryExitCodes,
+		Schedule:         job.Schedule.String,
+		ScheduleGrace:    FormatTimeout(job.ScheduleGraceSeconds),
+		NotifyTargets:    append([]string{}, notifyTargets...),
+		NotifyMode:       job.NotifyMode,
+		NotifyReminder:   FormatSeconds(job.NotifyReminderSeconds),
+		KeepLast:         FormatInt(job.KeepLast),
+		KeepDays:         FormatInt(job.KeepDays),
+		KeepFailedDays:   FormatInt(job.KeepFailedDays),
+		Archive:          job.Archive,
+		Concurrency:      FormatConcurrency(ConcurrencyMode(job.Concurrency), job.ConcurrencyLimit),
+		QueueTimeout:     FormatTimeout(job.QueueTimeoutSeconds),
+		LockGroups:       append([]string{}, lockGroups...),
+		Command:          job.Command.String,
+	}
+}
+
+type JobStatsShow struct {
+	TotalRuns      int64            `json:"total_runs"`
+	StatusCounts   map[string]int64 `json:"status_counts"`
+	SuccessRate24h string           `json:"success_rate_24h"`
+	SuccessRate7d  string           `json:"success_rate_7d"`
+	SuccessRate30d string           `json:"success_rate_30d"`
+	LastSuccess    string           `json:"last_success"`
+	LastFailure    string           `json:"last_failure"`
+	StreakStatus   string           `json:"streak_status"`
+	StreakLength   int64            `json:"streak_length"`
+	DurationP50    string           `json:"duration_p50"`
+	DurationP95    string           `json:"duration_p95"`
+	DurationMax    string           `json:"duration_max"`
+}
+
+type JobCheck struct {
+	JobName    string `json:"job_name"`
+	Schedule   string `json:"schedule"`
+	Grace      string `json:"grace"`
+	LastRunID  string `json:"last_run_id"`
+	LastRun    string `json:"last_run"`
+	ExpectedAt string `json:"expected_at"`
+	Status     string `json:"status"`
+}
+
+type NotificationShow struct {
 	ID            int64  `json:"id"`
+	RunID         int64  `json:"run_id"`
 	JobName       string `json:"job_name"`
-	StartTime     string `json:"start_time"`
-	EndTime       string `json:"end_time"`
-	LogFile       string `json:"log_file"`
-	SystemLogFile string `json:"system_log_file"`
+	Target        string `json:"target"`
 	Status        string `json:"status"`
-	Duration      string `json:"duration"`
-	Pid           string `json:"pid"`
+	Label         string `json:"label"`
+	Attempts      int64  `json:"attempts"`
+	LastError     string `json:"last_error"`
+	CreatedAt     string `json:"created_at"`
+	NextAttemptAt string `json:"next_attempt_at"`
+	SentAt        string `json:"sent_at"`
 }
 
-type JobShow struct {
-	ID               int64  `json:"id"`
-	Name             string `json:"name"`
-	NotifyLogContent bool   `json:"notify_log_content"`
+type PrunedRunShow struct {
+	ID        int64    `json:"id"`
+	JobName   string   `json:"job_name"`
+	StartTime string   `json:"start_time"`
+	Status    string   `json:"status"`
+	Attempts  int      `json:"attempts"`
+	Files     []string `json:"files"`
+}
+
+type LostRunShow struct {
+	ID        int64  `json:"id"`
+	JobName   string `json:"job_name"`
+	StartTime string `json:"start_time"`
+	Pid       string `json:"pid"`
+	Reason    string `json:"reason"`
+}
+
+type OutputMatchShow struct {
+	RunID     int64  `json:"run_id"`
+	JobName   string `json:"job_name"`
+	StartTime string `json:"start_time"`
+	Line      int64  `json:"line"`
+	Text      string `json:"text"`
 }
@@ -2,8 +2,12 @@ package core
 
 import (
 	"database/sql"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/jedib0t/go-pretty/v6/text"
 )
 
 func FormatStatus(status RunStatus, showEmoji bool) string {
@@ -18,6 +22,47 @@ func FormatStatus(status RunStatus, showEmoji bool) string {
 		return formatEmoji("⚠️", showEmoji) + string(status)
 	case RunStatusTerminated:
 		return formatEmoji("💥", showEmoji) + string(status)
+	case RunStatusTimedOut:
+		return formatEmoji("⏰", showEmoji) + string(status)
+	case RunStatusLost:
+		return formatEmoji("👻", showEmoji) + string(status)
+	case RunStatusQueued:
+		return formatEmoji("🕒", showEmoji) + string(status)
+	}
+	return string(status)
+}
+
+// Returns the colour of a status, used if display.color.status is set for it.
+func StatusColor(status RunStatus) text.Color {
+	switch status {
+	case RunStatusSucceeded:
+		return text.FgGreen
+	case RunStatusFailed:
+		return text.FgHiRed
+	case RunStatusRunning:
+		return text.FgCyan
+	case RunStatusSkipped:
+		return text.FgYellow
+	case RunStatusTerminated:
+		return text.FgHiMagenta
+	case RunStatusTimedOut:
+		return text.FgRed
+	case RunStatusLost:
+		return text.FgHiBlack
+	case RunStatusQueued:
+		return text.FgBlue
+	}
+	return text.FgWhite
+}
+
+func FormatCheckStatus(status JobCheckStatus, showEmoji bool) string {
+	switch status {
+	case JobCheckStatusOk:
+		return formatEmoji("✅", showEmoji) + string(status)
+	case JobCheckStatusLate:
+		return formatEmoji("⏳", showEmoji) + string(status)
+	case JobCheckStatusMissed:
+		return formatEmoji("🚨", showEmoji) + string(status)
 	}
 	return string(status)
 }
@@ -46,9 +91,125 @@ func FormatPid(value sql.NullInt64) string {
 	return ""
 }
 
+func FormatInt(value sql.NullInt64) string {
+	if value.Valid {
+		return strconv.FormatInt(value.Int64, 10)
+	}
+	return ""
+}
+
+func FormatCpuTime(ms sql.NullInt64) string {
+	if ms.Valid {
+		return (time.Duration(ms.Int64) * time.Millisecond).String()
+	}
+	return ""
+}
+
+func FormatMaxRss(kb sql.NullInt64) string {
+	if !kb.Valid {
+		return ""
+	}
+	return formatBytes(kb.Int64 * 1024)
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return strconv.FormatInt(bytes, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func FormatTimeout(seconds sql.NullInt64) string {
+	if seconds.Valid {
+		return (time.Duration(seconds.Int64) * time.Second).String()
+	}
+	return ""
+}
+
 func FormatDuration(start time.Time, end time.Time) string {
 	if end.IsZero() {
 		return ""
 	}
 	return end.Sub(start).String()
 }
+
+// Formats the percentage of finished runs that succeeded. Blank if no runs
+// have finished.
+func FormatRate(succeeded int64, finished int64) string {
+	if finished == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f%%", float64(succeeded)/float64(finished)*100)
+}
+
+// Formats a streak of runs with the same outcome, eg. 3 Failed.
+func FormatStreak(status string, length int64) string {
+	if length == 0 {
+		return ""
+	}
+	return strconv.FormatInt(length, 10) + " " + status
+}
+
+func FormatSeconds(seconds int64) string {
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+func FormatExitCodes(exitCodes []int64) string {
+	var codes []string
+	for _, code := range exitCodes {
+		codes = append(codes, strconv.FormatInt(code, 10))
+	}
+	return strings.Join(codes, ",")
+}
+
+// Parses a comma separated list of exit codes, as stored against a job.
+func ParseExitCodes(exitCodes string) ([]int64, error) {
+	if exitCodes == "" {
+		return nil, nil
+	}
+	var codes []int64
+	for code := range strings.SplitSeq(exitCodes, ",") {
+		parsed, err := strconv.ParseInt(strings.TrimSpace(code), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, parsed)
+	}
+	return codes, nil
+}
+
+// Formats the concurrency policy of a job, eg. skip or parallel:3.
+func FormatConcurrency(mode ConcurrencyMode, limit int64) string {
+	if mode == ConcurrencyParallel {
+		return string(mode) + ":" + strconv.FormatInt(limit, 10)
+	}
+	return string(mode)
+}
+
+// Parses a concurrency policy, as formatted by FormatConcurrency. Returns the
+// mode and the number of runs of the job allowed at once, which is only more
+// than 1 for parallel.
+func ParseConcurrency(concurrency string) (ConcurrencyMode, int64, error) {
+	value, limitValue, hasLimit := strings.Cut(concurrency, ":")
+	mode := ConcurrencyMode(value)
+	switch mode {
+	case ConcurrencySkip, ConcurrencyQueue, ConcurrencyReplace:
+		if hasLimit {
+			return "", 0, fmt.Errorf("only parallel takes a number of runs: %s", concurrency)
+		}
+		return mode, 1, nil
+	case ConcurrencyParallel:
+		limit, err := strconv.ParseInt(limitValue, 10, 64)
+		if err != nil || limit < 1 {
+			return "", 0, fmt.Errorf("parallel must be given a number of runs of at least 1, eg. parallel:3: %s", concurrency)
+		}
+		return mode, limit, nil
+	}
+	return "", 0, fmt.Errorf("unknown concurrency: %s", concurrency)
+}
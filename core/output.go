@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"os"
 	"path"
+	"strconv"
 	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
@@ -137,6 +138,8 @@ const JobAttr = "job_name"
 const EventAttr = "event"
 const RunStatusAttr = "run_status"
 const RunPidAttr = "run_pid"
+const RunAttemptAttr = "run_attempt"
+const NotifyTargetAttr = "notify_target"
 
 type Event string
 
@@ -147,6 +150,22 @@ const EventRunTerminated Event = "run-terminated"
 const EventRunManuallyTerminated Event = "run-terminated"
 const EventRunSigterm Event = "run-sigterm"
 const EventRunSkipped Event = "run-skipped"
+const EventRunTimedOut Event = "run-timed-out"
+const EventRunSigkill Event = "run-sigkill"
+const EventRunSignal Event = "run-signal"
+const EventRunRetry Event = "run-retry"
+const EventRunPruned Event = "run-pruned"
+const EventRunArchived Event = "run-archived"
+const EventRunLost Event = "run-lost"
+const EventRunQueued Event = "run-queued"
+const EventRunReplaced Event = "run-replaced"
+const EventJobLate Event = "job-late"
+const EventJobMissed Event = "job-missed"
+const EventNotifySent Event = "notify-sent"
+const EventNotifyFailed Event = "notify-failed"
+const EventNotifyRetry Event = "notify-retry"
+const EventNotifyAbandoned Event = "notify-abandoned"
+const EventNotifySuppressed Event = "notify-suppressed"
 
 func LogRunId(runId int64) slog.Attr {
 	return slog.Int64(RunAttr, runId)
@@ -168,6 +187,14 @@ func LogRunPid(pid int) slog.Attr {
 	return slog.Int(RunPidAttr, pid)
 }
 
+func LogRunAttempt(attempt int64) slog.Attr {
+	return slog.Int64(RunAttemptAttr, attempt)
+}
+
+func LogNotifyTarget(target string) slog.Attr {
+	return slog.String(NotifyTargetAttr, target)
+}
+
 func LogRunCreated(
 	logger *slog.Logger,
 	runId int64,
@@ -226,6 +253,69 @@ func LogRunSentSigterm(
 	)
 }
 
+func LogRunSentSignal(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	pid int,
+	signal string,
+) {
+	logger.Info(
+		"Run sent "+signal,
+		LogEvent(EventRunSignal),
+		LogRunId(runId),
+		LogJobName(jobName),
+		LogRunPid(pid),
+	)
+}
+
+func LogRunSentSigkill(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	pid int,
+) {
+	logger.Warn(
+		"Run sent SIGKILL",
+		LogEvent(EventRunSigkill),
+		LogRunId(runId),
+		LogJobName(jobName),
+		LogRunPid(pid),
+	)
+}
+
+func LogRunTimedOut(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	timeout time.Duration,
+) {
+	logger.Error(
+		"Run has timed out after "+timeout.String(),
+		LogEvent(EventRunTimedOut),
+		LogRunId(runId),
+		LogJobName(jobName),
+	)
+}
+
+func LogRunRetrying(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	attempt int64,
+	maxAttempts int64,
+	delay time.Duration,
+) {
+	logger.Warn(
+		"Run failed. Retrying in "+delay.String()+
+			" (attempt "+strconv.FormatInt(attempt, 10)+"/"+strconv.FormatInt(maxAttempts, 10)+")",
+		LogEvent(EventRunRetry),
+		LogRunId(runId),
+		LogJobName(jobName),
+		LogRunAttempt(attempt),
+	)
+}
+
 func LogRunTerminated(
 	logger *slog.Logger,
 	runId int64,
@@ -257,11 +347,208 @@ func LogRunSkipped(
 	logger *slog.Logger,
 	runId int64,
 	jobName string,
+	reason string,
 ) {
 	logger.Warn(
-		"Run has been skipped. Job is already running.",
+		"Run has been skipped. "+reason,
 		LogEvent(EventRunSkipped),
 		LogRunId(runId),
 		LogJobName(jobName),
 	)
 }
+
+func LogRunQueued(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	reason string,
+) {
+	logger.Info(
+		"Run has been queued. "+reason,
+		LogEvent(EventRunQueued),
+		LogRunId(runId),
+		LogJobName(jobName),
+	)
+}
+
+func LogRunReplaced(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	pid int,
+) {
+	logger.Warn(
+		"Run is being replaced by a new run",
+		LogEvent(EventRunReplaced),
+		LogRunId(runId),
+		LogJobName(jobName),
+		LogRunPid(pid),
+	)
+}
+
+func LogRunPruned(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+) {
+	logger.Info(
+		"Run pruned",
+		LogEvent(EventRunPruned),
+		LogRunId(runId),
+		LogJobName(jobName),
+	)
+}
+
+func LogRunLost(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	pid int,
+	reason string,
+) {
+	logger.Error(
+		"Run has been lost: "+reason,
+		LogEvent(EventRunLost),
+		LogRunId(runId),
+		LogJobName(jobName),
+		LogRunPid(pid),
+	)
+}
+
+func LogRunArchived(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	logFile string,
+) {
+	logger.Info(
+		"Run log archived to "+logFile,
+		LogEvent(EventRunArchived),
+		LogRunId(runId),
+		LogJobName(jobName),
+	)
+}
+
+func LogJobLate(
+	logger *slog.Logger,
+	jobName string,
+	expectedAt time.Time,
+) {
+	logger.Warn(
+		"Job is late. Expected a run at "+expectedAt.String(),
+		LogEvent(EventJobLate),
+		LogJobName(jobName),
+	)
+}
+
+func LogJobMissed(
+	logger *slog.Logger,
+	jobName string,
+	expectedAt time.Time,
+) {
+	logger.Error(
+		"Job has missed its scheduled run at "+expectedAt.String(),
+		LogEvent(EventJobMissed),
+		LogJobName(jobName),
+	)
+}
+
+func LogRunNotifySent(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	target string,
+) {
+	logger.Info(
+		"Notification sent to "+target,
+		LogEvent(EventNotifySent),
+		LogRunId(runId),
+		LogJobName(jobName),
+		LogNotifyTarget(target),
+	)
+}
+
+func LogRunNotifyFailed(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	target string,
+	err error,
+) {
+	logger.Error(
+		"Unable to send notification to "+target+": "+err.Error(),
+		LogEvent(EventNotifyFailed),
+		LogRunId(runId),
+		LogJobName(jobName),
+		LogNotifyTarget(target),
+	)
+}
+
+func LogRunNotifySuppressed(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	mode NotifyMode,
+) {
+	logger.Info(
+		"Notification not sent. The job's state is unchanged and its notify mode is "+string(mode),
+		LogEvent(EventNotifySuppressed),
+		LogRunId(runId),
+		LogJobName(jobName),
+	)
+}
+
+func LogRunNotifyRetrying(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	target string,
+	nextAttemptAt time.Time,
+) {
+	logger.Warn(
+		"Notification to "+target+" will be retried at "+nextAttemptAt.Format(time.RFC3339),
+		LogEvent(EventNotifyRetry),
+		LogRunId(runId),
+		LogJobName(jobName),
+		LogNotifyTarget(target),
+	)
+}
+
+func LogRunNotifyAbandoned(
+	logger *slog.Logger,
+	runId int64,
+	jobName string,
+	target string,
+	attempts int64,
+) {
+	logger.Error(
+		"Notification to "+target+" failed after "+strconv.FormatInt(attempts, 10)+" attempts. It will not be retried",
+		LogEvent(EventNotifyAbandoned),
+		LogRunId(runId),
+		LogJobName(jobName),
+		LogNotifyTarget(target),
+	)
+}
+
+func LogJobCheckNotifySent(
+	logger *slog.Logger,
+	target string,
+) {
+	logger.Info(
+		"Notification sent to "+target,
+		LogEvent(EventNotifySent),
+		LogNotifyTarget(target),
+	)
+}
+
+func LogJobCheckNotifyFailed(
+	logger *slog.Logger,
+	target string,
+	err error,
+) {
+	logger.Error(
+		"Unable to send notification to "+target+": "+err.Error(),
+		LogEvent(EventNotifyFailed),
+		LogNotifyTarget(target),
+	)
+}
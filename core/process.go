@@ -0,0 +1,66 @@
+package core
+
+import (
+	"database/sql"
+	"errors"
+	"syscall"
+
+	"github.com/samcarswell/trochilus/data"
+)
+
+// Sends a signal to every process of a run, ie. the process group exec
+// starts it in. Runs started before process groups were recorded only have
+// their own process signalled.
+func SignalRun(run data.Run, sig syscall.Signal) error {
+	if run.Pgid.Valid {
+		return syscall.Kill(-int(run.Pgid.Int64), sig)
+	}
+	if !run.Pid.Valid {
+		return errors.New("run does not have a PID associated with it")
+	}
+	return syscall.Kill(int(run.Pid.Int64), sig)
+}
+
+// Returns whether any process of a run is still running.
+func IsRunAlive(run data.Run) bool {
+	err := SignalRun(run, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// Returns whether the process with pid is still running.
+func IsProcessAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// Returns whether the process with pid is still running and, where start
+// times can be read, started at startTime rather than reusing its PID.
+func IsSameProcess(pid int, startTime sql.NullInt64) bool {
+	current, err := ProcessStartTime(pid)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return IsProcessAlive(pid)
+	}
+	if err != nil {
+		return false
+	}
+	return !startTime.Valid || current == startTime.Int64
+}
+
+// Identifies a process, telling it apart from a later process that reuses
+// its PID. Fields that can't be read, eg. on platforms other than Linux, are
+// left unset.
+type ProcessIdentity struct {
+	StartTime sql.NullInt64
+	BootID    sql.NullString
+}
+
+func GetProcessIdentity(pid int) ProcessIdentity {
+	var identity ProcessIdentity
+	if startTime, err := ProcessStartTime(pid); err == nil {
+		identity.StartTime = sql.NullInt64{Int64: startTime, Valid: true}
+	}
+	if bootID, err := BootID(); err == nil {
+		identity.BootID = sql.NullString{String: bootID, Valid: true}
+	}
+	return identity
+}
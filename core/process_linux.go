@@ -0,0 +1,47 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Returns the start time of a process, in clock ticks since boot, from
+// /proc/<pid>/stat. Along with the boot ID it tells a process apart from a
+// later one that has reused its PID. Returns an error matching
+// os.ErrNotExist if there is no process with the PID, or it has exited and
+// is a zombie waiting to be reaped by its parent.
+func ProcessStartTime(pid int) (int64, error) {
+	file := "/proc/" + strconv.Itoa(pid) + "/stat"
+	stat, err := os.ReadFile(file)
+	if errors.Is(err, syscall.ESRCH) {
+		// The process exited while its stat was being read
+		return 0, os.ErrNotExist
+	}
+	if err != nil {
+		return 0, err
+	}
+	// The command name is in parentheses and may contain spaces, so fields
+	// are counted from after it, starting with the state as field 3
+	end := bytes.LastIndexByte(stat, ')')
+	fields := strings.Fields(string(stat[end+1:]))
+	if end < 0 || len(fields) < 20 {
+		return 0, errors.New("unable to read start time from " + file)
+	}
+	if fields[0] == "Z" {
+		return 0, os.ErrNotExist
+	}
+	return strconv.ParseInt(fields[19], 10, 64)
+}
+
+// Returns the ID of the current boot of the machine.
+func BootID() (string, error) {
+	id, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(id)), nil
+}
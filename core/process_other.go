@@ -0,0 +1,15 @@
+//go:build !linux
+
+package core
+
+import "errors"
+
+// Process start times are only read on Linux.
+func ProcessStartTime(pid int) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
+
+// Boot IDs are only read on Linux.
+func BootID() (string, error) {
+	return "", errors.ErrUnsupported
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/samcarswell/trochilus/data"
+)
+
+// The bounds used for an unset since or until, so the start_time range is
+// always bound and the runs are searched by idx_runs_start_time.
+var (
+	minRunTime = time.Time{}
+	maxRunTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// Filters runs by job name, status and host, all ignored when blank, and by
+// start time. Sort is a sort parsed with opts.ParseRunSort, defaulting to
+// start:desc when blank.
+type RunFilter struct {
+	JobName string
+	Status  string
+	Host    string
+	Since   sql.NullTime
+	Until   sql.NullTime
+	Sort    string
+	// A negative limit is no limit in sqlite
+	Limit  int64
+	Offset int64
+}
+
+// Returns the top level runs matching filter.
+func GetRuns(ctx context.Context, db *data.Queries, filter RunFilter) ([]data.GetRunRow, error) {
+	params := data.GetRunsParams{
+		JobName: filter.JobName,
+		Status:  filter.Status,
+		Host:    filter.Host,
+		Since:   formatRunTime(minRunTime),
+		Until:   formatRunTime(maxRunTime),
+		Sort:    filter.Sort,
+		Limit:   filter.Limit,
+		Offset:  filter.Offset,
+	}
+	if filter.Since.Valid {
+		params.Since = formatRunTime(filter.Since.Time)
+	}
+	if filter.Until.Valid {
+		params.Until = formatRunTime(filter.Until.Time)
+	}
+	if filter.Sort == "" {
+		params.Sort = "start:desc"
+	}
+	rows, err := db.GetRuns(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	runRows := make([]data.GetRunRow, 0, len(rows))
+	for _, row := range rows {
+		runRows = append(runRows, data.GetRunRow(row))
+	}
+	return runRows, nil
+}
+
+// Formats a time as start_time is stored by current_timestamp, so it compares
+// as text with the start time of a run.
+func formatRunTime(t time.Time) string {
+	return t.UTC().Format(time.DateTime)
+}
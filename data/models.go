@@ -10,18 +10,92 @@ import (
 )
 
 type Job struct {
-	ID               int64
-	Name             string
-	NotifyLogContent bool
+	ID                    int64
+	Name                  string
+	NotifyLogContent      bool
+	TimeoutSeconds        sql.NullInt64
+	RetryAttempts         int64
+	RetryBackoff          string
+	RetryDelaySeconds     int64
+	RetryExitCodes        string
+	Schedule              sql.NullString
+	ScheduleGraceSeconds  sql.NullInt64
+	ScheduleUpdatedAt     sql.NullTime
+	NotifyMode            string
+	NotifyReminderSeconds int64
+	KeepLast              sql.NullInt64
+	KeepDays              sql.NullInt64
+	KeepFailedDays        sql.NullInt64
+	Archive               string
+	Concurrency           string
+	ConcurrencyLimit      int64
+	QueueTimeoutSeconds   sql.NullInt64
+	Command               sql.NullString
+}
+
+type JobLockGroup struct {
+	JobID     int64
+	LockGroup string
+}
+
+type JobNotifyTarget struct {
+	JobID      int64
+	TargetName string
+}
+
+type Notification struct {
+	ID            int64
+	RunID         int64
+	TargetName    string
+	Status        string
+	Attempts      int64
+	LastError     sql.NullString
+	CreatedAt     time.Time
+	SentAt        sql.NullTime
+	NextAttemptAt int64
+	LockedUntil   int64
+	Label         string
+}
+
+type RunOutput struct {
+	ID        int64
+	RunID     int64
+	Chunk     int64
+	FirstLine int64
+	Content   string
+}
+
+type RunOutputFt struct {
+	Content string
 }
 
 type Run struct {
-	ID          int64
-	JobID       int64
-	StartTime   time.Time
-	EndTime     sql.NullTime
-	LogFile     string
-	ExecLogFile string
-	Status      string
-	Pid         sql.NullInt64
+	ID                 int64
+	JobID              int64
+	StartTime          time.Time
+	EndTime            sql.NullTime
+	LogFile            string
+	ExecLogFile        string
+	Status             string
+	Pid                sql.NullInt64
+	ExitCode           sql.NullInt64
+	Signal             sql.NullString
+	UserCpuMs          sql.NullInt64
+	SystemCpuMs        sql.NullInt64
+	MaxRssKb           sql.NullInt64
+	BlockInput         sql.NullInt64
+	BlockOutput        sql.NullInt64
+	ParentRunID        sql.NullInt64
+	Attempt            int64
+	Host               string
+	Pgid               sql.NullInt64
+	PidStartTime       sql.NullInt64
+	BootID             sql.NullString
+	BlockedByRunID     sql.NullInt64
+	BlockedByJob       sql.NullString
+	BlockedByLockGroup sql.NullString
+	Command            sql.NullString
+	RerunOfRunID       sql.NullInt64
+	ExecPid            sql.NullInt64
+	ExecPidStartTime   sql.NullInt64
 }
@@ -8,27 +8,234 @@ package data
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
+const addJobLockGroup = `-- name: AddJobLockGroup :exec
+insert into job_lock_groups (job_id, lock_group)
+values (?, ?)
+`
+
+type AddJobLockGroupParams struct {
+	JobID     int64
+	LockGroup string
+}
+
+func (q *Queries) AddJobLockGroup(ctx context.Context, arg AddJobLockGroupParams) error {
+	_, err := q.db.ExecContext(ctx, addJobLockGroup, arg.JobID, arg.LockGroup)
+	return err
+}
+
+const addJobNotifyTarget = `-- name: AddJobNotifyTarget :exec
+insert into job_notify_targets (job_id, target_name)
+values (?, ?)
+`
+
+type AddJobNotifyTargetParams struct {
+	JobID      int64
+	TargetName string
+}
+
+func (q *Queries) AddJobNotifyTarget(ctx context.Context, arg AddJobNotifyTargetParams) error {
+	_, err := q.db.ExecContext(ctx, addJobNotifyTarget, arg.JobID, arg.TargetName)
+	return err
+}
+
+const claimNotifications = `-- name: ClaimNotifications :many
+update notifications
+set locked_until = ?1
+where id in (
+    select id
+    from notifications
+    where status = "Pending"
+    and next_attempt_at <= ?2
+    and locked_until <= ?2
+    order by id
+    limit ?3
+)
+returning id, run_id, target_name, status, attempts, last_error, created_at, sent_at, next_attempt_at, locked_until, label
+`
+
+type ClaimNotificationsParams struct {
+	LockedUntil   int64
+	NextAttemptAt int64
+	Limit         int64
+}
+
+func (q *Queries) ClaimNotifications(ctx context.Context, arg ClaimNotificationsParams) ([]Notification, error) {
+	rows, err := q.db.QueryContext(ctx, claimNotifications, arg.LockedUntil, arg.NextAttemptAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Notification
+	for rows.Next() {
+		var i Notification
+		if err := rows.Scan(
+			&i.ID,
+			&i.RunID,
+			&i.TargetName,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.SentAt,
+			&i.NextAttemptAt,
+			&i.LockedUntil,
+			&i.Label,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countConsecutiveFailedRuns = `-- name: CountConsecutiveFailedRuns :one
+select count(*)
+from runs
+where job_id = ?1
+and parent_run_id is null
+and id < ?2
+and status in ("Failed", "Terminated", "TimedOut", "Lost")
+and id > coalesce((
+    select max(id)
+    from runs
+    where job_id = ?1
+    and parent_run_id is null
+    and id < ?2
+    and status = "Succeeded"
+), 0)
+`
+
+type CountConsecutiveFailedRunsParams struct {
+	JobID int64
+	ID    int64
+}
+
+func (q *Queries) CountConsecutiveFailedRuns(ctx context.Context, arg CountConsecutiveFailedRunsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countConsecutiveFailedRuns, arg.JobID, arg.ID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createJob = `-- name: CreateJob :one
 insert into jobs
-    (name, notify_log_content)
-values (?, ?)
+    (name, notify_log_content, timeout_seconds, retry_attempts, retry_backoff, retry_delay_seconds, retry_exit_codes, notify_mode, notify_reminder_seconds)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?)
 returning id
 `
 
 type CreateJobParams struct {
-	Name             string
-	NotifyLogContent bool
+	Name                  string
+	NotifyLogContent      bool
+	TimeoutSeconds        sql.NullInt64
+	RetryAttempts         int64
+	RetryBackoff          string
+	RetryDelaySeconds     int64
+	RetryExitCodes        string
+	NotifyMode            string
+	NotifyReminderSeconds int64
 }
 
 func (q *Queries) CreateJob(ctx context.Context, arg CreateJobParams) (int64, error) {
-	row := q.db.QueryRowContext(ctx, createJob, arg.Name, arg.NotifyLogContent)
+	row := q.db.QueryRowContext(ctx, createJob,
+		arg.Name,
+		arg.NotifyLogContent,
+		arg.TimeoutSeconds,
+		arg.RetryAttempts,
+		arg.RetryBackoff,
+		arg.RetryDelaySeconds,
+		arg.RetryExitCodes,
+		arg.NotifyMode,
+		arg.NotifyReminderSeconds,
+	)
 	var id int64
 	err := row.Scan(&id)
 	return id, err
 }
 
+const createRunOutput = `-- name: CreateRunOutput :exec
+insert into run_output
+    (run_id, chunk, first_line, content)
+values (?, ?, ?, ?)
+`
+
+type CreateRunOutputParams struct {
+	RunID     int64
+	Chunk     int64
+	FirstLine int64
+	Content   string
+}
+
+func (q *Queries) CreateRunOutput(ctx context.Context, arg CreateRunOutputParams) error {
+	_, err := q.db.ExecContext(ctx, createRunOutput,
+		arg.RunID,
+		arg.Chunk,
+		arg.FirstLine,
+		arg.Content,
+	)
+	return err
+}
+
+const deleteJobLockGroups = `-- name: DeleteJobLockGroups :exec
+delete from job_lock_groups
+where job_id = ?
+`
+
+func (q *Queries) DeleteJobLockGroups(ctx context.Context, jobID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteJobLockGroups, jobID)
+	return err
+}
+
+const deleteJobNotifyTargets = `-- name: DeleteJobNotifyTargets :exec
+delete from job_notify_targets
+where job_id = ?
+`
+
+func (q *Queries) DeleteJobNotifyTargets(ctx context.Context, jobID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteJobNotifyTargets, jobID)
+	return err
+}
+
+const deleteRun = `-- name: DeleteRun :exec
+delete from runs
+where id = ?1
+or parent_run_id = ?1
+`
+
+func (q *Queries) DeleteRun(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteRun, id)
+	return err
+}
+
+const deleteRunNotifications = `-- name: DeleteRunNotifications :exec
+delete from notifications
+where run_id = ?
+`
+
+func (q *Queries) DeleteRunNotifications(ctx context.Context, runID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteRunNotifications, runID)
+	return err
+}
+
+const deleteRunOutput = `-- name: DeleteRunOutput :exec
+delete from run_output
+where run_id = ?
+`
+
+func (q *Queries) DeleteRunOutput(ctx context.Context, runID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteRunOutput, runID)
+	return err
+}
+
 const endRun = `-- name: EndRun :exec
 update runs
 set end_time = current_timestamp, status = ?
@@ -45,44 +252,1155 @@ func (q *Queries) EndRun(ctx context.Context, arg EndRunParams) error {
 	return err
 }
 
-const getJob = `-- name: GetJob :one
+const enqueueNotification = `-- name: EnqueueNotification :exec
+insert into notifications
+    (run_id, target_name, label, next_attempt_at)
+values (?, ?, ?, ?)
+`
+
+type EnqueueNotificationParams struct {
+	RunID         int64
+	TargetName    string
+	Label         string
+	NextAttemptAt int64
+}
+
+func (q *Queries) EnqueueNotification(ctx context.Context, arg EnqueueNotificationParams) error {
+	_, err := q.db.ExecContext(ctx, enqueueNotification,
+		arg.RunID,
+		arg.TargetName,
+		arg.Label,
+		arg.NextAttemptAt,
+	)
+	return err
+}
+
+const getJob = `-- name: GetJob :one
+select
+    jobs.id, jobs.name, jobs.notify_log_content, jobs.timeout_seconds, jobs.retry_attempts, jobs.retry_backoff, jobs.retry_delay_seconds, jobs.retry_exit_codes, jobs.schedule, jobs.schedule_grace_seconds, jobs.schedule_updated_at, jobs.notify_mode, jobs.notify_reminder_seconds, jobs.keep_last, jobs.keep_days, jobs.keep_failed_days, jobs.archive, jobs.concurrency, jobs.concurrency_limit, jobs.queue_timeout_seconds, jobs.command
+from jobs
+where jobs.name = ?
+`
+
+type GetJobRow struct {
+	Job Job
+}
+
+func (q *Queries) GetJob(ctx context.Context, name string) (GetJobRow, error) {
+	row := q.db.QueryRowContext(ctx, getJob, name)
+	var i GetJobRow
+	err := row.Scan(
+		&i.Job.ID,
+		&i.Job.Name,
+		&i.Job.NotifyLogContent,
+		&i.Job.TimeoutSeconds,
+		&i.Job.RetryAttempts,
+		&i.Job.RetryBackoff,
+		&i.Job.RetryDelaySeconds,
+		&i.Job.RetryExitCodes,
+		&i.Job.Schedule,
+		&i.Job.ScheduleGraceSeconds,
+		&i.Job.ScheduleUpdatedAt,
+		&i.Job.NotifyMode,
+		&i.Job.NotifyReminderSeconds,
+		&i.Job.KeepLast,
+		&i.Job.KeepDays,
+		&i.Job.KeepFailedDays,
+		&i.Job.Archive,
+		&i.Job.Concurrency,
+		&i.Job.ConcurrencyLimit,
+		&i.Job.QueueTimeoutSeconds,
+		&i.Job.Command,
+	)
+	return i, err
+}
+
+const getJobLockGroups = `-- name: GetJobLockGroups :many
+select lock_group
+from job_lock_groups
+where job_id = ?
+order by lock_group
+`
+
+func (q *Queries) GetJobLockGroups(ctx context.Context, jobID int64) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getJobLockGroups, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var lock_group string
+		if err := rows.Scan(&lock_group); err != nil {
+			return nil, err
+		}
+		items = append(items, lock_group)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getJobNotifyTargets = `-- name: GetJobNotifyTargets :many
+select target_name
+from job_notify_targets
+where job_id = ?
+order by target_name
+`
+
+func (q *Queries) GetJobNotifyTargets(ctx context.Context, jobID int64) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getJobNotifyTargets, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var target_name string
+		if err := rows.Scan(&target_name); err != nil {
+			return nil, err
+		}
+		items = append(items, target_name)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getJobRuns = `-- name: GetJobRuns :many
+select
+    runs.id, runs.job_id, runs.start_time, runs.end_time, runs.log_file, runs.exec_log_file, runs.status, runs.pid, runs.exit_code, runs.signal, runs.user_cpu_ms, runs.system_cpu_ms, runs.max_rss_kb, runs.block_input, runs.block_output, runs.parent_run_id, runs.attempt, runs.host, runs.pgid, runs.pid_start_time, runs.boot_id, runs.blocked_by_run_id, runs.blocked_by_job, runs.blocked_by_lock_group, runs.command, runs.rerun_of_run_id, runs.exec_pid, runs.exec_pid_start_time
+from runs
+where runs.job_id = ?
+and runs.parent_run_id is null
+order by runs.start_time desc, runs.id desc
+`
+
+type GetJobRunsRow struct {
+	Run Run
+}
+
+func (q *Queries) GetJobRuns(ctx context.Context, jobID int64) ([]GetJobRunsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getJobRuns, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetJobRunsRow
+	for rows.Next() {
+		var i GetJobRunsRow
+		if err := rows.Scan(
+			&i.Run.ID,
+			&i.Run.JobID,
+			&i.Run.StartTime,
+			&i.Run.EndTime,
+			&i.Run.LogFile,
+			&i.Run.ExecLogFile,
+			&i.Run.Status,
+			&i.Run.Pid,
+			&i.Run.ExitCode,
+			&i.Run.Signal,
+			&i.Run.UserCpuMs,
+			&i.Run.SystemCpuMs,
+			&i.Run.MaxRssKb,
+			&i.Run.BlockInput,
+			&i.Run.BlockOutput,
+			&i.Run.ParentRunID,
+			&i.Run.Attempt,
+			&i.Run.Host,
+			&i.Run.Pgid,
+			&i.Run.PidStartTime,
+			&i.Run.BootID,
+			&i.Run.BlockedByRunID,
+			&i.Run.BlockedByJob,
+			&i.Run.BlockedByLockGroup,
+			&i.Run.Command,
+			&i.Run.RerunOfRunID,
+			&i.Run.ExecPid,
+			&i.Run.ExecPidStartTime,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getJobRunsWithStatus = `-- name: GetJobRunsWithStatus :many
+select
+    runs.id, runs.job_id, runs.start_time, runs.end_time, runs.log_file, runs.exec_log_file, runs.status, runs.pid, runs.exit_code, runs.signal, runs.user_cpu_ms, runs.system_cpu_ms, runs.max_rss_kb, runs.block_input, runs.block_output, runs.parent_run_id, runs.attempt, runs.host, runs.pgid, runs.pid_start_time, runs.boot_id, runs.blocked_by_run_id, runs.blocked_by_job, runs.blocked_by_lock_group, runs.command, runs.rerun_of_run_id, runs.exec_pid, runs.exec_pid_start_time,
+    jobs.id, jobs.name, jobs.notify_log_content, jobs.timeout_seconds, jobs.retry_attempts, jobs.retry_backoff, jobs.retry_delay_seconds, jobs.retry_exit_codes, jobs.schedule, jobs.schedule_grace_seconds, jobs.schedule_updated_at, jobs.notify_mode, jobs.notify_reminder_seconds, jobs.keep_last, jobs.keep_days, jobs.keep_failed_days, jobs.archive, jobs.concurrency, jobs.concurrency_limit, jobs.queue_timeout_seconds, jobs.command
+from runs, jobs
+where runs.job_id = jobs.id
+and runs.status = ?1
+and +runs.job_id = ?2
+and +runs.host = ?3
+and +runs.parent_run_id is null
+order by runs.start_time desc, runs.id desc
+`
+
+type GetJobRunsWithStatusParams struct {
+	Status string
+	JobID  int64
+	Host   string
+}
+
+type GetJobRunsWithStatusRow struct {
+	Run Run
+	Job Job
+}
+
+func (q *Queries) GetJobRunsWithStatus(ctx context.Context, arg GetJobRunsWithStatusParams) ([]GetJobRunsWithStatusRow, error) {
+	rows, err := q.db.QueryContext(ctx, getJobRunsWithStatus,
+		arg.Status,
+		arg.JobID,
+		arg.Host,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetJobRunsWithStatusRow
+	for rows.Next() {
+		var i GetJobRunsWithStatusRow
+		if err := rows.Scan(
+			&i.Run.ID,
+			&i.Run.JobID,
+			&i.Run.StartTime,
+			&i.Run.EndTime,
+			&i.Run.LogFile,
+			&i.Run.ExecLogFile,
+			&i.Run.Status,
+			&i.Run.Pid,
+			&i.Run.ExitCode,
+			&i.Run.Signal,
+			&i.Run.UserCpuMs,
+			&i.Run.SystemCpuMs,
+			&i.Run.MaxRssKb,
+			&i.Run.BlockInput,
+			&i.Run.BlockOutput,
+			&i.Run.ParentRunID,
+			&i.Run.Attempt,
+			&i.Run.Host,
+			&i.Run.Pgid,
+			&i.Run.PidStartTime,
+			&i.Run.BootID,
+			&i.Run.BlockedByRunID,
+			&i.Run.BlockedByJob,
+			&i.Run.BlockedByLockGroup,
+			&i.Run.Command,
+			&i.Run.RerunOfRunID,
+			&i.Run.ExecPid,
+			&i.Run.ExecPidStartTime,
+			&i.Job.ID,
+			&i.Job.Name,
+			&i.Job.NotifyLogContent,
+			&i.Job.TimeoutSeconds,
+			&i.Job.RetryAttempts,
+			&i.Job.RetryBackoff,
+			&i.Job.RetryDelaySeconds,
+			&i.Job.RetryExitCodes,
+			&i.Job.Schedule,
+			&i.Job.ScheduleGraceSeconds,
+			&i.Job.ScheduleUpdatedAt,
+			&i.Job.NotifyMode,
+			&i.Job.NotifyReminderSeconds,
+			&i.Job.KeepLast,
+			&i.Job.KeepDays,
+			&i.Job.KeepFailedDays,
+			&i.Job.Archive,
+			&i.Job.Concurrency,
+			&i.Job.ConcurrencyLimit,
+			&i.Job.QueueTimeoutSeconds,
+			&i.Job.Command,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getJobs = `-- name: GetJobs :many
+select
+    jobs.id, jobs.name, jobs.notify_log_content, jobs.timeout_seconds, jobs.retry_attempts, jobs.retry_backoff, jobs.retry_delay_seconds, jobs.retry_exit_codes, jobs.schedule, jobs.schedule_grace_seconds, jobs.schedule_updated_at, jobs.notify_mode, jobs.notify_reminder_seconds, jobs.keep_last, jobs.keep_days, jobs.keep_failed_days, jobs.archive, jobs.concurrency, jobs.concurrency_limit, jobs.queue_timeout_seconds, jobs.command
+from jobs
+`
+
+type GetJobsRow struct {
+	Job Job
+}
+
+func (q *Queries) GetJobs(ctx context.Context) ([]GetJobsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getJobs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetJobsRow
+	for rows.Next() {
+		var i GetJobsRow
+		if err := rows.Scan(
+			&i.Job.ID,
+			&i.Job.Name,
+			&i.Job.NotifyLogContent,
+			&i.Job.TimeoutSeconds,
+			&i.Job.RetryAttempts,
+			&i.Job.RetryBackoff,
+			&i.Job.RetryDelaySeconds,
+			&i.Job.RetryExitCodes,
+			&i.Job.Schedule,
+			&i.Job.ScheduleGraceSeconds,
+			&i.Job.ScheduleUpdatedAt,
+			&i.Job.NotifyMode,
+			&i.Job.NotifyReminderSeconds,
+			&i.Job.KeepLast,
+			&i.Job.KeepDays,
+			&i.Job.KeepFailedDays,
+			&i.Job.Archive,
+			&i.Job.Concurrency,
+			&i.Job.ConcurrencyLimit,
+			&i.Job.QueueTimeoutSeconds,
+			&i.Job.Command,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLastFinishedRun = `-- name: GetLastFinishedRun :one
+select
+    runs.id, runs.job_id, runs.start_time, runs.end_time, runs.log_file, runs.exec_log_file, runs.status, runs.pid, runs.exit_code, runs.signal, runs.user_cpu_ms, runs.system_cpu_ms, runs.max_rss_kb, runs.block_input, runs.block_output, runs.parent_run_id, runs.attempt, runs.host, runs.pgid, runs.pid_start_time, runs.boot_id, runs.blocked_by_run_id, runs.blocked_by_job, runs.blocked_by_lock_group, runs.command, runs.rerun_of_run_id, runs.exec_pid, runs.exec_pid_start_time
+from runs
+where runs.job_id = ?
+and runs.parent_run_id is null
+and runs.end_time is not null
+and runs.status != "Skipped"
+order by runs.start_time desc, runs.id desc
+limit 1
+`
+
+type GetLastFinishedRunRow struct {
+	Run Run
+}
+
+func (q *Queries) GetLastFinishedRun(ctx context.Context, jobID int64) (GetLastFinishedRunRow, error) {
+	row := q.db.QueryRowContext(ctx, getLastFinishedRun, jobID)
+	var i GetLastFinishedRunRow
+	err := row.Scan(
+		&i.Run.ID,
+		&i.Run.JobID,
+		&i.Run.StartTime,
+		&i.Run.EndTime,
+		&i.Run.LogFile,
+		&i.Run.ExecLogFile,
+		&i.Run.Status,
+		&i.Run.Pid,
+		&i.Run.ExitCode,
+		&i.Run.Signal,
+		&i.Run.UserCpuMs,
+		&i.Run.SystemCpuMs,
+		&i.Run.MaxRssKb,
+		&i.Run.BlockInput,
+		&i.Run.BlockOutput,
+		&i.Run.ParentRunID,
+		&i.Run.Attempt,
+		&i.Run.Host,
+		&i.Run.Pgid,
+		&i.Run.PidStartTime,
+		&i.Run.BootID,
+		&i.Run.BlockedByRunID,
+		&i.Run.BlockedByJob,
+		&i.Run.BlockedByLockGroup,
+		&i.Run.Command,
+		&i.Run.RerunOfRunID,
+		&i.Run.ExecPid,
+		&i.Run.ExecPidStartTime,
+	)
+	return i, err
+}
+
+const getLastJobNotification = `-- name: GetLastJobNotification :one
+select notifications.created_at
+from notifications, runs
+where notifications.run_id = runs.id
+and runs.job_id = ?
+order by notifications.created_at desc
+limit 1
+`
+
+func (q *Queries) GetLastJobNotification(ctx context.Context, jobID int64) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getLastJobNotification, jobID)
+	var created_at time.Time
+	err := row.Scan(&created_at)
+	return created_at, err
+}
+
+const getLastRun = `-- name: GetLastRun :one
+select
+    runs.id, runs.job_id, runs.start_time, runs.end_time, runs.log_file, runs.exec_log_file, runs.status, runs.pid, runs.exit_code, runs.signal, runs.user_cpu_ms, runs.system_cpu_ms, runs.max_rss_kb, runs.block_input, runs.block_output, runs.parent_run_id, runs.attempt, runs.host, runs.pgid, runs.pid_start_time, runs.boot_id, runs.blocked_by_run_id, runs.blocked_by_job, runs.blocked_by_lock_group, runs.command, runs.rerun_of_run_id, runs.exec_pid, runs.exec_pid_start_time
+from runs
+where runs.job_id = ?
+and runs.parent_run_id is null
+order by runs.start_time desc, runs.id desc
+limit 1
+`
+
+type GetLastRunRow struct {
+	Run Run
+}
+
+func (q *Queries) GetLastRun(ctx context.Context, jobID int64) (GetLastRunRow, error) {
+	row := q.db.QueryRowContext(ctx, getLastRun, jobID)
+	var i GetLastRunRow
+	err := row.Scan(
+		&i.Run.ID,
+		&i.Run.JobID,
+		&i.Run.StartTime,
+		&i.Run.EndTime,
+		&i.Run.LogFile,
+		&i.Run.ExecLogFile,
+		&i.Run.Status,
+		&i.Run.Pid,
+		&i.Run.ExitCode,
+		&i.Run.Signal,
+		&i.Run.UserCpuMs,
+		&i.Run.SystemCpuMs,
+		&i.Run.MaxRssKb,
+		&i.Run.BlockInput,
+		&i.Run.BlockOutput,
+		&i.Run.ParentRunID,
+		&i.Run.Attempt,
+		&i.Run.Host,
+		&i.Run.Pgid,
+		&i.Run.PidStartTime,
+		&i.Run.BootID,
+		&i.Run.BlockedByRunID,
+		&i.Run.BlockedByJob,
+		&i.Run.BlockedByLockGroup,
+		&i.Run.Command,
+		&i.Run.RerunOfRunID,
+		&i.Run.ExecPid,
+		&i.Run.ExecPidStartTime,
+	)
+	return i, err
+}
+
+const getLastSucceededRun = `-- name: GetLastSucceededRun :one
+select
+    runs.id, runs.job_id, runs.start_time, runs.end_time, runs.log_file, runs.exec_log_file, runs.status, runs.pid, runs.exit_code, runs.signal, runs.user_cpu_ms, runs.system_cpu_ms, runs.max_rss_kb, runs.block_input, runs.block_output, runs.parent_run_id, runs.attempt, runs.host, runs.pgid, runs.pid_start_time, runs.boot_id, runs.blocked_by_run_id, runs.blocked_by_job, runs.blocked_by_lock_group, runs.command, runs.rerun_of_run_id, runs.exec_pid, runs.exec_pid_start_time
+from runs
+where runs.job_id = ?
+and runs.parent_run_id is null
+and runs.status = "Succeeded"
+order by runs.start_time desc, runs.id desc
+limit 1
+`
+
+type GetLastSucceededRunRow struct {
+	Run Run
+}
+
+func (q *Queries) GetLastSucceededRun(ctx context.Context, jobID int64) (GetLastSucceededRunRow, error) {
+	row := q.db.QueryRowContext(ctx, getLastSucceededRun, jobID)
+	var i GetLastSucceededRunRow
+	err := row.Scan(
+		&i.Run.ID,
+		&i.Run.JobID,
+		&i.Run.StartTime,
+		&i.Run.EndTime,
+		&i.Run.LogFile,
+		&i.Run.ExecLogFile,
+		&i.Run.Status,
+		&i.Run.Pid,
+		&i.Run.ExitCode,
+		&i.Run.Signal,
+		&i.Run.UserCpuMs,
+		&i.Run.SystemCpuMs,
+		&i.Run.MaxRssKb,
+		&i.Run.BlockInput,
+		&i.Run.BlockOutput,
+		&i.Run.ParentRunID,
+		&i.Run.Attempt,
+		&i.Run.Host,
+		&i.Run.Pgid,
+		&i.Run.PidStartTime,
+		&i.Run.BootID,
+		&i.Run.BlockedByRunID,
+		&i.Run.BlockedByJob,
+		&i.Run.BlockedByLockGroup,
+		&i.Run.Command,
+		&i.Run.RerunOfRunID,
+		&i.Run.ExecPid,
+		&i.Run.ExecPidStartTime,
+	)
+	return i, err
+}
+
+const getLockGroupRuns = `-- name: GetLockGroupRuns :many
+select
+    runs.id, runs.job_id, runs.start_time, runs.end_time, runs.log_file, runs.exec_log_file, runs.status, runs.pid, runs.exit_code, runs.signal, runs.user_cpu_ms, runs.system_cpu_ms, runs.max_rss_kb, runs.block_input, runs.block_output, runs.parent_run_id, runs.attempt, runs.host, runs.pgid, runs.pid_start_time, runs.boot_id, runs.blocked_by_run_id, runs.blocked_by_job, runs.blocked_by_lock_group, runs.command, runs.rerun_of_run_id, runs.exec_pid, runs.exec_pid_start_time,
+    jobs.id, jobs.name, jobs.notify_log_content, jobs.timeout_seconds, jobs.retry_attempts, jobs.retry_backoff, jobs.retry_delay_seconds, jobs.retry_exit_codes, jobs.schedule, jobs.schedule_grace_seconds, jobs.schedule_updated_at, jobs.notify_mode, jobs.notify_reminder_seconds, jobs.keep_last, jobs.keep_days, jobs.keep_failed_days, jobs.archive, jobs.concurrency, jobs.concurrency_limit, jobs.queue_timeout_seconds, jobs.command
+from runs, jobs, job_lock_groups
+where runs.job_id = jobs.id
+and job_lock_groups.job_id = jobs.id
+and job_lock_groups.lock_group = ?1
+and runs.parent_run_id is null
+and runs.status = "Running"
+and runs.host = ?2
+order by runs.id desc
+`
+
+type GetLockGroupRunsParams struct {
+	LockGroup string
+	Host      string
+}
+
+type GetLockGroupRunsRow struct {
+	Run Run
+	Job Job
+}
+
+func (q *Queries) GetLockGroupRuns(ctx context.Context, arg GetLockGroupRunsParams) ([]GetLockGroupRunsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getLockGroupRuns, arg.LockGroup, arg.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetLockGroupRunsRow
+	for rows.Next() {
+		var i GetLockGroupRunsRow
+		if err := rows.Scan(
+			&i.Run.ID,
+			&i.Run.JobID,
+			&i.Run.StartTime,
+			&i.Run.EndTime,
+			&i.Run.LogFile,
+			&i.Run.ExecLogFile,
+			&i.Run.Status,
+			&i.Run.Pid,
+			&i.Run.ExitCode,
+			&i.Run.Signal,
+			&i.Run.UserCpuMs,
+			&i.Run.SystemCpuMs,
+			&i.Run.MaxRssKb,
+			&i.Run.BlockInput,
+			&i.Run.BlockOutput,
+			&i.Run.ParentRunID,
+			&i.Run.Attempt,
+			&i.Run.Host,
+			&i.Run.Pgid,
+			&i.Run.PidStartTime,
+			&i.Run.BootID,
+			&i.Run.BlockedByRunID,
+			&i.Run.BlockedByJob,
+			&i.Run.BlockedByLockGroup,
+			&i.Run.Command,
+			&i.Run.RerunOfRunID,
+			&i.Run.ExecPid,
+			&i.Run.ExecPidStartTime,
+			&i.Job.ID,
+			&i.Job.Name,
+			&i.Job.NotifyLogContent,
+			&i.Job.TimeoutSeconds,
+			&i.Job.RetryAttempts,
+			&i.Job.RetryBackoff,
+			&i.Job.RetryDelaySeconds,
+			&i.Job.RetryExitCodes,
+			&i.Job.Schedule,
+			&i.Job.ScheduleGraceSeconds,
+			&i.Job.ScheduleUpdatedAt,
+			&i.Job.NotifyMode,
+			&i.Job.NotifyReminderSeconds,
+			&i.Job.KeepLast,
+			&i.Job.KeepDays,
+			&i.Job.KeepFailedDays,
+			&i.Job.Archive,
+			&i.Job.Concurrency,
+			&i.Job.ConcurrencyLimit,
+			&i.Job.QueueTimeoutSeconds,
+			&i.Job.Command,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getNotifications = `-- name: GetNotifications :many
+select
+    notifications.id, notifications.run_id, notifications.target_name, notifications.status, notifications.attempts, notifications.last_error, notifications.created_at, notifications.sent_at, notifications.next_attempt_at, notifications.locked_until, notifications.label,
+    jobs.name
+from notifications, runs, jobs
+where notifications.run_id = runs.id
+and runs.job_id = jobs.id
+and (?1 = 0 or notifications.run_id = ?1)
+and (?2 = '' or notifications.status = ?2)
+order by notifications.id
+`
+
+type GetNotificationsParams struct {
+	Dollar1 interface{}
+	Dollar2 interface{}
+}
+
+type GetNotificationsRow struct {
+	Notification Notification
+	Name         string
+}
+
+func (q *Queries) GetNotifications(ctx context.Context, arg GetNotificationsParams) ([]GetNotificationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getNotifications, arg.Dollar1, arg.Dollar2)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetNotificationsRow
+	for rows.Next() {
+		var i GetNotificationsRow
+		if err := rows.Scan(
+			&i.Notification.ID,
+			&i.Notification.RunID,
+			&i.Notification.TargetName,
+			&i.Notification.Status,
+			&i.Notification.Attempts,
+			&i.Notification.LastError,
+			&i.Notification.CreatedAt,
+			&i.Notification.SentAt,
+			&i.Notification.NextAttemptAt,
+			&i.Notification.LockedUntil,
+			&i.Notification.Label,
+			&i.Name,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPreviousRunStatus = `-- name: GetPreviousRunStatus :one
+select status
+from runs
+where job_id = ?1
+and parent_run_id is null
+and id < ?2
+order by id desc
+limit 1
+`
+
+type GetPreviousRunStatusParams struct {
+	JobID int64
+	ID    int64
+}
+
+func (q *Queries) GetPreviousRunStatus(ctx context.Context, arg GetPreviousRunStatusParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, getPreviousRunStatus, arg.JobID, arg.ID)
+	var status string
+	err := row.Scan(&status)
+	return status, err
+}
+
+const getPruneRuns = `-- name: GetPruneRuns :many
+select
+    runs.id, runs.job_id, runs.start_time, runs.end_time, runs.log_file, runs.exec_log_file, runs.status, runs.pid, runs.exit_code, runs.signal, runs.user_cpu_ms, runs.system_cpu_ms, runs.max_rss_kb, runs.block_input, runs.block_output, runs.parent_run_id, runs.attempt, runs.host, runs.pgid, runs.pid_start_time, runs.boot_id, runs.blocked_by_run_id, runs.blocked_by_job, runs.blocked_by_lock_group, runs.command, runs.rerun_of_run_id, runs.exec_pid, runs.exec_pid_start_time,
+    jobs.id, jobs.name, jobs.notify_log_content, jobs.timeout_seconds, jobs.retry_attempts, jobs.retry_backoff, jobs.retry_delay_seconds, jobs.retry_exit_codes, jobs.schedule, jobs.schedule_grace_seconds, jobs.schedule_updated_at, jobs.notify_mode, jobs.notify_reminder_seconds, jobs.keep_last, jobs.keep_days, jobs.keep_failed_days, jobs.archive, jobs.concurrency, jobs.concurrency_limit, jobs.queue_timeout_seconds, jobs.command
+from runs, jobs
+where runs.job_id = jobs.id
+and runs.parent_run_id is null
+and (?1 = '' or jobs.name = ?1)
+order by runs.job_id, runs.id desc
+`
+
+type GetPruneRunsRow struct {
+	Run Run
+	Job Job
+}
+
+func (q *Queries) GetPruneRuns(ctx context.Context, dollar_1 interface{}) ([]GetPruneRunsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPruneRuns, dollar_1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPruneRunsRow
+	for rows.Next() {
+		var i GetPruneRunsRow
+		if err := rows.Scan(
+			&i.Run.ID,
+			&i.Run.JobID,
+			&i.Run.StartTime,
+			&i.Run.EndTime,
+			&i.Run.LogFile,
+			&i.Run.ExecLogFile,
+			&i.Run.Status,
+			&i.Run.Pid,
+			&i.Run.ExitCode,
+			&i.Run.Signal,
+			&i.Run.UserCpuMs,
+			&i.Run.SystemCpuMs,
+			&i.Run.MaxRssKb,
+			&i.Run.BlockInput,
+			&i.Run.BlockOutput,
+			&i.Run.ParentRunID,
+			&i.Run.Attempt,
+			&i.Run.Host,
+			&i.Run.Pgid,
+			&i.Run.PidStartTime,
+			&i.Run.BootID,
+			&i.Run.BlockedByRunID,
+			&i.Run.BlockedByJob,
+			&i.Run.BlockedByLockGroup,
+			&i.Run.Command,
+			&i.Run.RerunOfRunID,
+			&i.Run.ExecPid,
+			&i.Run.ExecPidStartTime,
+			&i.Job.ID,
+			&i.Job.Name,
+			&i.Job.NotifyLogContent,
+			&i.Job.TimeoutSeconds,
+			&i.Job.RetryAttempts,
+			&i.Job.RetryBackoff,
+			&i.Job.RetryDelaySeconds,
+			&i.Job.RetryExitCodes,
+			&i.Job.Schedule,
+			&i.Job.ScheduleGraceSeconds,
+			&i.Job.ScheduleUpdatedAt,
+			&i.Job.NotifyMode,
+			&i.Job.NotifyReminderSeconds,
+			&i.Job.KeepLast,
+			&i.Job.KeepDays,
+			&i.Job.KeepFailedDays,
+			&i.Job.Archive,
+			&i.Job.Concurrency,
+			&i.Job.ConcurrencyLimit,
+			&i.Job.QueueTimeoutSeconds,
+			&i.Job.Command,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRun = `-- name: GetRun :one
+select
+    runs.id, runs.job_id, runs.start_time, runs.end_time, runs.log_file, runs.exec_log_file, runs.status, runs.pid, runs.exit_code, runs.signal, runs.user_cpu_ms, runs.system_cpu_ms, runs.max_rss_kb, runs.block_input, runs.block_output, runs.parent_run_id, runs.attempt, runs.host, runs.pgid, runs.pid_start_time, runs.boot_id, runs.blocked_by_run_id, runs.blocked_by_job, runs.blocked_by_lock_group, runs.command, runs.rerun_of_run_id, runs.exec_pid, runs.exec_pid_start_time,
+    jobs.id, jobs.name, jobs.notify_log_content, jobs.timeout_seconds, jobs.retry_attempts, jobs.retry_backoff, jobs.retry_delay_seconds, jobs.retry_exit_codes, jobs.schedule, jobs.schedule_grace_seconds, jobs.schedule_updated_at, jobs.notify_mode, jobs.notify_reminder_seconds, jobs.keep_last, jobs.keep_days, jobs.keep_failed_days, jobs.archive, jobs.concurrency, jobs.concurrency_limit, jobs.queue_timeout_seconds, jobs.command
+from runs, jobs
+where runs.job_id = jobs.id
+and runs.id = ?
+`
+
+type GetRunRow struct {
+	Run Run
+	Job Job
+}
+
+func (q *Queries) GetRun(ctx context.Context, id int64) (GetRunRow, error) {
+	row := q.db.QueryRowContext(ctx, getRun, id)
+	var i GetRunRow
+	err := row.Scan(
+		&i.Run.ID,
+		&i.Run.JobID,
+		&i.Run.StartTime,
+		&i.Run.EndTime,
+		&i.Run.LogFile,
+		&i.Run.ExecLogFile,
+		&i.Run.Status,
+		&i.Run.Pid,
+		&i.Run.ExitCode,
+		&i.Run.Signal,
+		&i.Run.UserCpuMs,
+		&i.Run.SystemCpuMs,
+		&i.Run.MaxRssKb,
+		&i.Run.BlockInput,
+		&i.Run.BlockOutput,
+		&i.Run.ParentRunID,
+		&i.Run.Attempt,
+		&i.Run.Host,
+		&i.Run.Pgid,
+		&i.Run.PidStartTime,
+		&i.Run.BootID,
+		&i.Run.BlockedByRunID,
+		&i.Run.BlockedByJob,
+		&i.Run.BlockedByLockGroup,
+		&i.Run.Command,
+		&i.Run.RerunOfRunID,
+		&i.Run.ExecPid,
+		&i.Run.ExecPidStartTime,
+		&i.Job.ID,
+		&i.Job.Name,
+		&i.Job.NotifyLogContent,
+		&i.Job.TimeoutSeconds,
+		&i.Job.RetryAttempts,
+		&i.Job.RetryBackoff,
+		&i.Job.RetryDelaySeconds,
+		&i.Job.RetryExitCodes,
+		&i.Job.Schedule,
+		&i.Job.ScheduleGraceSeconds,
+		&i.Job.ScheduleUpdatedAt,
+		&i.Job.NotifyMode,
+		&i.Job.NotifyReminderSeconds,
+		&i.Job.KeepLast,
+		&i.Job.KeepDays,
+		&i.Job.KeepFailedDays,
+		&i.Job.Archive,
+		&i.Job.Concurrency,
+		&i.Job.ConcurrencyLimit,
+		&i.Job.QueueTimeoutSeconds,
+		&i.Job.Command,
+	)
+	return i, err
+}
+
+const getRunAttempts = `-- name: GetRunAttempts :many
+select
+    runs.id, runs.job_id, runs.start_time, runs.end_time, runs.log_file, runs.exec_log_file, runs.status, runs.pid, runs.exit_code, runs.signal, runs.user_cpu_ms, runs.system_cpu_ms, runs.max_rss_kb, runs.block_input, runs.block_output, runs.parent_run_id, runs.attempt, runs.host, runs.pgid, runs.pid_start_time, runs.boot_id, runs.blocked_by_run_id, runs.blocked_by_job, runs.blocked_by_lock_group, runs.command, runs.rerun_of_run_id, runs.exec_pid, runs.exec_pid_start_time
+from runs
+where runs.parent_run_id = ?
+order by runs.attempt
+`
+
+type GetRunAttemptsRow struct {
+	Run Run
+}
+
+func (q *Queries) GetRunAttempts(ctx context.Context, parentRunID sql.NullInt64) ([]GetRunAttemptsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRunAttempts, parentRunID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRunAttemptsRow
+	for rows.Next() {
+		var i GetRunAttemptsRow
+		if err := rows.Scan(
+			&i.Run.ID,
+			&i.Run.JobID,
+			&i.Run.StartTime,
+			&i.Run.EndTime,
+			&i.Run.LogFile,
+			&i.Run.ExecLogFile,
+			&i.Run.Status,
+			&i.Run.Pid,
+			&i.Run.ExitCode,
+			&i.Run.Signal,
+			&i.Run.UserCpuMs,
+			&i.Run.SystemCpuMs,
+			&i.Run.MaxRssKb,
+			&i.Run.BlockInput,
+			&i.Run.BlockOutput,
+			&i.Run.ParentRunID,
+			&i.Run.Attempt,
+			&i.Run.Host,
+			&i.Run.Pgid,
+			&i.Run.PidStartTime,
+			&i.Run.BootID,
+			&i.Run.BlockedByRunID,
+			&i.Run.BlockedByJob,
+			&i.Run.BlockedByLockGroup,
+			&i.Run.Command,
+			&i.Run.RerunOfRunID,
+			&i.Run.ExecPid,
+			&i.Run.ExecPidStartTime,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRunOutput = `-- name: GetRunOutput :many
+select content
+from run_output
+where run_id = ?
+order by chunk
+`
+
+func (q *Queries) GetRunOutput(ctx context.Context, runID int64) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getRunOutput, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return nil, err
+		}
+		items = append(items, content)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRuns = `-- name: GetRuns :many
+select
+    runs.id, runs.job_id, runs.start_time, runs.end_time, runs.log_file, runs.exec_log_file, runs.status, runs.pid, runs.exit_code, runs.signal, runs.user_cpu_ms, runs.system_cpu_ms, runs.max_rss_kb, runs.block_input, runs.block_output, runs.parent_run_id, runs.attempt, runs.host, runs.pgid, runs.pid_start_time, runs.boot_id, runs.blocked_by_run_id, runs.blocked_by_job, runs.blocked_by_lock_group, runs.command, runs.rerun_of_run_id, runs.exec_pid, runs.exec_pid_start_time,
+    jobs.id, jobs.name, jobs.notify_log_content, jobs.timeout_seconds, jobs.retry_attempts, jobs.retry_backoff, jobs.retry_delay_seconds, jobs.retry_exit_codes, jobs.schedule, jobs.schedule_grace_seconds, jobs.schedule_updated_at, jobs.notify_mode, jobs.notify_reminder_seconds, jobs.keep_last, jobs.keep_days, jobs.keep_failed_days, jobs.archive, jobs.concurrency, jobs.concurrency_limit, jobs.queue_timeout_seconds, jobs.command
+from runs, jobs
+where runs.job_id = jobs.id
+and +runs.parent_run_id is null
+and (?1 = '' or jobs.name = ?1)
+and (?2 = '' or runs.status = ?2)
+and (?3 = '' or runs.host = ?3)
+and runs.start_time >= cast(?4 as text)
+and runs.start_time < cast(?5 as text)
+order by
+    case when ?6 = 'start:asc' then runs.start_time end asc,
+    case when ?6 = 'start:desc' then runs.start_time end desc,
+    case when ?6 = 'duration:asc' then julianday(runs.end_time) - julianday(runs.start_time) end asc,
+    case when ?6 = 'duration:desc' then julianday(runs.end_time) - julianday(runs.start_time) end desc,
+    case when ?6 like '%:asc' then runs.id end asc,
+    runs.id desc
+limit ?7 offset ?8
+`
+
+type GetRunsParams struct {
+	JobName interface{}
+	Status  interface{}
+	Host    interface{}
+	Since   string
+	Until   string
+	Sort    interface{}
+	Limit   int64
+	Offset  int64
+}
+
+type GetRunsRow struct {
+	Run Run
+	Job Job
+}
+
+// The unary plus keeps idx_runs_parent_run_id, which nearly every run matches,
+// from being used over the start_time range of idx_runs_start_time
+func (q *Queries) GetRuns(ctx context.Context, arg GetRunsParams) ([]GetRunsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRuns,
+		arg.JobName,
+		arg.Status,
+		arg.Host,
+		arg.Since,
+		arg.Until,
+		arg.Sort,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRunsRow
+	for rows.Next() {
+		var i GetRunsRow
+		if err := rows.Scan(
+			&i.Run.ID,
+			&i.Run.JobID,
+			&i.Run.StartTime,
+			&i.Run.EndTime,
+			&i.Run.LogFile,
+			&i.Run.ExecLogFile,
+			&i.Run.Status,
+			&i.Run.Pid,
+			&i.Run.ExitCode,
+			&i.Run.Signal,
+			&i.Run.UserCpuMs,
+			&i.Run.SystemCpuMs,
+			&i.Run.MaxRssKb,
+			&i.Run.BlockInput,
+			&i.Run.BlockOutput,
+			&i.Run.ParentRunID,
+			&i.Run.Attempt,
+			&i.Run.Host,
+			&i.Run.Pgid,
+			&i.Run.PidStartTime,
+			&i.Run.BootID,
+			&i.Run.BlockedByRunID,
+			&i.Run.BlockedByJob,
+			&i.Run.BlockedByLockGroup,
+			&i.Run.Command,
+			&i.Run.RerunOfRunID,
+			&i.Run.ExecPid,
+			&i.Run.ExecPidStartTime,
+			&i.Job.ID,
+			&i.Job.Name,
+			&i.Job.NotifyLogContent,
+			&i.Job.TimeoutSeconds,
+			&i.Job.RetryAttempts,
+			&i.Job.RetryBackoff,
+			&i.Job.RetryDelaySeconds,
+			&i.Job.RetryExitCodes,
+			&i.Job.Schedule,
+			&i.Job.ScheduleGraceSeconds,
+			&i.Job.ScheduleUpdatedAt,
+			&i.Job.NotifyMode,
+			&i.Job.NotifyReminderSeconds,
+			&i.Job.KeepLast,
+			&i.Job.KeepDays,
+			&i.Job.KeepFailedDays,
+			&i.Job.Archive,
+			&i.Job.Concurrency,
+			&i.Job.ConcurrencyLimit,
+			&i.Job.QueueTimeoutSeconds,
+			&i.Job.Command,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRunStatusCounts = `-- name: GetRunStatusCounts :many
 select
-    jobs.id, jobs.name, jobs.notify_log_content
-from jobs
-where jobs.name = ?
+    runs.job_id,
+    runs.status,
+    count(*) as count
+from runs
+where runs.parent_run_id is null
+group by runs.job_id, runs.status
 `
 
-type GetJobRow struct {
-	Job Job
+type GetRunStatusCountsRow struct {
+	JobID  int64
+	Status string
+	Count  int64
 }
 
-func (q *Queries) GetJob(ctx context.Context, name string) (GetJobRow, error) {
-	row := q.db.QueryRowContext(ctx, getJob, name)
-	var i GetJobRow
-	err := row.Scan(&i.Job.ID, &i.Job.Name, &i.Job.NotifyLogContent)
-	return i, err
+func (q *Queries) GetRunStatusCounts(ctx context.Context) ([]GetRunStatusCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRunStatusCounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRunStatusCountsRow
+	for rows.Next() {
+		var i GetRunStatusCountsRow
+		if err := rows.Scan(&i.JobID, &i.Status, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getJobs = `-- name: GetJobs :many
+const getScheduledJobs = `-- name: GetScheduledJobs :many
 select
-    jobs.id, jobs.name, jobs.notify_log_content
+    jobs.id, jobs.name, jobs.notify_log_content, jobs.timeout_seconds, jobs.retry_attempts, jobs.retry_backoff, jobs.retry_delay_seconds, jobs.retry_exit_codes, jobs.schedule, jobs.schedule_grace_seconds, jobs.schedule_updated_at, jobs.notify_mode, jobs.notify_reminder_seconds, jobs.keep_last, jobs.keep_days, jobs.keep_failed_days, jobs.archive, jobs.concurrency, jobs.concurrency_limit, jobs.queue_timeout_seconds, jobs.command
 from jobs
+where jobs.schedule is not null
+order by jobs.name
 `
 
-type GetJobsRow struct {
+type GetScheduledJobsRow struct {
 	Job Job
 }
 
-func (q *Queries) GetJobs(ctx context.Context) ([]GetJobsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getJobs)
+func (q *Queries) GetScheduledJobs(ctx context.Context) ([]GetScheduledJobsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getScheduledJobs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetJobsRow
+	var items []GetScheduledJobsRow
 	for rows.Next() {
-		var i GetJobsRow
-		if err := rows.Scan(&i.Job.ID, &i.Job.Name, &i.Job.NotifyLogContent); err != nil {
+		var i GetScheduledJobsRow
+		if err := rows.Scan(
+			&i.Job.ID,
+			&i.Job.Name,
+			&i.Job.NotifyLogContent,
+			&i.Job.TimeoutSeconds,
+			&i.Job.RetryAttempts,
+			&i.Job.RetryBackoff,
+			&i.Job.RetryDelaySeconds,
+			&i.Job.RetryExitCodes,
+			&i.Job.Schedule,
+			&i.Job.ScheduleGraceSeconds,
+			&i.Job.ScheduleUpdatedAt,
+			&i.Job.NotifyMode,
+			&i.Job.NotifyReminderSeconds,
+			&i.Job.KeepLast,
+			&i.Job.KeepDays,
+			&i.Job.KeepFailedDays,
+			&i.Job.Archive,
+			&i.Job.Concurrency,
+			&i.Job.ConcurrencyLimit,
+			&i.Job.QueueTimeoutSeconds,
+			&i.Job.Command,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -96,62 +1414,32 @@ func (q *Queries) GetJobs(ctx context.Context) ([]GetJobsRow, error) {
 	return items, nil
 }
 
-const getRun = `-- name: GetRun :one
-select
-    runs.id, runs.job_id, runs.start_time, runs.end_time, runs.log_file, runs.exec_log_file, runs.status, runs.pid,
-    jobs.id, jobs.name, jobs.notify_log_content
-from runs, jobs
-where runs.job_id = jobs.id
-and runs.id = ?
-`
-
-type GetRunRow struct {
-	Run Run
-	Job Job
-}
-
-func (q *Queries) GetRun(ctx context.Context, id int64) (GetRunRow, error) {
-	row := q.db.QueryRowContext(ctx, getRun, id)
-	var i GetRunRow
-	err := row.Scan(
-		&i.Run.ID,
-		&i.Run.JobID,
-		&i.Run.StartTime,
-		&i.Run.EndTime,
-		&i.Run.LogFile,
-		&i.Run.ExecLogFile,
-		&i.Run.Status,
-		&i.Run.Pid,
-		&i.Job.ID,
-		&i.Job.Name,
-		&i.Job.NotifyLogContent,
-	)
-	return i, err
-}
-
-const getRuns = `-- name: GetRuns :many
+const getUnfinishedRuns = `-- name: GetUnfinishedRuns :many
 select
-    runs.id, runs.job_id, runs.start_time, runs.end_time, runs.log_file, runs.exec_log_file, runs.status, runs.pid,
-    jobs.id, jobs.name, jobs.notify_log_content
+    runs.id, runs.job_id, runs.start_time, runs.end_time, runs.log_file, runs.exec_log_file, runs.status, runs.pid, runs.exit_code, runs.signal, runs.user_cpu_ms, runs.system_cpu_ms, runs.max_rss_kb, runs.block_input, runs.block_output, runs.parent_run_id, runs.attempt, runs.host, runs.pgid, runs.pid_start_time, runs.boot_id, runs.blocked_by_run_id, runs.blocked_by_job, runs.blocked_by_lock_group, runs.command, runs.rerun_of_run_id, runs.exec_pid, runs.exec_pid_start_time,
+    jobs.id, jobs.name, jobs.notify_log_content, jobs.timeout_seconds, jobs.retry_attempts, jobs.retry_backoff, jobs.retry_delay_seconds, jobs.retry_exit_codes, jobs.schedule, jobs.schedule_grace_seconds, jobs.schedule_updated_at, jobs.notify_mode, jobs.notify_reminder_seconds, jobs.keep_last, jobs.keep_days, jobs.keep_failed_days, jobs.archive, jobs.concurrency, jobs.concurrency_limit, jobs.queue_timeout_seconds, jobs.command
 from runs, jobs
 where runs.job_id = jobs.id
-and (?1 = '' or jobs.name = ?1)
+and runs.status in ("Running", "Queued")
+and +runs.host in (?, '')
+and +runs.parent_run_id is null
+order by runs.start_time desc, runs.id desc
 `
 
-type GetRunsRow struct {
+type GetUnfinishedRunsRow struct {
 	Run Run
 	Job Job
 }
 
-func (q *Queries) GetRuns(ctx context.Context, dollar_1 interface{}) ([]GetRunsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getRuns, dollar_1)
+func (q *Queries) GetUnfinishedRuns(ctx context.Context, host string) ([]GetUnfinishedRunsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUnfinishedRuns, host)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetRunsRow
+	var items []GetUnfinishedRunsRow
 	for rows.Next() {
-		var i GetRunsRow
+		var i GetUnfinishedRunsRow
 		if err := rows.Scan(
 			&i.Run.ID,
 			&i.Run.JobID,
@@ -161,9 +1449,107 @@ func (q *Queries) GetRuns(ctx context.Context, dollar_1 interface{}) ([]GetRunsR
 			&i.Run.ExecLogFile,
 			&i.Run.Status,
 			&i.Run.Pid,
+			&i.Run.ExitCode,
+			&i.Run.Signal,
+			&i.Run.UserCpuMs,
+			&i.Run.SystemCpuMs,
+			&i.Run.MaxRssKb,
+			&i.Run.BlockInput,
+			&i.Run.BlockOutput,
+			&i.Run.ParentRunID,
+			&i.Run.Attempt,
+			&i.Run.Host,
+			&i.Run.Pgid,
+			&i.Run.PidStartTime,
+			&i.Run.BootID,
+			&i.Run.BlockedByRunID,
+			&i.Run.BlockedByJob,
+			&i.Run.BlockedByLockGroup,
+			&i.Run.Command,
+			&i.Run.RerunOfRunID,
+			&i.Run.ExecPid,
+			&i.Run.ExecPidStartTime,
 			&i.Job.ID,
 			&i.Job.Name,
 			&i.Job.NotifyLogContent,
+			&i.Job.TimeoutSeconds,
+			&i.Job.RetryAttempts,
+			&i.Job.RetryBackoff,
+			&i.Job.RetryDelaySeconds,
+			&i.Job.RetryExitCodes,
+			&i.Job.Schedule,
+			&i.Job.ScheduleGraceSeconds,
+			&i.Job.ScheduleUpdatedAt,
+			&i.Job.NotifyMode,
+			&i.Job.NotifyReminderSeconds,
+			&i.Job.KeepLast,
+			&i.Job.KeepDays,
+			&i.Job.KeepFailedDays,
+			&i.Job.Archive,
+			&i.Job.Concurrency,
+			&i.Job.ConcurrencyLimit,
+			&i.Job.QueueTimeoutSeconds,
+			&i.Job.Command,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const grepRunOutput = `-- name: GrepRunOutput :many
+select
+    run_output.run_id,
+    run_output.first_line,
+    run_output.content,
+    runs.start_time,
+    jobs.name
+from run_output_fts, run_output, runs, jobs
+where run_output_fts.rowid = run_output.id
+and run_output.run_id = runs.id
+and runs.job_id = jobs.id
+and run_output_fts.content like ?1
+and (?2 = '' or jobs.name = ?2)
+and runs.start_time >= ?3
+order by run_output.run_id desc, run_output.chunk
+`
+
+type GrepRunOutputParams struct {
+	Content   string
+	Dollar2   interface{}
+	StartTime time.Time
+}
+
+type GrepRunOutputRow struct {
+	RunID     int64
+	FirstLine int64
+	Content   string
+	StartTime time.Time
+	Name      string
+}
+
+func (q *Queries) GrepRunOutput(ctx context.Context, arg GrepRunOutputParams) ([]GrepRunOutputRow, error) {
+	rows, err := q.db.QueryContext(ctx, grepRunOutput, arg.Content, arg.Dollar2, arg.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GrepRunOutputRow
+	for rows.Next() {
+		var i GrepRunOutputRow
+		if err := rows.Scan(
+			&i.RunID,
+			&i.FirstLine,
+			&i.Content,
+			&i.StartTime,
+			&i.Name,
 		); err != nil {
 			return nil, err
 		}
@@ -191,40 +1577,206 @@ func (q *Queries) IsRunFinished(ctx context.Context, id int64) (bool, error) {
 	return column_1, err
 }
 
+const loseRun = `-- name: LoseRun :execrows
+update runs
+set end_time = current_timestamp, status = "Lost"
+where id = ?
+and status in ("Running", "Queued")
+`
+
+func (q *Queries) LoseRun(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, loseRun, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markNotificationFailed = `-- name: MarkNotificationFailed :exec
+update notifications
+set status = ?2,
+    attempts = attempts + 1,
+    last_error = ?3,
+    next_attempt_at = ?4,
+    locked_until = 0
+where id = ?1
+`
+
+type MarkNotificationFailedParams struct {
+	ID            int64
+	Status        string
+	LastError     sql.NullString
+	NextAttemptAt int64
+}
+
+func (q *Queries) MarkNotificationFailed(ctx context.Context, arg MarkNotificationFailedParams) error {
+	_, err := q.db.ExecContext(ctx, markNotificationFailed,
+		arg.ID,
+		arg.Status,
+		arg.LastError,
+		arg.NextAttemptAt,
+	)
+	return err
+}
+
+const markNotificationSent = `-- name: MarkNotificationSent :exec
+update notifications
+set status = "Sent",
+    attempts = attempts + 1,
+    last_error = null,
+    sent_at = current_timestamp,
+    locked_until = 0
+where id = ?
+`
+
+func (q *Queries) MarkNotificationSent(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, markNotificationSent, id)
+	return err
+}
+
+const queueRun = `-- name: QueueRun :one
+insert into runs
+    (job_id, start_time, log_file, exec_log_file, status, host, command, rerun_of_run_id, exec_pid, exec_pid_start_time, boot_id)
+values (?, current_timestamp, "", ?, "Queued", ?, ?, ?, ?, ?, ?)
+returning id
+`
+
+type QueueRunParams struct {
+	JobID            int64
+	ExecLogFile      string
+	Host             string
+	Command          sql.NullString
+	RerunOfRunID     sql.NullInt64
+	ExecPid          sql.NullInt64
+	ExecPidStartTime sql.NullInt64
+	BootID           sql.NullString
+}
+
+func (q *Queries) QueueRun(ctx context.Context, arg QueueRunParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, queueRun,
+		arg.JobID,
+		arg.ExecLogFile,
+		arg.Host,
+		arg.Command,
+		arg.RerunOfRunID,
+		arg.ExecPid,
+		arg.ExecPidStartTime,
+		arg.BootID,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
 const skipRun = `-- name: SkipRun :one
 insert into runs
-    (job_id, start_time, end_time, log_file, exec_log_file, status)
-values (?, current_timestamp, current_timestamp, "", ?, "Skipped")
+    (job_id, start_time, end_time, log_file, exec_log_file, status, host, command, rerun_of_run_id)
+values (?, current_timestamp, current_timestamp, "", ?, "Skipped", ?, ?, ?)
 returning id
 `
 
 type SkipRunParams struct {
-	JobID       int64
-	ExecLogFile string
+	JobID        int64
+	ExecLogFile  string
+	Host         string
+	Command      sql.NullString
+	RerunOfRunID sql.NullInt64
 }
 
 func (q *Queries) SkipRun(ctx context.Context, arg SkipRunParams) (int64, error) {
-	row := q.db.QueryRowContext(ctx, skipRun, arg.JobID, arg.ExecLogFile)
+	row := q.db.QueryRowContext(ctx, skipRun,
+		arg.JobID,
+		arg.ExecLogFile,
+		arg.Host,
+		arg.Command,
+		arg.RerunOfRunID,
+	)
 	var id int64
 	err := row.Scan(&id)
 	return id, err
 }
 
-const startRun = `-- name: StartRun :one
+const startAttempt = `-- name: StartAttempt :one
 insert into runs
-    (job_id, start_time, log_file, exec_log_file, status)
-values (?, current_timestamp, ?, ?, "Running")
+    (job_id, start_time, log_file, exec_log_file, status, parent_run_id, attempt, host, command)
+values (?, current_timestamp, ?, ?, "Running", ?, ?, ?, ?)
 returning id
 `
 
-type StartRunParams struct {
+type StartAttemptParams struct {
 	JobID       int64
 	LogFile     string
 	ExecLogFile string
+	ParentRunID sql.NullInt64
+	Attempt     int64
+	Host        string
+	Command     sql.NullString
+}
+
+func (q *Queries) StartAttempt(ctx context.Context, arg StartAttemptParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, startAttempt,
+		arg.JobID,
+		arg.LogFile,
+		arg.ExecLogFile,
+		arg.ParentRunID,
+		arg.Attempt,
+		arg.Host,
+		arg.Command,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const startQueuedRun = `-- name: StartQueuedRun :exec
+update runs
+set start_time = current_timestamp,
+    status = "Running",
+    log_file = ?2
+where id = ?1 and status = "Queued"
+`
+
+type StartQueuedRunParams struct {
+	ID      int64
+	LogFile string
+}
+
+func (q *Queries) StartQueuedRun(ctx context.Context, arg StartQueuedRunParams) error {
+	_, err := q.db.ExecContext(ctx, startQueuedRun, arg.ID, arg.LogFile)
+	return err
+}
+
+const startRun = `-- name: StartRun :one
+insert into runs
+    (job_id, start_time, log_file, exec_log_file, status, host, command, rerun_of_run_id, exec_pid, exec_pid_start_time, boot_id)
+values (?, current_timestamp, ?, ?, "Running", ?, ?, ?, ?, ?, ?)
+returning id
+`
+
+type StartRunParams struct {
+	JobID            int64
+	LogFile          string
+	ExecLogFile      string
+	Host             string
+	Command          sql.NullString
+	RerunOfRunID     sql.NullInt64
+	ExecPid          sql.NullInt64
+	ExecPidStartTime sql.NullInt64
+	BootID           sql.NullString
 }
 
 func (q *Queries) StartRun(ctx context.Context, arg StartRunParams) (int64, error) {
-	row := q.db.QueryRowContext(ctx, startRun, arg.JobID, arg.LogFile, arg.ExecLogFile)
+	row := q.db.QueryRowContext(ctx, startRun,
+		arg.JobID,
+		arg.LogFile,
+		arg.ExecLogFile,
+		arg.Host,
+		arg.Command,
+		arg.RerunOfRunID,
+		arg.ExecPid,
+		arg.ExecPidStartTime,
+		arg.BootID,
+	)
 	var id int64
 	err := row.Scan(&id)
 	return id, err
@@ -232,33 +1784,269 @@ func (q *Queries) StartRun(ctx context.Context, arg StartRunParams) (int64, erro
 
 const updateJob = `-- name: UpdateJob :exec
 update jobs
-set name = ?2, notify_log_content = ?3
+set name = ?2,
+    notify_log_content = ?3,
+    timeout_seconds = ?4,
+    retry_attempts = ?5,
+    retry_backoff = ?6,
+    retry_delay_seconds = ?7,
+    retry_exit_codes = ?8,
+    notify_mode = ?9,
+    notify_reminder_seconds = ?10
 where id == ?1
 `
 
 type UpdateJobParams struct {
-	ID               int64
-	Name             string
-	NotifyLogContent bool
+	ID                    int64
+	Name                  string
+	NotifyLogContent      bool
+	TimeoutSeconds        sql.NullInt64
+	RetryAttempts         int64
+	RetryBackoff          string
+	RetryDelaySeconds     int64
+	RetryExitCodes        string
+	NotifyMode            string
+	NotifyReminderSeconds int64
 }
 
 func (q *Queries) UpdateJob(ctx context.Context, arg UpdateJobParams) error {
-	_, err := q.db.ExecContext(ctx, updateJob, arg.ID, arg.Name, arg.NotifyLogContent)
+	_, err := q.db.ExecContext(ctx, updateJob,
+		arg.ID,
+		arg.Name,
+		arg.NotifyLogContent,
+		arg.TimeoutSeconds,
+		arg.RetryAttempts,
+		arg.RetryBackoff,
+		arg.RetryDelaySeconds,
+		arg.RetryExitCodes,
+		arg.NotifyMode,
+		arg.NotifyReminderSeconds,
+	)
+	return err
+}
+
+const updateJobArchive = `-- name: UpdateJobArchive :exec
+update jobs
+set archive = ?2
+where id == ?1
+`
+
+type UpdateJobArchiveParams struct {
+	ID      int64
+	Archive string
+}
+
+func (q *Queries) UpdateJobArchive(ctx context.Context, arg UpdateJobArchiveParams) error {
+	_, err := q.db.ExecContext(ctx, updateJobArchive, arg.ID, arg.Archive)
+	return err
+}
+
+const updateJobCommand = `-- name: UpdateJobCommand :exec
+update jobs
+set command = ?2
+where id == ?1
+`
+
+type UpdateJobCommandParams struct {
+	ID      int64
+	Command sql.NullString
+}
+
+func (q *Queries) UpdateJobCommand(ctx context.Context, arg UpdateJobCommandParams) error {
+	_, err := q.db.ExecContext(ctx, updateJobCommand, arg.ID, arg.Command)
+	return err
+}
+
+const updateJobConcurrency = `-- name: UpdateJobConcurrency :exec
+update jobs
+set concurrency = ?2,
+    concurrency_limit = ?3,
+    queue_timeout_seconds = ?4
+where id == ?1
+`
+
+type UpdateJobConcurrencyParams struct {
+	ID                  int64
+	Concurrency         string
+	ConcurrencyLimit    int64
+	QueueTimeoutSeconds sql.NullInt64
+}
+
+func (q *Queries) UpdateJobConcurrency(ctx context.Context, arg UpdateJobConcurrencyParams) error {
+	_, err := q.db.ExecContext(ctx, updateJobConcurrency,
+		arg.ID,
+		arg.Concurrency,
+		arg.ConcurrencyLimit,
+		arg.QueueTimeoutSeconds,
+	)
+	return err
+}
+
+const updateJobRetention = `-- name: UpdateJobRetention :exec
+update jobs
+set keep_last = ?2,
+    keep_days = ?3,
+    keep_failed_days = ?4
+where id == ?1
+`
+
+type UpdateJobRetentionParams struct {
+	ID             int64
+	KeepLast       sql.NullInt64
+	KeepDays       sql.NullInt64
+	KeepFailedDays sql.NullInt64
+}
+
+func (q *Queries) UpdateJobRetention(ctx context.Context, arg UpdateJobRetentionParams) error {
+	_, err := q.db.ExecContext(ctx, updateJobRetention,
+		arg.ID,
+		arg.KeepLast,
+		arg.KeepDays,
+		arg.KeepFailedDays,
+	)
+	return err
+}
+
+const updateJobSchedule = `-- name: UpdateJobSchedule :exec
+update jobs
+set schedule = ?2,
+    schedule_grace_seconds = ?3,
+    schedule_updated_at = case
+        when schedule is ?2 then schedule_updated_at
+        else current_timestamp
+    end
+where id == ?1
+`
+
+type UpdateJobScheduleParams struct {
+	ID                   int64
+	Schedule             sql.NullString
+	ScheduleGraceSeconds sql.NullInt64
+}
+
+func (q *Queries) UpdateJobSchedule(ctx context.Context, arg UpdateJobScheduleParams) error {
+	_, err := q.db.ExecContext(ctx, updateJobSchedule, arg.ID, arg.Schedule, arg.ScheduleGraceSeconds)
+	return err
+}
+
+const updateRunAttempt = `-- name: UpdateRunAttempt :exec
+update runs
+set attempt = ?2
+where id == ?1
+`
+
+type UpdateRunAttemptParams struct {
+	ID      int64
+	Attempt int64
+}
+
+func (q *Queries) UpdateRunAttempt(ctx context.Context, arg UpdateRunAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, updateRunAttempt, arg.ID, arg.Attempt)
+	return err
+}
+
+const updateRunBlockedBy = `-- name: UpdateRunBlockedBy :exec
+update runs
+set blocked_by_run_id = ?2,
+    blocked_by_job = ?3,
+    blocked_by_lock_group = ?4
+where id = ?1
+`
+
+type UpdateRunBlockedByParams struct {
+	ID                 int64
+	BlockedByRunID     sql.NullInt64
+	BlockedByJob       sql.NullString
+	BlockedByLockGroup sql.NullString
+}
+
+func (q *Queries) UpdateRunBlockedBy(ctx context.Context, arg UpdateRunBlockedByParams) error {
+	_, err := q.db.ExecContext(ctx, updateRunBlockedBy,
+		arg.ID,
+		arg.BlockedByRunID,
+		arg.BlockedByJob,
+		arg.BlockedByLockGroup,
+	)
+	return err
+}
+
+const updateRunLogFile = `-- name: UpdateRunLogFile :exec
+update runs
+set log_file = ?2
+where id = ?1 or parent_run_id = ?1
+`
+
+type UpdateRunLogFileParams struct {
+	ID      int64
+	LogFile string
+}
+
+func (q *Queries) UpdateRunLogFile(ctx context.Context, arg UpdateRunLogFileParams) error {
+	_, err := q.db.ExecContext(ctx, updateRunLogFile, arg.ID, arg.LogFile)
 	return err
 }
 
 const updateRunPid = `-- name: UpdateRunPid :exec
 update runs
-set pid = ?2
+set pid = ?2,
+    pgid = ?3,
+    pid_start_time = ?4,
+    boot_id = ?5
 where id == ?1
 `
 
 type UpdateRunPidParams struct {
-	ID  int64
-	Pid sql.NullInt64
+	ID           int64
+	Pid          sql.NullInt64
+	Pgid         sql.NullInt64
+	PidStartTime sql.NullInt64
+	BootID       sql.NullString
 }
 
 func (q *Queries) UpdateRunPid(ctx context.Context, arg UpdateRunPidParams) error {
-	_, err := q.db.ExecContext(ctx, updateRunPid, arg.ID, arg.Pid)
+	_, err := q.db.ExecContext(ctx, updateRunPid,
+		arg.ID,
+		arg.Pid,
+		arg.Pgid,
+		arg.PidStartTime,
+		arg.BootID,
+	)
+	return err
+}
+
+const updateRunResult = `-- name: UpdateRunResult :exec
+update runs
+set exit_code = ?2,
+    signal = ?3,
+    user_cpu_ms = ?4,
+    system_cpu_ms = ?5,
+    max_rss_kb = ?6,
+    block_input = ?7,
+    block_output = ?8
+where id == ?1
+`
+
+type UpdateRunResultParams struct {
+	ID          int64
+	ExitCode    sql.NullInt64
+	Signal      sql.NullString
+	UserCpuMs   sql.NullInt64
+	SystemCpuMs sql.NullInt64
+	MaxRssKb    sql.NullInt64
+	BlockInput  sql.NullInt64
+	BlockOutput sql.NullInt64
+}
+
+func (q *Queries) UpdateRunResult(ctx context.Context, arg UpdateRunResultParams) error {
+	_, err := q.db.ExecContext(ctx, updateRunResult,
+		arg.ID,
+		arg.ExitCode,
+		arg.Signal,
+		arg.UserCpuMs,
+		arg.SystemCpuMs,
+		arg.MaxRssKb,
+		arg.BlockInput,
+		arg.BlockOutput,
+	)
 	return err
 }
@@ -0,0 +1,46 @@
+-- migrate:up
+create table if not exists runs1 (
+    id integer primary key autoincrement,
+    job_id int not null,
+    start_time timestamp not null,
+    end_time timestamp,
+    log_file varchar not null,
+    exec_log_file varchar not null,
+    status varchar not null,
+    pid int default null,
+    constraint fk_job_id foreign key(job_id) references jobs(id),
+    constraint ck_status check (status in ("Running", "Skipped", "Succeeded", "Failed", "Terminated", "TimedOut"))
+);
+insert into runs1
+(id, job_id, start_time, end_time, log_file, exec_log_file, status, pid)
+    select id, job_id, start_time, end_time, log_file, exec_log_file, status, pid
+    from runs;
+drop table runs;
+alter table runs1 rename to runs;
+
+alter table jobs
+add column timeout_seconds int default null;
+
+-- migrate:down
+create table if not exists runs1 (
+    id integer primary key autoincrement,
+    job_id int not null,
+    start_time timestamp not null,
+    end_time timestamp,
+    log_file varchar not null,
+    exec_log_file varchar not null,
+    status varchar not null,
+    pid int default null,
+    constraint fk_job_id foreign key(job_id) references jobs(id),
+    constraint ck_status check (status in ("Running", "Skipped", "Succeeded", "Failed", "Terminated"))
+);
+insert into runs1
+(id, job_id, start_time, end_time, log_file, exec_log_file, status, pid)
+    select id, job_id, start_time, end_time, log_file, exec_log_file,
+        case when status = "TimedOut" then "Failed" else status end, pid
+    from runs;
+drop table runs;
+alter table runs1 rename to runs;
+
+alter table jobs
+drop column timeout_seconds;
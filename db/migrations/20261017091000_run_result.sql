@@ -0,0 +1,31 @@
+-- migrate:up
+alter table runs
+add column exit_code int default null;
+alter table runs
+add column signal varchar default null;
+alter table runs
+add column user_cpu_ms int default null;
+alter table runs
+add column system_cpu_ms int default null;
+alter table runs
+add column max_rss_kb int default null;
+alter table runs
+add column block_input int default null;
+alter table runs
+add column block_output int default null;
+
+-- migrate:down
+alter table runs
+drop column exit_code;
+alter table runs
+drop column signal;
+alter table runs
+drop column user_cpu_ms;
+alter table runs
+drop column system_cpu_ms;
+alter table runs
+drop column max_rss_kb;
+alter table runs
+drop column block_input;
+alter table runs
+drop column block_output;
@@ -0,0 +1,33 @@
+-- migrate:up
+alter table jobs
+add column retry_attempts int not null default 1;
+alter table jobs
+add column retry_backoff varchar not null default "fixed"
+    constraint ck_retry_backoff check (retry_backoff in ("fixed", "exponential"));
+alter table jobs
+add column retry_delay_seconds int not null default 0;
+alter table jobs
+add column retry_exit_codes varchar not null default "";
+
+alter table runs
+add column parent_run_id int default null
+    constraint fk_parent_run_id references runs(id);
+alter table runs
+add column attempt int not null default 1;
+
+-- migrate:down
+alter table jobs
+drop column retry_attempts;
+alter table jobs
+drop column retry_backoff;
+alter table jobs
+drop column retry_delay_seconds;
+alter table jobs
+drop column retry_exit_codes;
+
+delete from runs
+where parent_run_id is not null;
+alter table runs
+drop column parent_run_id;
+alter table runs
+drop column attempt;
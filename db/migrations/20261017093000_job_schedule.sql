@@ -0,0 +1,15 @@
+-- migrate:up
+alter table jobs
+add column schedule varchar default null;
+alter table jobs
+add column schedule_grace_seconds int default null;
+alter table jobs
+add column schedule_updated_at timestamp default null;
+
+-- migrate:down
+alter table jobs
+drop column schedule;
+alter table jobs
+drop column schedule_grace_seconds;
+alter table jobs
+drop column schedule_updated_at;
@@ -0,0 +1,10 @@
+-- migrate:up
+create table if not exists job_notify_targets (
+    job_id int not null,
+    target_name varchar not null,
+    primary key (job_id, target_name),
+    constraint fk_job_id foreign key(job_id) references jobs(id) on delete cascade
+);
+
+-- migrate:down
+drop table if exists job_notify_targets;
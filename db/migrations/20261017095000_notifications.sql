@@ -0,0 +1,22 @@
+-- migrate:up
+create table if not exists notifications (
+    id integer primary key autoincrement,
+    run_id int not null,
+    target_name varchar not null,
+    status varchar not null default "Pending",
+    attempts int not null default 0,
+    last_error varchar,
+    created_at timestamp not null default current_timestamp,
+    sent_at timestamp,
+    next_attempt_at int not null, -- unix time
+    locked_until int not null default 0, -- unix time
+    constraint fk_run_id foreign key(run_id) references runs(id) on delete cascade,
+    check (status in ("Pending", "Sent", "Failed"))
+);
+create index if not exists idx_notifications_status_next_attempt_at on notifications(status, next_attempt_at);
+create index if not exists idx_notifications_run_id on notifications(run_id);
+
+-- migrate:down
+drop index if exists idx_notifications_run_id;
+drop index if exists idx_notifications_status_next_attempt_at;
+drop table if exists notifications;
@@ -0,0 +1,18 @@
+-- migrate:up
+alter table jobs
+add column notify_mode varchar not null default "always"
+    constraint ck_notify_mode check (notify_mode in ("always", "on-change", "on-failure-with-reminder"));
+alter table jobs
+add column notify_reminder_seconds int not null default 3600;
+
+alter table notifications
+add column label varchar not null default "";
+
+-- migrate:down
+alter table jobs
+drop column notify_mode;
+alter table jobs
+drop column notify_reminder_seconds;
+
+alter table notifications
+drop column label;
@@ -0,0 +1,15 @@
+-- migrate:up
+alter table jobs
+add column keep_last int default null;
+alter table jobs
+add column keep_days int default null;
+alter table jobs
+add column keep_failed_days int default null;
+
+-- migrate:down
+alter table jobs
+drop column keep_last;
+alter table jobs
+drop column keep_days;
+alter table jobs
+drop column keep_failed_days;
@@ -0,0 +1,8 @@
+-- migrate:up
+alter table jobs
+add column archive varchar not null default "none"
+    constraint ck_archive check (archive in ("none", "gzip", "zstd"));
+
+-- migrate:down
+alter table jobs
+drop column archive;
@@ -0,0 +1,18 @@
+-- migrate:up
+-- The full-text index of run_output is created by troc rather than here, as
+-- the sqlite driver used to apply migrations is built without FTS5
+create table if not exists run_output (
+    id integer primary key autoincrement,
+    run_id int not null,
+    chunk int not null,
+    first_line int not null,
+    content text not null,
+    constraint fk_run_id foreign key(run_id) references runs(id) on delete cascade,
+    unique (run_id, chunk)
+);
+
+-- migrate:down
+-- run_output_fts is dropped along with run_output, which drops the triggers
+-- that keep it up to date, so troc recreates both on the next up
+drop table if exists run_output_fts;
+drop table if exists run_output;
@@ -0,0 +1,19 @@
+-- migrate:up
+alter table runs
+add column host varchar not null default "";
+
+create index if not exists idx_runs_start_time on runs(start_time);
+create index if not exists idx_runs_job_id_start_time on runs(job_id, start_time);
+create index if not exists idx_runs_status on runs(status);
+create index if not exists idx_runs_host on runs(host);
+create index if not exists idx_runs_parent_run_id on runs(parent_run_id);
+
+-- migrate:down
+drop index if exists idx_runs_parent_run_id;
+drop index if exists idx_runs_host;
+drop index if exists idx_runs_status;
+drop index if exists idx_runs_job_id_start_time;
+drop index if exists idx_runs_start_time;
+
+alter table runs
+drop column host;
@@ -0,0 +1,7 @@
+-- migrate:up
+alter table runs
+add column pgid integer;
+
+-- migrate:down
+alter table runs
+drop column pgid;
@@ -0,0 +1,94 @@
+-- migrate:up
+create table if not exists runs1 (
+    id integer primary key autoincrement,
+    job_id int not null,
+    start_time timestamp not null,
+    end_time timestamp,
+    log_file varchar not null,
+    exec_log_file varchar not null,
+    status varchar not null,
+    pid int default null,
+    exit_code int default null,
+    signal varchar default null,
+    user_cpu_ms int default null,
+    system_cpu_ms int default null,
+    max_rss_kb int default null,
+    block_input int default null,
+    block_output int default null,
+    parent_run_id int default null
+    constraint fk_parent_run_id references runs(id),
+    attempt int not null default 1,
+    host varchar not null default "",
+    pgid integer,
+    pid_start_time integer,
+    boot_id varchar,
+    constraint fk_job_id foreign key(job_id) references jobs(id),
+    constraint ck_status check (status in ("Running", "Skipped", "Succeeded", "Failed", "Terminated", "TimedOut", "Lost"))
+);
+insert into runs1
+(id, job_id, start_time, end_time, log_file, exec_log_file, status, pid,
+    exit_code, signal, user_cpu_ms, system_cpu_ms, max_rss_kb, block_input,
+    block_output, parent_run_id, attempt, host, pgid)
+    select id, job_id, start_time, end_time, log_file, exec_log_file, status, pid,
+        exit_code, signal, user_cpu_ms, system_cpu_ms, max_rss_kb, block_input,
+        block_output, parent_run_id, attempt, host, pgid
+    from runs;
+drop table runs;
+-- The rename otherwise checks the run_output_fts triggers, which the driver
+-- migrations are applied with can't read as it's built without FTS5
+pragma legacy_alter_table = on;
+alter table runs1 rename to runs;
+pragma legacy_alter_table = off;
+
+create index if not exists idx_runs_start_time on runs(start_time);
+create index if not exists idx_runs_job_id_start_time on runs(job_id, start_time);
+create index if not exists idx_runs_status on runs(status);
+create index if not exists idx_runs_host on runs(host);
+create index if not exists idx_runs_parent_run_id on runs(parent_run_id);
+
+-- migrate:down
+create table if not exists runs1 (
+    id integer primary key autoincrement,
+    job_id int not null,
+    start_time timestamp not null,
+    end_time timestamp,
+    log_file varchar not null,
+    exec_log_file varchar not null,
+    status varchar not null,
+    pid int default null,
+    exit_code int default null,
+    signal varchar default null,
+    user_cpu_ms int default null,
+    system_cpu_ms int default null,
+    max_rss_kb int default null,
+    block_input int default null,
+    block_output int default null,
+    parent_run_id int default null
+    constraint fk_parent_run_id references runs(id),
+    attempt int not null default 1,
+    host varchar not null default "",
+    pgid integer,
+    constraint fk_job_id foreign key(job_id) references jobs(id),
+    constraint ck_status check (status in ("Running", "Skipped", "Succeeded", "Failed", "Terminated", "TimedOut"))
+);
+insert into runs1
+(id, job_id, start_time, end_time, log_file, exec_log_file, status, pid,
+    exit_code, signal, user_cpu_ms, system_cpu_ms, max_rss_kb, block_input,
+    block_output, parent_run_id, attempt, host, pgid)
+    select id, job_id, start_time, end_time, log_file, exec_log_file,
+        case when status = "Lost" then "Failed" else status end, pid,
+        exit_code, signal, user_cpu_ms, system_cpu_ms, max_rss_kb, block_input,
+        block_output, parent_run_id, attempt, host, pgid
+    from runs;
+drop table runs;
+-- The rename otherwise checks the run_output_fts triggers, which the driver
+-- migrations are applied with can't read as it's built without FTS5
+pragma legacy_alter_table = on;
+alter table runs1 rename to runs;
+pragma legacy_alter_table = off;
+
+create index if not exists idx_runs_start_time on runs(start_time);
+create index if not exists idx_runs_job_id_start_time on runs(job_id, start_time);
+create index if not exists idx_runs_status on runs(status);
+create index if not exists idx_runs_host on runs(host);
+create index if not exists idx_runs_parent_run_id on runs(parent_run_id);
@@ -0,0 +1,28 @@
+-- migrate:up
+create table if not exists job_lock_groups (
+    job_id int not null,
+    lock_group varchar not null,
+    primary key (job_id, lock_group),
+    constraint fk_job_id foreign key(job_id) references jobs(id) on delete cascade
+);
+create index if not exists idx_job_lock_groups_lock_group on job_lock_groups(lock_group);
+
+-- The run holding the lock a Skipped run was blocked by. Not a foreign key, as
+-- the blocking run may be pruned before the Skipped run
+alter table runs
+add column blocked_by_run_id int default null;
+alter table runs
+add column blocked_by_job varchar default null;
+alter table runs
+add column blocked_by_lock_group varchar default null;
+
+-- migrate:down
+alter table runs
+drop column blocked_by_lock_group;
+alter table runs
+drop column blocked_by_job;
+alter table runs
+drop column blocked_by_run_id;
+
+drop index if exists idx_job_lock_groups_lock_group;
+drop table if exists job_lock_groups;
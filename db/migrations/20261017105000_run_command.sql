@@ -0,0 +1,20 @@
+-- migrate:up
+alter table jobs
+add column command varchar default null;
+
+-- The command a run executed, null for runs from before it was recorded
+alter table runs
+add column command varchar default null;
+-- The run a rerun re-executed. Not a foreign key, as the original run may be
+-- pruned before the rerun
+alter table runs
+add column rerun_of_run_id int default null;
+
+-- migrate:down
+alter table runs
+drop column rerun_of_run_id;
+alter table runs
+drop column command;
+
+alter table jobs
+drop column command;
@@ -0,0 +1,13 @@
+-- migrate:up
+-- The PID and start time of the troc exec that ran a run, which outlives the
+-- command of each attempt and is waiting on the run while it is queued
+alter table runs
+add column exec_pid integer default null;
+alter table runs
+add column exec_pid_start_time integer default null;
+
+-- migrate:down
+alter table runs
+drop column exec_pid_start_time;
+alter table runs
+drop column exec_pid;
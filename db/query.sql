@@ -6,14 +6,14 @@ where jobs.name = ?;
 
 -- name: CreateJob :one
 insert into jobs
-    (name, notify_log_content)
-values (?, ?)
+    (name, notify_log_content, timeout_seconds, retry_attempts, retry_backoff, retry_delay_seconds, retry_exit_codes, notify_mode, notify_reminder_seconds)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?)
 returning id;
 
 -- name: StartRun :one
 insert into runs
-    (job_id, start_time, log_file, exec_log_file, status)
-values (?, current_timestamp, ?, ?, "Running")
+    (job_id, start_time, log_file, exec_log_file, status, host, command, rerun_of_run_id, exec_pid, exec_pid_start_time, boot_id)
+values (?, current_timestamp, ?, ?, "Running", ?, ?, ?, ?, ?, ?)
 returning id;
 
 -- name: EndRun :exec
@@ -21,10 +21,23 @@ update runs
 set end_time = current_timestamp, status = ?
 where id = ?;
 
+-- name: QueueRun :one
+insert into runs
+    (job_id, start_time, log_file, exec_log_file, status, host, command, rerun_of_run_id, exec_pid, exec_pid_start_time, boot_id)
+values (?, current_timestamp, "", ?, "Queued", ?, ?, ?, ?, ?, ?)
+returning id;
+
+-- name: StartQueuedRun :exec
+update runs
+set start_time = current_timestamp,
+    status = "Running",
+    log_file = ?2
+where id = ?1 and status = "Queued";
+
 -- name: SkipRun :one
 insert into runs
-    (job_id, start_time, end_time, log_file, exec_log_file, status)
-values (?, current_timestamp, current_timestamp, "", ?, "Skipped")
+    (job_id, start_time, end_time, log_file, exec_log_file, status, host, command, rerun_of_run_id)
+values (?, current_timestamp, current_timestamp, "", ?, "Skipped", ?, ?, ?)
 returning id;
 
 -- name: GetJobs :many
@@ -38,7 +51,45 @@ select
     sqlc.embed(jobs)
 from runs, jobs
 where runs.job_id = jobs.id
-and (?1 = '' or jobs.name = ?1);
+-- The unary plus keeps idx_runs_parent_run_id, which nearly every run matches,
+-- from being used over the start_time range of idx_runs_start_time
+and +runs.parent_run_id is null
+and (sqlc.arg(job_name) = '' or jobs.name = sqlc.arg(job_name))
+and (sqlc.arg(status) = '' or runs.status = sqlc.arg(status))
+and (sqlc.arg(host) = '' or runs.host = sqlc.arg(host))
+and runs.start_time >= cast(sqlc.arg(since) as text)
+and runs.start_time < cast(sqlc.arg(until) as text)
+order by
+    case when sqlc.arg(sort) = 'start:asc' then runs.start_time end asc,
+    case when sqlc.arg(sort) = 'start:desc' then runs.start_time end desc,
+    case when sqlc.arg(sort) = 'duration:asc' then julianday(runs.end_time) - julianday(runs.start_time) end asc,
+    case when sqlc.arg(sort) = 'duration:desc' then julianday(runs.end_time) - julianday(runs.start_time) end desc,
+    case when sqlc.arg(sort) like '%:asc' then runs.id end asc,
+    runs.id desc
+limit sqlc.arg(limit) offset sqlc.arg(offset);
+
+-- name: GetJobRunsWithStatus :many
+select
+    sqlc.embed(runs),
+    sqlc.embed(jobs)
+from runs, jobs
+where runs.job_id = jobs.id
+and runs.status = sqlc.arg(status)
+and +runs.job_id = sqlc.arg(job_id)
+and +runs.host = sqlc.arg(host)
+and +runs.parent_run_id is null
+order by runs.start_time desc, runs.id desc;
+
+-- name: GetUnfinishedRuns :many
+select
+    sqlc.embed(runs),
+    sqlc.embed(jobs)
+from runs, jobs
+where runs.job_id = jobs.id
+and runs.status in ("Running", "Queued")
+and +runs.host in (?, '')
+and +runs.parent_run_id is null
+order by runs.start_time desc, runs.id desc;
 
 -- name: GetRun :one
 select
@@ -53,12 +104,330 @@ select runs.end_time is not null
 from runs
 where runs.id = ?;
 
+-- name: LoseRun :execrows
+update runs
+set end_time = current_timestamp, status = "Lost"
+where id = ?
+and status in ("Running", "Queued");
+
 -- name: UpdateJob :exec
 update jobs
-set name = ?2, notify_log_content = ?3
+set name = ?2,
+    notify_log_content = ?3,
+    timeout_seconds = ?4,
+    retry_attempts = ?5,
+    retry_backoff = ?6,
+    retry_delay_seconds = ?7,
+    retry_exit_codes = ?8,
+    notify_mode = ?9,
+    notify_reminder_seconds = ?10
 where id == ?1;
 
 -- name: UpdateRunPid :exec
 update runs
-set pid = ?2
+set pid = ?2,
+    pgid = ?3,
+    pid_start_time = ?4,
+    boot_id = ?5
 where id == ?1;
+
+-- name: UpdateRunBlockedBy :exec
+update runs
+set blocked_by_run_id = ?2,
+    blocked_by_job = ?3,
+    blocked_by_lock_group = ?4
+where id = ?1;
+
+-- name: UpdateRunResult :exec
+update runs
+set exit_code = ?2,
+    signal = ?3,
+    user_cpu_ms = ?4,
+    system_cpu_ms = ?5,
+    max_rss_kb = ?6,
+    block_input = ?7,
+    block_output = ?8
+where id == ?1;
+
+-- name: StartAttempt :one
+insert into runs
+    (job_id, start_time, log_file, exec_log_file, status, parent_run_id, attempt, host, command)
+values (?, current_timestamp, ?, ?, "Running", ?, ?, ?, ?)
+returning id;
+
+-- name: UpdateRunAttempt :exec
+update runs
+set attempt = ?2
+where id == ?1;
+
+-- name: GetRunAttempts :many
+select
+    sqlc.embed(runs)
+from runs
+where runs.parent_run_id = ?
+order by runs.attempt;
+
+-- name: UpdateJobSchedule :exec
+update jobs
+set schedule = ?2,
+    schedule_grace_seconds = ?3,
+    schedule_updated_at = case
+        when schedule is ?2 then schedule_updated_at
+        else current_timestamp
+    end
+where id == ?1;
+
+-- name: GetScheduledJobs :many
+select
+    sqlc.embed(jobs)
+from jobs
+where jobs.schedule is not null
+order by jobs.name;
+
+-- name: GetLastRun :one
+select
+    sqlc.embed(runs)
+from runs
+where runs.job_id = ?
+and runs.parent_run_id is null
+order by runs.start_time desc, runs.id desc
+limit 1;
+
+-- name: GetJobNotifyTargets :many
+select target_name
+from job_notify_targets
+where job_id = ?
+order by target_name;
+
+-- name: AddJobNotifyTarget :exec
+insert into job_notify_targets (job_id, target_name)
+values (?, ?);
+
+-- name: DeleteJobNotifyTargets :exec
+delete from job_notify_targets
+where job_id = ?;
+
+-- name: GetJobLockGroups :many
+select lock_group
+from job_lock_groups
+where job_id = ?
+order by lock_group;
+
+-- name: AddJobLockGroup :exec
+insert into job_lock_groups (job_id, lock_group)
+values (?, ?);
+
+-- name: DeleteJobLockGroups :exec
+delete from job_lock_groups
+where job_id = ?;
+
+-- name: GetLockGroupRuns :many
+select
+    sqlc.embed(runs),
+    sqlc.embed(jobs)
+from runs, jobs, job_lock_groups
+where runs.job_id = jobs.id
+and job_lock_groups.job_id = jobs.id
+and job_lock_groups.lock_group = ?1
+and runs.parent_run_id is null
+and runs.status = "Running"
+and runs.host = ?2
+order by runs.id desc;
+
+-- name: EnqueueNotification :exec
+insert into notifications
+    (run_id, target_name, label, next_attempt_at)
+values (?, ?, ?, ?);
+
+-- name: ClaimNotifications :many
+update notifications
+set locked_until = ?1
+where id in (
+    select id
+    from notifications
+    where status = "Pending"
+    and next_attempt_at <= ?2
+    and locked_until <= ?2
+    order by id
+    limit ?3
+)
+returning *;
+
+-- name: MarkNotificationSent :exec
+update notifications
+set status = "Sent",
+    attempts = attempts + 1,
+    last_error = null,
+    sent_at = current_timestamp,
+    locked_until = 0
+where id = ?;
+
+-- name: MarkNotificationFailed :exec
+update notifications
+set status = ?2,
+    attempts = attempts + 1,
+    last_error = ?3,
+    next_attempt_at = ?4,
+    locked_until = 0
+where id = ?1;
+
+-- name: GetNotifications :many
+select
+    sqlc.embed(notifications),
+    jobs.name
+from notifications, runs, jobs
+where notifications.run_id = runs.id
+and runs.job_id = jobs.id
+and (?1 = 0 or notifications.run_id = ?1)
+and (?2 = '' or notifications.status = ?2)
+order by notifications.id;
+
+-- name: CountConsecutiveFailedRuns :one
+select count(*)
+from runs
+where job_id = ?1
+and parent_run_id is null
+and id < ?2
+and status in ("Failed", "Terminated", "TimedOut", "Lost")
+and id > coalesce((
+    select max(id)
+    from runs
+    where job_id = ?1
+    and parent_run_id is null
+    and id < ?2
+    and status = "Succeeded"
+), 0);
+
+-- name: GetLastJobNotification :one
+select notifications.created_at
+from notifications, runs
+where notifications.run_id = runs.id
+and runs.job_id = ?
+order by notifications.created_at desc
+limit 1;
+
+-- name: GetPreviousRunStatus :one
+select status
+from runs
+where job_id = ?1
+and parent_run_id is null
+and id < ?2
+order by id desc
+limit 1;
+
+-- name: UpdateJobArchive :exec
+update jobs
+set archive = ?2
+where id == ?1;
+
+-- name: UpdateJobCommand :exec
+update jobs
+set command = ?2
+where id == ?1;
+
+-- name: UpdateJobConcurrency :exec
+update jobs
+set concurrency = ?2,
+    concurrency_limit = ?3,
+    queue_timeout_seconds = ?4
+where id == ?1;
+
+-- name: UpdateRunLogFile :exec
+update runs
+set log_file = ?2
+where id = ?1 or parent_run_id = ?1;
+
+-- name: UpdateJobRetention :exec
+update jobs
+set keep_last = ?2,
+    keep_days = ?3,
+    keep_failed_days = ?4
+where id == ?1;
+
+-- name: GetPruneRuns :many
+select
+    sqlc.embed(runs),
+    sqlc.embed(jobs)
+from runs, jobs
+where runs.job_id = jobs.id
+and runs.parent_run_id is null
+and (?1 = '' or jobs.name = ?1)
+order by runs.job_id, runs.id desc;
+
+-- name: DeleteRunNotifications :exec
+delete from notifications
+where run_id = ?;
+
+-- name: DeleteRunOutput :exec
+delete from run_output
+where run_id = ?;
+
+-- name: DeleteRun :exec
+delete from runs
+where id = ?1
+or parent_run_id = ?1;
+
+-- name: CreateRunOutput :exec
+insert into run_output
+    (run_id, chunk, first_line, content)
+values (?, ?, ?, ?);
+
+-- name: GetRunOutput :many
+select content
+from run_output
+where run_id = ?
+order by chunk;
+
+-- name: GrepRunOutput :many
+select
+    run_output.run_id,
+    run_output.first_line,
+    run_output.content,
+    runs.start_time,
+    jobs.name
+from run_output_fts, run_output, runs, jobs
+where run_output_fts.rowid = run_output.id
+and run_output.run_id = runs.id
+and runs.job_id = jobs.id
+and run_output_fts.content like ?1
+and (?2 = '' or jobs.name = ?2)
+and runs.start_time >= ?3
+order by run_output.run_id desc, run_output.chunk;
+
+-- name: GetJobRuns :many
+select
+    sqlc.embed(runs)
+from runs
+where runs.job_id = ?
+and runs.parent_run_id is null
+order by runs.start_time desc, runs.id desc;
+
+-- name: GetLastFinishedRun :one
+select
+    sqlc.embed(runs)
+from runs
+where runs.job_id = ?
+and runs.parent_run_id is null
+and runs.end_time is not null
+and runs.status != "Skipped"
+order by runs.start_time desc, runs.id desc
+limit 1;
+
+-- name: GetLastSucceededRun :one
+select
+    sqlc.embed(runs)
+from runs
+where runs.job_id = ?
+and runs.parent_run_id is null
+and runs.status = "Succeeded"
+order by runs.start_time desc, runs.id desc
+limit 1;
+
+-- name: GetRunStatusCounts :many
+select
+    runs.job_id,
+    runs.status,
+    count(*) as count
+from runs
+where runs.parent_run_id is null
+group by runs.job_id, runs.status;
@@ -7,9 +7,15 @@ import (
 	"embed"
 
 	"github.com/samcarswell/trochilus/cmd"
+	_ "github.com/samcarswell/trochilus/cmd/check"
 	_ "github.com/samcarswell/trochilus/cmd/exec"
 	_ "github.com/samcarswell/trochilus/cmd/job"
+	_ "github.com/samcarswell/trochilus/cmd/metrics"
+	_ "github.com/samcarswell/trochilus/cmd/notify"
+	_ "github.com/samcarswell/trochilus/cmd/prune"
 	_ "github.com/samcarswell/trochilus/cmd/run"
+	_ "github.com/samcarswell/trochilus/cmd/serve"
+	_ "github.com/samcarswell/trochilus/cmd/tui"
 )
 
 //go:embed db/migrations/*.sql
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+)
+
+// Statuses of finished runs, reported by troc_job_last_run_status.
+var finishedStatuses = []core.RunStatus{
+	core.RunStatusSucceeded,
+	core.RunStatusFailed,
+	core.RunStatusTerminated,
+	core.RunStatusTimedOut,
+	core.RunStatusLost,
+}
+
+// The metrics of a job. LastStatus is blank if the job has no finished runs,
+// and LastSuccess is zero if it has never succeeded.
+type JobMetrics struct {
+	Name         string
+	LastStatus   core.RunStatus
+	LastDuration time.Duration
+	LastSuccess  time.Time
+	Running      int64
+	RunCounts    map[core.RunStatus]int64
+}
+
+// Returns the metrics of every job, ordered by name.
+func Collect(ctx context.Context, db *data.Queries) ([]JobMetrics, error) {
+	jobs, err := db.GetJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := db.GetRunStatusCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobMetrics := []JobMetrics{}
+	for _, job := range jobs {
+		m := JobMetrics{
+			Name:      job.Job.Name,
+			RunCounts: map[core.RunStatus]int64{},
+		}
+		for _, status := range core.RunStatuses {
+			m.RunCounts[status] = 0
+		}
+		for _, count := range counts {
+			if count.JobID == job.Job.ID {
+				m.RunCounts[core.RunStatus(count.Status)] = count.Count
+			}
+		}
+		m.Running = m.RunCounts[core.RunStatusRunning]
+
+		lastRun, err := db.GetLastFinishedRun(ctx, job.Job.ID)
+		if err == nil {
+			m.LastStatus = core.RunStatus(lastRun.Run.Status)
+			m.LastDuration = lastRun.Run.EndTime.Time.Sub(lastRun.Run.StartTime)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		lastSuccess, err := db.GetLastSucceededRun(ctx, job.Job.ID)
+		if err == nil {
+			m.LastSuccess = lastSuccess.Run.EndTime.Time
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		jobMetrics = append(jobMetrics, m)
+	}
+	slices.SortFunc(jobMetrics, func(a, b JobMetrics) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return jobMetrics, nil
+}
+
+// Writes the metrics in the Prometheus text exposition format.
+func Write(w io.Writer, jobs []JobMetrics) error {
+	b := bufio.NewWriter(w)
+	writeHeader(b, "troc_job_last_run_status", "gauge", "Status of the last finished run of the job. 1 for the status of the run, otherwise 0.")
+	for _, job := range jobs {
+		for _, status := range finishedStatuses {
+			value := 0
+			if job.LastStatus == status {
+				value = 1
+			}
+			fmt.Fprintf(b, "troc_job_last_run_status{job=\"%s\",status=\"%s\"} %d\n", escapeLabel(job.Name), status, value)
+		}
+	}
+	writeHeader(b, "troc_job_last_run_duration_seconds", "gauge", "Duration of the last finished run of the job.")
+	for _, job := range jobs {
+		if job.LastStatus != "" {
+			fmt.Fprintf(b, "troc_job_last_run_duration_seconds{job=\"%s\"} %g\n", escapeLabel(job.Name), job.LastDuration.Seconds())
+		}
+	}
+	writeHeader(b, "troc_job_last_success_timestamp_seconds", "gauge", "Unix time the last successful run of the job ended. 0 if the job has never succeeded.")
+	for _, job := range jobs {
+		var timestamp int64
+		if !job.LastSuccess.IsZero() {
+			timestamp = job.LastSuccess.Unix()
+		}
+		fmt.Fprintf(b, "troc_job_last_success_timestamp_seconds{job=\"%s\"} %d\n", escapeLabel(job.Name), timestamp)
+	}
+	writeHeader(b, "troc_job_running", "gauge", "Number of runs of the job that are running.")
+	for _, job := range jobs {
+		fmt.Fprintf(b, "troc_job_running{job=\"%s\"} %d\n", escapeLabel(job.Name), job.Running)
+	}
+	writeHeader(b, "troc_job_runs", "gauge", "Number of runs of the job by status. Pruned runs are not counted, so it decreases when runs are pruned.")
+	for _, job := range jobs {
+		for _, status := range core.RunStatuses {
+			fmt.Fprintf(b, "troc_job_runs{job=\"%s\",status=\"%s\"} %d\n", escapeLabel(job.Name), status, job.RunCounts[status])
+		}
+	}
+	return b.Flush()
+}
+
+func writeHeader(w io.Writer, name string, metricType string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// Writes the metrics of every job to a file. The file is replaced atomically,
+// so a collector never reads a partly written file.
+func WriteFile(ctx context.Context, db *data.Queries, file string) error {
+	jobs, err := Collect(ctx, db)
+	if err != nil {
+		return err
+	}
+	// The temporary file must not end with .prom, or the textfile collector
+	// may read it
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := Write(tmp, jobs); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
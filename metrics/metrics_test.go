@@ -0,0 +1,188 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func createRun(ctx context.Context, t *testing.T, db *data.Queries, jobId int64, status core.RunStatus) {
+	runId, err := db.StartRun(ctx, data.StartRunParams{
+		JobID:   jobId,
+		LogFile: "job.log",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if status == core.RunStatusRunning {
+		return
+	}
+	err = db.EndRun(ctx, data.EndRunParams{
+		ID:     runId,
+		Status: string(status),
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func createJob(ctx context.Context, t *testing.T, db *data.Queries, name string) int64 {
+	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  name,
+		RetryAttempts:         1,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return jobId
+}
+
+func Test_Collect(t *testing.T) {
+	ctx := context.Background()
+	db := test.CreateDb(ctx, t)
+	backupId := createJob(ctx, t, db, "backup")
+	createJob(ctx, t, db, "archive")
+	createRun(ctx, t, db, backupId, core.RunStatusSucceeded)
+	createRun(ctx, t, db, backupId, core.RunStatusFailed)
+	createRun(ctx, t, db, backupId, core.RunStatusSkipped)
+	createRun(ctx, t, db, backupId, core.RunStatusRunning)
+
+	jobs, err := Collect(ctx, db)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	assert.Equal(t, 2, len(jobs))
+	assert.Equal(t, "archive", jobs[0].Name)
+	assert.Equal(t, core.RunStatus(""), jobs[0].LastStatus)
+	assert.True(t, jobs[0].LastSuccess.IsZero())
+	assert.Equal(t, int64(0), jobs[0].RunCounts[core.RunStatusSucceeded])
+	assert.Equal(t, "backup", jobs[1].Name)
+	assert.Equal(t, core.RunStatusFailed, jobs[1].LastStatus)
+	assert.False(t, jobs[1].LastSuccess.IsZero())
+	assert.Equal(t, int64(1), jobs[1].Running)
+	assert.Equal(t, map[core.RunStatus]int64{
+		core.RunStatusRunning:    1,
+		core.RunStatusSkipped:    1,
+		core.RunStatusSucceeded:  1,
+		core.RunStatusFailed:     1,
+		core.RunStatusTerminated: 0,
+		core.RunStatusTimedOut:   0,
+		core.RunStatusLost:       0,
+		core.RunStatusQueued:     0,
+	}, jobs[1].RunCounts)
+}
+
+func Test_Write(t *testing.T) {
+	jobs := []JobMetrics{
+		{
+			Name:         "backup",
+			LastStatus:   core.RunStatusSucceeded,
+			LastDuration: 1500 * time.Millisecond,
+			LastSuccess:  time.Unix(1735725600, 0),
+			Running:      1,
+			RunCounts: map[core.RunStatus]int64{
+				core.RunStatusRunning:   1,
+				core.RunStatusSucceeded: 3,
+				core.RunStatusFailed:    2,
+			},
+		},
+		{
+			Name:      `new "job"`,
+			RunCounts: map[core.RunStatus]int64{},
+		},
+	}
+	var b bytes.Buffer
+
+	err := Write(&b, jobs)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	assert.Equal(t, `# HELP troc_job_last_run_status Status of the last finished run of the job. 1 for the status of the run, otherwise 0.
+# TYPE troc_job_last_run_status gauge
+troc_job_last_run_status{job="backup",status="Succeeded"} 1
+troc_job_last_run_status{job="backup",status="Failed"} 0
+troc_job_last_run_status{job="backup",status="Terminated"} 0
+troc_job_last_run_status{job="backup",status="TimedOut"} 0
+troc_job_last_run_status{job="backup",status="Lost"} 0
+troc_job_last_run_status{job="new \"job\"",status="Succeeded"} 0
+troc_job_last_run_status{job="new \"job\"",status="Failed"} 0
+troc_job_last_run_status{job="new \"job\"",status="Terminated"} 0
+troc_job_last_run_status{job="new \"job\"",status="TimedOut"} 0
+troc_job_last_run_status{job="new \"job\"",status="Lost"} 0
+# HELP troc_job_last_run_duration_seconds Duration of the last finished run of the job.
+# TYPE troc_job_last_run_duration_seconds gauge
+troc_job_last_run_duration_seconds{job="backup"} 1.5
+# HELP troc_job_last_success_timestamp_seconds Unix time the last successful run of the job ended. 0 if the job has never succeeded.
+# TYPE troc_job_last_success_timestamp_seconds gauge
+troc_job_last_success_timestamp_seconds{job="backup"} 1735725600
+troc_job_last_success_timestamp_seconds{job="new \"job\""} 0
+# HELP troc_job_running Number of runs of the job that are running.
+# TYPE troc_job_running gauge
+troc_job_running{job="backup"} 1
+troc_job_running{job="new \"job\""} 0
+# HELP troc_job_runs Number of runs of the job by status. Pruned runs are not counted, so it decreases when runs are pruned.
+# TYPE troc_job_runs gauge
+troc_job_runs{job="backup",status="Running"} 1
+troc_job_runs{job="backup",status="Skipped"} 0
+troc_job_runs{job="backup",status="Succeeded"} 3
+troc_job_runs{job="backup",status="Failed"} 2
+troc_job_runs{job="backup",status="Terminated"} 0
+troc_job_runs{job="backup",status="TimedOut"} 0
+troc_job_runs{job="backup",status="Lost"} 0
+troc_job_runs{job="backup",status="Queued"} 0
+troc_job_runs{job="new \"job\"",status="Running"} 0
+troc_job_runs{job="new \"job\"",status="Skipped"} 0
+troc_job_runs{job="new \"job\"",status="Succeeded"} 0
+troc_job_runs{job="new \"job\"",status="Failed"} 0
+troc_job_runs{job="new \"job\"",status="Terminated"} 0
+troc_job_runs{job="new \"job\"",status="TimedOut"} 0
+troc_job_runs{job="new \"job\"",status="Lost"} 0
+troc_job_runs{job="new \"job\"",status="Queued"} 0
+`, b.String())
+}
+
+func Test_WriteFile(t *testing.T) {
+	ctx := context.Background()
+	db := test.CreateDb(ctx, t)
+	jobId := createJob(ctx, t, db, "backup")
+	createRun(ctx, t, db, jobId, core.RunStatusSucceeded)
+	dir := t.TempDir()
+	file := path.Join(dir, "troc.prom")
+	if err := os.WriteFile(file, []byte("old"), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err := WriteFile(ctx, db, file)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Contains(t, string(content), `troc_job_last_run_status{job="backup",status="Succeeded"} 1`)
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, 1, len(entries))
+}
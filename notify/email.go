@@ -0,0 +1,382 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samcarswell/trochilus/archive"
+	"github.com/samcarswell/trochilus/config"
+)
+
+const EmailTlsStartTls = "starttls"
+const EmailTlsImplicit = "tls"
+const EmailTlsNone = "none"
+
+const emailDefaultAttachLimit = 1024 * 1024
+const emailDialTimeout = 30 * time.Second
+
+type emailNotifier struct {
+	email      config.EmailConfig
+	notifyConf config.NotifyConfig
+	hostname   string
+	showEmoji  bool
+	localTime  bool
+}
+
+func newEmailNotifier(target config.NotifyTargetConfig, conf config.Config) (Notifier, error) {
+	email := target.Email
+	if email.Host == "" {
+		return nil, errors.New("notify target '" + target.Name + "' host is blank")
+	}
+	if email.From == "" {
+		return nil, errors.New("notify target '" + target.Name + "' from is blank")
+	}
+	if len(email.To) == 0 {
+		return nil, errors.New("notify target '" + target.Name + "' to is blank")
+	}
+	if email.Tls == "" {
+		email.Tls = EmailTlsStartTls
+	}
+	if !slices.Contains([]string{EmailTlsStartTls, EmailTlsImplicit, EmailTlsNone}, email.Tls) {
+		return nil, fmt.Errorf("notify target '%s' has invalid tls: %s", target.Name, email.Tls)
+	}
+	if email.Port == 0 {
+		switch email.Tls {
+		case EmailTlsImplicit:
+			email.Port = 465
+		case EmailTlsStartTls:
+			email.Port = 587
+		default:
+			email.Port = 25
+		}
+	}
+	if email.AttachLimit == 0 {
+		email.AttachLimit = emailDefaultAttachLimit
+	}
+	return emailNotifier{
+		email:      email,
+		notifyConf: conf.Notify,
+		hostname:   conf.Notify.Hostname,
+		showEmoji:  conf.Display.Emoji,
+		localTime:  conf.LocalTime,
+	}, nil
+}
+
+func (n emailNotifier) NotifyRun(run RunNotifyInfo) error {
+	// Tagging the channel is specific to slack, so no statuses are tagged
+	text := getTemplateNotifyText(
+		notifyTemplate(n.notifyConf, run.Name, run.Status),
+		run,
+		config.StatusConfig{},
+		n.hostname,
+		n.showEmoji,
+		n.localTime,
+	)
+	var attachment *emailAttachment
+	if n.email.AttachLog && run.LogFile != "" {
+		attachment = newLogAttachment(run.LogFile, n.email.AttachLimit)
+	}
+	msg, err := newEmailMessage(n.email, notifyTextToSubject(text), text, attachment)
+	if err != nil {
+		return err
+	}
+	return sendEmail(n.email, msg)
+}
+
+func (n emailNotifier) NotifyJobChecks(checks []JobCheckNotifyInfo) error {
+	subject := "troc check" + hostnameIfExists(n.hostname) + ": " +
+		strconv.Itoa(len(checks)) + " job(s) late or missed"
+	text := getCheckNotifyText(checks, n.hostname, n.showEmoji, n.localTime, false)
+	msg, err := newEmailMessage(n.email, subject, text, nil)
+	if err != nil {
+		return err
+	}
+	return sendEmail(n.email, msg)
+}
+
+type emailAttachment struct {
+	Name    string
+	Content []byte
+}
+
+// Returns the run log as an attachment. Logs larger than limit are truncated
+// to their last limit bytes, as the end of a log is usually the most useful.
+// Archived logs are decompressed and attached under their original name.
+// As with the slack message, a log that cannot be read is omitted.
+func newLogAttachment(logFile string, limit int) *emailAttachment {
+	content, truncated, err := readLogTail(logFile, limit)
+	if err != nil {
+		log.Printf("Unable to read logfile: %s. Email will omit it.", logFile)
+		return nil
+	}
+	if truncated {
+		prefix := []byte("[troc: log truncated to the last " + strconv.Itoa(limit) + " bytes]\n")
+		content = append(prefix, content...)
+	}
+	return &emailAttachment{
+		Name:    filepath.Base(archive.UncompressedName(logFile)),
+		Content: content,
+	}
+}
+
+// Returns the last limit bytes of a log, and whether the log was longer.
+// An archived log can't be seeked, so the whole log is decompressed.
+func readLogTail(logFile string, limit int) ([]byte, bool, error) {
+	if archive.IsArchived(logFile) {
+		content, err := archive.ReadFile(logFile)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(content) > limit {
+			return content[len(content)-limit:], true, nil
+		}
+		return content, false, nil
+	}
+	f, err := os.Open(logFile)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	truncated := info.Size() > int64(limit)
+	if truncated {
+		if _, err := f.Seek(-int64(limit), io.SeekEnd); err != nil {
+			return nil, false, err
+		}
+	}
+	content, err := io.ReadAll(f)
+	return content, truncated, err
+}
+
+// Builds a multipart/alternative message with plain text and html versions of
+// text, wrapped in multipart/mixed if there is an attachment.
+func newEmailMessage(
+	email config.EmailConfig,
+	subject string,
+	text string,
+	attachment *emailAttachment,
+) ([]byte, error) {
+	msgId := make([]byte, 16)
+	if _, err := rand.Read(msgId); err != nil {
+		return nil, err
+	}
+	fromHost := email.From[strings.LastIndex(email.From, "@")+1:]
+
+	var alternativeBody bytes.Buffer
+	alternative := multipart.NewWriter(&alternativeBody)
+	if err := writeAlternativeParts(alternative, text); err != nil {
+		return nil, err
+	}
+	body := mimeBody{
+		ContentType: "multipart/alternative; boundary=" + alternative.Boundary(),
+		Body:        alternativeBody.Bytes(),
+	}
+	if attachment != nil {
+		var err error
+		body, err = wrapWithAttachment(body, attachment)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	headers := []string{
+		"From: " + email.From,
+		"To: " + strings.Join(email.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: <" + hex.EncodeToString(msgId) + "@" + fromHost + ">",
+		"MIME-Version: 1.0",
+		"Content-Type: " + body.ContentType,
+	}
+	msg := []byte(strings.Join(headers, "\r\n") + "\r\n\r\n")
+	return append(msg, body.Body...), nil
+}
+
+type mimeBody struct {
+	ContentType string
+	Body        []byte
+}
+
+// Returns a multipart/mixed body of content followed by the attachment.
+func wrapWithAttachment(content mimeBody, attachment *emailAttachment) (mimeBody, error) {
+	var body bytes.Buffer
+	mixed := multipart.NewWriter(&body)
+	part, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {content.ContentType},
+	})
+	if err != nil {
+		return mimeBody{}, err
+	}
+	if _, err := part.Write(content.Body); err != nil {
+		return mimeBody{}, err
+	}
+	part, err = mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name})},
+	})
+	if err != nil {
+		return mimeBody{}, err
+	}
+	if err := writeBase64(part, attachment.Content); err != nil {
+		return mimeBody{}, err
+	}
+	if err := mixed.Close(); err != nil {
+		return mimeBody{}, err
+	}
+	return mimeBody{
+		ContentType: "multipart/mixed; boundary=" + mixed.Boundary(),
+		Body:        body.Bytes(),
+	}, nil
+}
+
+func writeAlternativeParts(w *multipart.Writer, text string) error {
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", notifyTextToHtml(text)},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return err
+		}
+		if err := qp.Close(); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// Writes base64 wrapped at 76 characters per line, as required by RFC 2045.
+func writeBase64(w io.Writer, content []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := io.WriteString(w, encoded+"\r\n")
+	return err
+}
+
+var codeBlockRegexp = regexp.MustCompile("(?s)```\n?(.*?)```")
+var codeRegexp = regexp.MustCompile("`([^`\n]+)`")
+var boldRegexp = regexp.MustCompile(`\*([^*\n]+)\*`)
+
+// Converts the slack formatted notify text into html.
+func notifyTextToHtml(text string) string {
+	var out strings.Builder
+	out.WriteString("<html><body>")
+	last := 0
+	for _, m := range codeBlockRegexp.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(inlineTextToHtml(text[last:m[0]]))
+		out.WriteString("<pre>" + html.EscapeString(text[m[2]:m[3]]) + "</pre>")
+		last = m[1]
+	}
+	out.WriteString(inlineTextToHtml(text[last:]))
+	out.WriteString("</body></html>")
+	return out.String()
+}
+
+// Returns the first line of the slack formatted notify text, without its
+// formatting, so the subject follows the notify template.
+func notifyTextToSubject(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	line = codeRegexp.ReplaceAllString(line, "$1")
+	line = boldRegexp.ReplaceAllString(line, "$1")
+	return strings.TrimSpace(line)
+}
+
+func inlineTextToHtml(text string) string {
+	escaped := html.EscapeString(text)
+	escaped = codeRegexp.ReplaceAllString(escaped, "<code>$1</code>")
+	escaped = boldRegexp.ReplaceAllString(escaped, "<b>$1</b>")
+	return strings.ReplaceAll(escaped, "\n", "<br>\n")
+}
+
+func sendEmail(email config.EmailConfig, msg []byte) error {
+	addr := net.JoinHostPort(email.Host, strconv.Itoa(email.Port))
+	tlsConfig := &tls.Config{ServerName: email.Host}
+	dialer := &net.Dialer{Timeout: emailDialTimeout}
+
+	var conn net.Conn
+	var err error
+	if email.Tls == EmailTlsImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, email.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if email.Tls == EmailTlsStartTls {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if email.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", email.Username, email.Password, email.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(email.From); err != nil {
+		return err
+	}
+	for _, to := range email.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
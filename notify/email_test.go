@@ -0,0 +1,338 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samcarswell/trochilus/archive"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/stretchr/testify/assert"
+)
+
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Starts a fake SMTP server that accepts a single message without TLS or auth.
+func newSmtpServer(t *testing.T) (string, int, chan smtpMessage) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() { listener.Close() })
+	messages := make(chan smtpMessage, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) {
+			io.WriteString(conn, line+"\r\n")
+		}
+		var msg smtpMessage
+		reply("220 localhost fake smtp")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				msg.From = strings.Trim(strings.TrimPrefix(cmd, "MAIL FROM:"), "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				msg.To = append(msg.To, strings.Trim(strings.TrimPrefix(cmd, "RCPT TO:"), "<>"))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(strings.TrimPrefix(line, "."))
+				}
+				msg.Data = data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				messages <- msg
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, messages
+}
+
+type emailPart struct {
+	ContentType string
+	Filename    string
+	Content     string
+}
+
+// Returns the leaf parts of a multipart message, decoding their content.
+func readEmailParts(t *testing.T, contentType string, body io.Reader) []emailPart {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		t.Fatal("expected multipart content type: " + contentType)
+	}
+	var parts []emailPart
+	r := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := r.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		partType := part.Header.Get("Content-Type")
+		if strings.HasPrefix(partType, "multipart/") {
+			parts = append(parts, readEmailParts(t, partType, part)...)
+			continue
+		}
+		var content []byte
+		switch part.Header.Get("Content-Transfer-Encoding") {
+		case "quoted-printable":
+			content, err = io.ReadAll(quotedprintable.NewReader(part))
+		case "base64":
+			content, err = io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		default:
+			content, err = io.ReadAll(part)
+		}
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		parts = append(parts, emailPart{
+			ContentType: partType,
+			Filename:    part.FileName(),
+			// Text is sent with CRLF line endings
+			Content: strings.ReplaceAll(string(content), "\r\n", "\n"),
+		})
+	}
+	return parts
+}
+
+func Test_NotifyRunEmail(t *testing.T) {
+	host, port, messages := newSmtpServer(t)
+	logFile := path.Join(t.TempDir(), "job.log")
+	if err := os.WriteFile(logFile, []byte("line 1\nline 2\nline 3\n"), 0666); err != nil {
+		t.Fatal(err.Error())
+	}
+	conf := config.Config{
+		Notify: config.NotifyConfig{
+			Hostname: "example-server",
+			Status:   config.StatusConfig{Failed: true},
+			Targets: []config.NotifyTargetConfig{
+				{Name: "on-call", Type: TargetTypeEmail, Email: config.EmailConfig{
+					Host:        host,
+					Port:        port,
+					Tls:         EmailTlsNone,
+					From:        "troc@example.com",
+					To:          []string{"a@example.com", "b@example.com"},
+					AttachLog:   true,
+					AttachLimit: 14,
+				}},
+			},
+		},
+		Display: config.DisplayConfig{Emoji: true},
+	}
+
+	err := NotifyRun(slog.Default(), conf, nil, RunNotifyInfo{
+		Name:        "test-1",
+		Id:          34,
+		Status:      core.RunStatusFailed,
+		LogFile:     logFile,
+		Attempt:     2,
+		MaxAttempts: 3,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var smtpMsg smtpMessage
+	select {
+	case smtpMsg = <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("email was not received")
+	}
+	assert.Equal(t, "troc@example.com", smtpMsg.From)
+	assert.Equal(t, []string{"a@example.com", "b@example.com"}, smtpMsg.To)
+
+	msg, err := mail.ReadMessage(strings.NewReader(smtpMsg.Data))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, "test-1@example-server: run 34 - ❌ Failed (attempt 2/3)", subject)
+	assert.Equal(t, "a@example.com, b@example.com", msg.Header.Get("To"))
+
+	parts := readEmailParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	assert.Len(t, parts, 3)
+	text := "*test-1@example-server*: run 34 - ❌ Failed (attempt 2/3)\nLog: `" + logFile + "`"
+	assert.Equal(t, "text/plain; charset=utf-8", parts[0].ContentType)
+	assert.Equal(t, text, parts[0].Content)
+	assert.Equal(t, "text/html; charset=utf-8", parts[1].ContentType)
+	assert.Equal(t, "<html><body><b>test-1@example-server</b>: run 34 - ❌ Failed (attempt 2/3)<br>\nLog: <code>"+logFile+"</code></body></html>", parts[1].Content)
+	assert.Equal(t, "job.log", parts[2].Filename)
+	assert.Equal(t, "[troc: log truncated to the last 14 bytes]\nline 2\nline 3\n", parts[2].Content)
+}
+
+func Test_NotifyJobChecksEmail(t *testing.T) {
+	host, port, messages := newSmtpServer(t)
+	conf := config.Config{
+		Notify: config.NotifyConfig{
+			Email: config.EmailConfig{
+				Host: host,
+				Port: port,
+				Tls:  EmailTlsNone,
+				From: "troc@example.com",
+				To:   []string{"a@example.com"},
+			},
+		},
+	}
+
+	err := NotifyJobChecks(slog.Default(), conf, []JobCheckNotifyInfo{
+		{Name: "test-1", Status: core.JobCheckStatusMissed, ExpectedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	smtpMsg := <-messages
+	msg, err := mail.ReadMessage(strings.NewReader(smtpMsg.Data))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, "troc check: 1 job(s) late or missed", msg.Header.Get("Subject"))
+	parts := readEmailParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	assert.Len(t, parts, 2)
+	assert.Equal(t, "*troc check*: 1 job(s) late or missed\nMissed: *test-1* expected a run at 2025-01-01 10:00:00 +0000 UTC", parts[0].Content)
+}
+
+func Test_notifyTextToHtml(t *testing.T) {
+	text := "*test-1*: run 34 - Failed\nLog:\n```\n<b>output</b>\n```"
+	assert.Equal(t,
+		"<html><body><b>test-1</b>: run 34 - Failed<br>\nLog:<br>\n<pre>&lt;b&gt;output&lt;/b&gt;\n</pre></body></html>",
+		notifyTextToHtml(text),
+	)
+}
+
+func Test_notifyTextToSubject(t *testing.T) {
+	data := []struct {
+		text     string
+		expected string
+	}{
+		{"*test-1*: run 34 - Failed (recovered)\nLog: `job.log`", "test-1: run 34 - Failed (recovered)"},
+		{"`daily-sync` is done", "daily-sync is done"},
+	}
+	for _, d := range data {
+		assert.Equal(t, d.expected, notifyTextToSubject(d.text))
+	}
+}
+
+func Test_newEmailNotifierDefaults(t *testing.T) {
+	data := []struct {
+		tls  string
+		port int
+	}{
+		{"", 587},
+		{EmailTlsStartTls, 587},
+		{EmailTlsImplicit, 465},
+		{EmailTlsNone, 25},
+	}
+	for _, d := range data {
+		t.Run(strconv.Quote(d.tls), func(t *testing.T) {
+			notifier, err := newEmailNotifier(config.NotifyTargetConfig{
+				Name: "email",
+				Email: config.EmailConfig{
+					Host: "localhost",
+					Tls:  d.tls,
+					From: "troc@example.com",
+					To:   []string{"a@example.com"},
+				},
+			}, config.Config{})
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			assert.Equal(t, d.port, notifier.(emailNotifier).email.Port)
+			assert.Equal(t, emailDefaultAttachLimit, notifier.(emailNotifier).email.AttachLimit)
+		})
+	}
+
+	_, err := newEmailNotifier(config.NotifyTargetConfig{
+		Name: "email",
+		Email: config.EmailConfig{
+			Host: "localhost",
+			Tls:  "ssl",
+			From: "troc@example.com",
+			To:   []string{"a@example.com"},
+		},
+	}, config.Config{})
+	assert.EqualError(t, err, "notify target 'email' has invalid tls: ssl")
+}
+
+func Test_newLogAttachmentArchived(t *testing.T) {
+	logFile := path.Join(t.TempDir(), "job.log")
+	if err := os.WriteFile(logFile, []byte("line 1\nline 2\nline 3\n"), 0666); err != nil {
+		t.Fatal(err.Error())
+	}
+	archiveFile, err := archive.Compress(logFile, "", core.ArchiveFormatGzip)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data := []struct {
+		name     string
+		limit    int
+		expected string
+	}{
+		{"whole-log", 100, "line 1\nline 2\nline 3\n"},
+		{"truncated", 14, "[troc: log truncated to the last 14 bytes]\nline 2\nline 3\n"},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			attachment := newLogAttachment(archiveFile, d.limit)
+
+			assert.Equal(t, "job.log", attachment.Name)
+			assert.Equal(t, d.expected, string(attachment.Content))
+		})
+	}
+}
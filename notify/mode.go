@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+)
+
+const NotifyLabelRecovered = "recovered"
+const NotifyLabelStillFailing = "still failing"
+
+// Returns the label to notify a finished run with, and whether it should be
+// notified at all, under the notify mode of its job. The previous runs of the
+// job are read to tell whether the run changed the job's state.
+func runNotifyLabel(
+	ctx context.Context,
+	db *data.Queries,
+	run data.GetRunRow,
+	now time.Time,
+) (string, bool, error) {
+	mode := core.NotifyMode(run.Job.NotifyMode)
+	if mode == core.NotifyModeAlways || mode == "" {
+		return "", true, nil
+	}
+	previousFailures, err := db.CountConsecutiveFailedRuns(ctx, data.CountConsecutiveFailedRunsParams{
+		JobID: run.Job.ID,
+		ID:    run.Run.ID,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	var lastNotified time.Time
+	if mode == core.NotifyModeOnFailureWithReminder && previousFailures > 0 {
+		lastNotified, err = db.GetLastJobNotification(ctx, run.Job.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return "", false, err
+		}
+	}
+	label, notify := notifyModeLabel(
+		mode,
+		core.RunStatus(run.Run.Status),
+		previousFailures,
+		lastNotified,
+		time.Duration(run.Job.NotifyReminderSeconds)*time.Second,
+		now,
+	)
+	return label, notify, nil
+}
+
+// on-change notifies the first failure and the first success after a failure.
+// on-failure-with-reminder also notifies a failure after a failure if the
+// job hasn't been notified within the reminder.
+// lastNotified is zero if the job has never been notified.
+func notifyModeLabel(
+	mode core.NotifyMode,
+	status core.RunStatus,
+	previousFailures int64,
+	lastNotified time.Time,
+	reminder time.Duration,
+	now time.Time,
+) (string, bool) {
+	if mode == core.NotifyModeAlways {
+		return "", true
+	}
+	if status == core.RunStatusSucceeded {
+		if previousFailures > 0 {
+			return NotifyLabelRecovered, true
+		}
+		return "", false
+	}
+	if !core.IsFailedStatus(status) {
+		return "", false
+	}
+	if previousFailures == 0 {
+		return "", true
+	}
+	if mode == core.NotifyModeOnFailureWithReminder &&
+		(lastNotified.IsZero() || now.Sub(lastNotified) >= reminder) {
+		return NotifyLabelStillFailing, true
+	}
+	return "", false
+}
@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_notifyModeLabel(t *testing.T) {
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	data := []struct {
+		name             string
+		mode             core.NotifyMode
+		status           core.RunStatus
+		previousFailures int64
+		lastNotified     time.Time
+		expectedLabel    string
+		expectedNotify   bool
+	}{
+		{"always-success", core.NotifyModeAlways, core.RunStatusSucceeded, 0, time.Time{}, "", true},
+		{"always-still-failing", core.NotifyModeAlways, core.RunStatusFailed, 3, now, "", true},
+		{"on-change-first-failure", core.NotifyModeOnChange, core.RunStatusFailed, 0, time.Time{}, "", true},
+		{"on-change-still-failing", core.NotifyModeOnChange, core.RunStatusTimedOut, 1, now.Add(-24 * time.Hour), "", false},
+		{"on-change-recovered", core.NotifyModeOnChange, core.RunStatusSucceeded, 5, now, NotifyLabelRecovered, true},
+		{"on-change-still-succeeding", core.NotifyModeOnChange, core.RunStatusSucceeded, 0, time.Time{}, "", false},
+		{"on-change-skipped", core.NotifyModeOnChange, core.RunStatusSkipped, 0, time.Time{}, "", false},
+		{"reminder-first-failure", core.NotifyModeOnFailureWithReminder, core.RunStatusTerminated, 0, now, "", true},
+		{"reminder-within", core.NotifyModeOnFailureWithReminder, core.RunStatusFailed, 2, now.Add(-59 * time.Minute), "", false},
+		{"reminder-due", core.NotifyModeOnFailureWithReminder, core.RunStatusFailed, 2, now.Add(-time.Hour), NotifyLabelStillFailing, true},
+		{"reminder-never-notified", core.NotifyModeOnFailureWithReminder, core.RunStatusFailed, 2, time.Time{}, NotifyLabelStillFailing, true},
+		{"reminder-recovered", core.NotifyModeOnFailureWithReminder, core.RunStatusSucceeded, 2, now, NotifyLabelRecovered, true},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			label, notify := notifyModeLabel(d.mode, d.status, d.previousFailures, d.lastNotified, time.Hour, now)
+			assert.Equal(t, d.expectedLabel, label)
+			assert.Equal(t, d.expectedNotify, notify)
+		})
+	}
+}
+
+func Test_EnqueueOnChange(t *testing.T) {
+	ctx := context.Background()
+	db := test.CreateDb(ctx, t)
+	conf := config.Config{
+		Notify: config.NotifyConfig{
+			Webhook: config.WebhookConfig{Url: "http://localhost"},
+		},
+	}
+	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  test.UniqueIdentifer(),
+		RetryAttempts:         1,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeOnChange),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	runs := []struct {
+		status        core.RunStatus
+		expectedLabel []string
+	}{
+		{core.RunStatusSucceeded, nil},
+		{core.RunStatusFailed, []string{""}},
+		{core.RunStatusSkipped, nil},
+		{core.RunStatusFailed, nil},
+		{core.RunStatusSucceeded, []string{NotifyLabelRecovered}},
+		{core.RunStatusSucceeded, nil},
+	}
+	for _, run := range runs {
+		runId, err := db.StartRun(ctx, data.StartRunParams{JobID: jobId})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		err = db.EndRun(ctx, data.EndRunParams{ID: runId, Status: string(run.status)})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		assert.Nil(t, Enqueue(ctx, slog.Default(), db, conf, runId, nil))
+
+		var labels []string
+		for _, notification := range getOutboxNotifications(ctx, t, db, runId) {
+			labels = append(labels, notification.Label)
+		}
+		assert.Equal(t, run.expectedLabel, labels, "run %d: %s", runId, run.status)
+	}
+}
@@ -0,0 +1,176 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+)
+
+const TargetTypeSlack = "slack"
+const TargetTypeWebhook = "webhook"
+const TargetTypeEmail = "email"
+
+// Sends notifications to a single target.
+type Notifier interface {
+	NotifyRun(run RunNotifyInfo) error
+	NotifyJobChecks(checks []JobCheckNotifyInfo) error
+}
+
+// Creates a notifier from the config of a target. Should return an error if
+// the target is missing required config.
+type NotifierFactory func(target config.NotifyTargetConfig, conf config.Config) (Notifier, error)
+
+var registry = map[string]NotifierFactory{}
+
+// Registers a notifier for targets with the given type.
+func Register(targetType string, factory NotifierFactory) {
+	registry[targetType] = factory
+}
+
+func init() {
+	Register(TargetTypeSlack, newSlackNotifier)
+	Register(TargetTypeWebhook, newWebhookNotifier)
+	Register(TargetTypeEmail, newEmailNotifier)
+}
+
+type target struct {
+	Name     string
+	Notifier Notifier
+}
+
+// Returns the notify targets in the config. The notify.slack, notify.webhook
+// and notify.email config are included as targets named slack, webhook and
+// email if they are set, unless a target already has that name.
+func Targets(notifyConf config.NotifyConfig) []config.NotifyTargetConfig {
+	targets := slices.Clone(notifyConf.Targets)
+	hasTarget := func(name string) bool {
+		return slices.ContainsFunc(targets, func(t config.NotifyTargetConfig) bool {
+			return t.Name == name
+		})
+	}
+	if (notifyConf.Slack.Token != "" || notifyConf.Slack.Channel != "") && !hasTarget(TargetTypeSlack) {
+		targets = append(targets, config.NotifyTargetConfig{
+			Name:  TargetTypeSlack,
+			Type:  TargetTypeSlack,
+			Slack: notifyConf.Slack,
+		})
+	}
+	if notifyConf.Webhook.Url != "" && !hasTarget(TargetTypeWebhook) {
+		targets = append(targets, config.NotifyTargetConfig{
+			Name:    TargetTypeWebhook,
+			Type:    TargetTypeWebhook,
+			Webhook: notifyConf.Webhook,
+		})
+	}
+	if notifyConf.Email.Host != "" && !hasTarget(TargetTypeEmail) {
+		targets = append(targets, config.NotifyTargetConfig{
+			Name:  TargetTypeEmail,
+			Type:  TargetTypeEmail,
+			Email: notifyConf.Email,
+		})
+	}
+	return targets
+}
+
+// Returns the names of the notify targets in the config.
+func TargetNames(notifyConf config.NotifyConfig) []string {
+	var names []string
+	for _, t := range Targets(notifyConf) {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// Creates notifiers for the named targets, or for every target if no names
+// are given. Targets that can't be created are returned as errors, so that the
+// remaining targets can still be notified.
+func newTargets(conf config.Config, names []string) ([]target, error) {
+	configured := Targets(conf.Notify)
+	if len(names) == 0 {
+		for _, t := range configured {
+			names = append(names, t.Name)
+		}
+	}
+	var targets []target
+	var errs []error
+	for _, name := range names {
+		i := slices.IndexFunc(configured, func(t config.NotifyTargetConfig) bool {
+			return t.Name == name
+		})
+		if i == -1 {
+			errs = append(errs, errors.New("notify target '"+name+"' is not configured"))
+			continue
+		}
+		factory, ok := registry[configured[i].Type]
+		if !ok {
+			errs = append(errs, fmt.Errorf("notify target '%s' has unknown type: %s", name, configured[i].Type))
+			continue
+		}
+		notifier, err := factory(configured[i], conf)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		targets = append(targets, target{
+			Name:     name,
+			Notifier: notifier,
+		})
+	}
+	return targets, errors.Join(errs...)
+}
+
+// Returns an error if notifications cannot be sent to the named targets, or
+// to every target if no names are given.
+func ValidateConfig(conf config.Config, names []string) error {
+	if len(Targets(conf.Notify)) == 0 {
+		return errors.New("notify is set but no notify targets are configured. Set notify.slack.*, notify.webhook.*, notify.email.* or notify.targets")
+	}
+	_, err := newTargets(conf, names)
+	return err
+}
+
+// Notifies each of the named targets of a run, or every target if no names
+// are given. A target failing does not prevent the others being notified.
+func NotifyRun(
+	logger *slog.Logger,
+	conf config.Config,
+	names []string,
+	run RunNotifyInfo,
+) error {
+	targets, err := newTargets(conf, names)
+	errs := []error{err}
+	for _, t := range targets {
+		err := t.Notifier.NotifyRun(run)
+		if err != nil {
+			core.LogRunNotifyFailed(logger, run.Id, run.Name, t.Name, err)
+			errs = append(errs, fmt.Errorf("notify target '%s': %w", t.Name, err))
+			continue
+		}
+		core.LogRunNotifySent(logger, run.Id, run.Name, t.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// Notifies every target of jobs that are late or have missed a run.
+func NotifyJobChecks(
+	logger *slog.Logger,
+	conf config.Config,
+	checks []JobCheckNotifyInfo,
+) error {
+	targets, err := newTargets(conf, nil)
+	errs := []error{err}
+	for _, t := range targets {
+		err := t.Notifier.NotifyJobChecks(checks)
+		if err != nil {
+			core.LogJobCheckNotifyFailed(logger, t.Name, err)
+			errs = append(errs, fmt.Errorf("notify target '%s': %w", t.Name, err))
+			continue
+		}
+		core.LogJobCheckNotifySent(logger, t.Name)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NotifyRunTargets(t *testing.T) {
+	failing, _ := newWebhookServer(t, http.StatusInternalServerError)
+	first, firstRequests := newWebhookServer(t, http.StatusOK)
+	second, secondRequests := newWebhookServer(t, http.StatusOK)
+	conf := config.Config{
+		Notify: config.NotifyConfig{
+			Targets: []config.NotifyTargetConfig{
+				{Name: "failing", Type: TargetTypeWebhook, Webhook: config.WebhookConfig{Url: failing.URL}},
+				{Name: "first", Type: TargetTypeWebhook, Webhook: config.WebhookConfig{Url: first.URL}},
+				{Name: "second", Type: TargetTypeWebhook, Webhook: config.WebhookConfig{Url: second.URL}},
+			},
+		},
+	}
+	var logBuf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logBuf, core.GetSlogHandlerOptions()))
+
+	err := NotifyRun(logger, conf, nil, RunNotifyInfo{
+		Name:   "test-1",
+		Id:     34,
+		Status: core.RunStatusFailed,
+	})
+	assert.EqualError(t, err, "notify target 'failing': webhook returned status 500 Internal Server Error")
+	assert.Len(t, firstRequests, 1)
+	assert.Len(t, secondRequests, 1)
+
+	log, err := test.NewLogFromBuffer(logBuf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var sent, failed []string
+	for _, row := range log.Rows {
+		assert.Equal(t, int64(34), row.RunId)
+		assert.Equal(t, "test-1", row.JobName)
+		switch core.Event(row.Event) {
+		case core.EventNotifySent:
+			sent = append(sent, row.NotifyTarget)
+		case core.EventNotifyFailed:
+			failed = append(failed, row.NotifyTarget)
+		}
+	}
+	assert.Equal(t, []string{"first", "second"}, sent)
+	assert.Equal(t, []string{"failing"}, failed)
+}
+
+func Test_NotifyRunNamedTargets(t *testing.T) {
+	first, firstRequests := newWebhookServer(t, http.StatusOK)
+	second, secondRequests := newWebhookServer(t, http.StatusOK)
+	conf := config.Config{
+		Notify: config.NotifyConfig{
+			Targets: []config.NotifyTargetConfig{
+				{Name: "first", Type: TargetTypeWebhook, Webhook: config.WebhookConfig{Url: first.URL}},
+				{Name: "second", Type: TargetTypeWebhook, Webhook: config.WebhookConfig{Url: second.URL}},
+			},
+		},
+	}
+
+	err := NotifyRun(slog.Default(), conf, []string{"second", "missing"}, RunNotifyInfo{
+		Name:   "test-1",
+		Id:     34,
+		Status: core.RunStatusFailed,
+	})
+	assert.EqualError(t, err, "notify target 'missing' is not configured")
+	assert.Len(t, firstRequests, 0)
+	assert.Len(t, secondRequests, 1)
+}
+
+func Test_Targets(t *testing.T) {
+	notifyConf := config.NotifyConfig{
+		Slack:   config.SlackConfig{Token: "token", Channel: "channel"},
+		Webhook: config.WebhookConfig{Url: "http://localhost"},
+		Targets: []config.NotifyTargetConfig{
+			{Name: "webhook", Type: TargetTypeWebhook, Webhook: config.WebhookConfig{Url: "http://example.com"}},
+			{Name: "ops", Type: TargetTypeSlack, Slack: config.SlackConfig{Token: "ops-token", Channel: "ops"}},
+		},
+	}
+	targets := Targets(notifyConf)
+	assert.Equal(t, []string{"webhook", "ops", "slack"}, TargetNames(notifyConf))
+	assert.Equal(t, "http://example.com", targets[0].Webhook.Url)
+	assert.Equal(t, "token", targets[2].Slack.Token)
+}
+
+func Test_ValidateConfig(t *testing.T) {
+	data := []struct {
+		name     string
+		conf     config.NotifyConfig
+		targets  []string
+		expected string
+	}{
+		{"none", config.NotifyConfig{}, nil,
+			"notify is set but no notify targets are configured. Set notify.slack.*, notify.webhook.*, notify.email.* or notify.targets"},
+		{"slack-no-channel", config.NotifyConfig{
+			Slack: config.SlackConfig{Token: "token"},
+		}, nil, "notify target 'slack' channel is blank"},
+		{"slack", config.NotifyConfig{
+			Slack: config.SlackConfig{Token: "token", Channel: "channel"},
+		}, nil, ""},
+		{"webhook", config.NotifyConfig{
+			Webhook: config.WebhookConfig{Url: "http://localhost"},
+		}, nil, ""},
+		{"webhook-slack-no-token", config.NotifyConfig{
+			Slack:   config.SlackConfig{Channel: "channel"},
+			Webhook: config.WebhookConfig{Url: "http://localhost"},
+		}, nil, "notify target 'slack' token is blank"},
+		{"unknown-type", config.NotifyConfig{
+			Targets: []config.NotifyTargetConfig{{Name: "pager", Type: "pager"}},
+		}, nil, "notify target 'pager' has unknown type: pager"},
+		{"named", config.NotifyConfig{
+			Webhook: config.WebhookConfig{Url: "http://localhost"},
+		}, []string{"webhook"}, ""},
+		{"named-missing", config.NotifyConfig{
+			Webhook: config.WebhookConfig{Url: "http://localhost"},
+		}, []string{"slack"}, "notify target 'slack' is not configured"},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			err := ValidateConfig(config.Config{Notify: d.conf}, d.targets)
+			if d.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, d.expected)
+			}
+		})
+	}
+}
@@ -2,12 +2,13 @@ package notify
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
-	"os"
 	"strconv"
+	"time"
 
 	"github.com/samcarswell/trochilus/config"
 	"github.com/samcarswell/trochilus/core"
@@ -29,66 +30,135 @@ type RunNotifyInfo struct {
 	Id               int64
 	Status           core.RunStatus
 	LogFile          string
+	ExitCode         sql.NullInt64
+	Signal           string
+	UserCpuMs        sql.NullInt64
+	SystemCpuMs      sql.NullInt64
+	MaxRssKb         sql.NullInt64
+	Attempt          int64
+	MaxAttempts      int64
+	StartTime        time.Time
+	EndTime          time.Time
+	PreviousStatus   core.RunStatus
+	// "recovered" or "still failing" if the job's notify mode only notifies
+	// changes in its state
+	Label string
+	// Number of failed runs in a row, including this one
+	ConsecutiveFailures int64
+}
+
+type JobCheckNotifyInfo struct {
+	Name       string
+	Status     core.JobCheckStatus
+	ExpectedAt time.Time
 }
 
 const slackPostMessage = "https://slack.com/api/chat.postMessage"
 
-func NotifyRun(
-	conf config.Config,
-	run RunNotifyInfo,
-) (bool, error) {
-	slackStr := getNotifyText(
+type slackNotifier struct {
+	slack      config.SlackConfig
+	notifyConf config.NotifyConfig
+	showEmoji  bool
+	localTime  bool
+}
+
+func newSlackNotifier(target config.NotifyTargetConfig, conf config.Config) (Notifier, error) {
+	if target.Slack.Token == "" {
+		return nil, errors.New("notify target '" + target.Name + "' token is blank")
+	}
+	if target.Slack.Channel == "" {
+		return nil, errors.New("notify target '" + target.Name + "' channel is blank")
+	}
+	return slackNotifier{
+		slack:      target.Slack,
+		notifyConf: conf.Notify,
+		showEmoji:  conf.Display.Emoji,
+		localTime:  conf.LocalTime,
+	}, nil
+}
+
+func (n slackNotifier) NotifyRun(run RunNotifyInfo) error {
+	_, err := notifySlack(n.slack, getTemplateNotifyText(
+		notifyTemplate(n.notifyConf, run.Name, run.Status),
 		run,
-		conf.Notify.Status,
-		conf.Notify.Hostname,
-		conf.Display.Emoji,
-	)
-	return notifySlack(conf.Notify.Slack, slackStr)
+		n.notifyConf.Status,
+		n.notifyConf.Hostname,
+		n.showEmoji,
+		n.localTime,
+	))
+	return err
 }
 
-// Returns the notification test for a run.
-// This is designed to ignore incorrect inputs; ensuring a notification is sent
-// is critical; if it's missing some information, that's acceptable.
+// Sends a single message listing the jobs that are late or have missed
+// a scheduled run. The channel is tagged if any job has missed a run.
+func (n slackNotifier) NotifyJobChecks(checks []JobCheckNotifyInfo) error {
+	_, err := notifySlack(n.slack, getCheckNotifyText(
+		checks,
+		n.notifyConf.Hostname,
+		n.showEmoji,
+		n.localTime,
+		true,
+	))
+	return err
+}
+
+// Returns the notification text for a run from the default template.
 func getNotifyText(
 	run RunNotifyInfo,
 	tagStatuses config.StatusConfig,
 	hostname string,
 	showEmoji bool,
 ) string {
-	return "*" + run.Name + hostnameIfExists(hostname) + "*: run " +
-		strconv.FormatInt(run.Id, 10) + " - " +
-		core.FormatStatus(run.Status, showEmoji) +
-		tagChannelIfStatusConfigured(run.Status, tagStatuses) +
-		logFileAndOutput(run.NotifyLogContent, run.LogFile)
+	return getTemplateNotifyText(DefaultNotifyTemplate, run, tagStatuses, hostname, showEmoji, false)
 }
 
-func logFileAndOutput(notifyLogContent bool, logFile string) string {
-	if !notifyLogContent {
-		return logFileIfExists(logFile)
-	}
-	if logFile == "" {
-		return ""
-	}
-	logContent, err := os.ReadFile(logFile)
+// Returns the notification text for a run from a template.
+// This is designed to ignore incorrect inputs; ensuring a notification is sent
+// is critical; if it's missing some information, that's acceptable. If the
+// template can't be executed, the default template is used instead.
+func getTemplateNotifyText(
+	text string,
+	run RunNotifyInfo,
+	tagStatuses config.StatusConfig,
+	hostname string,
+	showEmoji bool,
+	useLocalTime bool,
+) string {
+	data := newNotifyTemplateData(run, tagStatuses, hostname, showEmoji, useLocalTime)
+	notifyText, err := executeNotifyTemplate(text, data)
 	if err != nil {
-		log.Printf("Unable to read logfile: %s. Notify message will omit it.", logFile)
-		return ""
+		log.Printf("Unable to execute notify template: %s. Using the default template.", err)
+		notifyText, _ = executeNotifyTemplate(DefaultNotifyTemplate, data)
 	}
-	return "\nLog:\n" + "```\n" + string(logContent) + "```"
+	return notifyText
 }
 
-func hostnameIfExists(hostname string) string {
-	if hostname == "" {
-		return ""
+func getCheckNotifyText(
+	checks []JobCheckNotifyInfo,
+	hostname string,
+	showEmoji bool,
+	useLocalTime bool,
+	tagChannel bool,
+) string {
+	tag := ""
+	lines := ""
+	for _, check := range checks {
+		if tagChannel && check.Status == core.JobCheckStatusMissed {
+			tag = " <!channel>"
+		}
+		lines += "\n" + core.FormatCheckStatus(check.Status, showEmoji) +
+			": *" + check.Name + "* expected a run at " +
+			core.FormatTime(check.ExpectedAt, useLocalTime)
 	}
-	return "@" + hostname
+	return "*troc check" + hostnameIfExists(hostname) + "*: " +
+		strconv.Itoa(len(checks)) + " job(s) late or missed" + tag + lines
 }
 
-func logFileIfExists(logFile string) string {
-	if logFile == "" {
+func hostnameIfExists(hostname string) string {
+	if hostname == "" {
 		return ""
 	}
-	return "\nLog: `" + logFile + "`"
+	return "@" + hostname
 }
 
 func tagChannelIfStatusConfigured(
@@ -99,7 +169,10 @@ func tagChannelIfStatusConfigured(
 		(status == core.RunStatusSkipped && tagStatuses.Skipped) ||
 		(status == core.RunStatusSucceeded && tagStatuses.Succeeded) ||
 		(status == core.RunStatusFailed && tagStatuses.Failed) ||
-		(status == core.RunStatusTerminated && tagStatuses.Terminated) {
+		(status == core.RunStatusTerminated && tagStatuses.Terminated) ||
+		(status == core.RunStatusTimedOut && tagStatuses.TimedOut) ||
+		(status == core.RunStatusLost && tagStatuses.Lost) ||
+		(status == core.RunStatusQueued && tagStatuses.Queued) {
 		return " <!channel>"
 	}
 	return ""
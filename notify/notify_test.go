@@ -1,7 +1,9 @@
 package notify
 
 import (
+	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/samcarswell/trochilus/config"
 	"github.com/samcarswell/trochilus/core"
@@ -51,6 +53,9 @@ Log: ` + "`/file/path`", true, config.StatusConfig{Running: true}},
 		{"terminated-tag-config", "test-11", 10000000, core.RunStatusTerminated, "/file/path", false, "server1.com",
 			`*test-11@server1.com*: run 10000000 - 💥 Terminated <!channel>
 Log: ` + "`/file/path`", true, config.StatusConfig{Terminated: true}},
+		{"timedout-tag-config", "test-12", 10000000, core.RunStatusTimedOut, "/file/path", false, "server1.com",
+			`*test-12@server1.com*: run 10000000 - ⏰ TimedOut <!channel>
+Log: ` + "`/file/path`", true, config.StatusConfig{TimedOut: true}},
 	}
 
 	for _, d := range data {
@@ -76,3 +81,101 @@ Log: ` + "`/file/path`", true, config.StatusConfig{Terminated: true}},
 		})
 	}
 }
+
+func Test_getNotifyTextRunResult(t *testing.T) {
+	data := []struct {
+		name     string
+		run      RunNotifyInfo
+		expected string
+	}{
+		{"exit-code", RunNotifyInfo{
+			Name:     "test-1",
+			Id:       34,
+			Status:   core.RunStatusFailed,
+			ExitCode: sql.NullInt64{Int64: 3, Valid: true},
+		}, "*test-1*: run 34 - ❌ Failed <!channel>\nExit code: 3"},
+		{"signal", RunNotifyInfo{
+			Name:   "test-2",
+			Id:     34,
+			Status: core.RunStatusTerminated,
+			Signal: "SIGKILL",
+		}, "*test-2*: run 34 - 💥 Terminated\nSignal: SIGKILL"},
+		{"resource-usage", RunNotifyInfo{
+			Name:        "test-3",
+			Id:          34,
+			Status:      core.RunStatusSucceeded,
+			ExitCode:    sql.NullInt64{Int64: 0, Valid: true},
+			UserCpuMs:   sql.NullInt64{Int64: 1500, Valid: true},
+			SystemCpuMs: sql.NullInt64{Int64: 20, Valid: true},
+			MaxRssKb:    sql.NullInt64{Int64: 2048, Valid: true},
+			LogFile:     "/file/path",
+		}, "*test-3*: run 34 - ✅ Succeeded\nExit code: 0\nCPU: 1.5s user, 20ms system; Max RSS: 2.0 MiB\nLog: `/file/path`"},
+		{"attempt", RunNotifyInfo{
+			Name:        "test-4",
+			Id:          34,
+			Status:      core.RunStatusFailed,
+			Attempt:     3,
+			MaxAttempts: 3,
+		}, "*test-4*: run 34 - ❌ Failed (attempt 3/3) <!channel>"},
+		{"single-attempt", RunNotifyInfo{
+			Name:        "test-5",
+			Id:          34,
+			Status:      core.RunStatusFailed,
+			Attempt:     1,
+			MaxAttempts: 1,
+		}, "*test-5*: run 34 - ❌ Failed <!channel>"},
+		{"recovered", RunNotifyInfo{
+			Name:   "test-6",
+			Id:     34,
+			Status: core.RunStatusSucceeded,
+			Label:  NotifyLabelRecovered,
+		}, "*test-6*: run 34 - ✅ Succeeded (recovered)"},
+		{"still-failing", RunNotifyInfo{
+			Name:                "test-7",
+			Id:                  34,
+			Status:              core.RunStatusFailed,
+			Label:               NotifyLabelStillFailing,
+			ConsecutiveFailures: 12,
+		}, "*test-7*: run 34 - ❌ Failed (still failing, 12 failed runs in a row) <!channel>"},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			notifyStr := getNotifyText(d.run, config.StatusConfig{Failed: true}, "", true)
+			if notifyStr != d.expected {
+				t.Error("Expected")
+				t.Error(d.expected)
+				t.Error("Actual")
+				t.Fatal(notifyStr)
+			}
+		})
+	}
+}
+
+func Test_getCheckNotifyText(t *testing.T) {
+	data := []struct {
+		name     string
+		checks   []JobCheckNotifyInfo
+		expected string
+	}{
+		{"late", []JobCheckNotifyInfo{
+			{Name: "test-1", Status: core.JobCheckStatusLate, ExpectedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)},
+		}, "*troc check@host*: 1 job(s) late or missed\n⏳ Late: *test-1* expected a run at 2025-01-01 10:00:00 +0000 UTC"},
+		{"missed", []JobCheckNotifyInfo{
+			{Name: "test-1", Status: core.JobCheckStatusLate, ExpectedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)},
+			{Name: "test-2", Status: core.JobCheckStatusMissed, ExpectedAt: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)},
+		}, "*troc check@host*: 2 job(s) late or missed <!channel>\n⏳ Late: *test-1* expected a run at 2025-01-01 10:00:00 +0000 UTC\n🚨 Missed: *test-2* expected a run at 2025-01-01 09:00:00 +0000 UTC"},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			notifyStr := getCheckNotifyText(d.checks, "host", true, false, true)
+			if notifyStr != d.expected {
+				t.Error("Expected")
+				t.Error(d.expected)
+				t.Error("Actual")
+				t.Fatal(notifyStr)
+			}
+		})
+	}
+}
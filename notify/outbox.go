@@ -0,0 +1,179 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+)
+
+// How long a flush has to deliver the notifications it claims before another
+// flush may claim them.
+const outboxLease = 5 * time.Minute
+const outboxBatchSize = 50
+const outboxMaxRetryDelay = time.Hour
+
+// Adds a notification of a run to the outbox for each of the named targets,
+// or for every target if no names are given. They are delivered by Flush.
+// Nothing is added if the notify mode of the job suppresses the run.
+func Enqueue(
+	ctx context.Context,
+	logger *slog.Logger,
+	db *data.Queries,
+	conf config.Config,
+	runId int64,
+	names []string,
+) error {
+	run, err := db.GetRun(ctx, runId)
+	if err != nil {
+		return err
+	}
+	label, notify, err := runNotifyLabel(ctx, db, run, time.Now())
+	if err != nil {
+		return err
+	}
+	if !notify {
+		core.LogRunNotifySuppressed(logger, run.Run.ID, run.Job.Name, core.NotifyMode(run.Job.NotifyMode))
+		return nil
+	}
+	if len(names) == 0 {
+		names = TargetNames(conf.Notify)
+	}
+	for _, name := range names {
+		err := db.EnqueueNotification(ctx, data.EnqueueNotificationParams{
+			RunID:         runId,
+			TargetName:    name,
+			Label:         label,
+			NextAttemptAt: time.Now().Unix(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delivers the notifications in the outbox that are due. A notification that
+// fails is retried by a later flush, with the delay doubling after each
+// attempt, until notify.outbox.maxattempts is reached.
+// Returns the errors of any deliveries that failed.
+func Flush(
+	ctx context.Context,
+	logger *slog.Logger,
+	conf config.Config,
+	db *data.Queries,
+) error {
+	var errs []error
+	for {
+		now := time.Now()
+		notifications, err := db.ClaimNotifications(ctx, data.ClaimNotificationsParams{
+			LockedUntil:   now.Add(outboxLease).Unix(),
+			NextAttemptAt: now.Unix(),
+			Limit:         outboxBatchSize,
+		})
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		if len(notifications) == 0 {
+			return errors.Join(errs...)
+		}
+		for _, notification := range notifications {
+			err := deliver(ctx, logger, conf, db, notification)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+}
+
+func deliver(
+	ctx context.Context,
+	logger *slog.Logger,
+	conf config.Config,
+	db *data.Queries,
+	notification data.Notification,
+) error {
+	run, err := db.GetRun(ctx, notification.RunID)
+	if err != nil {
+		return err
+	}
+	info := newRunNotifyInfo(run)
+	info.Label = notification.Label
+	if core.IsFailedStatus(info.Status) {
+		// Counts the failures before the next run, so this run is included
+		info.ConsecutiveFailures, err = db.CountConsecutiveFailedRuns(ctx, data.CountConsecutiveFailedRunsParams{
+			JobID: run.Job.ID,
+			ID:    run.Run.ID + 1,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	previousStatus, err := db.GetPreviousRunStatus(ctx, data.GetPreviousRunStatusParams{
+		JobID: run.Job.ID,
+		ID:    run.Run.ID,
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	info.PreviousStatus = core.RunStatus(previousStatus)
+	sendErr := NotifyRun(logger, conf, []string{notification.TargetName}, info)
+	if sendErr == nil {
+		return db.MarkNotificationSent(ctx, notification.ID)
+	}
+
+	attempts := notification.Attempts + 1
+	status := core.NotificationStatusPending
+	if attempts >= int64(conf.Notify.Outbox.MaxAttempts) {
+		status = core.NotificationStatusFailed
+	}
+	nextAttemptAt := time.Now().Add(outboxRetryDelay(conf.Notify.Outbox.RetryDelay, attempts))
+	err = db.MarkNotificationFailed(ctx, data.MarkNotificationFailedParams{
+		ID:            notification.ID,
+		Status:        string(status),
+		LastError:     sql.NullString{String: sendErr.Error(), Valid: true},
+		NextAttemptAt: nextAttemptAt.Unix(),
+	})
+	if err != nil {
+		return errors.Join(sendErr, err)
+	}
+	if status == core.NotificationStatusFailed {
+		core.LogRunNotifyAbandoned(logger, run.Run.ID, run.Job.Name, notification.TargetName, attempts)
+	} else {
+		core.LogRunNotifyRetrying(logger, run.Run.ID, run.Job.Name, notification.TargetName, nextAttemptAt)
+	}
+	return fmt.Errorf("notification %d: %w", notification.ID, sendErr)
+}
+
+// Returns the delay before the next attempt, doubling after each attempt.
+func outboxRetryDelay(delay time.Duration, attempts int64) time.Duration {
+	for i := int64(1); i < attempts && delay < outboxMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, outboxMaxRetryDelay)
+}
+
+func newRunNotifyInfo(run data.GetRunRow) RunNotifyInfo {
+	return RunNotifyInfo{
+		Name:             run.Job.Name,
+		Id:               run.Run.ID,
+		Status:           core.RunStatus(run.Run.Status),
+		LogFile:          run.Run.LogFile,
+		NotifyLogContent: run.Job.NotifyLogContent,
+		ExitCode:         run.Run.ExitCode,
+		Signal:           run.Run.Signal.String,
+		UserCpuMs:        run.Run.UserCpuMs,
+		SystemCpuMs:      run.Run.SystemCpuMs,
+		MaxRssKb:         run.Run.MaxRssKb,
+		Attempt:          run.Run.Attempt,
+		MaxAttempts:      run.Job.RetryAttempts,
+		StartTime:        run.Run.StartTime,
+		EndTime:          run.Run.EndTime.Time,
+	}
+}
@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func createOutboxRun(ctx context.Context, t *testing.T, db *data.Queries) int64 {
+	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  test.UniqueIdentifer(),
+		RetryAttempts:         1,
+		RetryBackoff:          "fixed",
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	runId, err := db.StartRun(ctx, data.StartRunParams{JobID: jobId})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = db.EndRun(ctx, data.EndRunParams{ID: runId, Status: string(core.RunStatusFailed)})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return runId
+}
+
+func getOutboxNotifications(ctx context.Context, t *testing.T, db *data.Queries, runId int64) []data.Notification {
+	rows, err := db.GetNotifications(ctx, data.GetNotificationsParams{Dollar1: runId, Dollar2: ""})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var notifications []data.Notification
+	for _, row := range rows {
+		notifications = append(notifications, row.Notification)
+	}
+	return notifications
+}
+
+func Test_FlushSent(t *testing.T) {
+	ctx := context.Background()
+	db := test.CreateDb(ctx, t)
+	server, requests := newWebhookServer(t, http.StatusOK)
+	conf := config.Config{
+		Notify: config.NotifyConfig{
+			Webhook: config.WebhookConfig{Url: server.URL},
+			Outbox:  config.OutboxConfig{MaxAttempts: 3, RetryDelay: time.Hour},
+		},
+	}
+	runId := createOutboxRun(ctx, t, db)
+
+	assert.Nil(t, Enqueue(ctx, slog.Default(), db, conf, runId, nil))
+	notifications := getOutboxNotifications(ctx, t, db, runId)
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, "webhook", notifications[0].TargetName)
+	assert.Equal(t, string(core.NotificationStatusPending), notifications[0].Status)
+
+	assert.Nil(t, Flush(ctx, slog.Default(), conf, db))
+	assert.Len(t, requests, 1)
+	notifications = getOutboxNotifications(ctx, t, db, runId)
+	assert.Equal(t, string(core.NotificationStatusSent), notifications[0].Status)
+	assert.Equal(t, int64(1), notifications[0].Attempts)
+	assert.True(t, notifications[0].SentAt.Valid)
+
+	// A sent notification isn't delivered again
+	<-requests
+	assert.Nil(t, Flush(ctx, slog.Default(), conf, db))
+	assert.Len(t, requests, 0)
+}
+
+func Test_FlushRetry(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	server, requests := newWebhookServer(t, http.StatusInternalServerError)
+	conf := config.Config{
+		Notify: config.NotifyConfig{
+			Webhook: config.WebhookConfig{Url: server.URL},
+			Outbox:  config.OutboxConfig{MaxAttempts: 2, RetryDelay: time.Hour},
+		},
+	}
+	runId := createOutboxRun(ctx, t, db)
+	assert.Nil(t, Enqueue(ctx, slog.Default(), db, conf, runId, []string{"webhook"}))
+
+	before := time.Now()
+	assert.NotNil(t, Flush(ctx, slog.Default(), conf, db))
+	<-requests
+	notifications := getOutboxNotifications(ctx, t, db, runId)
+	assert.Equal(t, string(core.NotificationStatusPending), notifications[0].Status)
+	assert.Equal(t, int64(1), notifications[0].Attempts)
+	assert.Equal(t, "notify target 'webhook': webhook returned status 500 Internal Server Error", notifications[0].LastError.String)
+	assert.GreaterOrEqual(t, notifications[0].NextAttemptAt, before.Add(time.Hour).Unix())
+
+	// Not due yet, so nothing is delivered
+	assert.Nil(t, Flush(ctx, slog.Default(), conf, db))
+	assert.Len(t, requests, 0)
+
+	// The last attempt fails the notification
+	_, err := conn.ExecContext(ctx, "update notifications set next_attempt_at = 0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.NotNil(t, Flush(ctx, slog.Default(), conf, db))
+	<-requests
+	notifications = getOutboxNotifications(ctx, t, db, runId)
+	assert.Equal(t, string(core.NotificationStatusFailed), notifications[0].Status)
+	assert.Equal(t, int64(2), notifications[0].Attempts)
+
+	// A failed notification isn't delivered again
+	_, err = conn.ExecContext(ctx, "update notifications set next_attempt_at = 0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Nil(t, Flush(ctx, slog.Default(), conf, db))
+	assert.Len(t, requests, 0)
+}
+
+func Test_outboxRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, outboxRetryDelay(30*time.Second, 1))
+	assert.Equal(t, 60*time.Second, outboxRetryDelay(30*time.Second, 2))
+	assert.Equal(t, 4*time.Minute, outboxRetryDelay(30*time.Second, 4))
+	assert.Equal(t, time.Hour, outboxRetryDelay(30*time.Second, 100))
+}
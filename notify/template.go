@@ -0,0 +1,202 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/samcarswell/trochilus/archive"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+)
+
+// The template of run notifications, unless overridden by notify.template or
+// notify.templates.
+const DefaultNotifyTemplate = "*{{.Job}}{{with .Host}}@{{.}}{{end}}*: run {{.ID}} - {{.StatusText}}" +
+	"{{if gt .MaxAttempts 1}} (attempt {{.Attempt}}/{{.MaxAttempts}}){{end}}" +
+	"{{with .Label}} ({{.}}{{if and (eq . \"still failing\") (gt $.ConsecutiveFailures 1)}}, {{$.ConsecutiveFailures}} failed runs in a row{{end}}){{end}}" +
+	"{{with .Tag}} {{.}}{{end}}" +
+	"{{if .Signal}}\nSignal: {{.Signal}}{{else if .ExitCode}}\nExit code: {{.ExitCode}}{{end}}" +
+	"{{if or .UserCpu .SystemCpu .MaxRss}}\nCPU: {{.UserCpu}} user, {{.SystemCpu}} system; Max RSS: {{.MaxRss}}{{end}}" +
+	"{{if .NotifyLogContent}}{{with .LogContent}}\nLog:\n```\n{{.}}```{{end}}{{else if .LogFile}}\nLog: `{{.LogFile}}`{{end}}"
+
+// The data notification templates are executed with.
+type NotifyTemplateData struct {
+	ID     int64
+	Job    string
+	Host   string
+	Status string
+	// Status with an emoji, if display.emoji is set. eg. ✅ Succeeded
+	StatusText string
+	// Status of the run before this one, empty if there isn't one
+	PreviousStatus      string
+	Attempt             int64
+	MaxAttempts         int64
+	Label               string
+	ConsecutiveFailures int64
+	// <!channel> if notify.status.* is set for the status. Only set for slack
+	Tag              string
+	StartTime        time.Time
+	EndTime          time.Time
+	Duration         string
+	ExitCode         *int64
+	Signal           string
+	UserCpu          string
+	SystemCpu        string
+	MaxRss           string
+	LogFile          string
+	NotifyLogContent bool
+	// Content of the log if the job's notify-log is set
+	LogContent *string
+}
+
+// Returns the last lines of the run log, for use in templates as
+// {{.LogTail 20}}
+func (d NotifyTemplateData) LogTail(lines int) string {
+	if d.LogFile == "" || lines <= 0 {
+		return ""
+	}
+	return strings.Join(logTail(d.LogFile, lines), "\n")
+}
+
+func newNotifyTemplateData(
+	run RunNotifyInfo,
+	tagStatuses config.StatusConfig,
+	hostname string,
+	showEmoji bool,
+	useLocalTime bool,
+) NotifyTemplateData {
+	data := NotifyTemplateData{
+		ID:                  run.Id,
+		Job:                 run.Name,
+		Host:                hostname,
+		Status:              string(run.Status),
+		StatusText:          core.FormatStatus(run.Status, showEmoji),
+		PreviousStatus:      string(run.PreviousStatus),
+		Attempt:             run.Attempt,
+		MaxAttempts:         run.MaxAttempts,
+		Label:               run.Label,
+		ConsecutiveFailures: run.ConsecutiveFailures,
+		Tag:                 strings.TrimSpace(tagChannelIfStatusConfigured(run.Status, tagStatuses)),
+		StartTime:           run.StartTime,
+		EndTime:             run.EndTime,
+		Duration:            core.FormatDuration(run.StartTime, run.EndTime),
+		Signal:              run.Signal,
+		UserCpu:             core.FormatCpuTime(run.UserCpuMs),
+		SystemCpu:           core.FormatCpuTime(run.SystemCpuMs),
+		MaxRss:              core.FormatMaxRss(run.MaxRssKb),
+		LogFile:             run.LogFile,
+		NotifyLogContent:    run.NotifyLogContent,
+	}
+	if run.StartTime.IsZero() {
+		data.Duration = ""
+	}
+	if useLocalTime {
+		data.StartTime = run.StartTime.In(time.Local)
+		data.EndTime = run.EndTime.In(time.Local)
+	}
+	if run.ExitCode.Valid {
+		data.ExitCode = &run.ExitCode.Int64
+	}
+	if run.NotifyLogContent && run.LogFile != "" {
+		logContent, err := archive.ReadFile(run.LogFile)
+		if err != nil {
+			log.Printf("Unable to read logfile: %s. Notify message will omit it.", run.LogFile)
+		} else {
+			content := string(logContent)
+			data.LogContent = &content
+		}
+	}
+	return data
+}
+
+// Returns the template for a run. The most specific of notify.templates
+// matching the job and status is used, then notify.template, then the
+// default.
+func notifyTemplate(conf config.NotifyConfig, jobName string, status core.RunStatus) string {
+	text := conf.Template
+	best := 0
+	for _, t := range conf.Templates {
+		score := 0
+		if t.Job != "" {
+			if t.Job != jobName {
+				continue
+			}
+			score += 2
+		}
+		if t.Status != "" {
+			if t.Status != string(status) {
+				continue
+			}
+			score += 1
+		}
+		if score > best {
+			text = t.Template
+			best = score
+		}
+	}
+	if text == "" {
+		return DefaultNotifyTemplate
+	}
+	return text
+}
+
+func executeNotifyTemplate(text string, data NotifyTemplateData) (string, error) {
+	t, err := template.New("notify").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Checks that the configured templates parse, and execute against an example
+// run, so a mistake is found when the config is loaded rather than when a
+// notification is sent.
+func ValidateTemplates(conf config.NotifyConfig) error {
+	exitCode := int64(1)
+	example := NotifyTemplateData{
+		ID:             1,
+		Job:            "example",
+		Host:           conf.Hostname,
+		Status:         string(core.RunStatusFailed),
+		StatusText:     string(core.RunStatusFailed),
+		PreviousStatus: string(core.RunStatusSucceeded),
+		Attempt:        1,
+		MaxAttempts:    1,
+		StartTime:      time.Now(),
+		EndTime:        time.Now(),
+		Duration:       "0s",
+		ExitCode:       &exitCode,
+	}
+	var errs []error
+	if conf.Template != "" {
+		if _, err := executeNotifyTemplate(conf.Template, example); err != nil {
+			errs = append(errs, fmt.Errorf("invalid notify.template: %w", err))
+		}
+	}
+	for i, t := range conf.Templates {
+		if t.Job == "" && t.Status == "" {
+			errs = append(errs, fmt.Errorf("invalid notify.templates[%d]: job or status must be set", i))
+		}
+		switch core.RunStatus(t.Status) {
+		case "", core.RunStatusRunning, core.RunStatusSkipped, core.RunStatusSucceeded,
+			core.RunStatusFailed, core.RunStatusTerminated, core.RunStatusTimedOut, core.RunStatusLost,
+			core.RunStatusQueued:
+		default:
+			errs = append(errs, fmt.Errorf("invalid notify.templates[%d]: unknown status %s", i, t.Status))
+		}
+		if t.Template == "" {
+			errs = append(errs, fmt.Errorf("invalid notify.templates[%d]: template must be set", i))
+		} else if _, err := executeNotifyTemplate(t.Template, example); err != nil {
+			errs = append(errs, fmt.Errorf("invalid notify.templates[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
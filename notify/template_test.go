@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"database/sql"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_notifyTemplate(t *testing.T) {
+	conf := config.NotifyConfig{
+		Template: "global",
+		Templates: []config.NotifyTemplateConfig{
+			{Status: "Failed", Template: "failed"},
+			{Job: "job-1", Template: "job-1"},
+			{Job: "job-1", Status: "Failed", Template: "job-1-failed"},
+			{Job: "job-2", Status: "Succeeded", Template: "job-2-succeeded"},
+		},
+	}
+	data := []struct {
+		name     string
+		conf     config.NotifyConfig
+		job      string
+		status   core.RunStatus
+		expected string
+	}{
+		{"default", config.NotifyConfig{}, "job-1", core.RunStatusFailed, DefaultNotifyTemplate},
+		{"global", conf, "job-3", core.RunStatusSucceeded, "global"},
+		{"status", conf, "job-3", core.RunStatusFailed, "failed"},
+		{"job", conf, "job-1", core.RunStatusSucceeded, "job-1"},
+		{"job-status", conf, "job-1", core.RunStatusFailed, "job-1-failed"},
+		{"job-other-status", conf, "job-2", core.RunStatusFailed, "failed"},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			assert.Equal(t, d.expected, notifyTemplate(d.conf, d.job, d.status))
+		})
+	}
+}
+
+func Test_getTemplateNotifyText(t *testing.T) {
+	logFile := path.Join(t.TempDir(), "job.log")
+	if err := os.WriteFile(logFile, []byte("line 1\nline 2\nline 3\n"), 0666); err != nil {
+		t.Fatal(err.Error())
+	}
+	startTime := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	run := RunNotifyInfo{
+		Name:           "test-1",
+		Id:             34,
+		Status:         core.RunStatusFailed,
+		PreviousStatus: core.RunStatusSucceeded,
+		LogFile:        logFile,
+		ExitCode:       sql.NullInt64{Int64: 3, Valid: true},
+		StartTime:      startTime,
+		EndTime:        startTime.Add(90 * time.Second),
+	}
+	data := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"fields", "{{.Job}}@{{.Host}} #{{.ID}} {{.StatusText}} after {{.PreviousStatus}} {{.Tag}}",
+			"test-1@host #34 ❌ Failed after Succeeded <!channel>"},
+		{"result", "exit {{.ExitCode}} in {{.Duration}} from {{.StartTime.Format \"15:04\"}} to {{.EndTime.Format \"15:04:05\"}}",
+			"exit 3 in 1m30s from 10:00 to 10:01:30"},
+		{"log-tail", "{{.LogFile}}\n{{.LogTail 2}}", logFile + "\nline 2\nline 3"},
+		{"invalid-falls-back-to-default", "{{.NotAField}}",
+			"*test-1@host*: run 34 - ❌ Failed <!channel>\nExit code: 3\nLog: `" + logFile + "`"},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			text := getTemplateNotifyText(d.template, run, config.StatusConfig{Failed: true}, "host", true, false)
+			assert.Equal(t, d.expected, text)
+		})
+	}
+}
+
+func Test_ValidateTemplates(t *testing.T) {
+	data := []struct {
+		name  string
+		conf  config.NotifyConfig
+		valid bool
+	}{
+		{"empty", config.NotifyConfig{}, true},
+		{"default", config.NotifyConfig{Template: DefaultNotifyTemplate}, true},
+		{"valid", config.NotifyConfig{
+			Template: "{{.Job}}: {{.Status}}",
+			Templates: []config.NotifyTemplateConfig{
+				{Job: "job-1", Status: "TimedOut", Template: "{{.Job}} timed out after {{.Duration}}\n{{.LogTail 10}}"},
+			},
+		}, true},
+		{"parse-error", config.NotifyConfig{Template: "{{.Job"}, false},
+		{"unknown-field", config.NotifyConfig{Template: "{{.Name}}"}, false},
+		{"unknown-status", config.NotifyConfig{
+			Templates: []config.NotifyTemplateConfig{{Status: "failed", Template: "{{.Job}}"}},
+		}, false},
+		{"no-job-or-status", config.NotifyConfig{
+			Templates: []config.NotifyTemplateConfig{{Template: "{{.Job}}"}},
+		}, false},
+		{"no-template", config.NotifyConfig{
+			Templates: []config.NotifyTemplateConfig{{Job: "job-1"}},
+		}, false},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			err := ValidateTemplates(d.conf)
+			if d.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/samcarswell/trochilus/archive"
+	"github.com/samcarswell/trochilus/config"
+)
+
+const webhookSignatureHeader = "X-Troc-Signature"
+const webhookPayloadVersion = 1
+const webhookDefaultTimeout = 30 * time.Second
+
+const WebhookEventRun = "run"
+const WebhookEventCheck = "check"
+
+// The JSON body posted to a webhook. Fields may be added, but existing
+// fields will not be renamed or removed without changing Version.
+type WebhookPayload struct {
+	Version         int        `json:"version"`
+	Event           string     `json:"event"`
+	RunID           int64      `json:"run_id"`
+	JobName         string     `json:"job_name"`
+	Host            string     `json:"host"`
+	Status          string     `json:"status"`
+	StartTime       *time.Time `json:"start_time"`
+	EndTime         *time.Time `json:"end_time"`
+	DurationSeconds *float64   `json:"duration_seconds"`
+	ExitCode        *int64     `json:"exit_code"`
+	Signal          string     `json:"signal"`
+	Attempt         int64      `json:"attempt"`
+	MaxAttempts     int64      `json:"max_attempts"`
+	LogFile         string     `json:"log_file"`
+	LogTail         []string   `json:"log_tail,omitempty"`
+	// "recovered" or "still failing" if the job's notify mode only notifies
+	// changes in its state, otherwise empty.
+	Label               string `json:"label"`
+	ConsecutiveFailures int64  `json:"consecutive_failures"`
+}
+
+func newWebhookPayload(run RunNotifyInfo, hostname string, logTailLines int) WebhookPayload {
+	payload := WebhookPayload{
+		Version:             webhookPayloadVersion,
+		Event:               WebhookEventRun,
+		RunID:               run.Id,
+		JobName:             run.Name,
+		Host:                hostname,
+		Status:              string(run.Status),
+		Signal:              run.Signal,
+		Attempt:             run.Attempt,
+		MaxAttempts:         run.MaxAttempts,
+		LogFile:             run.LogFile,
+		Label:               run.Label,
+		ConsecutiveFailures: run.ConsecutiveFailures,
+	}
+	if !run.StartTime.IsZero() {
+		startTime := run.StartTime.UTC()
+		payload.StartTime = &startTime
+	}
+	if !run.EndTime.IsZero() {
+		endTime := run.EndTime.UTC()
+		payload.EndTime = &endTime
+		if payload.StartTime != nil {
+			duration := endTime.Sub(*payload.StartTime).Seconds()
+			payload.DurationSeconds = &duration
+		}
+	}
+	if run.ExitCode.Valid {
+		payload.ExitCode = &run.ExitCode.Int64
+	}
+	if logTailLines > 0 && run.LogFile != "" {
+		payload.LogTail = logTail(run.LogFile, logTailLines)
+	}
+	return payload
+}
+
+// The JSON body posted to a webhook by troc check.
+type WebhookCheckPayload struct {
+	Version int               `json:"version"`
+	Event   string            `json:"event"`
+	Host    string            `json:"host"`
+	Jobs    []WebhookCheckJob `json:"jobs"`
+}
+
+type WebhookCheckJob struct {
+	JobName    string    `json:"job_name"`
+	Status     string    `json:"status"`
+	ExpectedAt time.Time `json:"expected_at"`
+}
+
+func newWebhookCheckPayload(checks []JobCheckNotifyInfo, hostname string) WebhookCheckPayload {
+	payload := WebhookCheckPayload{
+		Version: webhookPayloadVersion,
+		Event:   WebhookEventCheck,
+		Host:    hostname,
+		Jobs:    []WebhookCheckJob{},
+	}
+	for _, check := range checks {
+		payload.Jobs = append(payload.Jobs, WebhookCheckJob{
+			JobName:    check.Name,
+			Status:     string(check.Status),
+			ExpectedAt: check.ExpectedAt.UTC(),
+		})
+	}
+	return payload
+}
+
+type webhookNotifier struct {
+	webhook  config.WebhookConfig
+	hostname string
+}
+
+func newWebhookNotifier(target config.NotifyTargetConfig, conf config.Config) (Notifier, error) {
+	if target.Webhook.Url == "" {
+		return nil, errors.New("notify target '" + target.Name + "' url is blank")
+	}
+	webhook := target.Webhook
+	if webhook.Method == "" {
+		webhook.Method = http.MethodPost
+	}
+	if webhook.Timeout == 0 {
+		webhook.Timeout = webhookDefaultTimeout
+	}
+	return webhookNotifier{
+		webhook:  webhook,
+		hostname: conf.Notify.Hostname,
+	}, nil
+}
+
+func (n webhookNotifier) NotifyRun(run RunNotifyInfo) error {
+	_, err := notifyWebhook(n.webhook, newWebhookPayload(run, n.hostname, n.webhook.LogTail))
+	return err
+}
+
+func (n webhookNotifier) NotifyJobChecks(checks []JobCheckNotifyInfo) error {
+	_, err := notifyWebhook(n.webhook, newWebhookCheckPayload(checks, n.hostname))
+	return err
+}
+
+// Returns the last lines of a log file. As with the slack message, a log that
+// cannot be read is omitted rather than preventing the notification.
+func logTail(logFile string, lines int) []string {
+	f, err := archive.Open(logFile)
+	if err != nil {
+		log.Printf("Unable to read logfile: %s. Webhook payload will omit it.", logFile)
+		return nil
+	}
+	defer f.Close()
+
+	var tail []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		tail = append(tail, scanner.Text())
+		if len(tail) > lines {
+			tail = tail[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("Unable to read logfile: %s. Webhook payload will omit it.", logFile)
+		return nil
+	}
+	return tail
+}
+
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func notifyWebhook(webhookConf config.WebhookConfig, payload any) (bool, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return false, err
+	}
+
+	r, err := http.NewRequest(strings.ToUpper(webhookConf.Method), webhookConf.Url, bytes.NewBuffer(body))
+	if err != nil {
+		return false, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	for name, value := range webhookConf.Headers {
+		r.Header.Set(name, value)
+	}
+	if webhookConf.Secret != "" {
+		r.Header.Set(webhookSignatureHeader, signWebhookBody(webhookConf.Secret, body))
+	}
+
+	client := &http.Client{
+		Timeout: webhookConf.Timeout,
+	}
+	res, err := client.Do(r)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return false, fmt.Errorf("webhook returned status %s", res.Status)
+	}
+	return true, nil
+}
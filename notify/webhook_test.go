@@ -0,0 +1,197 @@
+package notify
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/stretchr/testify/assert"
+)
+
+type webhookRequest struct {
+	Method  string
+	Headers http.Header
+	Body    []byte
+}
+
+func newWebhookServer(t *testing.T, status int) (*httptest.Server, chan webhookRequest) {
+	requests := make(chan webhookRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		requests <- webhookRequest{
+			Method:  r.Method,
+			Headers: r.Header,
+			Body:    body,
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func Test_NotifyRunWebhook(t *testing.T) {
+	server, requests := newWebhookServer(t, http.StatusOK)
+	logFile := path.Join(t.TempDir(), "job.log")
+	if err := os.WriteFile(logFile, []byte("line 1\nline 2\nline 3\n"), 0666); err != nil {
+		t.Fatal(err.Error())
+	}
+	startTime := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	conf := config.Config{
+		Notify: config.NotifyConfig{
+			Hostname: "example-server",
+			Webhook: config.WebhookConfig{
+				Url:     server.URL,
+				Method:  "PUT",
+				Headers: map[string]string{"x-api-key": "key"},
+				Secret:  "secret",
+				LogTail: 2,
+				Timeout: 5 * time.Second,
+			},
+		},
+	}
+
+	err := NotifyRun(slog.Default(), conf, nil, RunNotifyInfo{
+		Name:                "test-1",
+		Id:                  34,
+		Status:              core.RunStatusFailed,
+		LogFile:             logFile,
+		ExitCode:            sql.NullInt64{Int64: 3, Valid: true},
+		Attempt:             1,
+		MaxAttempts:         1,
+		StartTime:           startTime,
+		EndTime:             startTime.Add(90 * time.Second),
+		Label:               NotifyLabelStillFailing,
+		ConsecutiveFailures: 4,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	req := <-requests
+	assert.Equal(t, "PUT", req.Method)
+	assert.Equal(t, "application/json", req.Headers.Get("Content-Type"))
+	assert.Equal(t, "key", req.Headers.Get("X-Api-Key"))
+	assert.Equal(t, signWebhookBody("secret", req.Body), req.Headers.Get(webhookSignatureHeader))
+
+	var payload map[string]any
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, map[string]any{
+		"version":              float64(1),
+		"event":                "run",
+		"run_id":               float64(34),
+		"job_name":             "test-1",
+		"host":                 "example-server",
+		"status":               "Failed",
+		"start_time":           "2025-01-01T10:00:00Z",
+		"end_time":             "2025-01-01T10:01:30Z",
+		"duration_seconds":     float64(90),
+		"exit_code":            float64(3),
+		"signal":               "",
+		"attempt":              float64(1),
+		"max_attempts":         float64(1),
+		"log_file":             logFile,
+		"log_tail":             []any{"line 2", "line 3"},
+		"label":                "still failing",
+		"consecutive_failures": float64(4),
+	}, payload)
+}
+
+func Test_NotifyRunWebhookSkipped(t *testing.T) {
+	server, requests := newWebhookServer(t, http.StatusNoContent)
+	conf := config.Config{
+		Notify: config.NotifyConfig{
+			Webhook: config.WebhookConfig{
+				Url: server.URL,
+			},
+		},
+	}
+
+	err := NotifyRun(slog.Default(), conf, nil, RunNotifyInfo{
+		Name:      "test-1",
+		Id:        34,
+		Status:    core.RunStatusSkipped,
+		StartTime: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	req := <-requests
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "", req.Headers.Get(webhookSignatureHeader))
+	var payload WebhookPayload
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Nil(t, payload.EndTime)
+	assert.Nil(t, payload.DurationSeconds)
+	assert.Nil(t, payload.ExitCode)
+	assert.Nil(t, payload.LogTail)
+}
+
+func Test_NotifyRunWebhookError(t *testing.T) {
+	server, _ := newWebhookServer(t, http.StatusInternalServerError)
+	conf := config.Config{
+		Notify: config.NotifyConfig{
+			Webhook: config.WebhookConfig{
+				Url: server.URL,
+			},
+		},
+	}
+
+	err := NotifyRun(slog.Default(), conf, nil, RunNotifyInfo{
+		Name:   "test-1",
+		Id:     34,
+		Status: core.RunStatusFailed,
+	})
+	assert.EqualError(t, err, "notify target 'webhook': webhook returned status 500 Internal Server Error")
+}
+
+func Test_NotifyJobChecksWebhook(t *testing.T) {
+	server, requests := newWebhookServer(t, http.StatusOK)
+	conf := config.Config{
+		Notify: config.NotifyConfig{
+			Hostname: "example-server",
+			Webhook: config.WebhookConfig{
+				Url: server.URL,
+			},
+		},
+	}
+
+	err := NotifyJobChecks(slog.Default(), conf, []JobCheckNotifyInfo{
+		{Name: "test-1", Status: core.JobCheckStatusMissed, ExpectedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	req := <-requests
+	var payload map[string]any
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, map[string]any{
+		"version": float64(1),
+		"event":   "check",
+		"host":    "example-server",
+		"jobs": []any{map[string]any{
+			"job_name":    "test-1",
+			"status":      "Missed",
+			"expected_at": "2025-01-01T10:00:00Z",
+		}},
+	}, payload)
+}
@@ -1,12 +1,21 @@
 package opts
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/robfig/cron/v3"
 	"github.com/samcarswell/trochilus/core"
 	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
 )
 
 func GetStringOptOrExit(cmd *cobra.Command, name string) string {
@@ -33,6 +42,182 @@ func GetInt64OrExit(cmd *cobra.Command, name string) int64 {
 	}
 	return optVal
 }
+func GetDurationOptOrExit(cmd *cobra.Command, name string) time.Duration {
+	optVal, err := cmd.Flags().GetDuration(name)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err)
+	}
+	if optVal < 0 {
+		core.LogErrorAndExit(slog.Default(), errors.New("option "+name+" must not be negative"))
+	}
+	return optVal
+}
+
+// Returns a duration option as whole seconds, for storing against a job.
+// A zero duration is returned as null, meaning the setting is disabled.
+func GetSecondsOptOrExit(cmd *cobra.Command, name string) sql.NullInt64 {
+	optVal := GetDurationOptOrExit(cmd, name)
+	if optVal == 0 {
+		return sql.NullInt64{}
+	}
+	if optVal < time.Second {
+		core.LogErrorAndExit(slog.Default(), errors.New("option "+name+" must be at least 1s"))
+	}
+	return sql.NullInt64{
+		Int64: int64(optVal / time.Second),
+		Valid: true,
+	}
+}
+
+// Returns a count option that may not be negative. 0 is returned as null,
+// meaning unset.
+func GetCountOptOrExit(cmd *cobra.Command, name string) sql.NullInt64 {
+	optVal, err := cmd.Flags().GetInt64(name)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err)
+	}
+	if optVal < 0 {
+		core.LogErrorAndExit(slog.Default(), errors.New("option "+name+" must not be negative"))
+	}
+	if optVal == 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{
+		Int64: optVal,
+		Valid: true,
+	}
+}
+
+func GetRetryAttemptsOptOrExit(cmd *cobra.Command, name string) int64 {
+	optVal, err := cmd.Flags().GetInt64(name)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err)
+	}
+	if optVal < 1 {
+		core.LogErrorAndExit(slog.Default(), errors.New("option "+name+" must be at least 1"))
+	}
+	return optVal
+}
+
+func GetRetryBackoffOptOrExit(cmd *cobra.Command, name string) string {
+	optVal := GetStringOptOrExit(cmd, name)
+	switch core.RetryBackoff(optVal) {
+	case core.RetryBackoffFixed, core.RetryBackoffExponential:
+	default:
+		core.LogErrorAndExit(slog.Default(), fmt.Errorf("invalid %s: %s", name, optVal))
+	}
+	return optVal
+}
+
+func GetNotifyModeOptOrExit(cmd *cobra.Command, name string) string {
+	optVal := GetStringOptOrExit(cmd, name)
+	switch core.NotifyMode(optVal) {
+	case core.NotifyModeAlways, core.NotifyModeOnChange, core.NotifyModeOnFailureWithReminder:
+	default:
+		core.LogErrorAndExit(slog.Default(), fmt.Errorf("invalid %s: %s", name, optVal))
+	}
+	return optVal
+}
+
+func GetArchiveFormatOptOrExit(cmd *cobra.Command, name string) string {
+	optVal := GetStringOptOrExit(cmd, name)
+	switch core.ArchiveFormat(optVal) {
+	case core.ArchiveFormatNone, core.ArchiveFormatGzip, core.ArchiveFormatZstd:
+	default:
+		core.LogErrorAndExit(slog.Default(), fmt.Errorf("invalid %s: %s", name, optVal))
+	}
+	return optVal
+}
+
+// Returns a concurrency policy option as its mode and the number of runs of
+// the job allowed at once.
+func GetConcurrencyOptOrExit(cmd *cobra.Command, name string) (core.ConcurrencyMode, int64) {
+	optVal := GetStringOptOrExit(cmd, name)
+	mode, limit, err := core.ParseConcurrency(optVal)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err, fmt.Errorf("invalid %s: %s", name, optVal))
+	}
+	return mode, limit
+}
+
+// Returns an exit code list option in the format stored against a job.
+func GetExitCodesOptOrExit(cmd *cobra.Command, name string) string {
+	optVal, err := cmd.Flags().GetInt64Slice(name)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err)
+	}
+	return core.FormatExitCodes(optVal)
+}
+
+// Returns a cron expression option, validated against the standard cron
+// format. An empty expression is returned as null, meaning no schedule.
+func GetScheduleOptOrExit(cmd *cobra.Command, name string) sql.NullString {
+	optVal := GetStringOptOrExit(cmd, name)
+	if optVal == "" {
+		return sql.NullString{}
+	}
+	if _, err := cron.ParseStandard(optVal); err != nil {
+		core.LogErrorAndExit(slog.Default(), err, fmt.Errorf("invalid %s: %s", name, optVal))
+	}
+	return sql.NullString{
+		String: optVal,
+		Valid:  true,
+	}
+}
+
+// Returns a command option. An empty command is returned as null, meaning no
+// command.
+func GetCommandOptOrExit(cmd *cobra.Command, name string) sql.NullString {
+	optVal := GetStringOptOrExit(cmd, name)
+	if strings.TrimSpace(optVal) == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{
+		String: optVal,
+		Valid:  true,
+	}
+}
+
+// Returns a list of notify target names, each of which must be configured.
+func GetNotifyTargetsOptOrExit(cmd *cobra.Command, name string, configured []string) []string {
+	optVal, err := cmd.Flags().GetStringSlice(name)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err)
+	}
+	var targets []string
+	for _, target := range optVal {
+		if target == "" || slices.Contains(targets, target) {
+			continue
+		}
+		if !slices.Contains(configured, target) {
+			core.LogErrorAndExit(slog.Default(), errors.New("notify target '"+target+"' is not configured"))
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// Returns a list of lock group names. Names are used in lock file names, so
+// may only contain letters, digits, '.', '_' and '-'.
+func GetLockGroupsOptOrExit(cmd *cobra.Command, name string) []string {
+	optVal, err := cmd.Flags().GetStringSlice(name)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), err)
+	}
+	var groups []string
+	for _, group := range optVal {
+		if group == "" || slices.Contains(groups, group) {
+			continue
+		}
+		if !lockGroupPattern.MatchString(group) {
+			core.LogErrorAndExit(slog.Default(), fmt.Errorf("invalid %s: %s", name, group))
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+var lockGroupPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
 
 func FormatTableOpt(cmd *cobra.Command, dest *string) {
 	cmd.Flags().StringVarP(dest, "format", "f", string(core.FormatPretty), "Format output (pretty|json|csv|tsv)")
@@ -59,3 +244,119 @@ func FormatObjectOptValidate(cmd *cobra.Command, format string) error {
 	}
 	return nil
 }
+
+// Returns a run status option, matched ignoring case. An empty option is
+// returned as is.
+func GetRunStatusOptOrExit(cmd *cobra.Command, name string) string {
+	optVal := GetStringOptOrExit(cmd, name)
+	status, err := ParseRunStatus(optVal)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), fmt.Errorf("invalid %s: %s", name, optVal))
+	}
+	return status
+}
+
+// Returns the run status matching value, ignoring case. A blank value is
+// returned as is.
+func ParseRunStatus(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	for _, status := range core.RunStatuses {
+		if strings.EqualFold(value, string(status)) {
+			return string(status), nil
+		}
+	}
+	return "", errors.New("unknown run status " + value)
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Parses a time that is either absolute, eg. 2025-01-02 or 2025-01-02 15:04,
+// or relative to now, eg. 24h for 24 hours ago. Absolute times without a
+// time zone are in the local time zone.
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		if duration < 0 {
+			return time.Time{}, errors.New("relative time must not be negative")
+		}
+		return now.Add(-duration), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("expected a time such as 2006-01-02, 2006-01-02 15:04 or 24h")
+}
+
+// Returns a time option parsed with ParseTime. An unset option is returned as
+// null.
+func GetTimeOptOrExit(cmd *cobra.Command, name string, now time.Time) sql.NullTime {
+	if !cmd.Flags().Changed(name) {
+		return sql.NullTime{}
+	}
+	optVal := GetStringOptOrExit(cmd, name)
+	t, err := ParseTime(optVal, now)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), fmt.Errorf("invalid %s: %s", name, optVal), err)
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+var runSortFields = []string{"start", "duration", "id"}
+
+// Returns a run sort option parsed with ParseRunSort.
+func GetRunSortOptOrExit(cmd *cobra.Command, name string) string {
+	optVal := GetStringOptOrExit(cmd, name)
+	sort, err := ParseRunSort(optVal)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), fmt.Errorf("invalid %s: %s", name, optVal))
+	}
+	return sort
+}
+
+// Parses a run sort in the form field:direction, eg. duration:desc. The
+// direction defaults to desc.
+func ParseRunSort(value string) (string, error) {
+	field, direction, found := strings.Cut(strings.ToLower(value), ":")
+	if !found {
+		direction = "desc"
+	}
+	if !slices.Contains(runSortFields, field) || (direction != "asc" && direction != "desc") {
+		return "", errors.New("expected a sort such as start, duration:asc or id:desc")
+	}
+	return field + ":" + direction, nil
+}
+
+// Returns a signal option parsed with ParseSignal.
+func GetSignalOptOrExit(cmd *cobra.Command, name string) syscall.Signal {
+	optVal := GetStringOptOrExit(cmd, name)
+	sig, err := ParseSignal(optVal)
+	if err != nil {
+		core.LogErrorAndExit(slog.Default(), fmt.Errorf("invalid %s: %s", name, optVal))
+	}
+	return sig
+}
+
+// Parses a signal name, with or without the SIG prefix and ignoring case, or
+// number. eg. TERM, SIGKILL or 9
+func ParseSignal(value string) (syscall.Signal, error) {
+	if num, err := strconv.Atoi(value); err == nil && num > 0 && unix.SignalName(syscall.Signal(num)) != "" {
+		return syscall.Signal(num), nil
+	}
+	name := strings.ToUpper(value)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig := unix.SignalNum(name); sig != 0 {
+		return sig, nil
+	}
+	return 0, errors.New("expected a signal such as TERM, SIGINT or 9")
+}
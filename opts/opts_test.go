@@ -0,0 +1,72 @@
+package opts
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseTime(t *testing.T) {
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	data := []struct {
+		name     string
+		value    string
+		expected time.Time
+		err      bool
+	}{
+		{"relative", "24h", now.Add(-24 * time.Hour), false},
+		{"relative-minutes", "90m", now.Add(-90 * time.Minute), false},
+		{"rfc3339", "2025-01-01T08:30:00Z", time.Date(2025, 1, 1, 8, 30, 0, 0, time.UTC), false},
+		{"date-time", "2025-01-01 08:30", time.Date(2025, 1, 1, 8, 30, 0, 0, time.Local), false},
+		{"date", "2025-01-01", time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local), false},
+		{"negative", "-1h", time.Time{}, true},
+		{"invalid", "yesterday", time.Time{}, true},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			parsed, err := ParseTime(d.value, now)
+			if d.err {
+				assert.Error(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			assert.True(t, d.expected.Equal(parsed), "expected %s, got %s", d.expected, parsed)
+		})
+	}
+}
+
+func Test_ParseSignal(t *testing.T) {
+	data := []struct {
+		name     string
+		value    string
+		expected syscall.Signal
+		err      bool
+	}{
+		{"name", "TERM", syscall.SIGTERM, false},
+		{"prefixed", "SIGKILL", syscall.SIGKILL, false},
+		{"lower-case", "int", syscall.SIGINT, false},
+		{"number", "1", syscall.SIGHUP, false},
+		{"unknown-name", "NOPE", 0, true},
+		{"unknown-number", "999", 0, true},
+		{"zero", "0", 0, true},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			sig, err := ParseSignal(d.value)
+			if d.err {
+				assert.Error(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			assert.Equal(t, d.expected, sig)
+		})
+	}
+}
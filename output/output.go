@@ -0,0 +1,143 @@
+package output
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/samcarswell/trochilus/archive"
+	"github.com/samcarswell/trochilus/data"
+)
+
+// A line of stored run output matching a pattern.
+type Match struct {
+	RunID     int64
+	JobName   string
+	StartTime time.Time
+	Line      int64
+	Text      string
+}
+
+// Stores the log of a run in the database, split into chunks of chunkLines
+// lines. The chunks are stored in one transaction, so the output of a run is
+// either stored in full or not at all.
+func Store(
+	ctx context.Context,
+	conn *sql.DB,
+	runId int64,
+	logFile string,
+	chunkLines int,
+) error {
+	if chunkLines < 1 {
+		return errors.New("output chunk lines must be at least 1")
+	}
+	r, err := archive.Open(logFile)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := data.New(conn).WithTx(tx)
+
+	reader := bufio.NewReader(r)
+	var chunk strings.Builder
+	var chunkNo int64
+	var lines, firstLine int64 = 0, 1
+	storeChunk := func() error {
+		if chunk.Len() == 0 {
+			return nil
+		}
+		chunkNo++
+		err := qtx.CreateRunOutput(ctx, data.CreateRunOutputParams{
+			RunID:     runId,
+			Chunk:     chunkNo,
+			FirstLine: firstLine,
+			Content:   chunk.String(),
+		})
+		firstLine += lines
+		lines = 0
+		chunk.Reset()
+		return err
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			chunk.WriteString(line)
+			lines++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if lines >= int64(chunkLines) {
+			if err := storeChunk(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := storeChunk(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Returns the stored output of a run, or false if none has been stored.
+func Read(ctx context.Context, db *data.Queries, runId int64) (string, bool, error) {
+	chunks, err := db.GetRunOutput(ctx, runId)
+	if err != nil {
+		return "", false, err
+	}
+	return strings.Join(chunks, ""), len(chunks) > 0, nil
+}
+
+// Returns the lines of stored output that contain the pattern, ignoring case,
+// newest run first. The full-text index finds the chunks that may match, and
+// their lines are then checked individually.
+func Grep(
+	ctx context.Context,
+	db *data.Queries,
+	pattern string,
+	jobName string,
+	since time.Time,
+) ([]Match, error) {
+	if pattern == "" {
+		return nil, errors.New("pattern must not be blank")
+	}
+	rows, err := db.GrepRunOutput(ctx, data.GrepRunOutputParams{
+		Content:   "%" + pattern + "%",
+		Dollar2:   jobName,
+		StartTime: since.UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	lowerPattern := strings.ToLower(pattern)
+	matches := []Match{}
+	for _, row := range rows {
+		lines := strings.Split(strings.TrimSuffix(row.Content, "\n"), "\n")
+		for i, line := range lines {
+			if !strings.Contains(strings.ToLower(line), lowerPattern) {
+				continue
+			}
+			matches = append(matches, Match{
+				RunID:     row.RunID,
+				JobName:   row.Name,
+				StartTime: row.StartTime,
+				Line:      row.FirstLine + int64(i),
+				Text:      line,
+			})
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,204 @@
+package output
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func createOutputRun(ctx context.Context, t *testing.T, db *data.Queries, jobName string, content string) int64 {
+	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  jobName,
+		RetryAttempts:         1,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	logFile := path.Join(t.TempDir(), jobName+".log")
+	if err := os.WriteFile(logFile, []byte(content), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	runId, err := db.StartRun(ctx, data.StartRunParams{
+		JobID:   jobId,
+		LogFile: logFile,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return runId
+}
+
+func Test_Store(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	content := "line 1\nline 2\nline 3\nline 4\nline 5"
+	runId := createOutputRun(ctx, t, db, test.UniqueIdentifer(), content)
+	run, err := db.GetRun(ctx, runId)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = Store(ctx, conn, runId, run.Run.LogFile, 2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	chunks, err := db.GetRunOutput(ctx, runId)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, []string{"line 1\nline 2\n", "line 3\nline 4\n", "line 5"}, chunks)
+	stored, ok, err := Read(ctx, db, runId)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.True(t, ok)
+	assert.Equal(t, content, stored)
+}
+
+func Test_StoreEmpty(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	runId := createOutputRun(ctx, t, db, test.UniqueIdentifer(), "")
+	run, err := db.GetRun(ctx, runId)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = Store(ctx, conn, runId, run.Run.LogFile, 1000)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	_, ok, err := Read(ctx, db, runId)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.False(t, ok)
+}
+
+func Test_Grep(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobName1 := test.UniqueIdentifer()
+	jobName2 := test.UniqueIdentifer()
+	runId1 := createOutputRun(ctx, t, db, jobName1, "starting\nan ERROR occurred\n100% done\nerror again\n")
+	runId2 := createOutputRun(ctx, t, db, jobName2, "no errors\n")
+	for _, runId := range []int64{runId1, runId2} {
+		run, err := db.GetRun(ctx, runId)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := Store(ctx, conn, runId, run.Run.LogFile, 2); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	data := []struct {
+		name     string
+		pattern  string
+		jobName  string
+		since    time.Time
+		expected []Match
+	}{
+		{"ignores-case", "error", "", time.Time{}, []Match{
+			{RunID: runId2, JobName: jobName2, Line: 1, Text: "no errors"},
+			{RunID: runId1, JobName: jobName1, Line: 2, Text: "an ERROR occurred"},
+			{RunID: runId1, JobName: jobName1, Line: 4, Text: "error again"},
+		}},
+		{"job-name", "error", jobName1, time.Time{}, []Match{
+			{RunID: runId1, JobName: jobName1, Line: 2, Text: "an ERROR occurred"},
+			{RunID: runId1, JobName: jobName1, Line: 4, Text: "error again"},
+		}},
+		{"like-wildcards", "0%", "", time.Time{}, []Match{
+			{RunID: runId1, JobName: jobName1, Line: 3, Text: "100% done"},
+		}},
+		{"short-pattern", "ag", "", time.Time{}, []Match{
+			{RunID: runId1, JobName: jobName1, Line: 4, Text: "error again"},
+		}},
+		{"since", "error", "", time.Now().Add(time.Hour), []Match{}},
+		{"no-match", "warning", "", time.Time{}, []Match{}},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			matches, err := Grep(ctx, db, d.pattern, d.jobName, d.since)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			for i := range matches {
+				matches[i].StartTime = time.Time{}
+			}
+			assert.Equal(t, d.expected, matches)
+		})
+	}
+}
+
+func Test_GrepDeletedOutput(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	runId := createOutputRun(ctx, t, db, test.UniqueIdentifer(), "an error\n")
+	run, err := db.GetRun(ctx, runId)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := Store(ctx, conn, runId, run.Run.LogFile, 1000); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = db.DeleteRunOutput(ctx, runId)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	matches, err := Grep(ctx, db, "error", "", time.Time{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	assert.Empty(t, matches)
+}
+
+func Test_GrepMissingTriggers(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	var dbPath string
+	if err := conn.QueryRowContext(ctx, `select file from pragma_database_list where name = 'main'`).Scan(&dbPath); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := conn.ExecContext(ctx, `drop trigger run_output_insert; drop trigger run_output_delete`); err != nil {
+		t.Fatal(err.Error())
+	}
+	// Reopening recreates the triggers, as on the next up after a rollback
+	conn = config.CreateOrUpdateDatabase(os.DirFS(test.MigrationsDir()), ctx, dbPath, ".")
+	db := data.New(conn)
+	runId := createOutputRun(ctx, t, db, test.UniqueIdentifer(), "an error\n")
+	run, err := db.GetRun(ctx, runId)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := Store(ctx, conn, runId, run.Run.LogFile, 1000); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	matches, err := Grep(ctx, db, "error", "", time.Time{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	assert.Len(t, matches, 1)
+}
@@ -0,0 +1,191 @@
+package reap
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/notify"
+)
+
+const (
+	reasonRebooted = "the machine has restarted since the run started"
+	reasonExited   = "its process no longer exists"
+	reasonReused   = "its PID has been reused by another process"
+)
+
+// A Running or Queued run whose troc exec no longer exists, eg. as it was
+// killed with SIGKILL.
+type LostRun struct {
+	Run     data.Run
+	JobName string
+	// The running attempt of a run with retries
+	Attempt *data.Run
+	Reason  string
+}
+
+// Returns why a run is lost, or "" if it is still running. isExecuting is
+// whether its command is running, rather than it being queued or waiting to
+// retry. bootID is the ID of the current boot, or "" if it can't be read.
+//
+// A run is lost once the troc exec running it no longer exists, and any
+// command it was executing has exited, as the command outlives troc exec
+// when it is killed. Runs started before troc exec recorded its PID can only
+// be lost while executing, once their command no longer exists.
+func lostReason(run data.Run, isExecuting bool, bootID string) (string, error) {
+	if run.BootID.Valid && bootID != "" && run.BootID.String != bootID {
+		return reasonRebooted, nil
+	}
+	var commandReason string
+	if isExecuting && run.Pid.Valid {
+		var err error
+		commandReason, err = processLostReason(int(run.Pid.Int64), run.PidStartTime, func() bool {
+			return core.IsRunAlive(run)
+		})
+		if err != nil || commandReason == "" {
+			return "", err
+		}
+	}
+	if !run.ExecPid.Valid {
+		return commandReason, nil
+	}
+	pid := int(run.ExecPid.Int64)
+	return processLostReason(pid, run.ExecPidStartTime, func() bool {
+		return core.IsProcessAlive(pid)
+	})
+}
+
+// Returns why the process with pid is gone, or "" if it is still running.
+// Processes recorded without a start time, or where it can't be read, are
+// only gone once isAlive is false.
+func processLostReason(pid int, pidStartTime sql.NullInt64, isAlive func() bool) (string, error) {
+	startTime, err := core.ProcessStartTime(pid)
+	if errors.Is(err, errors.ErrUnsupported) {
+		if isAlive() {
+			return "", nil
+		}
+		return reasonExited, nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return reasonExited, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if pidStartTime.Valid && pidStartTime.Int64 != startTime {
+		return reasonReused, nil
+	}
+	return "", nil
+}
+
+// Returns the Running and Queued runs executed on host that are lost,
+// including runs from before the host was recorded. Runs that have recorded
+// neither the PID of their troc exec nor of their command are never lost.
+func Plan(ctx context.Context, db *data.Queries, host string) ([]LostRun, error) {
+	bootID, err := core.BootID()
+	if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+		return nil, err
+	}
+	rows, err := db.GetUnfinishedRuns(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var lost []LostRun
+	for _, row := range rows {
+		isExecuting := row.Run.Status == string(core.RunStatusRunning)
+		attempts, err := db.GetRunAttempts(ctx, sql.NullInt64{Int64: row.Run.ID, Valid: true})
+		if err != nil {
+			return nil, err
+		}
+		var attempt *data.Run
+		if len(attempts) > 0 {
+			// A run is waiting to retry once its last attempt has finished
+			last := attempts[len(attempts)-1].Run
+			isExecuting = isExecuting && last.Status == string(core.RunStatusRunning)
+			if isExecuting {
+				attempt = &last
+			}
+		}
+		reason, err := lostReason(row.Run, isExecuting, bootID)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			lost = append(lost, LostRun{
+				Run:     row.Run,
+				JobName: row.Job.Name,
+				Attempt: attempt,
+				Reason:  reason,
+			})
+		}
+	}
+	return lost, nil
+}
+
+// Marks the runs and their running attempts as Lost. If isNotify is set their
+// notifications are added to the outbox, to be delivered by notify.Flush.
+// Runs that have completed since they were planned are left as they are.
+// Returns the runs that were marked.
+func Reap(
+	ctx context.Context,
+	logger *slog.Logger,
+	conn *sql.DB,
+	conf config.Config,
+	runs []LostRun,
+	isNotify bool,
+) ([]LostRun, error) {
+	var reaped []LostRun
+	for _, run := range runs {
+		ok, err := reapRun(ctx, logger, conn, conf, run, isNotify)
+		if err != nil {
+			return reaped, err
+		}
+		if ok {
+			core.LogRunLost(logger, run.Run.ID, run.JobName, int(run.Run.Pid.Int64), run.Reason)
+			reaped = append(reaped, run)
+		}
+	}
+	return reaped, nil
+}
+
+// Marks a run as Lost in one transaction with its attempt and notifications.
+// Returns false if the run is no longer Running or Queued.
+func reapRun(
+	ctx context.Context,
+	logger *slog.Logger,
+	conn *sql.DB,
+	conf config.Config,
+	run LostRun,
+	isNotify bool,
+) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := data.New(conn).WithTx(tx)
+	if run.Attempt != nil {
+		if _, err := qtx.LoseRun(ctx, run.Attempt.ID); err != nil {
+			return false, err
+		}
+	}
+	updated, err := qtx.LoseRun(ctx, run.Run.ID)
+	if err != nil || updated == 0 {
+		return false, err
+	}
+	if isNotify {
+		targets, err := qtx.GetJobNotifyTargets(ctx, run.Run.JobID)
+		if err != nil {
+			return false, err
+		}
+		if err := notify.Enqueue(ctx, logger, qtx, conf, run.Run.ID, targets); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,274 @@
+package reap
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os/exec"
+	"testing"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// Starts a process that runs until the test ends.
+func startProcess(t *testing.T) int {
+	process := exec.Command("sleep", "60")
+	if err := process.Start(); err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() {
+		_ = process.Process.Kill()
+		_ = process.Wait()
+	})
+	return process.Process.Pid
+}
+
+// Returns the PID of a process that has exited.
+func exitedProcess(t *testing.T) int {
+	process := exec.Command("true")
+	if err := process.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return process.Process.Pid
+}
+
+// Returns a run of the process, as recorded by troc exec.
+func processRun(pid int) data.Run {
+	identity := core.GetProcessIdentity(pid)
+	return data.Run{
+		Pid:          sql.NullInt64{Int64: int64(pid), Valid: true},
+		PidStartTime: identity.StartTime,
+		BootID:       identity.BootID,
+	}
+}
+
+// Returns a run whose troc exec is the process, as recorded by troc exec.
+func execRun(pid int) data.Run {
+	identity := core.GetProcessIdentity(pid)
+	return data.Run{
+		ExecPid:          sql.NullInt64{Int64: int64(pid), Valid: true},
+		ExecPidStartTime: identity.StartTime,
+		BootID:           identity.BootID,
+	}
+}
+
+func Test_lostReason(t *testing.T) {
+	bootID, err := core.BootID()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	running := processRun(startProcess(t))
+	reused := running
+	reused.PidStartTime.Int64--
+	rebooted := running
+	rebooted.BootID.String = "another-boot"
+	unrecorded := running
+	unrecorded.PidStartTime = sql.NullInt64{}
+	unrecorded.BootID = sql.NullString{}
+	exec := execRun(startProcess(t))
+	execExited := execRun(exitedProcess(t))
+	execReused := exec
+	execReused.ExecPidStartTime.Int64--
+	// troc exec was killed, but its command is still running
+	commandRunning := execExited
+	commandRunning.Pid = running.Pid
+	commandRunning.PidStartTime = running.PidStartTime
+	commandExited := exec
+	commandExited.Pid = sql.NullInt64{Int64: int64(exitedProcess(t)), Valid: true}
+
+	data := []struct {
+		name        string
+		run         data.Run
+		isExecuting bool
+		expected    string
+	}{
+		{"running", running, true, ""},
+		{"exited", processRun(exitedProcess(t)), true, reasonExited},
+		{"reused", reused, true, reasonReused},
+		{"rebooted", rebooted, true, reasonRebooted},
+		{"unrecorded", unrecorded, true, ""},
+		{"not executing without exec", processRun(exitedProcess(t)), false, ""},
+		{"exec running", exec, false, ""},
+		{"exec exited", execExited, false, reasonExited},
+		{"exec reused", execReused, false, reasonReused},
+		{"exec exited with command running", commandRunning, true, ""},
+		{"exec exited while waiting to retry", commandRunning, false, reasonExited},
+		{"exec running with command exited", commandExited, true, ""},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			reason, err := lostReason(d.run, d.isExecuting, bootID)
+			assert.Nil(t, err)
+			assert.Equal(t, d.expected, reason)
+		})
+	}
+}
+
+func Test_PlanAndReap(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	host := "host-1"
+	conf := config.Config{
+		Notify: config.NotifyConfig{
+			Webhook: config.WebhookConfig{Url: "http://localhost"},
+		},
+	}
+	jobName := test.UniqueIdentifer()
+	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  jobName,
+		RetryAttempts:         3,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	setPid := func(runId int64, pid int) {
+		run := processRun(pid)
+		err := db.UpdateRunPid(ctx, data.UpdateRunPidParams{
+			ID:           runId,
+			Pid:          run.Pid,
+			Pgid:         run.Pid,
+			PidStartTime: run.PidStartTime,
+			BootID:       run.BootID,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	startRun := func(host string, pid int) int64 {
+		runId, err := db.StartRun(ctx, data.StartRunParams{JobID: jobId, Host: host})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if pid != 0 {
+			setPid(runId, pid)
+		}
+		return runId
+	}
+	startAttempt := func(runId int64, attempt int64, pid int) int64 {
+		attemptId, err := db.StartAttempt(ctx, data.StartAttemptParams{
+			JobID:       jobId,
+			ParentRunID: sql.NullInt64{Int64: runId, Valid: true},
+			Attempt:     attempt,
+			Host:        host,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		setPid(attemptId, pid)
+		return attemptId
+	}
+	startExecRun := func(host string, execPid int) int64 {
+		run := execRun(execPid)
+		runId, err := db.StartRun(ctx, data.StartRunParams{
+			JobID:            jobId,
+			Host:             host,
+			ExecPid:          run.ExecPid,
+			ExecPidStartTime: run.ExecPidStartTime,
+			BootID:           run.BootID,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return runId
+	}
+	queueRun := func(execPid int) int64 {
+		run := execRun(execPid)
+		runId, err := db.QueueRun(ctx, data.QueueRunParams{
+			JobID:            jobId,
+			Host:             host,
+			ExecPid:          run.ExecPid,
+			ExecPidStartTime: run.ExecPidStartTime,
+			BootID:           run.BootID,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return runId
+	}
+	getStatus := func(runId int64) string {
+		run, err := db.GetRun(ctx, runId)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return run.Run.Status
+	}
+
+	runningId := startRun(host, startProcess(t))
+	exitedId := startRun(host, exitedProcess(t))
+	otherHostId := startRun("host-2", exitedProcess(t))
+	startingId := startRun(host, 0)
+	// Waiting to retry, as its only attempt has failed
+	retryingId := startRun(host, 0)
+	failedAttemptId := startAttempt(retryingId, 1, exitedProcess(t))
+	setPid(retryingId, exitedProcess(t))
+	if err := db.EndRun(ctx, data.EndRunParams{ID: failedAttemptId, Status: string(core.RunStatusFailed)}); err != nil {
+		t.Fatal(err.Error())
+	}
+	attemptingId := startRun(host, 0)
+	attemptPid := exitedProcess(t)
+	startAttempt(attemptingId, 1, exitedProcess(t))
+	attemptId := startAttempt(attemptingId, 2, attemptPid)
+	setPid(attemptingId, attemptPid)
+	// troc exec was killed while waiting to retry
+	execRetryingId := startExecRun(host, exitedProcess(t))
+	execFailedAttemptId := startAttempt(execRetryingId, 1, exitedProcess(t))
+	if err := db.EndRun(ctx, data.EndRunParams{ID: execFailedAttemptId, Status: string(core.RunStatusFailed)}); err != nil {
+		t.Fatal(err.Error())
+	}
+	queuedId := queueRun(startProcess(t))
+	execQueuedId := queueRun(exitedProcess(t))
+	// Started before the host was recorded
+	noHostId := startRun("", exitedProcess(t))
+	if err := db.AddJobNotifyTarget(ctx, data.AddJobNotifyTargetParams{JobID: jobId, TargetName: "webhook"}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	lost, err := Plan(ctx, db, host)
+
+	assert.Nil(t, err)
+	var lostIds []int64
+	for _, run := range lost {
+		lostIds = append(lostIds, run.Run.ID)
+		assert.Equal(t, jobName, run.JobName)
+		assert.Equal(t, reasonExited, run.Reason)
+	}
+	assert.Equal(t, []int64{noHostId, execQueuedId, execRetryingId, attemptingId, exitedId}, lostIds)
+	assert.Nil(t, lost[1].Attempt)
+	assert.Nil(t, lost[2].Attempt)
+	assert.Equal(t, attemptId, lost[3].Attempt.ID)
+
+	// Runs that complete before they are reaped are left as they are
+	if err := db.EndRun(ctx, data.EndRunParams{ID: exitedId, Status: string(core.RunStatusSucceeded)}); err != nil {
+		t.Fatal(err.Error())
+	}
+	reaped, err := Reap(ctx, slog.Default(), conn, conf, lost, true)
+
+	assert.Nil(t, err)
+	assert.Len(t, reaped, 4)
+	for _, runId := range []int64{noHostId, execQueuedId, execRetryingId, attemptingId, attemptId} {
+		assert.Equal(t, string(core.RunStatusLost), getStatus(runId), "run %d", runId)
+	}
+	assert.Equal(t, string(core.RunStatusFailed), getStatus(execFailedAttemptId))
+	assert.Equal(t, string(core.RunStatusSucceeded), getStatus(exitedId))
+	assert.Equal(t, string(core.RunStatusQueued), getStatus(queuedId))
+	for _, runId := range []int64{runningId, otherHostId, startingId, retryingId} {
+		assert.Equal(t, string(core.RunStatusRunning), getStatus(runId), "run %d", runId)
+	}
+	notifications, err := db.GetNotifications(ctx, data.GetNotificationsParams{Dollar1: 0, Dollar2: ""})
+	assert.Nil(t, err)
+	var notifiedIds []int64
+	for _, notification := range notifications {
+		notifiedIds = append(notifiedIds, notification.Notification.RunID)
+		assert.Equal(t, "webhook", notification.Notification.TargetName)
+	}
+	assert.ElementsMatch(t, []int64{noHostId, execQueuedId, execRetryingId, attemptingId}, notifiedIds)
+}
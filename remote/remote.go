@@ -0,0 +1,162 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/opts"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const defaultTroc = "troc"
+
+var defaultSsh = []string{"ssh"}
+
+// Runs troc commands on another machine.
+type Transport interface {
+	// Returns a command that runs troc with args on the other machine
+	Command(ctx context.Context, args []string) *exec.Cmd
+	Name() string
+}
+
+// Runs troc over ssh. The troc command is run by the remote user's shell, so
+// it can be a path such as ~/bin/troc, while args are quoted.
+type SSH struct {
+	Host string
+	Troc string
+	Ssh  []string
+}
+
+// Returns the remote configured with name, or a remote with name as its ssh
+// destination if none is configured.
+func New(remotes []config.RemoteConfig, name string) SSH {
+	remote := config.RemoteConfig{Name: name, Host: name}
+	for _, configured := range remotes {
+		if configured.Name == name {
+			remote = configured
+		}
+	}
+	ssh := SSH{
+		Host: remote.Host,
+		Troc: remote.Troc,
+		Ssh:  remote.Ssh,
+	}
+	if ssh.Troc == "" {
+		ssh.Troc = defaultTroc
+	}
+	if len(ssh.Ssh) == 0 {
+		ssh.Ssh = defaultSsh
+	}
+	return ssh
+}
+
+func (s SSH) Command(ctx context.Context, args []string) *exec.Cmd {
+	remoteCmd := s.Troc
+	for _, arg := range args {
+		remoteCmd += " " + quote(arg)
+	}
+	// -- stops a host starting with - from being read as an option
+	sshArgs := append(append([]string{}, s.Ssh[1:]...), "--", s.Host, remoteCmd)
+	return exec.CommandContext(ctx, s.Ssh[0], sshArgs...)
+}
+
+func (s SSH) Name() string {
+	return s.Host
+}
+
+// Quotes an argument for a POSIX shell.
+func quote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// Adds the --remote option to a command that can be run on another machine.
+func RemoteOpt(cmd *cobra.Command) {
+	cmd.Flags().String("remote", "", "Runs the command on another machine over ssh. Either the name of a configured remote or an ssh destination, eg. user@host")
+}
+
+// Returns the transport to the machine set by the --remote option, or nil if
+// the command is run locally.
+func GetRemoteOptOrExit(cmd *cobra.Command, remotes []config.RemoteConfig) Transport {
+	name := opts.GetStringOptOrExit(cmd, "remote")
+	if name == "" {
+		return nil
+	}
+	return New(remotes, name)
+}
+
+// Returns whether the command is run on another machine with --remote.
+func IsRemote(cmd *cobra.Command) bool {
+	flag := cmd.Flags().Lookup("remote")
+	return flag != nil && flag.Value.String() != ""
+}
+
+// Returns the arguments that run a command as it was called, without the
+// --remote and --format options, for running it on another machine.
+func Args(cmd *cobra.Command) []string {
+	args := strings.Fields(cmd.CommandPath())[1:]
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if flag.Name == "remote" || flag.Name == "format" {
+			return
+		}
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			for _, value := range slice.GetSlice() {
+				args = append(args, "--"+flag.Name+"="+value)
+			}
+			return
+		}
+		args = append(args, "--"+flag.Name+"="+flag.Value.String())
+	})
+	return args
+}
+
+// Runs troc with args on the remote, writing its output to stdout. The logs
+// of the remote troc are written to stderr.
+func Run(ctx context.Context, t Transport, args []string, stdout io.Writer) error {
+	cmd := t.Command(ctx, args)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Join(err, errors.New("troc failed on "+t.Name()))
+	}
+	return nil
+}
+
+// Runs troc with args on the remote with json output, and decodes it into v.
+func Json(ctx context.Context, t Transport, args []string, v any) error {
+	var stdout bytes.Buffer
+	if err := Run(ctx, t, append(args, "--format", string(core.FormatJson)), &stdout); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(stdout.Bytes(), v); err != nil {
+		return errors.Join(err, errors.New("unable to read output of troc on "+t.Name()))
+	}
+	return nil
+}
+
+// Runs a command on the remote as it was called, writing its output to
+// stdout, and exits if it fails.
+func RunOrExit(cmd *cobra.Command, t Transport) {
+	if err := Run(cmd.Context(), t, Args(cmd), os.Stdout); err != nil {
+		core.LogErrorAndExit(slog.Default(), err)
+	}
+}
+
+// Runs a command on the remote as it was called, returning its json output
+// decoded into T, and exits if it fails.
+func JsonOrExit[T any](cmd *cobra.Command, t Transport) T {
+	var v T
+	if err := Json(cmd.Context(), t, Args(cmd), &v); err != nil {
+		core.LogErrorAndExit(slog.Default(), err)
+	}
+	return v
+}
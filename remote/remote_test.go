@@ -0,0 +1,119 @@
+package remote
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+// Writes an executable shell script, returning its path.
+func writeScript(t *testing.T, name string, script string) string {
+	file := path.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte("#!/bin/sh\n"+script), 0700); err != nil {
+		t.Fatal(err.Error())
+	}
+	return file
+}
+
+// Returns a transport to a stand-in for ssh, which runs the remote command
+// with a local shell as sshd would.
+func fakeSSH(t *testing.T, troc string) SSH {
+	ssh := writeScript(t, "ssh", `
+while [ "$1" != "--" ]; do shift; done
+exec sh -c "$3"
+`)
+	return SSH{Host: "user@host", Troc: troc, Ssh: []string{ssh, "-p", "2222"}}
+}
+
+func Test_New(t *testing.T) {
+	remotes := []config.RemoteConfig{
+		{Name: "web1", Host: "deploy@web1.example.com", Troc: "~/bin/troc", Ssh: []string{"ssh", "-p", "2222"}},
+		{Name: "web2", Host: "web2.example.com"},
+	}
+
+	assert.Equal(t, SSH{Host: "deploy@web1.example.com", Troc: "~/bin/troc", Ssh: []string{"ssh", "-p", "2222"}}, New(remotes, "web1"))
+	assert.Equal(t, SSH{Host: "web2.example.com", Troc: "troc", Ssh: []string{"ssh"}}, New(remotes, "web2"))
+	assert.Equal(t, SSH{Host: "user@web3", Troc: "troc", Ssh: []string{"ssh"}}, New(remotes, "user@web3"))
+}
+
+func Test_SSHCommand(t *testing.T) {
+	ssh := SSH{Host: "user@host", Troc: "~/bin/troc", Ssh: []string{"/usr/bin/ssh", "-p", "2222"}}
+
+	cmd := ssh.Command(context.Background(), []string{"run", "list", "--name=it's a job"})
+
+	assert.Equal(t, []string{"/usr/bin/ssh", "-p", "2222", "--", "user@host", `~/bin/troc 'run' 'list' '--name=it'\''s a job'`}, cmd.Args)
+}
+
+func Test_Args(t *testing.T) {
+	root := &cobra.Command{Use: "troc"}
+	run := &cobra.Command{Use: "run"}
+	list := &cobra.Command{Use: "list", Run: func(cmd *cobra.Command, args []string) {}}
+	root.AddCommand(run)
+	run.AddCommand(list)
+	list.Flags().String("name", "", "")
+	list.Flags().Bool("running", false, "")
+	list.Flags().Int64("limit", 50, "")
+	list.Flags().StringSlice("target", nil, "")
+	list.Flags().StringP("format", "f", "pretty", "")
+	RemoteOpt(list)
+
+	root.SetArgs([]string{"run", "list", "--name", "backup job", "--running", "-f", "csv", "--target", "a,b", "--remote", "web1"})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	assert.Equal(t, []string{"run", "list", "--name=backup job", "--running=true", "--target=a", "--target=b"}, Args(list))
+	assert.Equal(t, New(nil, "web1"), GetRemoteOptOrExit(list, nil))
+}
+
+func Test_Run(t *testing.T) {
+	args := []string{"plain", "with space", `it's "quoted"`, "$HOME", "`id`", ""}
+	var stdout strings.Builder
+
+	err := Run(context.Background(), fakeSSH(t, "printf '%s|'"), args, &stdout)
+
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Join(args, "|")+"|", stdout.String())
+}
+
+func Test_RunFailed(t *testing.T) {
+	err := Run(context.Background(), fakeSSH(t, "exit 3 #"), []string{"run", "list"}, &strings.Builder{})
+
+	assert.ErrorContains(t, err, "exit status 3")
+	assert.ErrorContains(t, err, "troc failed on user@host")
+}
+
+func Test_Json(t *testing.T) {
+	dir := t.TempDir()
+	troc := writeScript(t, "troc", `
+printf '%s\n' "$@" > `+dir+`/args
+echo '[{"id": 2, "job_name": "backup", "status": "Failed"}, {"id": 1, "job_name": "backup", "status": "Succeeded"}]'
+`)
+	var runs []core.RunShow
+
+	err := Json(context.Background(), fakeSSH(t, troc), []string{"run", "list", "--name=backup"}, &runs)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []core.RunShow{
+		{ID: 2, JobName: "backup", Status: "Failed"},
+		{ID: 1, JobName: "backup", Status: "Succeeded"},
+	}, runs)
+	remoteArgs, err := os.ReadFile(path.Join(dir, "args"))
+	assert.Nil(t, err)
+	assert.Equal(t, "run\nlist\n--name=backup\n--format\njson\n", string(remoteArgs))
+}
+
+func Test_JsonInvalid(t *testing.T) {
+	var runs []core.RunShow
+
+	err := Json(context.Background(), fakeSSH(t, "echo not json #"), []string{"run", "list"}, &runs)
+
+	assert.ErrorContains(t, err, "unable to read output of troc on user@host")
+}
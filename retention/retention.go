@@ -0,0 +1,180 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+)
+
+const day = 24 * time.Hour
+
+// How long the runs of a job are kept. Zero values are unset.
+type Policy struct {
+	KeepLast       int64
+	KeepDays       int64
+	KeepFailedDays int64
+}
+
+// A run to be pruned, along with the files that are deleted with it.
+type PrunedRun struct {
+	Run      data.Run
+	JobName  string
+	Attempts int
+	Files    []string
+}
+
+// Returns the retention of a job. Values set on the job override the config.
+func JobPolicy(conf config.RetentionConfig, job data.Job) Policy {
+	policy := Policy{
+		KeepLast:       int64(conf.KeepLast),
+		KeepDays:       int64(conf.KeepDays),
+		KeepFailedDays: int64(conf.KeepFailedDays),
+	}
+	if job.KeepLast.Valid {
+		policy.KeepLast = job.KeepLast.Int64
+	}
+	if job.KeepDays.Valid {
+		policy.KeepDays = job.KeepDays.Int64
+	}
+	if job.KeepFailedDays.Valid {
+		policy.KeepFailedDays = job.KeepFailedDays.Int64
+	}
+	return policy
+}
+
+// Returns the runs of a job that are outside its retention. runs must be
+// ordered newest first. A run is kept if it is one of the last KeepLast runs,
+// started within KeepDays, or failed within KeepFailedDays. Running and Queued
+// runs are always kept, and nothing is pruned unless KeepLast or KeepDays is set.
+func expiredRuns(runs []data.Run, policy Policy, now time.Time) []data.Run {
+	if policy.KeepLast <= 0 && policy.KeepDays <= 0 {
+		return nil
+	}
+	var expired []data.Run
+	for i, run := range runs {
+		status := core.RunStatus(run.Status)
+		age := now.Sub(run.StartTime)
+		if status == core.RunStatusRunning || status == core.RunStatusQueued ||
+			(policy.KeepLast > 0 && int64(i) < policy.KeepLast) ||
+			(policy.KeepDays > 0 && age < time.Duration(policy.KeepDays)*day) ||
+			(core.IsFailedStatus(status) && policy.KeepFailedDays > 0 &&
+				age < time.Duration(policy.KeepFailedDays)*day) {
+			continue
+		}
+		expired = append(expired, run)
+	}
+	return expired
+}
+
+// Returns the runs that are outside the retention of their job, for every
+// job or only the named job.
+func Plan(
+	ctx context.Context,
+	db *data.Queries,
+	conf config.RetentionConfig,
+	jobName string,
+	now time.Time,
+) ([]PrunedRun, error) {
+	rows, err := db.GetPruneRuns(ctx, jobName)
+	if err != nil {
+		return nil, err
+	}
+	var pruned []PrunedRun
+	// Rows are grouped by job, newest first
+	for start := 0; start < len(rows); {
+		end := start
+		var runs []data.Run
+		for end < len(rows) && rows[end].Job.ID == rows[start].Job.ID {
+			runs = append(runs, rows[end].Run)
+			end++
+		}
+		job := rows[start].Job
+		for _, run := range expiredRuns(runs, JobPolicy(conf, job), now) {
+			prunedRun, err := newPrunedRun(ctx, db, run, job.Name)
+			if err != nil {
+				return nil, err
+			}
+			pruned = append(pruned, prunedRun)
+		}
+		start = end
+	}
+	return pruned, nil
+}
+
+func newPrunedRun(ctx context.Context, db *data.Queries, run data.Run, jobName string) (PrunedRun, error) {
+	attempts, err := db.GetRunAttempts(ctx, sql.NullInt64{Int64: run.ID, Valid: true})
+	if err != nil {
+		return PrunedRun{}, err
+	}
+	pruned := PrunedRun{
+		Run:      run,
+		JobName:  jobName,
+		Attempts: len(attempts),
+	}
+	addFile := func(file string) {
+		if file != "" && !slices.Contains(pruned.Files, file) {
+			pruned.Files = append(pruned.Files, file)
+		}
+	}
+	addFile(run.LogFile)
+	addFile(run.ExecLogFile)
+	for _, attempt := range attempts {
+		addFile(attempt.Run.LogFile)
+		addFile(attempt.Run.ExecLogFile)
+	}
+	return pruned, nil
+}
+
+// Deletes the runs, their attempts, notifications and stored output in one
+// transaction, so either every run is deleted or none are. Their files are
+// only deleted once the transaction is committed. Files that no longer exist are ignored.
+func Prune(
+	ctx context.Context,
+	logger *slog.Logger,
+	conn *sql.DB,
+	runs []PrunedRun,
+) error {
+	if len(runs) == 0 {
+		return nil
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := data.New(conn).WithTx(tx)
+	for _, run := range runs {
+		if err := qtx.DeleteRunNotifications(ctx, run.Run.ID); err != nil {
+			return err
+		}
+		if err := qtx.DeleteRunOutput(ctx, run.Run.ID); err != nil {
+			return err
+		}
+		if err := qtx.DeleteRun(ctx, run.Run.ID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, run := range runs {
+		core.LogRunPruned(logger, run.Run.ID, run.JobName)
+		for _, file := range run.Files {
+			err := os.Remove(file)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,169 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_expiredRuns(t *testing.T) {
+	now := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	newRun := func(id int64, status core.RunStatus, daysAgo int) data.Run {
+		return data.Run{
+			ID:        id,
+			Status:    string(status),
+			StartTime: now.Add(-time.Duration(daysAgo) * day),
+		}
+	}
+	// Newest first
+	runs := []data.Run{
+		newRun(6, core.RunStatusRunning, 0),
+		newRun(5, core.RunStatusSucceeded, 1),
+		newRun(4, core.RunStatusFailed, 3),
+		newRun(3, core.RunStatusSucceeded, 5),
+		newRun(2, core.RunStatusTimedOut, 7),
+		newRun(1, core.RunStatusRunning, 9),
+	}
+	data := []struct {
+		name     string
+		policy   Policy
+		expected []int64
+	}{
+		{"unset", Policy{}, nil},
+		{"keep-failed-days-only", Policy{KeepFailedDays: 1}, nil},
+		{"keep-last", Policy{KeepLast: 3}, []int64{3, 2}},
+		{"keep-days", Policy{KeepDays: 4}, []int64{3, 2}},
+		{"keep-last-or-days", Policy{KeepLast: 4, KeepDays: 2}, []int64{2}},
+		{"keep-failed-days", Policy{KeepLast: 1, KeepFailedDays: 8}, []int64{5, 3}},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			var ids []int64
+			for _, run := range expiredRuns(runs, d.policy, now) {
+				ids = append(ids, run.ID)
+			}
+			assert.Equal(t, d.expected, ids)
+		})
+	}
+}
+
+func Test_JobPolicy(t *testing.T) {
+	conf := config.RetentionConfig{KeepLast: 10, KeepDays: 30, KeepFailedDays: 90}
+	assert.Equal(t, Policy{KeepLast: 10, KeepDays: 30, KeepFailedDays: 90}, JobPolicy(conf, data.Job{}))
+	assert.Equal(t, Policy{KeepLast: 5, KeepDays: 30, KeepFailedDays: 1}, JobPolicy(conf, data.Job{
+		KeepLast:       sql.NullInt64{Int64: 5, Valid: true},
+		KeepFailedDays: sql.NullInt64{Int64: 1, Valid: true},
+	}))
+}
+
+func Test_Prune(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	dir := t.TempDir()
+
+	createJob := func(name string) int64 {
+		jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+			Name:                  name,
+			RetryAttempts:         1,
+			RetryBackoff:          string(core.RetryBackoffFixed),
+			NotifyMode:            string(core.NotifyModeAlways),
+			NotifyReminderSeconds: 3600,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return jobId
+	}
+	createFile := func(name string) string {
+		file := path.Join(dir, name)
+		if err := os.WriteFile(file, []byte(name), 0666); err != nil {
+			t.Fatal(err.Error())
+		}
+		return file
+	}
+	createRun := func(jobId int64, name string) int64 {
+		runId, err := db.StartRun(ctx, data.StartRunParams{
+			JobID:       jobId,
+			LogFile:     createFile(name + ".log"),
+			ExecLogFile: createFile("trocsys_" + name + ".log"),
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		err = db.EndRun(ctx, data.EndRunParams{ID: runId, Status: string(core.RunStatusFailed)})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return runId
+	}
+
+	jobName := test.UniqueIdentifer()
+	jobId := createJob(jobName)
+	oldRunId := createRun(jobId, "old")
+	attemptId, err := db.StartAttempt(ctx, data.StartAttemptParams{
+		JobID:       jobId,
+		LogFile:     createFile("old-attempt.log"),
+		ExecLogFile: path.Join(dir, "trocsys_old.log"),
+		ParentRunID: sql.NullInt64{Int64: oldRunId, Valid: true},
+		Attempt:     2,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = db.EnqueueNotification(ctx, data.EnqueueNotificationParams{RunID: oldRunId, TargetName: "slack"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = db.CreateRunOutput(ctx, data.CreateRunOutputParams{RunID: oldRunId, Chunk: 1, FirstLine: 1, Content: "old"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	newRunId := createRun(jobId, "new")
+	otherJobRunId := createRun(createJob(test.UniqueIdentifer()), "other")
+
+	pruned, err := Plan(ctx, db, config.RetentionConfig{KeepLast: 1}, jobName, time.Now())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Len(t, pruned, 1)
+	assert.Equal(t, oldRunId, pruned[0].Run.ID)
+	assert.Equal(t, 1, pruned[0].Attempts)
+	assert.Equal(t, []string{
+		path.Join(dir, "old.log"),
+		path.Join(dir, "trocsys_old.log"),
+		path.Join(dir, "old-attempt.log"),
+	}, pruned[0].Files)
+
+	assert.Nil(t, Prune(ctx, slog.Default(), conn, pruned))
+	for _, id := range []int64{oldRunId, attemptId} {
+		_, err = db.GetRun(ctx, id)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	}
+	for _, file := range pruned[0].Files {
+		assert.NoFileExists(t, file)
+	}
+	notifications, err := db.GetNotifications(ctx, data.GetNotificationsParams{Dollar1: oldRunId, Dollar2: ""})
+	assert.Nil(t, err)
+	assert.Empty(t, notifications)
+	runOutput, err := db.GetRunOutput(ctx, oldRunId)
+	assert.Nil(t, err)
+	assert.Empty(t, runOutput)
+	for _, id := range []int64{newRunId, otherJobRunId} {
+		_, err = db.GetRun(ctx, id)
+		assert.Nil(t, err)
+	}
+	assert.FileExists(t, path.Join(dir, "new.log"))
+	assert.FileExists(t, path.Join(dir, "trocsys_new.log"))
+}
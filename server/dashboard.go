@@ -0,0 +1,345 @@
+package server
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+)
+
+//go:embed templates static
+var dashboardFiles embed.FS
+
+const tokenCookie = "troc_token"
+
+type dashboardJob struct {
+	Name      string
+	Schedule  string
+	LastRunID int64
+	Status    string
+	LastRun   string
+	Duration  string
+}
+
+type jobsPage struct {
+	Jobs []dashboardJob
+}
+
+type runFilters struct {
+	Status string
+	Host   string
+	Since  string
+	Until  string
+	Limit  string
+}
+
+type jobPage struct {
+	Job      core.JobShow
+	Runs     []core.RunShow
+	Filters  runFilters
+	Statuses []core.RunStatus
+	NewerURL string
+	OlderURL string
+}
+
+type runPage struct {
+	Run     core.RunShow
+	Running bool
+	CanKill bool
+}
+
+type loginPage struct {
+	Next  string
+	Error string
+}
+
+type errorPage struct {
+	Status  int
+	Message string
+}
+
+// Parses the pages of the dashboard. Each page is parsed with the layout, and
+// status badges use the same emojis as the rest of troc.
+func parsePages(conf config.Config) map[string]*template.Template {
+	funcs := template.FuncMap{
+		"status": func(status string) string {
+			return core.FormatStatus(core.RunStatus(status), conf.Display.Emoji)
+		},
+		"statusClass": func(status string) string {
+			return "status-" + strings.ToLower(status)
+		},
+	}
+	pages := map[string]*template.Template{}
+	for _, page := range []string{"jobs", "job", "run", "login", "error"} {
+		pages[page] = template.Must(template.New("layout.html").Funcs(funcs).ParseFS(
+			dashboardFiles,
+			"templates/layout.html",
+			"templates/"+page+".html",
+		))
+	}
+	return pages
+}
+
+func (s *Server) dashboardRoutes(mux *http.ServeMux) {
+	static, err := fs.Sub(dashboardFiles, "static")
+	if err != nil {
+		panic(err)
+	}
+	mux.Handle("GET /ui/static/", http.StripPrefix("/ui/static/", http.FileServerFS(static)))
+	mux.Handle("GET /{$}", http.RedirectHandler("/ui/", http.StatusFound))
+	mux.HandleFunc("GET /ui/{$}", s.authorizePage(s.jobsPage))
+	mux.HandleFunc("GET /ui/jobs/{name}", s.authorizePage(s.jobPage))
+	mux.HandleFunc("GET /ui/runs/{id}", s.authorizePage(s.runPage))
+	mux.HandleFunc("POST /ui/runs/{id}/kill", s.authorizePage(s.killRun))
+	mux.HandleFunc("GET /ui/login", s.loginPage)
+	mux.HandleFunc("POST /ui/login", s.login)
+}
+
+// Redirects requests without the token to the login page. The token is
+// accepted from the cookie set by logging in, as a browser can't send the
+// bearer token itself.
+func (s *Server) authorizePage(next http.HandlerFunc) http.HandlerFunc {
+	if s.conf.Serve.Token == "" {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.isAuthorized(r) {
+			http.Redirect(w, r, "/ui/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) jobsPage(w http.ResponseWriter, r *http.Request) {
+	jobRows, err := s.queries.GetJobs(r.Context())
+	if err != nil {
+		s.pageError(w, r, err)
+		return
+	}
+	page := jobsPage{}
+	for _, jobRow := range jobRows {
+		job := dashboardJob{
+			Name:     jobRow.Job.Name,
+			Schedule: jobRow.Job.Schedule.String,
+		}
+		lastRunRow, err := s.queries.GetLastRun(r.Context(), jobRow.Job.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			s.pageError(w, r, err)
+			return
+		}
+		if err == nil {
+			lastRun := lastRunRow.Run
+			job.LastRunID = lastRun.ID
+			job.Status = lastRun.Status
+			job.LastRun = core.FormatTime(lastRun.StartTime, s.conf.LocalTime)
+			job.Duration = core.FormatDuration(lastRun.StartTime, lastRun.EndTime.Time)
+		}
+		page.Jobs = append(page.Jobs, job)
+	}
+	s.render(w, http.StatusOK, "jobs", page)
+}
+
+// Shows the runs of a job, filtered with the same query parameters as /runs.
+func (s *Server) jobPage(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	jobRow, err := s.queries.GetJob(r.Context(), name)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.render(w, http.StatusNotFound, "error", errorPage{http.StatusNotFound, "Job with name '" + name + "' not found"})
+		return
+	}
+	if err != nil {
+		s.pageError(w, r, err)
+		return
+	}
+	notifyTargets, err := s.queries.GetJobNotifyTargets(r.Context(), jobRow.Job.ID)
+	if err != nil {
+		s.pageError(w, r, err)
+		return
+	}
+	lockGroups, err := s.queries.GetJobLockGroups(r.Context(), jobRow.Job.ID)
+	if err != nil {
+		s.pageError(w, r, err)
+		return
+	}
+	query := r.URL.Query()
+	page := jobPage{
+		Job: core.NewJobShow(jobRow.Job, notifyTargets, lockGroups),
+		Filters: runFilters{
+			Status: query.Get("status"),
+			Host:   query.Get("host"),
+			Since:  query.Get("since"),
+			Until:  query.Get("until"),
+			Limit:  query.Get("limit"),
+		},
+		Statuses: core.RunStatuses,
+	}
+	params, err := parseRunsParams(r, time.Now())
+	if err != nil {
+		s.render(w, http.StatusBadRequest, "error", errorPage{http.StatusBadRequest, err.Error()})
+		return
+	}
+	params.JobName = name
+	runRows, err := core.GetRuns(r.Context(), s.queries, params)
+	if err != nil {
+		s.pageError(w, r, err)
+		return
+	}
+	for _, runRow := range runRows {
+		page.Runs = append(page.Runs, core.NewRunShow(runRow.Run, runRow.Job.Name, s.conf.LocalTime))
+	}
+	if params.Offset > 0 && params.Limit > 0 {
+		page.NewerURL = pageURL(r, max(params.Offset-params.Limit, 0))
+	}
+	if params.Limit > 0 && int64(len(runRows)) == params.Limit {
+		page.OlderURL = pageURL(r, params.Offset+params.Limit)
+	}
+	s.render(w, http.StatusOK, "job", page)
+}
+
+// Returns the URL of the request with another offset.
+func pageURL(r *http.Request, offset int64) string {
+	query := r.URL.Query()
+	query.Set("offset", strconv.FormatInt(offset, 10))
+	return r.URL.Path + "?" + query.Encode()
+}
+
+func (s *Server) runPage(w http.ResponseWriter, r *http.Request) {
+	runRow, ok := s.getRunPage(w, r)
+	if !ok {
+		return
+	}
+	show := core.NewRunShow(runRow.Run, runRow.Job.Name, s.conf.LocalTime)
+	attempts, err := s.queries.GetRunAttempts(r.Context(), sql.NullInt64{Int64: runRow.Run.ID, Valid: true})
+	if err != nil {
+		s.pageError(w, r, err)
+		return
+	}
+	for _, attempt := range attempts {
+		show.Attempts = append(show.Attempts, core.NewRunShow(attempt.Run, runRow.Job.Name, s.conf.LocalTime))
+	}
+	running := runRow.Run.Status == string(core.RunStatusRunning)
+	s.render(w, http.StatusOK, "run", runPage{
+		Run:     show,
+		Running: running,
+		CanKill: running && s.conf.Serve.AllowKill,
+	})
+}
+
+// Sends SIGTERM to a run, as troc run kill does. Only allowed if
+// serve.allowkill is set, and only for runs executed on this host.
+func (s *Server) killRun(w http.ResponseWriter, r *http.Request) {
+	if !s.conf.Serve.AllowKill {
+		s.render(w, http.StatusForbidden, "error", errorPage{http.StatusForbidden, "Killing runs is not allowed. Set serve.allowkill to allow it"})
+		return
+	}
+	runRow, ok := s.getRunPage(w, r)
+	if !ok {
+		return
+	}
+	run := runRow.Run
+	message := ""
+	switch {
+	case run.Status != string(core.RunStatusRunning):
+		message = "Run must be in a running state to kill it"
+	case !run.Pid.Valid:
+		message = "Run does not have a PID associated with it"
+	case run.Host != "" && run.Host != s.conf.Notify.Hostname:
+		message = "Run was executed on " + run.Host + ", not " + s.conf.Notify.Hostname
+	}
+	if message != "" {
+		s.render(w, http.StatusConflict, "error", errorPage{http.StatusConflict, message})
+		return
+	}
+	if err := core.SignalRun(run, syscall.SIGTERM); err != nil {
+		s.pageError(w, r, err)
+		return
+	}
+	core.LogRunSentSigterm(s.logger, run.ID, runRow.Job.Name, int(run.Pid.Int64))
+	http.Redirect(w, r, "/ui/runs/"+strconv.FormatInt(run.ID, 10), http.StatusSeeOther)
+}
+
+func (s *Server) loginPage(w http.ResponseWriter, r *http.Request) {
+	s.render(w, http.StatusOK, "login", loginPage{Next: localPath(r.URL.Query().Get("next"))})
+}
+
+// Checks the token and stores it in a cookie for the pages and log stream of
+// the dashboard.
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	next := localPath(r.FormValue("next"))
+	token := r.FormValue("token")
+	if !s.isToken(token) {
+		s.render(w, http.StatusUnauthorized, "login", loginPage{Next: next, Error: "Invalid token"})
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     tokenCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// Returns the path if it is on this server, so logging in can't redirect
+// elsewhere.
+func localPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/ui/"
+	}
+	return path
+}
+
+// Returns the run with the id in the path. If there is no such run, an error
+// page is written and false is returned.
+func (s *Server) getRunPage(w http.ResponseWriter, r *http.Request) (data.GetRunRow, bool) {
+	idValue := r.PathValue("id")
+	id, err := strconv.ParseInt(idValue, 10, 64)
+	if err != nil {
+		s.render(w, http.StatusBadRequest, "error", errorPage{http.StatusBadRequest, "Invalid run id " + idValue})
+		return data.GetRunRow{}, false
+	}
+	runRow, err := s.queries.GetRun(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.render(w, http.StatusNotFound, "error", errorPage{http.StatusNotFound, "Run with id " + idValue + " not found"})
+		return data.GetRunRow{}, false
+	}
+	if err != nil {
+		s.pageError(w, r, err)
+		return data.GetRunRow{}, false
+	}
+	return runRow, true
+}
+
+func (s *Server) pageError(w http.ResponseWriter, r *http.Request, err error) {
+	s.logger.Error("Unable to handle request", "path", r.URL.Path, "error", err)
+	s.render(w, http.StatusInternalServerError, "error", errorPage{http.StatusInternalServerError, "Internal server error"})
+}
+
+func (s *Server) render(w http.ResponseWriter, status int, page string, value any) {
+	var b bytes.Buffer
+	if err := s.pages[page].Execute(&b, value); err != nil {
+		s.logger.Error("Unable to render page "+page, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := b.WriteTo(w); err != nil {
+		s.logger.Error("Unable to write page "+page, "error", err)
+	}
+}
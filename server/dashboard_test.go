@@ -0,0 +1,267 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func post(t *testing.T, url string, form url.Values, headers map[string]string) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func Test_jobsPage(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	backupId := createJob(ctx, t, db, "backup")
+	createJob(ctx, t, db, "report")
+	createRun(ctx, t, db, backupId, core.RunStatusFailed, "")
+	srv := newTestServer(t, conn, config.Config{Display: config.DisplayConfig{Emoji: true}})
+
+	resp, body := get(t, srv.URL+"/", nil)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/ui/", resp.Request.URL.Path)
+	assert.Contains(t, body, `<a href="/ui/jobs/backup">backup</a>`)
+	assert.Contains(t, body, `<a href="/ui/runs/1" class="badge status-failed">❌ Failed</a>`)
+	assert.Contains(t, body, `<a href="/ui/jobs/report">report</a>`)
+	assert.Contains(t, body, "Never run")
+}
+
+func Test_jobPage(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	backupId := createJob(ctx, t, db, "backup")
+	reportId := createJob(ctx, t, db, "report")
+	createRun(ctx, t, db, backupId, core.RunStatusSucceeded, "")
+	createRun(ctx, t, db, backupId, core.RunStatusFailed, "")
+	createRun(ctx, t, db, reportId, core.RunStatusFailed, "")
+	createRun(ctx, t, db, backupId, core.RunStatusSucceeded, "")
+	srv := newTestServer(t, conn, config.Config{})
+
+	data := []struct {
+		name     string
+		query    string
+		runs     []string
+		notRuns  []string
+		contains []string
+	}{
+		{"all", "", []string{"1", "2", "4"}, []string{"3"}, []string{}},
+		{"blank-filters", "?status=&host=&since=&until=&limit=", []string{"1", "2", "4"}, []string{"3"}, []string{}},
+		{"status", "?status=Succeeded", []string{"1", "4"}, []string{"2", "3"}, []string{`<option value="Succeeded" selected>`}},
+		{"newer", "?limit=1&offset=1", []string{"2"}, []string{"1", "4"}, []string{
+			`<a href="/ui/jobs/backup?limit=1&amp;offset=0">Newer</a>`,
+			`<a href="/ui/jobs/backup?limit=1&amp;offset=2">Older</a>`,
+		}},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			resp, body := get(t, srv.URL+"/ui/jobs/backup"+d.query, nil)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			for _, id := range d.runs {
+				assert.Contains(t, body, `<a href="/ui/runs/`+id+`">`)
+			}
+			for _, id := range d.notRuns {
+				assert.NotContains(t, body, `<a href="/ui/runs/`+id+`">`)
+			}
+			for _, s := range d.contains {
+				assert.Contains(t, body, s)
+			}
+		})
+	}
+
+	resp, _ := get(t, srv.URL+"/ui/jobs/backup?status=unknown", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, body := get(t, srv.URL+"/ui/jobs/missing", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, body, "Job with name &#39;missing&#39; not found")
+}
+
+func Test_runPage(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobId := createJob(ctx, t, db, "backup")
+	createRun(ctx, t, db, jobId, core.RunStatusSucceeded, "")
+	createRun(ctx, t, db, jobId, core.RunStatusRunning, "")
+
+	data := []struct {
+		name      string
+		allowKill bool
+		runId     string
+		canKill   bool
+	}{
+		{"finished", true, "1", false},
+		{"running", true, "2", true},
+		{"kill-not-allowed", false, "2", false},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			srv := newTestServer(t, conn, config.Config{
+				Serve: config.ServeConfig{AllowKill: d.allowKill},
+			})
+			resp, body := get(t, srv.URL+"/ui/runs/"+d.runId, nil)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Contains(t, body, `<h1>Run `+d.runId+` of <a href="/ui/jobs/backup">backup</a></h1>`)
+			assert.Contains(t, body, `data-run-id="`+d.runId+`"`)
+			if d.canKill {
+				assert.Contains(t, body, `action="/ui/runs/`+d.runId+`/kill"`)
+			} else {
+				assert.NotContains(t, body, "/kill")
+			}
+		})
+	}
+
+	srv := newTestServer(t, conn, config.Config{})
+	resp, _ := get(t, srv.URL+"/ui/runs/3", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func Test_killRun(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobId := createJob(ctx, t, db, "backup")
+	createRun(ctx, t, db, jobId, core.RunStatusSucceeded, "")
+	remoteId := createRun(ctx, t, db, jobId, core.RunStatusRunning, "")
+	runId, err := db.StartRun(ctx, data.StartRunParams{
+		JobID:   jobId,
+		LogFile: "job.log",
+		Host:    "host-2",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	process := exec.Command("sleep", "10")
+	if err := process.Start(); err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() {
+		_ = process.Process.Kill()
+	})
+	for _, id := range []int64{remoteId, runId} {
+		err = db.UpdateRunPid(ctx, data.UpdateRunPidParams{
+			ID:  id,
+			Pid: sql.NullInt64{Int64: int64(process.Process.Pid), Valid: true},
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	conf := config.Config{
+		Notify: config.NotifyConfig{Hostname: "host-2"},
+		Serve:  config.ServeConfig{AllowKill: true},
+	}
+	srv := newTestServer(t, conn, conf)
+	conf.Serve.AllowKill = false
+	notAllowedSrv := newTestServer(t, conn, conf)
+
+	resp := post(t, notAllowedSrv.URL+"/ui/runs/3/kill", nil, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = post(t, srv.URL+"/ui/runs/1/kill", nil, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode, "finished run can't be killed")
+	resp = post(t, srv.URL+"/ui/runs/2/kill", nil, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode, "run on host-1 can't be killed from host-2")
+	resp = post(t, srv.URL+"/ui/runs/3/kill", nil, map[string]string{"Sec-Fetch-Site": "cross-site"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = post(t, srv.URL+"/ui/runs/3/kill", nil, nil)
+
+	assert.Equal(t, http.StatusSeeOther, resp.StatusCode)
+	assert.Equal(t, "/ui/runs/3", resp.Header.Get("Location"))
+	err = process.Wait()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected process to be signalled, got %v", err)
+	}
+	assert.Equal(t, syscall.SIGTERM, exitErr.Sys().(syscall.WaitStatus).Signal())
+}
+
+func Test_login(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	srv := newTestServer(t, conn, config.Config{
+		Serve: config.ServeConfig{Token: "secret"},
+	})
+
+	resp, body := get(t, srv.URL+"/ui/jobs/backup?status=Failed", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/ui/login", resp.Request.URL.Path)
+	assert.Contains(t, body, `<input type="hidden" name="next" value="/ui/jobs/backup?status=Failed">`)
+
+	resp = post(t, srv.URL+"/ui/login", url.Values{"token": {"wrong"}, "next": {"/ui/"}}, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Empty(t, resp.Cookies())
+
+	resp = post(t, srv.URL+"/ui/login", url.Values{"token": {"secret"}, "next": {"/ui/runs/1"}}, nil)
+	assert.Equal(t, http.StatusSeeOther, resp.StatusCode)
+	assert.Equal(t, "/ui/runs/1", resp.Header.Get("Location"))
+	cookies := resp.Cookies()
+	assert.Equal(t, 1, len(cookies))
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+
+	// The cookie is accepted by the dashboard and the API it streams logs from
+	cookie := cookies[0].Name + "=" + cookies[0].Value
+	resp, _ = get(t, srv.URL+"/ui/", map[string]string{"Cookie": cookie})
+	assert.Equal(t, "/ui/", resp.Request.URL.Path)
+	resp, _ = get(t, srv.URL+"/runs", map[string]string{"Cookie": cookie})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = get(t, srv.URL+"/runs", map[string]string{"Cookie": cookies[0].Name + "=wrong"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = get(t, srv.URL+"/ui/static/dashboard.css", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func Test_localPath(t *testing.T) {
+	data := []struct {
+		path     string
+		expected string
+	}{
+		{"/ui/runs/1", "/ui/runs/1"},
+		{"", "/ui/"},
+		{"https://example.com", "/ui/"},
+		{"//example.com", "/ui/"},
+		{"/\\example.com", "/ui/"},
+	}
+
+	for _, d := range data {
+		t.Run(d.path, func(t *testing.T) {
+			assert.Equal(t, d.expected, localPath(d.path))
+		})
+	}
+}
@@ -0,0 +1,520 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samcarswell/trochilus/archive"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/metrics"
+	"github.com/samcarswell/trochilus/opts"
+	"github.com/samcarswell/trochilus/output"
+	"github.com/samcarswell/trochilus/stats"
+)
+
+const defaultRunLimit = 50
+
+// How often a followed log is checked for new output.
+var followInterval = 500 * time.Millisecond
+
+// A read-only HTTP API and dashboard over the jobs and runs in the database.
+// It only reads, so it can be served alongside troc exec writing to the same
+// database. The one exception is the kill button of the dashboard, which
+// signals a run rather than writing to the database.
+type Server struct {
+	logger  *slog.Logger
+	conn    *sql.DB
+	queries *data.Queries
+	conf    config.Config
+	pages   map[string]*template.Template
+}
+
+func New(logger *slog.Logger, conn *sql.DB, conf config.Config) *Server {
+	return &Server{
+		logger:  logger,
+		conn:    conn,
+		queries: data.New(conn),
+		conf:    conf,
+		pages:   parsePages(conf),
+	}
+}
+
+// Returns the handler of the API and dashboard. Every endpoint but /healthz
+// requires the token if serve.token is set. Cross-origin requests that aren't
+// GET are rejected, so another site can't kill runs.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	s.dashboardRoutes(mux)
+	mux.HandleFunc("GET /healthz", s.healthz)
+	mux.HandleFunc("GET /metrics", s.authorize(s.metrics))
+	mux.HandleFunc("GET /jobs", s.authorize(s.jobs))
+	mux.HandleFunc("GET /jobs/{name}", s.authorize(s.job))
+	mux.HandleFunc("GET /runs", s.authorize(s.runs))
+	mux.HandleFunc("GET /runs/{id}", s.authorize(s.run))
+	mux.HandleFunc("GET /runs/{id}/log", s.authorize(s.runLog))
+	return http.NewCrossOriginProtection().Handler(mux)
+}
+
+func (s *Server) authorize(next http.HandlerFunc) http.HandlerFunc {
+	if s.conf.Serve.Token == "" {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.isAuthorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="troc"`)
+			writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Returns whether the request has the token, either as a bearer token or in
+// the cookie set by logging in to the dashboard.
+func (s *Server) isAuthorized(r *http.Request) bool {
+	if s.conf.Serve.Token == "" {
+		return true
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return s.isToken(token)
+	}
+	cookie, err := r.Cookie(tokenCookie)
+	return err == nil && s.isToken(cookie.Value)
+}
+
+func (s *Server) isToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.conf.Serve.Token)) == 1
+}
+
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	if err := s.conn.PingContext(r.Context()); err != nil {
+		s.logger.Error("Health check failed", "error", err)
+		writeError(w, http.StatusServiceUnavailable, "database unavailable")
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) metrics(w http.ResponseWriter, r *http.Request) {
+	jobs, err := metrics.Collect(r.Context(), s.queries)
+	if err != nil {
+		s.internalError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := metrics.Write(w, jobs); err != nil {
+		s.logger.Error("Unable to write metrics", "error", err)
+	}
+}
+
+func (s *Server) jobs(w http.ResponseWriter, r *http.Request) {
+	jobRows, err := s.queries.GetJobs(r.Context())
+	if err != nil {
+		s.internalError(w, err)
+		return
+	}
+	jobs := []core.JobShow{}
+	for _, job := range jobRows {
+		notifyTargets, err := s.queries.GetJobNotifyTargets(r.Context(), job.Job.ID)
+		if err != nil {
+			s.internalError(w, err)
+			return
+		}
+		lockGroups, err := s.queries.GetJobLockGroups(r.Context(), job.Job.ID)
+		if err != nil {
+			s.internalError(w, err)
+			return
+		}
+		jobs = append(jobs, core.NewJobShow(job.Job, notifyTargets, lockGroups))
+	}
+	writeJson(w, http.StatusOK, jobs)
+}
+
+func (s *Server) job(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	job, err := s.queries.GetJob(r.Context(), name)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "job with name '"+name+"' not found")
+		return
+	}
+	if err != nil {
+		s.internalError(w, err)
+		return
+	}
+	notifyTargets, err := s.queries.GetJobNotifyTargets(r.Context(), job.Job.ID)
+	if err != nil {
+		s.internalError(w, err)
+		return
+	}
+	lockGroups, err := s.queries.GetJobLockGroups(r.Context(), job.Job.ID)
+	if err != nil {
+		s.internalError(w, err)
+		return
+	}
+	jobStats, err := stats.ForJob(r.Context(), s.queries, job.Job.ID, time.Now())
+	if err != nil {
+		s.internalError(w, err)
+		return
+	}
+	show := core.NewJobShow(job.Job, notifyTargets, lockGroups)
+	statsShow := jobStats.Show(s.conf.LocalTime)
+	show.Stats = &statsShow
+	writeJson(w, http.StatusOK, show)
+}
+
+// Lists runs with the same filters as troc run list, given as the query
+// parameters job, status, host, since, until, sort, limit and offset. Blank
+// parameters are ignored, as a form submits its empty fields.
+func (s *Server) runs(w http.ResponseWriter, r *http.Request) {
+	params, err := parseRunsParams(r, time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	runRows, err := core.GetRuns(r.Context(), s.queries, params)
+	if err != nil {
+		s.internalError(w, err)
+		return
+	}
+	runs := []core.RunShow{}
+	for _, runRow := range runRows {
+		runs = append(runs, core.NewRunShow(runRow.Run, runRow.Job.Name, s.conf.LocalTime))
+	}
+	writeJson(w, http.StatusOK, runs)
+}
+
+func parseRunsParams(r *http.Request, now time.Time) (core.RunFilter, error) {
+	query := r.URL.Query()
+	status, err := opts.ParseRunStatus(query.Get("status"))
+	if err != nil {
+		return core.RunFilter{}, err
+	}
+	sort := "start:desc"
+	if query.Get("sort") != "" {
+		sort, err = opts.ParseRunSort(query.Get("sort"))
+		if err != nil {
+			return core.RunFilter{}, err
+		}
+	}
+	params := core.RunFilter{
+		JobName: query.Get("job"),
+		Status:  status,
+		Host:    query.Get("host"),
+		Sort:    sort,
+		Limit:   defaultRunLimit,
+	}
+	for _, t := range []struct {
+		name  string
+		value *sql.NullTime
+	}{
+		{"since", &params.Since},
+		{"until", &params.Until},
+	} {
+		if query.Get(t.name) == "" {
+			continue
+		}
+		parsed, err := opts.ParseTime(query.Get(t.name), now)
+		if err != nil {
+			return core.RunFilter{}, fmt.Errorf("invalid %s: %w", t.name, err)
+		}
+		*t.value = sql.NullTime{Time: parsed.UTC(), Valid: true}
+	}
+	for _, c := range []struct {
+		name  string
+		value *int64
+	}{
+		{"limit", &params.Limit},
+		{"offset", &params.Offset},
+	} {
+		if query.Get(c.name) == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(query.Get(c.name), 10, 64)
+		if err != nil || parsed < 0 {
+			return core.RunFilter{}, errors.New(c.name + " must be a number that is not negative")
+		}
+		*c.value = parsed
+	}
+	if params.Limit == 0 {
+		// A negative limit is no limit in sqlite
+		params.Limit = -1
+	}
+	return params, nil
+}
+
+func (s *Server) run(w http.ResponseWriter, r *http.Request) {
+	runRow, ok := s.getRun(w, r)
+	if !ok {
+		return
+	}
+	show := core.NewRunShow(runRow.Run, runRow.Job.Name, s.conf.LocalTime)
+	attempts, err := s.queries.GetRunAttempts(r.Context(), sql.NullInt64{Int64: runRow.Run.ID, Valid: true})
+	if err != nil {
+		s.internalError(w, err)
+		return
+	}
+	for _, attempt := range attempts {
+		show.Attempts = append(show.Attempts, core.NewRunShow(attempt.Run, runRow.Job.Name, s.conf.LocalTime))
+	}
+	writeJson(w, http.StatusOK, show)
+}
+
+// Serves the log of a run. Range requests are supported. With follow=true, or
+// an Accept header of text/event-stream, the log is streamed as server-sent
+// events until the run has finished.
+func (s *Server) runLog(w http.ResponseWriter, r *http.Request) {
+	runRow, ok := s.getRun(w, r)
+	if !ok {
+		return
+	}
+	if r.URL.Query().Get("follow") == "true" || strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		s.followLog(w, r, runRow.Run)
+		return
+	}
+	run := runRow.Run
+	if !archive.IsArchived(run.LogFile) {
+		file, err := os.Open(run.LogFile)
+		if err == nil {
+			defer file.Close()
+			info, err := file.Stat()
+			if err != nil {
+				s.internalError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			http.ServeContent(w, r, "", info.ModTime(), file)
+			return
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			s.internalError(w, err)
+			return
+		}
+	}
+	content, modTime, err := s.readFinishedLog(r.Context(), run.ID)
+	if errors.Is(err, os.ErrNotExist) {
+		writeError(w, http.StatusNotFound, "log of run "+strconv.FormatInt(run.ID, 10)+" no longer exists")
+		return
+	}
+	if err != nil {
+		s.internalError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeContent(w, r, "", modTime, content)
+}
+
+// Returns the log of a finished run whose log file may have been archived or
+// deleted. The run is read again, as its log may have been archived since it
+// was last read. If the log no longer exists, the output stored in the
+// database is returned.
+func (s *Server) readFinishedLog(ctx context.Context, runId int64) (io.ReadSeeker, time.Time, error) {
+	runRow, err := s.queries.GetRun(ctx, runId)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	run := runRow.Run
+	info, err := os.Stat(run.LogFile)
+	if err == nil {
+		content, err := archive.ReadFile(run.LogFile)
+		return bytes.NewReader(content), info.ModTime(), err
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, time.Time{}, err
+	}
+	stored, ok, err := output.Read(ctx, s.queries, run.ID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !ok {
+		return nil, time.Time{}, os.ErrNotExist
+	}
+	return strings.NewReader(stored), run.EndTime.Time, nil
+}
+
+// Streams the log of a run as server-sent events, one per line. The id of each
+// event is the offset of the log after the line, so a client that reconnects
+// with Last-Event-ID resumes where it left off. The offset may also be given
+// with the offset query parameter. Once the run has finished and the whole log
+// has been sent, an end event with the status of the run is sent.
+func (s *Server) followLog(w http.ResponseWriter, r *http.Request, run data.Run) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	offsetValue := r.Header.Get("Last-Event-ID")
+	if offsetValue == "" {
+		offsetValue = r.URL.Query().Get("offset")
+	}
+	var offset int64
+	if offsetValue != "" {
+		parsed, err := strconv.ParseInt(offsetValue, 10, 64)
+		if err != nil || parsed < 0 {
+			writeError(w, http.StatusBadRequest, "offset must be a number that is not negative")
+			return
+		}
+		offset = parsed
+	}
+
+	var reader *bufio.Reader
+	var file *os.File
+	if !archive.IsArchived(run.LogFile) {
+		var err error
+		file, err = os.Open(run.LogFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.internalError(w, err)
+			return
+		}
+	}
+	if file != nil {
+		defer file.Close()
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			s.internalError(w, err)
+			return
+		}
+		reader = bufio.NewReader(file)
+	} else {
+		// Archived and stored logs are only written once the run has finished
+		content, _, err := s.readFinishedLog(r.Context(), run.ID)
+		if errors.Is(err, os.ErrNotExist) {
+			writeError(w, http.StatusNotFound, "log of run "+strconv.FormatInt(run.ID, 10)+" no longer exists")
+			return
+		}
+		if err != nil {
+			s.internalError(w, err)
+			return
+		}
+		if _, err := content.Seek(offset, io.SeekStart); err != nil {
+			s.internalError(w, err)
+			return
+		}
+		reader = bufio.NewReader(content)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var partial string
+	finished := false
+	for {
+		line, err := reader.ReadString('\n')
+		partial += line
+		if err == nil {
+			offset += int64(len(partial))
+			writeLogEvent(w, offset, partial)
+			partial = ""
+			continue
+		}
+		if err != io.EOF {
+			s.logger.Error("Unable to read log "+run.LogFile, "error", err)
+			return
+		}
+		flusher.Flush()
+		if file == nil || finished {
+			// The last line of a finished log may not end with a newline
+			if partial != "" {
+				offset += int64(len(partial))
+				writeLogEvent(w, offset, partial)
+			}
+			status, err := s.getRunStatus(r.Context(), run.ID)
+			if err != nil {
+				s.logger.Error("Unable to get run status", "error", err)
+				return
+			}
+			fmt.Fprintf(w, "event: end\ndata: %s\n\n", status)
+			flusher.Flush()
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(followInterval):
+		}
+		isFinished, err := s.queries.IsRunFinished(r.Context(), run.ID)
+		if err != nil {
+			s.logger.Error("Unable to check if run has finished", "error", err)
+			return
+		}
+		// The log is read once more after the run has finished, for any output
+		// written since the last read
+		finished = isFinished
+	}
+}
+
+func writeLogEvent(w io.Writer, offset int64, line string) {
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	fmt.Fprintf(w, "id: %d\n", offset)
+	// Carriage returns end a field in an event stream, so each part of a line
+	// written over itself is sent as a line of the event's data
+	for _, part := range strings.Split(line, "\r") {
+		fmt.Fprintf(w, "data: %s\n", part)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+func (s *Server) getRunStatus(ctx context.Context, runId int64) (string, error) {
+	runRow, err := s.queries.GetRun(ctx, runId)
+	if err != nil {
+		return "", err
+	}
+	return runRow.Run.Status, nil
+}
+
+// Returns the run with the id in the path. If there is no such run, an error
+// response is written and false is returned.
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request) (data.GetRunRow, bool) {
+	idValue := r.PathValue("id")
+	id, err := strconv.ParseInt(idValue, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid run id "+idValue)
+		return data.GetRunRow{}, false
+	}
+	runRow, err := s.queries.GetRun(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "run with id "+idValue+" not found")
+		return data.GetRunRow{}, false
+	}
+	if err != nil {
+		s.internalError(w, err)
+		return data.GetRunRow{}, false
+	}
+	return runRow, true
+}
+
+func (s *Server) internalError(w http.ResponseWriter, err error) {
+	s.logger.Error("Unable to handle request", "error", err)
+	writeError(w, http.StatusInternalServerError, "internal server error")
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJson(w, status, map[string]string{"error": message})
+}
+
+func writeJson(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(value); err != nil {
+		slog.Default().Error("Unable to write response", "error", err)
+	}
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/output"
+	"github.com/samcarswell/trochilus/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func createJob(ctx context.Context, t *testing.T, db *data.Queries, name string) int64 {
+	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  name,
+		RetryAttempts:         1,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return jobId
+}
+
+// Creates a run with a log. A status other than Running ends the run.
+func createRun(ctx context.Context, t *testing.T, db *data.Queries, jobId int64, status core.RunStatus, log string) int64 {
+	logFile := path.Join(t.TempDir(), "job.log")
+	if err := os.WriteFile(logFile, []byte(log), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	runId, err := db.StartRun(ctx, data.StartRunParams{
+		JobID:   jobId,
+		LogFile: logFile,
+		Host:    "host-1",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if status != core.RunStatusRunning {
+		endRun(ctx, t, db, runId, status)
+	}
+	return runId
+}
+
+func endRun(ctx context.Context, t *testing.T, db *data.Queries, runId int64, status core.RunStatus) {
+	err := db.EndRun(ctx, data.EndRunParams{
+		ID:     runId,
+		Status: string(status),
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func newTestServer(t *testing.T, conn *sql.DB, conf config.Config) *httptest.Server {
+	srv := httptest.NewServer(New(slog.Default(), conn, conf).Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, url string, headers map[string]string) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return resp, string(body)
+}
+
+func Test_authorize(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	srv := newTestServer(t, conn, config.Config{
+		Serve: config.ServeConfig{Token: "secret"},
+	})
+
+	data := []struct {
+		name     string
+		path     string
+		token    string
+		expected int
+	}{
+		{"no-token", "/jobs", "", http.StatusUnauthorized},
+		{"wrong-token", "/jobs", "Bearer wrong", http.StatusUnauthorized},
+		{"not-bearer", "/jobs", "secret", http.StatusUnauthorized},
+		{"token", "/jobs", "Bearer secret", http.StatusOK},
+		{"metrics", "/metrics", "", http.StatusUnauthorized},
+		{"healthz", "/healthz", "", http.StatusOK},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			resp, _ := get(t, srv.URL+d.path, map[string]string{"Authorization": d.token})
+			assert.Equal(t, d.expected, resp.StatusCode)
+		})
+	}
+}
+
+func Test_jobs(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobId := createJob(ctx, t, db, "backup")
+	createRun(ctx, t, db, jobId, core.RunStatusSucceeded, "")
+	srv := newTestServer(t, conn, config.Config{})
+
+	resp, body := get(t, srv.URL+"/jobs", nil)
+	var jobs []core.JobShow
+	if err := json.Unmarshal([]byte(body), &jobs); err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, len(jobs))
+	assert.Equal(t, "backup", jobs[0].Name)
+	assert.Nil(t, jobs[0].Stats)
+
+	resp, body = get(t, srv.URL+"/jobs/backup", nil)
+	var job core.JobShow
+	if err := json.Unmarshal([]byte(body), &job); err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(1), job.Stats.TotalRuns)
+	assert.Equal(t, "100.0%", job.Stats.SuccessRate24h)
+
+	resp, body = get(t, srv.URL+"/jobs/missing", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.JSONEq(t, `{"error": "job with name 'missing' not found"}`, body)
+}
+
+func Test_runs(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	backupId := createJob(ctx, t, db, "backup")
+	reportId := createJob(ctx, t, db, "report")
+	createRun(ctx, t, db, backupId, core.RunStatusSucceeded, "")
+	createRun(ctx, t, db, reportId, core.RunStatusFailed, "")
+	createRun(ctx, t, db, backupId, core.RunStatusRunning, "")
+	srv := newTestServer(t, conn, config.Config{})
+
+	data := []struct {
+		name     string
+		query    string
+		expected []int64
+	}{
+		{"newest-first", "", []int64{3, 2, 1}},
+		{"job", "?job=backup", []int64{3, 1}},
+		{"status", "?status=failed", []int64{2}},
+		{"host", "?host=host-2", []int64{}},
+		{"since", "?since=1h", []int64{3, 2, 1}},
+		{"sort", "?sort=id:asc&limit=2", []int64{1, 2}},
+		{"offset", "?limit=1&offset=2", []int64{1}},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			resp, body := get(t, srv.URL+"/runs"+d.query, nil)
+			var runs []core.RunShow
+			if err := json.Unmarshal([]byte(body), &runs); err != nil {
+				t.Fatal(err.Error())
+			}
+			ids := []int64{}
+			for _, run := range runs {
+				ids = append(ids, run.ID)
+			}
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, d.expected, ids)
+		})
+	}
+
+	for _, query := range []string{"?status=unknown", "?since=yesterday", "?sort=name", "?limit=-1"} {
+		t.Run("bad-request"+query, func(t *testing.T) {
+			resp, _ := get(t, srv.URL+"/runs"+query, nil)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+}
+
+func Test_run(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobId := createJob(ctx, t, db, "backup")
+	runId := createRun(ctx, t, db, jobId, core.RunStatusSucceeded, "")
+	srv := newTestServer(t, conn, config.Config{})
+
+	resp, body := get(t, srv.URL+"/runs/1", nil)
+	var run core.RunShow
+	if err := json.Unmarshal([]byte(body), &run); err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, runId, run.ID)
+	assert.Equal(t, "backup", run.JobName)
+	assert.Equal(t, "host-1", run.Host)
+
+	resp, _ = get(t, srv.URL+"/runs/2", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = get(t, srv.URL+"/runs/latest", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func Test_runLog(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobId := createJob(ctx, t, db, "backup")
+	runId := createRun(ctx, t, db, jobId, core.RunStatusSucceeded, "line 1\nline 2\n")
+	srv := newTestServer(t, conn, config.Config{})
+
+	resp, body := get(t, srv.URL+"/runs/1/log", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "line 1\nline 2\n", body)
+
+	resp, body = get(t, srv.URL+"/runs/1/log", map[string]string{"Range": "bytes=7-"})
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "line 2\n", body)
+
+	// Once the log is deleted, the stored output is served
+	run, err := db.GetRun(ctx, runId)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := output.Store(ctx, conn, runId, run.Run.LogFile, 1000); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Remove(run.Run.LogFile); err != nil {
+		t.Fatal(err.Error())
+	}
+	resp, body = get(t, srv.URL+"/runs/1/log", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "line 1\nline 2\n", body)
+
+	if err := db.DeleteRunOutput(ctx, runId); err != nil {
+		t.Fatal(err.Error())
+	}
+	resp, _ = get(t, srv.URL+"/runs/1/log", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func Test_runLogFollow(t *testing.T) {
+	followInterval = 10 * time.Millisecond
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	jobId := createJob(ctx, t, db, "backup")
+	runId := createRun(ctx, t, db, jobId, core.RunStatusRunning, "line 1\npart")
+	run, err := db.GetRun(ctx, runId)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	srv := newTestServer(t, conn, config.Config{})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		f, err := os.OpenFile(run.Run.LogFile, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			panic(err)
+		}
+		if _, err := f.WriteString("ial\r\nline 3"); err != nil {
+			panic(err)
+		}
+		f.Close()
+		err = db.EndRun(ctx, data.EndRunParams{
+			ID:     runId,
+			Status: string(core.RunStatusFailed),
+		})
+		if err != nil {
+			panic(err)
+		}
+	}()
+	resp, body := get(t, srv.URL+"/runs/1/log?follow=true", nil)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "id: 7\ndata: line 1\n\n"+
+		"id: 16\ndata: partial\n\n"+
+		"id: 22\ndata: line 3\n\n"+
+		"event: end\ndata: Failed\n\n", body)
+
+	resp, body = get(t, srv.URL+"/runs/1/log", map[string]string{
+		"Accept":        "text/event-stream",
+		"Last-Event-ID": "16",
+	})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "id: 22\ndata: line 3\n\nevent: end\ndata: Failed\n\n", body)
+}
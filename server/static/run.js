@@ -0,0 +1,32 @@
+// Tails the log of a run with the server-sent events of /runs/{id}/log. The
+// stream ends once the run has finished, and a run that was running is
+// reloaded to show how it finished.
+(function () {
+    const log = document.getElementById("log");
+    if (!log) {
+        return;
+    }
+    const running = log.dataset.running === "true";
+    const events = new EventSource("/runs/" + log.dataset.runId + "/log?follow=true");
+
+    events.onmessage = function (event) {
+        const atBottom = log.scrollTop + log.clientHeight >= log.scrollHeight - 4;
+        log.append(event.data + "\n");
+        if (atBottom) {
+            log.scrollTop = log.scrollHeight;
+        }
+    };
+    events.addEventListener("end", function () {
+        events.close();
+        if (running) {
+            window.location.reload();
+        }
+    });
+    events.onerror = function () {
+        // A stream that was interrupted is resumed by the browser from the
+        // last event. A request that failed is not retried.
+        if (events.readyState === EventSource.CLOSED) {
+            log.append("The log is unavailable\n");
+        }
+    };
+})();
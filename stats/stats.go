@@ -0,0 +1,157 @@
+package stats
+
+import (
+	"context"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+)
+
+const day = 24 * time.Hour
+
+// The outcome of the finished runs of a job within a window.
+type Rate struct {
+	Succeeded int64
+	Finished  int64
+}
+
+// The number of consecutive runs, newest first, with the same outcome. Status
+// is RunStatusSucceeded or RunStatusFailed, which includes runs that were
+// terminated or timed out.
+type Streak struct {
+	Status core.RunStatus
+	Length int64
+}
+
+// Statistics of the runs of a job. Attempts are not counted separately from
+// their run.
+type Stats struct {
+	TotalRuns    int64
+	FinishedRuns int64
+	StatusCounts map[core.RunStatus]int64
+	Rate24h      Rate
+	Rate7d       Rate
+	Rate30d      Rate
+	LastSuccess  time.Time
+	LastFailure  time.Time
+	Streak       Streak
+	DurationP50  time.Duration
+	DurationP95  time.Duration
+	DurationMax  time.Duration
+}
+
+// Returns the statistics of the runs of a job.
+func ForJob(ctx context.Context, db *data.Queries, jobId int64, now time.Time) (Stats, error) {
+	rows, err := db.GetJobRuns(ctx, jobId)
+	if err != nil {
+		return Stats{}, err
+	}
+	runs := []data.Run{}
+	for _, row := range rows {
+		runs = append(runs, row.Run)
+	}
+	return Compute(runs, now), nil
+}
+
+// Returns the statistics of runs, which must be ordered newest first. Success
+// rates, streaks and durations only count finished runs, ie. ones that are not
+// running, queued or skipped. The last success and failure are the times those
+// runs ended.
+func Compute(runs []data.Run, now time.Time) Stats {
+	stats := Stats{
+		TotalRuns:    int64(len(runs)),
+		StatusCounts: map[core.RunStatus]int64{},
+	}
+	for _, status := range core.RunStatuses {
+		stats.StatusCounts[status] = 0
+	}
+	durations := []time.Duration{}
+	streakEnded := false
+	for _, run := range runs {
+		status := core.RunStatus(run.Status)
+		stats.StatusCounts[status]++
+		if status == core.RunStatusRunning || status == core.RunStatusSkipped || status == core.RunStatusQueued {
+			continue
+		}
+		stats.FinishedRuns++
+		succeeded := status == core.RunStatusSucceeded
+		for _, w := range []struct {
+			rate   *Rate
+			window time.Duration
+		}{
+			{&stats.Rate24h, day},
+			{&stats.Rate7d, 7 * day},
+			{&stats.Rate30d, 30 * day},
+		} {
+			if run.StartTime.After(now.Add(-w.window)) {
+				w.rate.Finished++
+				if succeeded {
+					w.rate.Succeeded++
+				}
+			}
+		}
+
+		outcome := core.RunStatusFailed
+		if succeeded {
+			outcome = core.RunStatusSucceeded
+			if stats.LastSuccess.IsZero() {
+				stats.LastSuccess = run.EndTime.Time
+			}
+		} else if stats.LastFailure.IsZero() {
+			stats.LastFailure = run.EndTime.Time
+		}
+		if stats.Streak.Length == 0 {
+			stats.Streak.Status = outcome
+		}
+		if !streakEnded && stats.Streak.Status == outcome {
+			stats.Streak.Length++
+		} else {
+			streakEnded = true
+		}
+
+		if run.EndTime.Valid {
+			durations = append(durations, run.EndTime.Time.Sub(run.StartTime))
+		}
+	}
+	slices.Sort(durations)
+	stats.DurationP50 = percentile(durations, 0.5)
+	stats.DurationP95 = percentile(durations, 0.95)
+	stats.DurationMax = percentile(durations, 1)
+	return stats
+}
+
+// Returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// Returns the statistics in the form they are displayed.
+func (s Stats) Show(useLocalTime bool) core.JobStatsShow {
+	show := core.JobStatsShow{
+		TotalRuns:      s.TotalRuns,
+		StatusCounts:   map[string]int64{},
+		SuccessRate24h: core.FormatRate(s.Rate24h.Succeeded, s.Rate24h.Finished),
+		SuccessRate7d:  core.FormatRate(s.Rate7d.Succeeded, s.Rate7d.Finished),
+		SuccessRate30d: core.FormatRate(s.Rate30d.Succeeded, s.Rate30d.Finished),
+		LastSuccess:    core.FormatTime(s.LastSuccess, useLocalTime),
+		LastFailure:    core.FormatTime(s.LastFailure, useLocalTime),
+		StreakStatus:   string(s.Streak.Status),
+		StreakLength:   s.Streak.Length,
+	}
+	for status, count := range s.StatusCounts {
+		show.StatusCounts[string(status)] = count
+	}
+	if s.FinishedRuns > 0 {
+		show.DurationP50 = s.DurationP50.String()
+		show.DurationP95 = s.DurationP95.String()
+		show.DurationMax = s.DurationMax.String()
+	}
+	return show
+}
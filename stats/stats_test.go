@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRun(status core.RunStatus, start time.Time, duration time.Duration) data.Run {
+	run := data.Run{
+		Status:    string(status),
+		StartTime: start,
+	}
+	if status != core.RunStatusRunning {
+		run.EndTime = sql.NullTime{Time: start.Add(duration), Valid: true}
+	}
+	return run
+}
+
+func Test_Compute(t *testing.T) {
+	now := time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC)
+	runs := []data.Run{
+		newRun(core.RunStatusRunning, now.Add(-time.Minute), 0),
+		newRun(core.RunStatusFailed, now.Add(-time.Hour), 4*time.Second),
+		newRun(core.RunStatusSkipped, now.Add(-2*time.Hour), 0),
+		newRun(core.RunStatusTimedOut, now.Add(-3*time.Hour), 10*time.Second),
+		newRun(core.RunStatusSucceeded, now.Add(-2*day), 2*time.Second),
+		newRun(core.RunStatusSucceeded, now.Add(-3*day), time.Second),
+		newRun(core.RunStatusTerminated, now.Add(-10*day), 3*time.Second),
+		newRun(core.RunStatusSucceeded, now.Add(-40*day), 5*time.Second),
+	}
+
+	stats := Compute(runs, now)
+
+	assert.Equal(t, int64(8), stats.TotalRuns)
+	assert.Equal(t, int64(6), stats.FinishedRuns)
+	assert.Equal(t, map[core.RunStatus]int64{
+		core.RunStatusRunning:    1,
+		core.RunStatusSkipped:    1,
+		core.RunStatusSucceeded:  3,
+		core.RunStatusFailed:     1,
+		core.RunStatusTerminated: 1,
+		core.RunStatusTimedOut:   1,
+		core.RunStatusLost:       0,
+		core.RunStatusQueued:     0,
+	}, stats.StatusCounts)
+	assert.Equal(t, Rate{Succeeded: 0, Finished: 2}, stats.Rate24h)
+	assert.Equal(t, Rate{Succeeded: 2, Finished: 4}, stats.Rate7d)
+	assert.Equal(t, Rate{Succeeded: 2, Finished: 5}, stats.Rate30d)
+	assert.Equal(t, now.Add(-2*day).Add(2*time.Second), stats.LastSuccess)
+	assert.Equal(t, now.Add(-time.Hour).Add(4*time.Second), stats.LastFailure)
+	assert.Equal(t, Streak{Status: core.RunStatusFailed, Length: 2}, stats.Streak)
+	assert.Equal(t, 3*time.Second, stats.DurationP50)
+	assert.Equal(t, 10*time.Second, stats.DurationP95)
+	assert.Equal(t, 10*time.Second, stats.DurationMax)
+}
+
+func Test_ComputeNoRuns(t *testing.T) {
+	stats := Compute([]data.Run{}, time.Now())
+	show := stats.Show(false)
+
+	assert.Equal(t, int64(0), show.TotalRuns)
+	assert.Equal(t, int64(0), show.StatusCounts[string(core.RunStatusSucceeded)])
+	assert.Equal(t, "", show.SuccessRate24h)
+	assert.Equal(t, "", show.LastSuccess)
+	assert.Equal(t, "", show.StreakStatus)
+	assert.Equal(t, "", show.DurationMax)
+}
+
+func Test_percentile(t *testing.T) {
+	durations := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	data := []struct {
+		name     string
+		p        float64
+		expected time.Duration
+	}{
+		{"p50", 0.5, 5},
+		{"p95", 0.95, 10},
+		{"p90", 0.9, 9},
+		{"max", 1, 10},
+		{"min", 0, 1},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			assert.Equal(t, d.expected, percentile(durations, d.p))
+		})
+	}
+}
@@ -14,9 +14,14 @@ type TrocRun struct {
 	Exe string
 }
 
+type TrocJob struct {
+	Exe string
+}
+
 type TrocBase struct {
 	Exe string
 	Run TrocRun
+	Job TrocJob
 }
 
 type TrocCli struct {
@@ -39,22 +44,37 @@ func NewTrocCli(t *testing.T, exe string) *TrocCli {
 			Run: TrocRun{
 				Exe: exe,
 			},
+			Job: TrocJob{
+				Exe: exe,
+			},
 		},
 	}
 	SetupEnv(t)
 	return &tc
 }
 
-func (t TrocRun) List() TrocCmd {
-	return getCmd(t.Exe, []string{"run", "list", "-f", "json"})
+func (t TrocRun) List(args ...string) TrocCmd {
+	return getCmd(t.Exe, append([]string{"run", "list", "-f", "json"}, args...))
+}
+
+func (t TrocRun) Kill(runId int64, args ...string) TrocCmd {
+	return getCmd(t.Exe, append([]string{"run", "kill", "-r", strconv.FormatInt(runId, 10), "--force"}, args...))
+}
+
+func (t TrocRun) Reap(args ...string) TrocCmd {
+	return getCmd(t.Exe, append([]string{"run", "reap", "-f", "json"}, args...))
+}
+
+func (t TrocJob) Add(name string, args ...string) TrocCmd {
+	return getCmd(t.Exe, append([]string{"job", "add", "--name", name}, args...))
 }
 
-func (t TrocRun) Kill(runId int64) TrocCmd {
-	return getCmd(t.Exe, []string{"run", "kill", "-r", strconv.FormatInt(runId, 10), "--force"})
+func (t TrocJob) Run(name string, args ...string) TrocCmd {
+	return getCmd(t.Exe, append([]string{"job", "run", "--name", name}, args...))
 }
 
-func (t TrocBase) Exec(name string, script string) TrocCmd {
-	return getCmd(t.Exe, []string{"exec", "--name", name, script})
+func (t TrocBase) Exec(name string, script string, args ...string) TrocCmd {
+	return getCmd(t.Exe, append(append([]string{"exec", "--name", name}, args...), script))
 }
 
 func (t TrocBase) Version() TrocCmd {
@@ -4,15 +4,18 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"io"
 	"log/slog"
 	"math/rand"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -30,15 +33,16 @@ var (
 )
 
 type logRow struct {
-	Time      time.Time      `json:"time"`
-	Level     string         `json:"level"`
-	Msg       string         `json:"msg"`
-	Event     string         `json:"event"`
-	RunId     int64          `json:"run_id"`
-	JobName   string         `json:"job_name"`
-	RunStatus string         `json:"run_status"`
-	RunPid    int            `json:"run_pid"`
-	Data      map[string]any `json:"data"`
+	Time         time.Time      `json:"time"`
+	Level        string         `json:"level"`
+	Msg          string         `json:"msg"`
+	Event        string         `json:"event"`
+	RunId        int64          `json:"run_id"`
+	JobName      string         `json:"job_name"`
+	RunStatus    string         `json:"run_status"`
+	RunPid       int            `json:"run_pid"`
+	NotifyTarget string         `json:"notify_target"`
+	Data         map[string]any `json:"data"`
 }
 
 func MigrationsDir() string {
@@ -46,16 +50,20 @@ func MigrationsDir() string {
 }
 
 func CreateDb(ctx context.Context, t *testing.T) *data.Queries {
+	return data.New(CreateDbConn(ctx, t))
+}
+
+func CreateDbConn(ctx context.Context, t *testing.T) *sql.DB {
 	dir := t.TempDir()
 	dbPath := path.Join(dir, "troc.db")
 	t.Log("Creating database at " + dbPath)
 	f := os.DirFS(MigrationsDir())
-	return data.New(config.CreateOrUpdateDatabase(
+	return config.CreateOrUpdateDatabase(
 		f,
 		context.Background(),
 		dbPath,
 		".", // Not sure why this works. Passing the correct path doesn't work
-	))
+	)
 }
 
 func CreateSysLogFile(t *testing.T) (string, *slog.Logger) {
@@ -105,6 +113,22 @@ func AssertFileInDirectory(t *testing.T, dir string, path string) {
 	assert.Equal(t, dir, fileDir)
 }
 
+// Returns whether a process is running. Zombies are not running, as they
+// have exited and are only waiting to be reaped.
+func IsProcessRunning(t *testing.T, pid int) bool {
+	out, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// ps exits with 1 if there is no such process
+		return false
+	}
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	stat := strings.TrimSpace(string(out))
+	return stat != "" && !strings.HasPrefix(stat, "Z")
+}
+
 func AssertFileContents(t *testing.T, expected string, path string) {
 	file, err := os.ReadFile(path)
 	if err != nil {
@@ -197,6 +221,7 @@ func NewLogFromReader(reader io.Reader) (Log, error) {
 		delete(row.Data, "job_name")
 		delete(row.Data, "run_status")
 		delete(row.Data, "run_pid")
+		delete(row.Data, "notify_target")
 		log.Rows = append(log.Rows, row)
 	}
 	return log, nil
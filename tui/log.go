@@ -0,0 +1,103 @@
+package tui
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// The most lines of a log that are kept. Earlier lines are dropped, so a
+// long running job can be followed without using ever more memory.
+const maxLogLines = 10000
+
+// The lines of a run log, written by the goroutine following it and read when
+// the log is drawn.
+type logBuffer struct {
+	mu sync.Mutex
+	// The last line is the line being written, and is blank if the log ends
+	// with a newline
+	lines    []string
+	dropped  int
+	finished bool
+	err      error
+	changed  chan<- struct{}
+}
+
+func newLogBuffer(changed chan<- struct{}) *logBuffer {
+	return &logBuffer{
+		lines:   []string{""},
+		changed: changed,
+	}
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	parts := strings.Split(string(p), "\n")
+	b.lines[len(b.lines)-1] += parts[0]
+	b.lines = append(b.lines, parts[1:]...)
+	if len(b.lines) > maxLogLines {
+		drop := len(b.lines) - maxLogLines
+		b.lines = append([]string{}, b.lines[drop:]...)
+		b.dropped += drop
+	}
+	b.mu.Unlock()
+	b.notify()
+	return len(p), nil
+}
+
+// Marks the log as no longer being followed, because the run finished or the
+// log could not be read.
+func (b *logBuffer) finish(err error) {
+	b.mu.Lock()
+	b.finished = true
+	b.err = err
+	b.mu.Unlock()
+	b.notify()
+}
+
+func (b *logBuffer) notify() {
+	select {
+	case b.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Returns the lines of the log ready to be drawn, wrapped to the width.
+// Escape sequences and carriage returns are processed rather than drawn,
+// as they would move the cursor outside of the log.
+func (b *logBuffer) wrap(width int) []string {
+	b.mu.Lock()
+	lines := b.lines
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	lines = append([]string{}, lines...)
+	b.mu.Unlock()
+
+	var wrapped []string
+	for _, line := range lines {
+		line = strings.ReplaceAll(line, "\t", "    ")
+		line = text.StripEscape(text.ProcessCRLF(line))
+		line = strings.Map(func(r rune) rune {
+			if r < ' ' || r == 0x7f {
+				return -1
+			}
+			return r
+		}, line)
+		if line == "" {
+			wrapped = append(wrapped, "")
+			continue
+		}
+		wrapped = append(wrapped, strings.Split(text.WrapHard(line, width), "\n")...)
+	}
+	return wrapped
+}
+
+// Returns the state of the log: the number of dropped lines, whether it is
+// still being followed, and the error it failed with.
+func (b *logBuffer) state() (int, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped, b.finished, b.err
+}
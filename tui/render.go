@@ -0,0 +1,176 @@
+package tui
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/samcarswell/trochilus/core"
+)
+
+const (
+	jobsHelp = "↑↓ move  enter runs  r refresh  q quit"
+	runsHelp = "↑↓ move  enter log  esc jobs  K kill  T term  q quit"
+	logHelp  = "↑↓ pgup pgdn scroll  G follow  esc runs  K kill  T term  q quit"
+)
+
+// Returns the lines of the screen: a title, the body and a line for help,
+// messages and confirmations.
+func (a *app) render(width int, height int) []string {
+	a.bodyHeight = max(height-2, 0)
+	a.width = width
+	var title string
+	var body []string
+	var help string
+	switch a.screen {
+	case screenJobs:
+		title = "troc › jobs"
+		body = a.renderJobs()
+		help = jobsHelp
+	case screenRuns:
+		title = "troc › " + a.jobName
+		body = a.renderRuns()
+		help = runsHelp
+	case screenLog:
+		title = "troc › " + a.run.Job.Name + " › run " + strconv.FormatInt(a.run.Run.ID, 10) +
+			"  " + a.formatStatus(a.run.Run.Status) +
+			"  " + core.FormatDuration(a.run.Run.StartTime, a.run.Run.EndTime.Time)
+		body = a.renderLog()
+		help = logHelp
+	}
+
+	footer := help
+	switch {
+	case a.confirm != nil:
+		footer = text.FgYellow.Sprint(a.confirm.prompt + " [y/N]")
+	case a.message != "" && a.messageError:
+		footer = text.FgHiRed.Sprint(a.message)
+	case a.message != "":
+		footer = a.message
+	}
+
+	lines := []string{highlight(title, width)}
+	for i := range a.bodyHeight {
+		line := ""
+		if i < len(body) {
+			line = body[i]
+		}
+		lines = append(lines, text.Trim(line, width))
+	}
+	lines = append(lines, text.Trim(footer, width))
+	return lines[:min(len(lines), height)]
+}
+
+func (a *app) renderJobs() []string {
+	if len(a.jobs) == 0 {
+		return []string{"No jobs have been run yet."}
+	}
+	rows := [][]string{{"Job", "Status", "Last Run", "Duration", "Schedule"}}
+	for _, item := range a.jobs {
+		row := []string{item.job.Name, "", "", "", item.job.Schedule.String}
+		if item.lastRun != nil {
+			row[1] = a.formatStatus(item.lastRun.Status)
+			row[2] = core.FormatTime(item.lastRun.StartTime, a.conf.LocalTime)
+			row[3] = core.FormatDuration(item.lastRun.StartTime, item.lastRun.EndTime.Time)
+		}
+		rows = append(rows, row)
+	}
+	return a.renderList(rows, &a.jobList)
+}
+
+func (a *app) renderRuns() []string {
+	if len(a.runs) == 0 {
+		return []string{"No runs of " + a.jobName + "."}
+	}
+	rows := [][]string{{"ID", "Status", "Start Time", "Duration", "Host", "Exit Code", "Attempt"}}
+	for _, run := range a.runs {
+		rows = append(rows, []string{
+			strconv.FormatInt(run.ID, 10),
+			a.formatStatus(run.Status),
+			core.FormatTime(run.StartTime, a.conf.LocalTime),
+			core.FormatDuration(run.StartTime, run.EndTime.Time),
+			run.Host,
+			core.FormatInt(run.ExitCode),
+			strconv.FormatInt(run.Attempt, 10),
+		})
+	}
+	return a.renderList(rows, &a.runList)
+}
+
+// Lays out the header and rows of a list in columns, highlighting the row of
+// the cursor.
+func (a *app) renderList(rows [][]string, position *listPosition) []string {
+	lines := columns(rows)
+	body := []string{text.Bold.Sprint(lines[0])}
+	start, end := position.visible(a.bodyHeight-1, len(lines)-1)
+	for i := start; i < end; i++ {
+		line := "  " + lines[i+1]
+		if i == position.cursor {
+			line = highlight("› "+lines[i+1], a.width)
+		}
+		body = append(body, line)
+	}
+	return body
+}
+
+func (a *app) renderLog() []string {
+	if a.log == nil {
+		return nil
+	}
+	lines := a.log.wrap(max(a.width, 1))
+	dropped, finished, err := a.log.state()
+	if dropped > 0 {
+		lines = append([]string{text.Faint.Sprint("… " + strconv.Itoa(dropped) + " earlier lines are not shown")}, lines...)
+	}
+	if err != nil {
+		lines = append(lines, text.FgHiRed.Sprint(err.Error()))
+	} else if finished && a.run.Run.Status != string(core.RunStatusRunning) {
+		lines = append(lines, text.Faint.Sprint("— run "+a.run.Run.Status+" —"))
+	}
+	top := max(len(lines)-a.bodyHeight, 0)
+	if !a.following {
+		a.logTop = min(a.logTop, top)
+		top = a.logTop
+	}
+	return lines[top:min(top+a.bodyHeight, len(lines))]
+}
+
+// Returns a status with its emoji and colour, as set by display.emoji and
+// display.color.status.
+func (a *app) formatStatus(status string) string {
+	runStatus := core.RunStatus(status)
+	formatted := core.FormatStatus(runStatus, a.conf.Display.Emoji)
+	if a.conf.Display.Color.Status.IsColored(runStatus) {
+		return core.StatusColor(runStatus).Sprint(formatted)
+	}
+	return formatted
+}
+
+// Pads each cell to the width of the widest cell in its column.
+func columns(rows [][]string) []string {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], text.StringWidthWithoutEscSequences(cell))
+		}
+	}
+	var lines []string
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = text.Pad(cell, widths[i], ' ')
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(cells, "  "), " "))
+	}
+	return lines
+}
+
+// Draws a line in reverse video across the whole width. Styles within the
+// line reset every style, so reverse video is set again after each of them.
+func highlight(line string, width int) string {
+	line = text.Pad(text.Trim(line, width), width, ' ')
+	return reverseStyle + strings.ReplaceAll(line, resetStyle, resetStyle+reverseStyle) + resetStyle
+}
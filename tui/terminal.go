@@ -0,0 +1,175 @@
+package tui
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	enterAltScreen = "\x1b[?1049h"
+	exitAltScreen  = "\x1b[?1049l"
+	hideCursor     = "\x1b[?25l"
+	showCursor     = "\x1b[?25h"
+	cursorHome     = "\x1b[H"
+	clearLine      = "\x1b[K"
+	resetStyle     = "\x1b[0m"
+	reverseStyle   = "\x1b[7m"
+)
+
+type key string
+
+const (
+	keyUp        key = "up"
+	keyDown      key = "down"
+	keyLeft      key = "left"
+	keyRight     key = "right"
+	keyPageUp    key = "pgup"
+	keyPageDown  key = "pgdown"
+	keyHome      key = "home"
+	keyEnd       key = "end"
+	keyEnter     key = "enter"
+	keyEscape    key = "esc"
+	keyBackspace key = "backspace"
+	keyCtrlC     key = "ctrl+c"
+)
+
+var escapeKeys = map[string]key{
+	"[A":  keyUp,
+	"[B":  keyDown,
+	"[C":  keyRight,
+	"[D":  keyLeft,
+	"OA":  keyUp,
+	"OB":  keyDown,
+	"OC":  keyRight,
+	"OD":  keyLeft,
+	"[5~": keyPageUp,
+	"[6~": keyPageDown,
+	"[H":  keyHome,
+	"[F":  keyEnd,
+	"OH":  keyHome,
+	"OF":  keyEnd,
+	"[1~": keyHome,
+	"[4~": keyEnd,
+	"[7~": keyHome,
+	"[8~": keyEnd,
+}
+
+// A terminal in raw mode showing the alternate screen, so the UI is drawn over
+// neither the shell nor its scrollback.
+type terminal struct {
+	in    *os.File
+	out   *os.File
+	state unix.Termios
+}
+
+func openTerminal(in *os.File, out *os.File) (*terminal, error) {
+	state, err := unix.IoctlGetTermios(int(in.Fd()), ioctlGetTermios)
+	if err != nil {
+		return nil, errors.New("troc tui must be run in a terminal")
+	}
+	raw := *state
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(int(in.Fd()), ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+	t := &terminal{in: in, out: out, state: *state}
+	if _, err := io.WriteString(out, enterAltScreen+hideCursor); err != nil {
+		return nil, errors.Join(err, t.restore())
+	}
+	return t, nil
+}
+
+// Returns the terminal to the state it was in before it was opened.
+func (t *terminal) restore() error {
+	_, err := io.WriteString(t.out, resetStyle+showCursor+exitAltScreen)
+	return errors.Join(err, unix.IoctlSetTermios(int(t.in.Fd()), ioctlSetTermios, &t.state))
+}
+
+// Returns the width and height of the terminal.
+func (t *terminal) size() (int, int, error) {
+	ws, err := unix.IoctlGetWinsize(int(t.out.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
+
+// Draws a frame over the previous one. Each line is cleared after it is
+// written, so no part of a longer line of the previous frame is left behind.
+func (t *terminal) draw(lines []string) error {
+	var b strings.Builder
+	b.WriteString(cursorHome)
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString(resetStyle + clearLine)
+	}
+	_, err := io.WriteString(t.out, b.String())
+	return err
+}
+
+// Reads keys from the terminal until it can't be read.
+func (t *terminal) readKeys(keys chan<- []key) {
+	defer close(keys)
+	buf := make([]byte, 256)
+	for {
+		n, err := t.in.Read(buf)
+		if err != nil {
+			return
+		}
+		keys <- parseKeys(buf[:n])
+	}
+}
+
+// Splits input read from the terminal into keys. Escape sequences of the arrow,
+// page, home and end keys are recognised, and other escape sequences are
+// ignored. Any other key is its character.
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		switch {
+		case b[0] == 0x1b && len(b) > 1 && (b[1] == '[' || b[1] == 'O'):
+			// The sequence ends with its first letter or ~
+			end := 2
+			for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+				end++
+			}
+			end = min(end+1, len(b))
+			if k, ok := escapeKeys[string(b[1:end])]; ok {
+				keys = append(keys, k)
+			}
+			b = b[end:]
+		case b[0] == 0x1b:
+			keys = append(keys, keyEscape)
+			b = b[1:]
+		case b[0] == '\r' || b[0] == '\n':
+			keys = append(keys, keyEnter)
+			b = b[1:]
+		case b[0] == 0x7f || b[0] == 0x08:
+			keys = append(keys, keyBackspace)
+			b = b[1:]
+		case b[0] == 0x03:
+			keys = append(keys, keyCtrlC)
+			b = b[1:]
+		default:
+			r, size := utf8.DecodeRune(b)
+			if r >= ' ' {
+				keys = append(keys, key(string(r)))
+			}
+			b = b[size:]
+		}
+	}
+	return keys
+}
@@ -0,0 +1,468 @@
+package tui
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/watch"
+)
+
+// How often the jobs and runs being shown are read from the database.
+const refreshInterval = time.Second
+
+// The most runs of a job that are listed.
+const runLimit = 500
+
+type screen int
+
+const (
+	screenJobs screen = iota
+	screenRuns
+	screenLog
+)
+
+type jobItem struct {
+	job     data.Job
+	lastRun *data.Run
+}
+
+// The position of the cursor in a list, and the first item of the list that
+// is drawn.
+type listPosition struct {
+	cursor int
+	top    int
+}
+
+func (p *listPosition) move(delta int, length int) {
+	p.cursor = max(min(p.cursor+delta, length-1), 0)
+}
+
+// Returns the items of the list that fit in the height, scrolling it so the
+// cursor is drawn.
+func (p *listPosition) visible(height int, length int) (int, int) {
+	p.move(0, length)
+	if p.cursor < p.top {
+		p.top = p.cursor
+	}
+	if p.cursor >= p.top+height {
+		p.top = p.cursor - height + 1
+	}
+	p.top = max(min(p.top, length-height), 0)
+	return p.top, min(p.top+height, length)
+}
+
+type confirmation struct {
+	prompt string
+	action func() error
+}
+
+type app struct {
+	ctx     context.Context
+	logger  *slog.Logger
+	queries *data.Queries
+	conf    config.Config
+
+	screen  screen
+	jobs    []jobItem
+	jobList listPosition
+	jobName string
+	runs    []data.Run
+	runList listPosition
+	run     data.GetRunRow
+
+	log        *logBuffer
+	stopFollow context.CancelFunc
+	// The first line of the log that is drawn. While following, the end of
+	// the log is drawn instead.
+	logTop    int
+	following bool
+
+	confirm      *confirmation
+	message      string
+	messageError bool
+	// The size the screen was last drawn at
+	width      int
+	bodyHeight int
+	quit       bool
+	changed    chan struct{}
+}
+
+func newApp(ctx context.Context, logger *slog.Logger, queries *data.Queries, conf config.Config) *app {
+	return &app{
+		ctx:     ctx,
+		logger:  logger,
+		queries: queries,
+		conf:    conf,
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// Runs the terminal UI until it is quit or ctx is done. The terminal is
+// restored before returning.
+func Run(ctx context.Context, logger *slog.Logger, queries *data.Queries, conf config.Config) (err error) {
+	term, err := openTerminal(os.Stdin, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, term.restore())
+	}()
+	a := newApp(ctx, logger, queries, conf)
+	defer a.closeLog()
+
+	keys := make(chan []key)
+	go term.readKeys(keys)
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, syscall.SIGWINCH)
+	defer signal.Stop(resized)
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	a.refresh()
+	for {
+		width, height, err := term.size()
+		if err != nil {
+			return err
+		}
+		if err := term.draw(a.render(width, height)); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case pressed, ok := <-keys:
+			if !ok {
+				return errors.New("unable to read from terminal")
+			}
+			for _, k := range pressed {
+				a.handleKey(k)
+			}
+			if a.quit {
+				return nil
+			}
+		case <-resized:
+		case <-ticker.C:
+			a.refresh()
+		case <-a.changed:
+		}
+	}
+}
+
+func (a *app) handleKey(k key) {
+	if a.confirm != nil {
+		confirm := a.confirm
+		a.confirm = nil
+		if k != "y" && k != "Y" {
+			a.setMessage("Cancelled")
+			return
+		}
+		if err := confirm.action(); err != nil {
+			a.setError(err)
+		}
+		a.refresh()
+		return
+	}
+	switch k {
+	case "q", keyCtrlC:
+		a.quit = true
+		return
+	case "r":
+		a.refresh()
+		return
+	case "K", "T":
+		if run, jobName, ok := a.currentRun(); ok && k == "K" {
+			a.confirmKill(run, jobName)
+		} else if ok {
+			a.confirmTerm(run, jobName)
+		}
+		return
+	}
+	a.message = ""
+	switch a.screen {
+	case screenJobs:
+		if a.handleListKey(k, &a.jobList, len(a.jobs)) {
+			return
+		}
+		switch k {
+		case keyEnter, keyRight, "l":
+			a.openJob()
+		}
+	case screenRuns:
+		if a.handleListKey(k, &a.runList, len(a.runs)) {
+			return
+		}
+		switch k {
+		case keyEnter, keyRight, "l":
+			a.openLog()
+		case keyEscape, keyLeft, keyBackspace, "h":
+			a.screen = screenJobs
+			a.refresh()
+		}
+	case screenLog:
+		switch k {
+		case keyUp, "k":
+			a.scrollLog(-1)
+		case keyDown, "j":
+			a.scrollLog(1)
+		case keyPageUp:
+			a.scrollLog(-a.bodyHeight)
+		case keyPageDown:
+			a.scrollLog(a.bodyHeight)
+		case keyHome, "g":
+			a.following = false
+			a.logTop = 0
+		case keyEnd, "G", "f":
+			a.following = true
+		case keyEscape, keyLeft, keyBackspace, "h":
+			a.closeLog()
+			a.screen = screenRuns
+			a.refresh()
+		}
+	}
+}
+
+// Moves the cursor of a list, returning whether the key was a movement.
+func (a *app) handleListKey(k key, position *listPosition, length int) bool {
+	switch k {
+	case keyUp, "k":
+		position.move(-1, length)
+	case keyDown, "j":
+		position.move(1, length)
+	case keyPageUp:
+		position.move(-a.bodyHeight, length)
+	case keyPageDown:
+		position.move(a.bodyHeight, length)
+	case keyHome, "g":
+		position.move(-length, length)
+	case keyEnd, "G":
+		position.move(length, length)
+	default:
+		return false
+	}
+	return true
+}
+
+func (a *app) scrollLog(delta int) {
+	if a.following {
+		// Scrolling starts from the end of the log being drawn
+		a.logTop = max(len(a.log.wrap(max(a.width, 1)))-a.bodyHeight, 0)
+		a.following = false
+	}
+	a.logTop = max(a.logTop+delta, 0)
+}
+
+func (a *app) openJob() {
+	if len(a.jobs) == 0 {
+		return
+	}
+	name := a.jobs[a.jobList.cursor].job.Name
+	if name != a.jobName {
+		a.runList = listPosition{}
+	}
+	a.jobName = name
+	a.screen = screenRuns
+	a.refresh()
+}
+
+// Returns the run the screen is about, and the name of its job, for the
+// actions on it.
+func (a *app) currentRun() (data.Run, string, bool) {
+	switch a.screen {
+	case screenRuns:
+		run, ok := a.selectedRun()
+		return run, a.jobName, ok
+	case screenLog:
+		return a.run.Run, a.run.Job.Name, true
+	}
+	return data.Run{}, "", false
+}
+
+func (a *app) selectedRun() (data.Run, bool) {
+	if len(a.runs) == 0 {
+		return data.Run{}, false
+	}
+	return a.runs[a.runList.cursor], true
+}
+
+// Shows the log of the selected run, following it as troc run watch does.
+func (a *app) openLog() {
+	run, ok := a.selectedRun()
+	if !ok {
+		return
+	}
+	runRow, err := a.queries.GetRun(a.ctx, run.ID)
+	if err != nil {
+		a.setError(err)
+		return
+	}
+	a.closeLog()
+	a.run = runRow
+	a.screen = screenLog
+	a.logTop = 0
+	a.following = true
+	a.log = newLogBuffer(a.changed)
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.stopFollow = cancel
+	log := a.log
+	go func() {
+		err := watch.Follow(ctx, a.queries, runRow.Run, log)
+		if errors.Is(err, context.Canceled) {
+			err = nil
+		}
+		log.finish(err)
+	}()
+}
+
+func (a *app) closeLog() {
+	if a.stopFollow != nil {
+		a.stopFollow()
+		a.stopFollow = nil
+	}
+}
+
+// Asks to send SIGTERM to a run, as troc run kill does.
+func (a *app) confirmKill(run data.Run, jobName string) {
+	if run.Status != string(core.RunStatusRunning) {
+		a.setError(errors.New("run must be in a running state to kill it"))
+		return
+	}
+	if !run.Pid.Valid {
+		a.setError(errors.New("run does not have a PID associated with it"))
+		return
+	}
+	a.confirm = &confirmation{
+		prompt: fmt.Sprintf("Send SIGTERM to run %d of %s with PID %d?", run.ID, jobName, run.Pid.Int64),
+		action: func() error {
+			if err := core.SignalRun(run, syscall.SIGTERM); err != nil {
+				return errors.Join(err, errors.New("unable to kill run"))
+			}
+			core.LogRunSentSigterm(a.logger, run.ID, jobName, int(run.Pid.Int64))
+			a.setMessage("Sent SIGTERM to run " + strconv.FormatInt(run.ID, 10))
+			return nil
+		},
+	}
+}
+
+// Asks to mark a run as terminated, as troc run term does.
+func (a *app) confirmTerm(run data.Run, jobName string) {
+	if run.Status != string(core.RunStatusRunning) {
+		a.setError(errors.New("run must be in a running state to manually fail"))
+		return
+	}
+	a.confirm = &confirmation{
+		prompt: fmt.Sprintf("Mark run %d of %s as Terminated? Its process is not stopped.", run.ID, jobName),
+		action: func() error {
+			err := a.queries.EndRun(a.ctx, data.EndRunParams{
+				Status: string(core.RunStatusTerminated),
+				ID:     run.ID,
+			})
+			if err != nil {
+				return err
+			}
+			core.LogRunManuallyTerminated(a.logger, run.ID, jobName)
+			a.setMessage("Marked run " + strconv.FormatInt(run.ID, 10) + " as Terminated")
+			return nil
+		},
+	}
+}
+
+// Reads the jobs, runs or run being shown from the database.
+func (a *app) refresh() {
+	var err error
+	switch a.screen {
+	case screenJobs:
+		err = a.refreshJobs()
+	case screenRuns:
+		err = a.refreshRuns()
+	case screenLog:
+		var runRow data.GetRunRow
+		runRow, err = a.queries.GetRun(a.ctx, a.run.Run.ID)
+		if err == nil {
+			a.run = runRow
+		}
+	}
+	if err != nil {
+		a.setError(err)
+	}
+}
+
+func (a *app) refreshJobs() error {
+	jobRows, err := a.queries.GetJobs(a.ctx)
+	if err != nil {
+		return err
+	}
+	jobs := []jobItem{}
+	for _, jobRow := range jobRows {
+		item := jobItem{job: jobRow.Job}
+		lastRunRow, err := a.queries.GetLastRun(a.ctx, jobRow.Job.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil {
+			item.lastRun = &lastRunRow.Run
+		}
+		jobs = append(jobs, item)
+	}
+	// The cursor stays on the same job as jobs are added
+	if len(a.jobs) > 0 {
+		selected := a.jobs[a.jobList.cursor].job.ID
+		for i, item := range jobs {
+			if item.job.ID == selected {
+				a.jobList.cursor = i
+			}
+		}
+	}
+	a.jobs = jobs
+	a.jobList.move(0, len(a.jobs))
+	return nil
+}
+
+func (a *app) refreshRuns() error {
+	runRows, err := core.GetRuns(a.ctx, a.queries, core.RunFilter{
+		JobName: a.jobName,
+		Status:  "",
+		Host:    "",
+		Sort:    "start:desc",
+		Limit:   runLimit,
+	})
+	if err != nil {
+		return err
+	}
+	runs := []data.Run{}
+	for _, runRow := range runRows {
+		runs = append(runs, runRow.Run)
+	}
+	// The cursor stays on the same run as new runs start
+	if len(a.runs) > 0 {
+		selected := a.runs[a.runList.cursor].ID
+		for i, run := range runs {
+			if run.ID == selected {
+				a.runList.cursor = i
+			}
+		}
+	}
+	a.runs = runs
+	a.runList.move(0, len(a.runs))
+	return nil
+}
+
+func (a *app) setMessage(message string) {
+	a.message = message
+	a.messageError = false
+}
+
+func (a *app) setError(err error) {
+	a.message = err.Error()
+	a.messageError = true
+}
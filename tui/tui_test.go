@@ -0,0 +1,287 @@
+package tui
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/samcarswell/trochilus/config"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func createJob(ctx context.Context, t *testing.T, db *data.Queries, name string) int64 {
+	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  name,
+		RetryAttempts:         1,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return jobId
+}
+
+// Creates a run with a log. A status other than Running ends the run.
+func createRun(ctx context.Context, t *testing.T, db *data.Queries, jobId int64, status core.RunStatus, log string) int64 {
+	logFile := path.Join(t.TempDir(), "job.log")
+	if err := os.WriteFile(logFile, []byte(log), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	runId, err := db.StartRun(ctx, data.StartRunParams{
+		JobID:   jobId,
+		LogFile: logFile,
+		Host:    "host-1",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if status != core.RunStatusRunning {
+		err = db.EndRun(ctx, data.EndRunParams{
+			ID:     runId,
+			Status: string(status),
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	return runId
+}
+
+func newTestApp(ctx context.Context, db *data.Queries) *app {
+	a := newApp(ctx, slog.Default(), db, config.Config{
+		Display: config.DisplayConfig{Emoji: true},
+	})
+	a.refresh()
+	return a
+}
+
+// Returns the screen as plain text.
+func screenText(a *app) string {
+	return text.StripEscape(strings.Join(a.render(100, 12), "\n"))
+}
+
+func press(a *app, keys ...key) {
+	for _, k := range keys {
+		a.handleKey(k)
+	}
+}
+
+func Test_parseKeys(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected []key
+	}{
+		{"letters", "qK", []key{"q", "K"}},
+		{"arrows", "\x1b[A\x1b[B\x1bOC\x1b[D", []key{keyUp, keyDown, keyRight, keyLeft}},
+		{"pages", "\x1b[5~\x1b[6~", []key{keyPageUp, keyPageDown}},
+		{"home-end", "\x1b[H\x1b[4~", []key{keyHome, keyEnd}},
+		{"escape", "\x1b", []key{keyEscape}},
+		{"unknown-sequence", "\x1b[15~j", []key{"j"}},
+		{"enter", "\r", []key{keyEnter}},
+		{"backspace", "\x7f", []key{keyBackspace}},
+		{"ctrl-c", "\x03", []key{keyCtrlC}},
+		{"unicode", "é", []key{"é"}},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			assert.Equal(t, d.expected, parseKeys([]byte(d.input)))
+		})
+	}
+}
+
+func Test_listPosition(t *testing.T) {
+	p := listPosition{}
+
+	p.move(15, 20)
+	start, end := p.visible(5, 20)
+	assert.Equal(t, 15, p.cursor)
+	assert.Equal(t, []int{11, 16}, []int{start, end})
+
+	p.move(-3, 20)
+	start, end = p.visible(5, 20)
+	assert.Equal(t, []int{11, 16}, []int{start, end})
+
+	p.move(100, 20)
+	start, end = p.visible(5, 20)
+	assert.Equal(t, 19, p.cursor)
+	assert.Equal(t, []int{15, 20}, []int{start, end})
+
+	p.move(0, 3)
+	start, end = p.visible(5, 3)
+	assert.Equal(t, 2, p.cursor)
+	assert.Equal(t, []int{0, 3}, []int{start, end})
+}
+
+func Test_logBuffer(t *testing.T) {
+	b := newLogBuffer(make(chan struct{}, 1))
+
+	_, _ = b.Write([]byte("one\n\x1b[32mgreen\x1b[0m\n\tindented\nprogress 10%\rprogress 100%\n"))
+	_, _ = b.Write([]byte("a long line that wraps"))
+
+	assert.Equal(t, []string{
+		"one",
+		"green",
+		"    indented",
+		"progress 100%",
+		"a long line that wra",
+		"ps",
+	}, b.wrap(20))
+}
+
+func Test_logBufferDropsLines(t *testing.T) {
+	b := newLogBuffer(make(chan struct{}, 1))
+
+	_, _ = b.Write([]byte(strings.Repeat("line\n", maxLogLines+5)))
+
+	dropped, _, _ := b.state()
+	assert.Equal(t, 6, dropped)
+	assert.Equal(t, maxLogLines-1, len(b.wrap(80)))
+}
+
+func Test_jobsAndRuns(t *testing.T) {
+	ctx := context.Background()
+	db := test.CreateDb(ctx, t)
+	backupId := createJob(ctx, t, db, "backup")
+	createJob(ctx, t, db, "report")
+	createRun(ctx, t, db, backupId, core.RunStatusSucceeded, "")
+	createRun(ctx, t, db, backupId, core.RunStatusFailed, "")
+	a := newTestApp(ctx, db)
+
+	screen := screenText(a)
+	assert.Contains(t, screen, "troc › jobs")
+	assert.Contains(t, screen, "› backup  ❌ Failed")
+	assert.Contains(t, screen, "  report")
+	assert.Contains(t, screen, jobsHelp)
+
+	press(a, keyEnter)
+	screen = screenText(a)
+	assert.Equal(t, screenRuns, a.screen)
+	assert.Contains(t, screen, "troc › backup")
+	assert.Contains(t, screen, "› 2   ❌ Failed")
+	assert.Contains(t, screen, "  1   ✅ Succeeded")
+
+	press(a, keyDown)
+	run, ok := a.selectedRun()
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), run.ID)
+
+	// New runs don't move the cursor off the selected run
+	createRun(ctx, t, db, backupId, core.RunStatusRunning, "")
+	a.refresh()
+	run, _ = a.selectedRun()
+	assert.Equal(t, int64(1), run.ID)
+
+	press(a, keyEscape, keyDown, keyEnter)
+	assert.Equal(t, screenRuns, a.screen)
+	assert.Contains(t, screenText(a), "No runs of report.")
+
+	press(a, "q")
+	assert.True(t, a.quit)
+}
+
+func Test_log(t *testing.T) {
+	ctx := context.Background()
+	db := test.CreateDb(ctx, t)
+	jobId := createJob(ctx, t, db, "backup")
+	createRun(ctx, t, db, jobId, core.RunStatusSucceeded, "line 1\nline 2\nline 3\n")
+	a := newTestApp(ctx, db)
+	defer a.closeLog()
+
+	press(a, keyEnter, keyEnter)
+
+	assert.Equal(t, screenLog, a.screen)
+	assert.Eventually(t, func() bool {
+		return strings.Contains(screenText(a), "— run Succeeded —")
+	}, 5*time.Second, 10*time.Millisecond)
+	screen := screenText(a)
+	assert.Contains(t, screen, "troc › backup › run 1  ✅ Succeeded")
+	assert.Contains(t, screen, "line 1\nline 2\nline 3\n")
+
+	press(a, "k")
+	assert.False(t, a.following)
+	press(a, "G")
+	assert.True(t, a.following)
+
+	press(a, keyEscape)
+	assert.Equal(t, screenRuns, a.screen)
+	assert.Nil(t, a.stopFollow)
+}
+
+func Test_kill(t *testing.T) {
+	ctx := context.Background()
+	db := test.CreateDb(ctx, t)
+	jobId := createJob(ctx, t, db, "backup")
+	runId := createRun(ctx, t, db, jobId, core.RunStatusRunning, "")
+	process := exec.Command("sleep", "10")
+	if err := process.Start(); err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() {
+		_ = process.Process.Kill()
+	})
+	err := db.UpdateRunPid(ctx, data.UpdateRunPidParams{
+		ID:  runId,
+		Pid: sql.NullInt64{Int64: int64(process.Process.Pid), Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	a := newTestApp(ctx, db)
+	press(a, keyEnter)
+
+	press(a, "K")
+	assert.Contains(t, screenText(a), "Send SIGTERM to run 1 of backup with PID")
+	press(a, "n")
+	assert.Contains(t, screenText(a), "Cancelled")
+
+	press(a, "K", "y")
+
+	assert.Contains(t, screenText(a), "Sent SIGTERM to run 1")
+	err = process.Wait()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected process to be signalled, got %v", err)
+	}
+	assert.Equal(t, syscall.SIGTERM, exitErr.Sys().(syscall.WaitStatus).Signal())
+}
+
+func Test_term(t *testing.T) {
+	ctx := context.Background()
+	db := test.CreateDb(ctx, t)
+	jobId := createJob(ctx, t, db, "backup")
+	createRun(ctx, t, db, jobId, core.RunStatusSucceeded, "")
+	a := newTestApp(ctx, db)
+	press(a, keyEnter)
+
+	press(a, "T")
+	assert.Nil(t, a.confirm)
+	assert.Contains(t, screenText(a), "run must be in a running state to manually fail")
+
+	createRun(ctx, t, db, jobId, core.RunStatusRunning, "")
+	press(a, "r", "k", "T")
+	assert.Contains(t, screenText(a), "Mark run 2 of backup as Terminated?")
+	press(a, "y")
+
+	runRow, err := db.GetRun(ctx, 2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, string(core.RunStatusTerminated), runRow.Run.Status)
+	assert.Contains(t, screenText(a), "Marked run 2 as Terminated")
+	assert.Contains(t, screenText(a), "› 2   💥 Terminated")
+}
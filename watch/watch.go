@@ -0,0 +1,120 @@
+package watch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/samcarswell/trochilus/archive"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/output"
+)
+
+// How often a followed log is checked for new output.
+var interval = 500 * time.Millisecond
+
+// Writes the whole of a run log to w, decompressing it if it has been
+// archived. If the log no longer exists, the output stored in the database is
+// written.
+func Copy(ctx context.Context, queries *data.Queries, run data.Run, w io.Writer) error {
+	r, err := archive.Open(run.LogFile)
+	if errors.Is(err, os.ErrNotExist) {
+		stored, ok, storedErr := output.Read(ctx, queries, run.ID)
+		if storedErr != nil {
+			return errors.Join(storedErr, errors.New("unable to read stored output"))
+		}
+		if ok {
+			_, err := io.WriteString(w, stored)
+			return err
+		}
+	}
+	if err != nil {
+		return errors.Join(err, errors.New("unable to read log "+run.LogFile))
+	}
+	defer r.Close()
+	if _, err := io.Copy(w, r); err != nil {
+		return errors.Join(err, errors.New("unable to read log "+run.LogFile))
+	}
+	return nil
+}
+
+// Writes the log of a run to w as it is written, until the run has finished or
+// ctx is done. Logs that have been archived or deleted belong to finished runs,
+// so they are written whole with Copy.
+func Follow(ctx context.Context, queries *data.Queries, run data.Run, w io.Writer) error {
+	// Archived logs are only written once the run has finished
+	if archive.IsArchived(run.LogFile) {
+		return Copy(ctx, queries, run, w)
+	}
+	file, err := os.Open(run.LogFile)
+	if errors.Is(err, os.ErrNotExist) {
+		// The log may have been archived since the run was read, or deleted
+		// after its output was stored
+		runRow, err := queries.GetRun(ctx, run.ID)
+		if err != nil {
+			return err
+		}
+		return Copy(ctx, queries, runRow.Run, w)
+	}
+	if err != nil {
+		return errors.Join(err, errors.New("unable to read log "+run.LogFile))
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	finished := false
+	for {
+		line, err := reader.ReadString('\n')
+		if _, writeErr := io.WriteString(w, line); writeErr != nil {
+			return writeErr
+		}
+		if err == nil {
+			continue
+		}
+		if err != io.EOF {
+			return errors.Join(err, errors.New("unable to read log "+run.LogFile))
+		}
+		if finished {
+			return nil
+		}
+		// without this sleep you would hogg the CPU
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		truncated, err := isTruncated(file)
+		if err != nil {
+			return err
+		}
+		if truncated {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			reader.Reset(file)
+		}
+		// The log is read once more after the run has finished, for any output
+		// written since the last read
+		finished, err = queries.IsRunFinished(ctx, run.ID)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// https://medium.com/@arunprabhu.1/tailing-a-file-in-golang-72944204f22b
+func isTruncated(file *os.File) (bool, error) {
+	// current read position in a file
+	currentPos, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, err
+	}
+	// file stat to get the size
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	return currentPos > fileInfo.Size(), nil
+}
@@ -0,0 +1,155 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/samcarswell/trochilus/archive"
+	"github.com/samcarswell/trochilus/core"
+	"github.com/samcarswell/trochilus/data"
+	"github.com/samcarswell/trochilus/output"
+	"github.com/samcarswell/trochilus/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func createRun(ctx context.Context, t *testing.T, db *data.Queries, log string) data.Run {
+	jobId, err := db.CreateJob(ctx, data.CreateJobParams{
+		Name:                  "job",
+		RetryAttempts:         1,
+		RetryBackoff:          string(core.RetryBackoffFixed),
+		NotifyMode:            string(core.NotifyModeAlways),
+		NotifyReminderSeconds: 3600,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	logFile := path.Join(t.TempDir(), "job.log")
+	if err := os.WriteFile(logFile, []byte(log), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	runId, err := db.StartRun(ctx, data.StartRunParams{
+		JobID:   jobId,
+		LogFile: logFile,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	runRow, err := db.GetRun(ctx, runId)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return runRow.Run
+}
+
+func endRun(ctx context.Context, t *testing.T, db *data.Queries, runId int64) {
+	err := db.EndRun(ctx, data.EndRunParams{
+		ID:     runId,
+		Status: string(core.RunStatusSucceeded),
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func Test_Copy(t *testing.T) {
+	ctx := context.Background()
+	conn := test.CreateDbConn(ctx, t)
+	db := data.New(conn)
+	run := createRun(ctx, t, db, "line 1\nline 2\n")
+	endRun(ctx, t, db, run.ID)
+
+	var b bytes.Buffer
+	assert.NoError(t, Copy(ctx, db, run, &b))
+	assert.Equal(t, "line 1\nline 2\n", b.String())
+
+	archived := run
+	archivedLog, err := archive.Compress(run.LogFile, "", core.ArchiveFormatGzip)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	archived.LogFile = archivedLog
+	b.Reset()
+	assert.NoError(t, Copy(ctx, db, archived, &b))
+	assert.Equal(t, "line 1\nline 2\n", b.String())
+
+	if err := output.Store(ctx, conn, run.ID, run.LogFile, 1000); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Remove(run.LogFile); err != nil {
+		t.Fatal(err.Error())
+	}
+	b.Reset()
+	assert.NoError(t, Copy(ctx, db, run, &b))
+	assert.Equal(t, "line 1\nline 2\n", b.String())
+
+	if err := db.DeleteRunOutput(ctx, run.ID); err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.ErrorIs(t, Copy(ctx, db, run, &b), os.ErrNotExist)
+}
+
+func Test_Follow(t *testing.T) {
+	interval = 10 * time.Millisecond
+	ctx := context.Background()
+	db := test.CreateDb(ctx, t)
+	run := createRun(ctx, t, db, "line 1\npart")
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		f, err := os.OpenFile(run.LogFile, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			panic(err)
+		}
+		if _, err := f.WriteString("ial\nline 3"); err != nil {
+			panic(err)
+		}
+		f.Close()
+		err = db.EndRun(ctx, data.EndRunParams{
+			ID:     run.ID,
+			Status: string(core.RunStatusSucceeded),
+		})
+		if err != nil {
+			panic(err)
+		}
+	}()
+	var b bytes.Buffer
+
+	err := Follow(ctx, db, run, &b)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "line 1\npartial\nline 3", b.String())
+}
+
+func Test_FollowCancelled(t *testing.T) {
+	interval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	db := test.CreateDb(ctx, t)
+	run := createRun(ctx, t, db, "line 1\n")
+	time.AfterFunc(50*time.Millisecond, cancel)
+	var b bytes.Buffer
+
+	err := Follow(ctx, db, run, &b)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "line 1\n", b.String())
+}
+
+func Test_FollowDeletedLog(t *testing.T) {
+	ctx := context.Background()
+	db := test.CreateDb(ctx, t)
+	run := createRun(ctx, t, db, "line 1\n")
+	endRun(ctx, t, db, run.ID)
+	if err := os.Remove(run.LogFile); err != nil {
+		t.Fatal(err.Error())
+	}
+	var b bytes.Buffer
+
+	err := Follow(ctx, db, run, &b)
+
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Empty(t, b.String())
+}